@@ -8,70 +8,107 @@ import (
 
 // Max Min heap definition with
 // all fields are private (lowercase)
-type CoarseRWMaxMinHeap struct {
-	data     []*filter.FilterItem // underlying storage for the heap, using pointers so go can manage the memory
-	capacity int                  // fixed capacity parameter, set at construction
-	size     int                  // current number of items in the heap
+type CoarseRWMaxMinHeap[T any] struct {
+	data     []T               // underlying storage for the heap, index 0 is unused
+	less     func(a, b T) bool // ordering of the items, a < b
+	capacity int               // fixed capacity parameter, set at construction
+	size     int               // current number of items in the heap
 	rwLk     sync.RWMutex
+	onEvict  func(item T)
+	key      func(item T) string // nil for a heap without keys, "" for an item without one
+	index    map[string]int      // where each keyed item is in data
 }
 
 // public functions (Uppercase)
 
-// ctor
-func NewCoarseRWMaxMinHeap(capacity int) *CoarseRWMaxMinHeap {
-	return &CoarseRWMaxMinHeap{
-		data:     make([]*filter.FilterItem, 1, capacity+1), // initialize the array to the size param
+// ctor for the filter, items are ranked by score and unique by key
+func NewCoarseRWMaxMinHeap(capacity int) *CoarseRWMaxMinHeap[*filter.FilterItem] {
+	return NewKeyedCoarseRWMaxMinHeapFunc(capacity, ScoreLess, ItemKey)
+}
+
+// ctor for any item type ordered by less
+func NewCoarseRWMaxMinHeapFunc[T any](capacity int, less func(a, b T) bool) *CoarseRWMaxMinHeap[T] {
+	return &CoarseRWMaxMinHeap[T]{
+		data:     make([]T, 1, capacity+1), // initialize the array to the size param
+		less:     less,
 		capacity: capacity,
 		size:     0,
 	}
 }
 
-func (s *CoarseRWMaxMinHeap) Describe() {}
+// ctor for any item type ordered by less, with at most one item per key.
+// Items with an empty key can be there any number of times.
+func NewKeyedCoarseRWMaxMinHeapFunc[T any](capacity int, less func(a, b T) bool, key func(item T) string) *CoarseRWMaxMinHeap[T] {
+	s := NewCoarseRWMaxMinHeapFunc(capacity, less)
+	s.key = key
+	s.index = make(map[string]int)
+	return s
+}
 
-// insert item into heap, returns boolean representing success
-func (s *CoarseRWMaxMinHeap) Insert(item *filter.FilterItem) bool {
+func (s *CoarseRWMaxMinHeap[T]) Describe() {}
+
+// insert item into heap, returns whether it was stored, rejected, stored by
+// evicting the min or replaced the item with the same key
+func (s *CoarseRWMaxMinHeap[T]) Insert(item T) InsertResult[T] {
 	s.rwLk.Lock()
 	defer s.rwLk.Unlock()
 
-	if item == nil {
-		return false
-	}
+	return s.insert(item)
+}
 
-	if s.size >= s.capacity {
-		indexOfMin := s.getIndexOfMin()
-		curMin := s.data[indexOfMin]
-		if curMin.GetScore() < item.GetScore() { // curMin < item
-			// make room for inserting the bigger item
-			// pick out min, remove it, reorder heap
-			toRemove := s.getIndexOfMin()
-			// fmt.Println("removing: ", toRemove)
-			// fmt.Println("replacing with: ", s.size)
-			s.data[toRemove] = s.data[s.size]
-			s.data = s.data[:s.size]
-			s.size--
-			s.percolateDown(toRemove)
-		} else {
-			// don't insert this item
-			return true
+// insert all items under one lock, keeping the top capacity items of the
+// batch and the heap, returns boolean representing success
+func (s *CoarseRWMaxMinHeap[T]) InsertBatch(items []T) bool {
+	s.rwLk.Lock()
+	defer s.rwLk.Unlock()
+
+	if s.capacity < 1 {
+		for _, item := range items {
+			s.evict(item)
 		}
+		return true
+	}
+	if batchByInserts(s.size, len(items)) {
+		for _, item := range items {
+			if s.insert(item).Outcome == InsertRejected {
+				// a batch has no outcome per item, so the items that
+				// didn't make the cut get reported too
+				s.evict(item)
+			}
+		}
+		return true
 	}
 
-	s.data = append(s.data, item)
-	s.size++
-
-	s.percolateUp(s.size)
+	// throw everything together, cut it down to capacity and rebuild
+	s.data = append(s.data, s.replaceKeyed(items)...)
+	n := len(s.data) - 1
+	if n > s.capacity {
+		selectLargest(n, s.capacity, s.smaller, s.swap)
+		var zero T
+		for i := s.capacity + 1; i <= n; i++ {
+			// let go of the dropped items
+			s.evict(s.data[i])
+			s.data[i] = zero
+		}
+		n = s.capacity
+		s.data = s.data[:n+1]
+	}
+	s.size = n
+	heapify(n, s.smaller, s.swap)
+	s.reindex()
 
 	return true
 }
 
 // Get the top ranked item, returns item and boolean representing success
-func (s *CoarseRWMaxMinHeap) GetMax() *filter.FilterItem {
+func (s *CoarseRWMaxMinHeap[T]) GetMax() T {
 	s.rwLk.RLock()
 	defer s.rwLk.RUnlock()
 
 	if s.size == 0 {
 		// zero-element heap
-		return nil
+		var zero T
+		return zero
 	} else {
 		// one-element or more
 		return s.data[1]
@@ -79,7 +116,7 @@ func (s *CoarseRWMaxMinHeap) GetMax() *filter.FilterItem {
 }
 
 // Get the bottom ranked item, returns item and boolean representing success
-func (s *CoarseRWMaxMinHeap) GetMin() *filter.FilterItem {
+func (s *CoarseRWMaxMinHeap[T]) GetMin() T {
 	s.rwLk.RLock()
 	defer s.rwLk.RUnlock()
 
@@ -87,17 +124,19 @@ func (s *CoarseRWMaxMinHeap) GetMin() *filter.FilterItem {
 }
 
 // Remove the top ranked item, returns item and boolean representing success
-func (s *CoarseRWMaxMinHeap) RemoveMax() *filter.FilterItem {
+func (s *CoarseRWMaxMinHeap[T]) RemoveMax() T {
 	s.rwLk.Lock()
 	defer s.rwLk.Unlock()
 
 	// _, 1, 2, 3 -> len() = 4
 	if s.size == 0 {
 		// zero-element heap
-		return nil
+		var zero T
+		return zero
 	}
 
 	retItem := s.data[1]
+	s.forget(retItem)
 	if s.size == 1 {
 		// one-element heap
 		s.data = s.data[:1]
@@ -105,6 +144,7 @@ func (s *CoarseRWMaxMinHeap) RemoveMax() *filter.FilterItem {
 		// take item from end of the heap and add to top, then percolate down
 		s.data[1] = s.data[len(s.data)-1]
 		s.data = s.data[:len(s.data)-1]
+		s.place(1)
 
 		// percolate 'em lil nodes
 		s.percolateDown(1)
@@ -116,16 +156,17 @@ func (s *CoarseRWMaxMinHeap) RemoveMax() *filter.FilterItem {
 }
 
 // Remove the bottom ranked item, returns item and boolean representing success
-func (s *CoarseRWMaxMinHeap) RemoveMin() *filter.FilterItem {
+func (s *CoarseRWMaxMinHeap[T]) RemoveMin() T {
 	s.rwLk.Lock()
 	defer s.rwLk.Unlock()
 
 	if s.size == 0 {
 		// zero-element heap
-		return nil
+		var zero T
+		return zero
 	}
 
-	var retItem *filter.FilterItem
+	var retItem T
 	if s.size == 1 {
 		// one-element heap
 		retItem = s.data[1]
@@ -144,50 +185,314 @@ func (s *CoarseRWMaxMinHeap) RemoveMin() *filter.FilterItem {
 		// take item from end of the heap and add to top, then percolate down
 		s.data[toRemove] = s.data[len(s.data)-1]
 		s.data = s.data[:len(s.data)-1]
+		s.place(toRemove)
 
 		// percolate 'em lil nodes
 		s.percolateDown(toRemove)
 	}
 
+	s.forget(retItem)
 	s.size--
 
 	return retItem
 }
 
-func (s *CoarseRWMaxMinHeap) Clear() bool {
+// Remove up to k of the top ranked items under one lock, best first
+func (s *CoarseRWMaxMinHeap[T]) RemoveTopK(k int) []T {
+	if k <= 0 {
+		return nil
+	}
+	return s.removeTop(k, nil)
+}
+
+// Remove the items ranking no lower than threshold under one lock, up to
+// limit of them (0 for all), best first
+func (s *CoarseRWMaxMinHeap[T]) RemoveAbove(threshold T, limit int) []T {
+	return s.removeTop(limit, func(item T) bool { return !s.less(item, threshold) })
+}
+
+func (s *CoarseRWMaxMinHeap[T]) Clear() bool {
 	s.rwLk.Lock()
 	defer s.rwLk.Unlock()
-	s.data = make([]*filter.FilterItem, 1, s.capacity+1)
+	s.data = make([]T, 1, s.capacity+1)
 	s.size = 0
+	if s.key != nil {
+		s.index = make(map[string]int)
+	}
 	return true
 }
 
 // get the current size of the heap
-func (s *CoarseRWMaxMinHeap) Size() int {
+func (s *CoarseRWMaxMinHeap[T]) Size() int {
 	s.rwLk.RLock()
 	defer s.rwLk.RUnlock()
 	return s.size
 }
 
-func (s *CoarseRWMaxMinHeap) IsEmpty() bool {
+func (s *CoarseRWMaxMinHeap[T]) IsEmpty() bool {
 	s.rwLk.RLock()
 	defer s.rwLk.RUnlock()
 	return s.size == 0
 }
 
-func (s *CoarseRWMaxMinHeap) IsFull() bool {
+func (s *CoarseRWMaxMinHeap[T]) IsFull() bool {
 	s.rwLk.RLock()
 	defer s.rwLk.RUnlock()
 	return s.size == s.capacity
 }
 
+// f gets every item thrown away to make room, set it before sharing the heap
+func (s *CoarseRWMaxMinHeap[T]) OnEvict(f func(item T)) {
+	s.onEvict = f
+}
+
+// Replace the item under key with f of it and move it to its new rank,
+// returns the new item and false if there is no such item. f must keep
+// the key.
+func (s *CoarseRWMaxMinHeap[T]) Update(key string, f func(item T) T) (T, bool) {
+	s.rwLk.Lock()
+	defer s.rwLk.Unlock()
+
+	i, ok := s.index[key]
+	if !ok || key == "" {
+		var zero T
+		return zero, false
+	}
+	s.data[i] = f(s.data[i])
+	s.fix(i)
+	return s.data[s.index[key]], true
+}
+
+// Remove the item under key, returns it and false if there is no such item
+func (s *CoarseRWMaxMinHeap[T]) Delete(key string) (T, bool) {
+	s.rwLk.Lock()
+	defer s.rwLk.Unlock()
+
+	i, ok := s.index[key]
+	if !ok || key == "" {
+		var zero T
+		return zero, false
+	}
+	return s.removeAt(i), true
+}
+
+// Visit the items best first under the read lock, see WalkingMaxMinHeap
+func (s *CoarseRWMaxMinHeap[T]) Walk(max bool, skip func(item T) bool, visit func(item T) bool) {
+	s.rwLk.RLock()
+	defer s.rwLk.RUnlock()
+
+	var skipAt func(i int) bool
+	if skip != nil {
+		skipAt = func(i int) bool { return skip(s.data[i]) }
+	}
+	walkHeap(s.size, max, s.smaller, skipAt, func(i int) bool { return visit(s.data[i]) })
+}
+
+// Take out the items drop says to, looking at no more than scan slots from
+// position from on so the lock is never held for long. Returns what was
+// taken out and where to go on from, 0 once the end of the heap was reached.
+// Items that get moved around meanwhile may be skipped until the next pass.
+func (s *CoarseRWMaxMinHeap[T]) RemoveWhere(drop func(item T) bool, from, scan int) ([]T, int) {
+	s.rwLk.Lock()
+	defer s.rwLk.Unlock()
+
+	if from < 1 {
+		from = 1
+	}
+	var removed []T
+	for i := from; i < from+scan; i++ {
+		if i > s.size {
+			return removed, 0
+		}
+		for i <= s.size && drop(s.data[i]) {
+			// the last item takes its place and may move up past i, so it
+			// has to be one we keep, what moves down into i gets looked at
+			// again
+			for s.size > i && drop(s.data[s.size]) {
+				removed = append(removed, s.removeAt(s.size))
+			}
+			removed = append(removed, s.removeAt(i))
+		}
+	}
+	return removed, from + scan
+}
+
 ///////////////////////////////////
 // private helper functions
 ///////////////////////////////////
 
 // helpers expect the locks to already be held
 
-func (s *CoarseRWMaxMinHeap) getIndexOfMin() int {
+// insert with the write lock held
+func (s *CoarseRWMaxMinHeap[T]) insert(item T) InsertResult[T] {
+	if i, ok := s.lookup(item); ok {
+		s.replace(i, item)
+		return InsertResult[T]{Outcome: InsertUpdated}
+	}
+
+	res := InsertResult[T]{Outcome: InsertStored}
+	if s.size >= s.capacity {
+		if s.capacity < 1 {
+			// nothing to make room in
+			return InsertResult[T]{Outcome: InsertRejected}
+		}
+		indexOfMin := s.getIndexOfMin()
+		curMin := s.data[indexOfMin]
+		if s.less(curMin, item) { // curMin < item
+			// make room for inserting the bigger item
+			// pick out min, remove it, reorder heap
+			toRemove := s.getIndexOfMin()
+			// fmt.Println("removing: ", toRemove)
+			// fmt.Println("replacing with: ", s.size)
+			res = InsertResult[T]{Outcome: InsertEvicted, Evicted: curMin}
+			s.evict(curMin)
+			s.forget(curMin)
+			s.data[toRemove] = s.data[s.size]
+			s.data = s.data[:s.size]
+			s.size--
+			s.place(toRemove)
+			s.percolateDown(toRemove)
+		} else {
+			// don't insert this item
+			return InsertResult[T]{Outcome: InsertRejected}
+		}
+	}
+
+	s.data = append(s.data, item)
+	s.size++
+	s.place(s.size)
+
+	s.percolateUp(s.size)
+
+	return res
+}
+
+// take out the item at i, the last item fills the hole and finds its rank
+// from there
+func (s *CoarseRWMaxMinHeap[T]) removeAt(i int) T {
+	item := s.data[i]
+	s.forget(item)
+
+	last := len(s.data) - 1
+	s.data[i] = s.data[last]
+	var zero T
+	s.data[last] = zero
+	s.data = s.data[:last]
+	s.size--
+	if i < last {
+		s.place(i)
+		s.fix(i)
+	}
+	return item
+}
+
+// take the max out while take (nil for any) says it qualifies, up to limit
+// items (0 for all)
+func (s *CoarseRWMaxMinHeap[T]) removeTop(limit int, take func(item T) bool) []T {
+	s.rwLk.Lock()
+	defer s.rwLk.Unlock()
+
+	var items []T
+	for s.size > 0 && (limit == 0 || len(items) < limit) {
+		if take != nil && !take(s.data[1]) {
+			break
+		}
+		items = append(items, s.removeAt(1))
+	}
+	return items
+}
+
+// where the item with item's key is, false if item has no key or there is
+// no such item
+func (s *CoarseRWMaxMinHeap[T]) lookup(item T) (int, bool) {
+	if s.key == nil {
+		return 0, false
+	}
+	k := s.key(item)
+	if k == "" {
+		return 0, false
+	}
+	i, ok := s.index[k]
+	return i, ok
+}
+
+// put item in the place of the one at i, which has the same key
+func (s *CoarseRWMaxMinHeap[T]) replace(i int, item T) {
+	s.data[i] = item
+	s.fix(i)
+}
+
+// Keyed items already in the heap replace theirs in place, the rest is
+// returned to be merged in. Within items the last one with a key wins.
+func (s *CoarseRWMaxMinHeap[T]) replaceKeyed(items []T) []T {
+	if s.key == nil {
+		return items
+	}
+	rest := make([]T, 0, len(items))
+	seen := make(map[string]int) // key -> position in rest
+	for _, item := range items {
+		k := s.key(item)
+		if k == "" {
+			rest = append(rest, item)
+		} else if i, ok := s.index[k]; ok {
+			s.replace(i, item)
+		} else if j, ok := seen[k]; ok {
+			rest[j] = item
+		} else {
+			seen[k] = len(rest)
+			rest = append(rest, item)
+		}
+	}
+	return rest
+}
+
+// The item at i changed its rank, move it up or down to where it belongs.
+// Down first: whatever comes up from below is fine with i's ancestors, and
+// once the item has settled the only things it can be out of order with
+// are its new ancestors.
+func (s *CoarseRWMaxMinHeap[T]) fix(i int) {
+	s.percolateUp(s.percolateDown(i))
+}
+
+// record where the item at i is, nothing to do if i was the last slot and
+// went away with the removed item
+func (s *CoarseRWMaxMinHeap[T]) place(i int) {
+	if s.key == nil || i >= len(s.data) {
+		return
+	}
+	if k := s.key(s.data[i]); k != "" {
+		s.index[k] = i
+	}
+}
+
+// the item left the heap
+func (s *CoarseRWMaxMinHeap[T]) forget(item T) {
+	if s.key == nil {
+		return
+	}
+	if k := s.key(item); k != "" {
+		delete(s.index, k)
+	}
+}
+
+// rebuild the index after data was reordered wholesale
+func (s *CoarseRWMaxMinHeap[T]) reindex() {
+	if s.key == nil {
+		return
+	}
+	s.index = make(map[string]int, s.size)
+	for i := 1; i <= s.size; i++ {
+		s.place(i)
+	}
+}
+
+func (s *CoarseRWMaxMinHeap[T]) evict(item T) {
+	if s.onEvict != nil {
+		s.onEvict(item)
+	}
+}
+
+func (s *CoarseRWMaxMinHeap[T]) getIndexOfMin() int {
 	if s.size == 0 {
 		// zero-element heap
 		return 0
@@ -207,15 +512,16 @@ func (s *CoarseRWMaxMinHeap) getIndexOfMin() int {
 	}
 }
 
-func (s *CoarseRWMaxMinHeap) percolateDown(i int) {
+// returns where the item that was at i ended up
+func (s *CoarseRWMaxMinHeap[T]) percolateDown(i int) int {
 	if isMaxLevel(i) {
-		s.percolateDownMax(i)
+		return s.percolateDownMax(i)
 	} else {
-		s.percolateDownMin(i)
+		return s.percolateDownMin(i)
 	}
 }
 
-func (s *CoarseRWMaxMinHeap) percolateDownMax(i int) {
+func (s *CoarseRWMaxMinHeap[T]) percolateDownMax(i int) int {
 	if s.hasChildren(i) {
 		m := s.largestChildOrGrandchild(i) // m is zero if no children or grandchildren
 		if m > i*2+1 {
@@ -224,17 +530,22 @@ func (s *CoarseRWMaxMinHeap) percolateDownMax(i int) {
 				s.swap(m, i)
 				parentOfM := m / 2
 				if s.smaller(m, parentOfM) {
+					// our item stops at the parent, the parent's goes on
 					s.swap(m, parentOfM)
+					s.percolateDown(m)
+					return parentOfM
 				}
-				s.percolateDown(m)
+				return s.percolateDown(m)
 			}
 		} else if s.smaller(i, m) {
 			s.swap(m, i)
+			return m
 		}
 	}
+	return i
 }
 
-func (s *CoarseRWMaxMinHeap) percolateDownMin(i int) {
+func (s *CoarseRWMaxMinHeap[T]) percolateDownMin(i int) int {
 	if s.hasChildren(i) {
 		m := s.smallestChildOrGrandchild(i) // m is zero if no children or grandchildren
 		if m > i*2+1 {
@@ -243,17 +554,22 @@ func (s *CoarseRWMaxMinHeap) percolateDownMin(i int) {
 				s.swap(m, i)
 				parentOfM := m / 2
 				if s.smaller(parentOfM, m) {
+					// our item stops at the parent, the parent's goes on
 					s.swap(m, parentOfM)
+					s.percolateDown(m)
+					return parentOfM
 				}
-				s.percolateDown(m)
+				return s.percolateDown(m)
 			}
 		} else if s.smaller(m, i) {
 			s.swap(m, i)
+			return m
 		}
 	}
+	return i
 }
 
-func (s *CoarseRWMaxMinHeap) percolateUp(i int) {
+func (s *CoarseRWMaxMinHeap[T]) percolateUp(i int) {
 	// like bubbles!!!???!?!?!?!
 	if i == 1 {
 		return
@@ -281,7 +597,7 @@ func (s *CoarseRWMaxMinHeap) percolateUp(i int) {
 
 }
 
-func (s *CoarseRWMaxMinHeap) percolateUpMax(i int) {
+func (s *CoarseRWMaxMinHeap[T]) percolateUpMax(i int) {
 	gp := i / 4
 	if gp > 0 && s.smaller(gp, i) {
 		s.swap(i, gp)
@@ -289,7 +605,7 @@ func (s *CoarseRWMaxMinHeap) percolateUpMax(i int) {
 	}
 }
 
-func (s *CoarseRWMaxMinHeap) percolateUpMin(i int) {
+func (s *CoarseRWMaxMinHeap[T]) percolateUpMin(i int) {
 	gp := i / 4
 	if gp > 0 && s.smaller(i, gp) {
 		s.swap(i, gp)
@@ -297,7 +613,7 @@ func (s *CoarseRWMaxMinHeap) percolateUpMin(i int) {
 	}
 }
 
-func (s *CoarseRWMaxMinHeap) swap(i, j int) {
+func (s *CoarseRWMaxMinHeap[T]) swap(i, j int) {
 	if i >= len(s.data) || j >= len(s.data) {
 		return
 	}
@@ -305,13 +621,15 @@ func (s *CoarseRWMaxMinHeap) swap(i, j int) {
 	temp := s.data[i]
 	s.data[i] = s.data[j]
 	s.data[j] = temp
+	s.place(i)
+	s.place(j)
 }
 
-func (s *CoarseRWMaxMinHeap) hasChildren(i int) bool {
+func (s *CoarseRWMaxMinHeap[T]) hasChildren(i int) bool {
 	return 2*i < len(s.data)
 }
 
-func (s *CoarseRWMaxMinHeap) largestChildOrGrandchild(i int) int {
+func (s *CoarseRWMaxMinHeap[T]) largestChildOrGrandchild(i int) int {
 	minIndex := 0
 
 	// check the children
@@ -335,7 +653,7 @@ func (s *CoarseRWMaxMinHeap) largestChildOrGrandchild(i int) int {
 	return minIndex
 }
 
-func (s *CoarseRWMaxMinHeap) smallestChildOrGrandchild(i int) int {
+func (s *CoarseRWMaxMinHeap[T]) smallestChildOrGrandchild(i int) int {
 	minIndex := 0
 
 	// check the children
@@ -359,6 +677,6 @@ func (s *CoarseRWMaxMinHeap) smallestChildOrGrandchild(i int) int {
 	return minIndex
 }
 
-func (s *CoarseRWMaxMinHeap) smaller(a, b int) bool {
-	return s.data[a].GetScore() < s.data[b].GetScore()
+func (s *CoarseRWMaxMinHeap[T]) smaller(a, b int) bool {
+	return s.less(s.data[a], s.data[b])
 }
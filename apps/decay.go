@@ -0,0 +1,134 @@
+package apps
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/protobuf/proto"
+)
+
+/*
+ * Score Decay
+ *
+ * With a half-life set, an item's effective score is
+ * score * exp(-λ·age), λ = ln 2 / half-life. All items decay at the same
+ * rate, so their order never changes as time goes by, only a new item
+ * lands higher than an old one with the same score. The heap therefore
+ * orders by a fixed key, ln(score) + λ·(inserted - epoch), and nothing is
+ * ever re-heapified on a tick. The keys grow with the insertion time, so
+ * every rebaseAfter half-lives the epoch moves up to now and all keys are
+ * recomputed, before a float32 key loses too much precision.
+ *
+ * The heap holds private copies of the items: the score is the key, and
+ * effective_score holds the score the item came in with. Items leave the
+ * heap as new copies with the original score back and the effective score
+ * as of now.
+ */
+
+const rebaseAfter = 64 // half-lives between moves of the epoch
+
+type decay struct {
+	lambda float64       // per ms
+	lk     sync.RWMutex  // heap operations share it, moving the epoch takes it
+	epoch  atomic.Int64  // unix ms the keys are relative to
+	every  time.Duration // how often the epoch moves
+}
+
+// nil without a half-life, which is no decay
+func newDecay(halfLife time.Duration) *decay {
+	if halfLife <= 0 {
+		return nil
+	}
+	d := &decay{
+		lambda: math.Ln2 / float64(halfLife.Milliseconds()),
+		every:  rebaseAfter * halfLife,
+	}
+	d.epoch.Store(now())
+	return d
+}
+
+// The score the filter orders item by: the effective score of an item from
+// a filter with score decay, the score otherwise
+func EffectiveScore(item *filter.FilterItem) float32 {
+	if item.EffectiveScore != nil {
+		return *item.EffectiveScore
+	}
+	return item.GetScore()
+}
+
+// the heap's copy of item, stamped with its arrival if it has none. The key
+// is relative to the epoch, so hold the lock until the copy is in the heap.
+func (d *decay) in(item *filter.FilterItem, t int64) *filter.FilterItem {
+	in := proto.Clone(item).(*filter.FilterItem)
+	if in.InsertedUnixMs == 0 {
+		in.InsertedUnixMs = t
+	}
+	score := item.GetScore()
+	in.Score = d.key(score, in.InsertedUnixMs)
+	in.EffectiveScore = &score
+	return in
+}
+
+// a copy of the heap's item to hand out, decayed as of t
+func (d *decay) out(item *filter.FilterItem, t int64) *filter.FilterItem {
+	if item == nil {
+		return nil
+	}
+	out := proto.Clone(item).(*filter.FilterItem)
+	out.Score = item.GetEffectiveScore()
+	effective := d.effective(out.Score, out.InsertedUnixMs, t)
+	out.EffectiveScore = &effective
+	return out
+}
+
+func (d *decay) key(score float32, inserted int64) float32 {
+	// a score of 0 is -Inf, below everything else
+	return float32(math.Log(float64(score)) + d.lambda*float64(inserted-d.epoch.Load()))
+}
+
+func (d *decay) effective(score float32, inserted int64, t int64) float32 {
+	age := t - inserted
+	if age < 0 {
+		age = 0
+	}
+	return float32(float64(score) * math.Exp(-d.lambda*float64(age)))
+}
+
+// Move the epoch up to t if it's due, recomputing the key of every item in
+// heap. Takes the lock, so call it without.
+func (d *decay) rebase(heap FilterHeap, t int64) {
+	if time.Duration(t-d.epoch.Load())*time.Millisecond < d.every {
+		return
+	}
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	if time.Duration(t-d.epoch.Load())*time.Millisecond < d.every {
+		// someone else just did
+		return
+	}
+
+	// rekey copies of everything and rebuild the heap from them, a heap that
+	// can't be walked is emptied item by item instead
+	var items []*filter.FilterItem
+	if walker, ok := walkingHeap(heap); ok {
+		walker.Walk(true, nil, func(item *filter.FilterItem) bool {
+			items = append(items, item)
+			return true
+		})
+		heap.Clear()
+	} else {
+		for item := heap.RemoveMax(); item != nil; item = heap.RemoveMax() {
+			items = append(items, item)
+		}
+	}
+	d.epoch.Store(t)
+	for _, item := range items {
+		item.Score = d.key(item.GetEffectiveScore(), item.InsertedUnixMs)
+	}
+	if len(items) > 0 {
+		heap.InsertBatch(items)
+	}
+}
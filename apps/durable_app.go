@@ -0,0 +1,1071 @@
+package apps
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+/*
+ * Durable App
+ *
+ * Write-ahead log and snapshots under any filter app. Each mutation is logged
+ * right after it's applied as what it did to the filter: the items that went
+ * in, and the items that came out, including the ones it evicted. Replaying
+ * the log takes out exactly the items that left, whatever the clock, the TTLs
+ * or the heap's tie breaks would make of the mutations the second time. A
+ * mutation that can't be logged is undone as far as it can be, an upsert
+ * loses the version it replaced. Reads don't touch the log.
+ *
+ * Mutations of items without a key run at once, as the heap under them lets
+ * them, and may be logged in another order than they were applied: an item
+ * can be logged out before it's logged in, which replaying makes up for, see
+ * walState. The ones with a key, and clears, have the app to themselves, so a
+ * key's versions are logged in order. One writer takes the records as they
+ * come and writes everything queued meanwhile at once, with one fsync under
+ * the always policy, while the mutations wait for theirs.
+ *
+ * Leases handed out over the app are logged with the item they took out, and
+ * their end with the item if it comes back, so an item that is still out on
+ * a lease when the filter goes down is put back when it comes up again.
+ *
+ * Every SnapshotInterval the log is rotated, and the last snapshot plus the
+ * finished segments are compacted into a new snapshot in the background by
+ * replaying them into a map of the items, the live heap is never locked for
+ * it. On startup the newest snapshot and the segments after it are replayed
+ * the same way, what's left goes into the filter in one batch and is written
+ * out as a new snapshot.
+ */
+
+const (
+	walChange byte = iota + 1 // a filter.FilterChange
+	walClear
+)
+
+// op, payload length and crc of op and payload
+const walHeaderSize = 9
+
+// items per record of a snapshot
+const snapshotBatch = 1024
+
+// most records written with one fsync
+const walBatch = 256
+
+type DurableCDSFApp struct {
+	app   ConcurrentDataStreamFilter
+	dir   string
+	fsync string // always, interval or never
+	rec   recorder
+
+	mu     sync.RWMutex // mutations share it, the ones with a key hold it alone
+	closed bool         // under mu
+	queue  chan *walWrite
+	writer chan struct{} // closed when the writer is done
+
+	walLk sync.Mutex // the segment being written
+	wal   *os.File
+	seq   uint64 // number of the segment being written
+	dirty bool   // written since the last fsync
+
+	compactLk sync.Mutex // one snapshot at a time
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// Recover app from cfg.DataDir and log everything that happens to it from
+// now on
+func NewDurableCDSFApp(app ConcurrentDataStreamFilter, cfg Config) (*DurableCDSFApp, error) {
+	switch cfg.Fsync {
+	case "always", "interval", "never":
+	default:
+		return nil, fmt.Errorf("bad fsync policy %q", cfg.Fsync)
+	}
+	if err := os.MkdirAll(cfg.DataDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &DurableCDSFApp{
+		app:    app,
+		dir:    cfg.DataDir,
+		fsync:  cfg.Fsync,
+		done:   make(chan struct{}),
+		queue:  make(chan *walWrite, walBatch),
+		writer: make(chan struct{}),
+	}
+	s.rec.watch(app)
+
+	last, err := s.recover()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.openSegment(last + 1); err != nil {
+		return nil, err
+	}
+	go s.write()
+	log.Println("filter recovered", app.GetSize(), "items from", cfg.DataDir)
+
+	if cfg.Fsync == "interval" && cfg.FsyncInterval > 0 {
+		s.every(cfg.FsyncInterval, s.syncDirty)
+	}
+	if cfg.SnapshotInterval > 0 {
+		s.every(cfg.SnapshotInterval, func() {
+			if err := s.Snapshot(); err != nil {
+				log.Println("filter snapshot failed:", err)
+			}
+		})
+	}
+	return s, nil
+}
+
+func (s *DurableCDSFApp) Insert(item *filter.FilterItem) (FilterInsertResult, error) {
+	if item == nil {
+		// rejected anyway, nothing to log
+		return s.app.Insert(item)
+	}
+	var res FilterInsertResult
+	err := s.mutate(item.GetKey() != "", func(c *filter.FilterChange) (err error) {
+		res, err = s.app.Insert(item)
+		noteInsert(c, item, res, err)
+		return err
+	})
+	return res, err
+}
+
+func (s *DurableCDSFApp) InsertBatch(items []*filter.FilterItem) error {
+	if !validBatch(items) {
+		return s.app.InsertBatch(items)
+	}
+	return s.mutate(hasKeys(items), func(c *filter.FilterChange) error {
+		err := s.app.InsertBatch(items)
+		if err == nil {
+			c.Inserted = append(c.Inserted, items...)
+		}
+		return err
+	})
+}
+
+func (s *DurableCDSFApp) GetMax() (*filter.FilterItem, error) {
+	return s.app.GetMax()
+}
+
+func (s *DurableCDSFApp) GetMin() (*filter.FilterItem, error) {
+	return s.app.GetMin()
+}
+
+func (s *DurableCDSFApp) RemoveMax() (*filter.FilterItem, error) {
+	var item *filter.FilterItem
+	err := s.mutate(false, func(c *filter.FilterChange) (err error) {
+		item, err = s.app.RemoveMax()
+		noteRemoved(c, item)
+		return err
+	})
+	return item, err
+}
+
+func (s *DurableCDSFApp) RemoveMin() (*filter.FilterItem, error) {
+	var item *filter.FilterItem
+	err := s.mutate(false, func(c *filter.FilterChange) (err error) {
+		item, err = s.app.RemoveMin()
+		noteRemoved(c, item)
+		return err
+	})
+	return item, err
+}
+
+func (s *DurableCDSFApp) RemoveTopK(k int) ([]*filter.FilterItem, error) {
+	var items []*filter.FilterItem
+	err := s.mutate(false, func(c *filter.FilterChange) (err error) {
+		items, err = s.app.RemoveTopK(k)
+		noteRemoved(c, items...)
+		return err
+	})
+	return items, err
+}
+
+func (s *DurableCDSFApp) RemoveAbove(threshold float32, limit int) ([]*filter.FilterItem, error) {
+	var items []*filter.FilterItem
+	err := s.mutate(false, func(c *filter.FilterChange) (err error) {
+		items, err = s.app.RemoveAbove(threshold, limit)
+		noteRemoved(c, items...)
+		return err
+	})
+	return items, err
+}
+
+func (s *DurableCDSFApp) GetSize() int {
+	return s.app.GetSize()
+}
+
+func (s *DurableCDSFApp) IsFull() bool {
+	return s.app.IsFull()
+}
+
+// a clear takes everything out whatever is in, so it's logged ahead
+func (s *DurableCDSFApp) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errClosed()
+	}
+	if err := s.append(walClear, nil); err != nil {
+		return err
+	}
+	return s.app.Clear()
+}
+
+func (s *DurableCDSFApp) UpdateScore(key string, score float32) (*filter.FilterItem, error) {
+	keyed, err := keyedApp(s.app)
+	if err != nil {
+		return nil, err
+	}
+	var item *filter.FilterItem
+	err = s.mutate(true, func(c *filter.FilterChange) (err error) {
+		item, err = keyed.UpdateScore(key, score)
+		if item != nil {
+			// goes in over the old version
+			c.Inserted = append(c.Inserted, item)
+		}
+		return err
+	})
+	return item, err
+}
+
+func (s *DurableCDSFApp) Delete(key string) (*filter.FilterItem, error) {
+	keyed, err := keyedApp(s.app)
+	if err != nil {
+		return nil, err
+	}
+	var item *filter.FilterItem
+	err = s.mutate(true, func(c *filter.FilterChange) (err error) {
+		item, err = keyed.Delete(key)
+		noteRemoved(c, item)
+		return err
+	})
+	return item, err
+}
+
+// f gets everything the wrapped app evicts
+func (s *DurableCDSFApp) OnEvict(f func(item *filter.FilterItem, reason EvictReason)) {
+	s.rec.onEvict.Store(&f)
+}
+
+// Rotate the log and compact everything before the rotation into a new
+// snapshot, then drop the files it replaces
+func (s *DurableCDSFApp) Snapshot() error {
+	s.compactLk.Lock()
+	defer s.compactLk.Unlock()
+
+	upto, err := s.rotate()
+	if err != nil {
+		return err
+	}
+	snapSeq, segs, err := s.listFiles()
+	if err != nil {
+		return err
+	}
+	st, _, err := s.load(snapSeq, segs, upto)
+	if err != nil {
+		return err
+	}
+	if err := s.writeSnapshot(st, upto); err != nil {
+		return err
+	}
+	s.dropBefore(upto, snapSeq, segs)
+	return nil
+}
+
+// The live items as the log has them, after rotating it so everything logged
+// so far is in finished segments. The caller keeps mutations out meanwhile.
+func (s *DurableCDSFApp) items() ([]*filter.FilterItem, error) {
+	s.compactLk.Lock()
+	defer s.compactLk.Unlock()
+
+	upto, err := s.rotate()
+	if err != nil {
+		return nil, err
+	}
+	snapSeq, segs, err := s.listFiles()
+	if err != nil {
+		return nil, err
+	}
+	st, _, err := s.load(snapSeq, segs, upto)
+	if err != nil {
+		return nil, err
+	}
+	return st.live(now()), nil
+}
+
+func (s *DurableCDSFApp) Unwrap() ConcurrentDataStreamFilter {
+	return s.app
+}
+
+// stop the background work, flush the log and close the app
+func (s *DurableCDSFApp) Close() error {
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	close(s.queue)
+	<-s.writer
+
+	s.walLk.Lock()
+	defer s.walLk.Unlock()
+	if err := s.wal.Sync(); err != nil {
+		return err
+	}
+	if err := s.wal.Close(); err != nil {
+		return err
+	}
+	return s.app.Close()
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+func (s *DurableCDSFApp) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("wal-%016d", seq))
+}
+
+// snapshot-N holds the state after every segment before N
+func (s *DurableCDSFApp) snapshotPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("snapshot-%016d", seq))
+}
+
+// the newest snapshot (0 if none) and every segment, in order
+func (s *DurableCDSFApp) listFiles() (uint64, []uint64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var snapSeq uint64
+	var segs []uint64
+	for _, e := range entries {
+		var seq uint64
+		name := e.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			// a snapshot we didn't get to finish
+			os.Remove(filepath.Join(s.dir, name))
+		} else if _, err := fmt.Sscanf(name, "snapshot-%d", &seq); err == nil && seq > snapSeq {
+			snapSeq = seq
+		} else if _, err := fmt.Sscanf(name, "wal-%d", &seq); err == nil {
+			segs = append(segs, seq)
+		}
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i] < segs[j] })
+	return snapSeq, segs, nil
+}
+
+// Replay the newest snapshot and the segments after it into the app, and
+// start over from a snapshot of the result. Returns the last segment number
+// seen.
+func (s *DurableCDSFApp) recover() (uint64, error) {
+	snapSeq, segs, err := s.listFiles()
+	if err != nil {
+		return 0, err
+	}
+	if snapSeq == 0 && len(segs) == 0 {
+		return 0, nil
+	}
+
+	st, last, err := s.load(snapSeq, segs, math.MaxUint64)
+	if err != nil {
+		return 0, err
+	}
+	// the leases went down with the filter, the snapshot has them over so
+	// the lease IDs can start over too. An item logged out that never got
+	// logged in went down with the mutation that put it in.
+	st.endLeases()
+	st.owed = make(map[string][]*filter.FilterItem)
+	if err := s.writeSnapshot(st, last+1); err != nil {
+		return 0, err
+	}
+	s.dropBefore(last+1, snapSeq, segs)
+
+	if items := st.live(now()); len(items) > 0 {
+		if err := s.app.InsertBatch(items); err != nil {
+			return 0, err
+		}
+	}
+	return last, nil
+}
+
+// The filter the snapshot snapSeq (0 for none) and the segments from it up
+// to upto leave, and the number of the last of them
+func (s *DurableCDSFApp) load(snapSeq uint64, segs []uint64, upto uint64) (*walState, uint64, error) {
+	st := newWalState()
+	last := snapSeq
+	if snapSeq > 0 {
+		if err := replayFile(st, s.snapshotPath(snapSeq)); err != nil {
+			return nil, 0, err
+		}
+	}
+	for _, seq := range segs {
+		if seq < snapSeq || seq >= upto {
+			// compacted already, the snapshot just didn't get to drop it,
+			// or still being written
+			continue
+		}
+		if err := replayFile(st, s.segmentPath(seq)); err != nil {
+			return nil, 0, err
+		}
+		last = seq
+	}
+	return st, last, nil
+}
+
+// everything before upto lives in snapshot-upto now
+func (s *DurableCDSFApp) dropBefore(upto uint64, snapSeq uint64, segs []uint64) {
+	if snapSeq > 0 && snapSeq < upto {
+		os.Remove(s.snapshotPath(snapSeq))
+	}
+	for _, seq := range segs {
+		if seq < upto {
+			os.Remove(s.segmentPath(seq))
+		}
+	}
+}
+
+// Apply a mutation and log what it did, alone if it may put in an item with a
+// key. Caller doesn't hold mu.
+func (s *DurableCDSFApp) mutate(alone bool, apply func(c *filter.FilterChange) error) error {
+	if alone {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	} else {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+	if s.closed {
+		return errClosed()
+	}
+
+	c, err := s.rec.record(apply)
+	if unchanged(c) {
+		return err
+	}
+	if werr := s.append(walChange, c); werr != nil {
+		in, out := netChange(c)
+		changeApp(s.app, out, in)
+		return werr
+	}
+	return err
+}
+
+// RemoveMax for the lease id, see leaseLogger
+func (s *DurableCDSFApp) leaseMax(id string) (*filter.FilterItem, error) {
+	var item *filter.FilterItem
+	err := s.mutate(false, func(c *filter.FilterChange) (err error) {
+		item, err = leaseMax(s.app, id)
+		if item != nil {
+			noteRemoved(c, item)
+			c.Leased = id
+		}
+		return err
+	})
+	return item, err
+}
+
+func (s *DurableCDSFApp) returnLease(id string, item *filter.FilterItem) (FilterInsertResult, error) {
+	var res FilterInsertResult
+	err := s.mutate(item.GetKey() != "", func(c *filter.FilterChange) (err error) {
+		res, err = returnLease(s.app, id, item)
+		noteInsert(c, item, res, err)
+		c.Released = id
+		return err
+	})
+	return res, err
+}
+
+func (s *DurableCDSFApp) endLease(id string) error {
+	return s.mutate(false, func(c *filter.FilterChange) error {
+		c.Released = id
+		return endLease(s.app, id)
+	})
+}
+
+// Make a change a primary made and log it as it came, with whatever making it
+// evicted here on top, for a backup
+func (s *DurableCDSFApp) apply(primary *filter.FilterChange) error {
+	return s.mutate(hasKeys(primary.GetInserted()), func(c *filter.FilterChange) error {
+		proto.Merge(c, primary)
+		in, out := netChange(primary)
+		return changeApp(s.app, in, out)
+	})
+}
+
+// start the next segment, returns its number
+func (s *DurableCDSFApp) rotate() (uint64, error) {
+	s.walLk.Lock()
+	defer s.walLk.Unlock()
+	upto := s.seq + 1
+	return upto, s.openSegment(upto)
+}
+
+// switch writing to a new segment, caller holds walLk (or nobody else runs yet)
+func (s *DurableCDSFApp) openSegment(seq uint64) error {
+	f, err := os.OpenFile(s.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if s.wal != nil {
+		s.wal.Sync()
+		s.wal.Close()
+	}
+	s.wal, s.seq, s.dirty = f, seq, false
+	return syncDir(s.dir)
+}
+
+// a record on its way to the log, and where the writer says how it went
+type walWrite struct {
+	rec  []byte
+	done chan error
+}
+
+// log one mutation and wait for the writer to have it, caller holds mu
+func (s *DurableCDSFApp) append(op byte, msg proto.Message) error {
+	w := &walWrite{rec: encodeRecord(op, msg), done: make(chan error, 1)}
+	s.queue <- w
+	return <-w.done
+}
+
+// Write the records in the order they're queued until Close, the ones queued
+// while a write runs go in the next one together
+func (s *DurableCDSFApp) write() {
+	defer close(s.writer)
+	for w := range s.queue {
+		batch := []*walWrite{w}
+	more:
+		for len(batch) < walBatch {
+			select {
+			case w, ok := <-s.queue:
+				if !ok {
+					break more
+				}
+				batch = append(batch, w)
+			default:
+				break more
+			}
+		}
+
+		err := s.writeBatch(batch)
+		for _, w := range batch {
+			w.done <- err
+		}
+	}
+}
+
+func (s *DurableCDSFApp) writeBatch(batch []*walWrite) error {
+	var buf []byte
+	for _, w := range batch {
+		buf = append(buf, w.rec...)
+	}
+
+	s.walLk.Lock()
+	defer s.walLk.Unlock()
+	if _, err := s.wal.Write(buf); err != nil {
+		return status.Errorf(codes.Internal, "Filter failed to log mutation: %v", err)
+	}
+	if s.fsync == "always" {
+		if err := s.wal.Sync(); err != nil {
+			return status.Errorf(codes.Internal, "Filter failed to log mutation: %v", err)
+		}
+	} else {
+		s.dirty = true
+	}
+	return nil
+}
+
+func (s *DurableCDSFApp) syncDirty() {
+	s.walLk.Lock()
+	defer s.walLk.Unlock()
+	if s.dirty {
+		s.wal.Sync()
+		s.dirty = false
+	}
+}
+
+// run f every d until Close
+func (s *DurableCDSFApp) every(d time.Duration, f func()) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				f()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Write st into snapshot-seq: its live items in batches, then its leases and
+// the items it owes a removal
+func (s *DurableCDSFApp) writeSnapshot(st *walState, seq uint64) error {
+	tmp := s.snapshotPath(seq) + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	write := func(c *filter.FilterChange) error {
+		_, err := w.Write(encodeRecord(walChange, c))
+		return err
+	}
+	for items := st.live(now()); len(items) > 0; {
+		n := len(items)
+		if n > snapshotBatch {
+			n = snapshotBatch
+		}
+		if err := write(&filter.FilterChange{Inserted: items[:n]}); err != nil {
+			f.Close()
+			return err
+		}
+		items = items[n:]
+	}
+	for id, item := range st.leases {
+		if err := write(&filter.FilterChange{Removed: []*filter.FilterItem{item}, Leased: id}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if owed := st.owing(now()); len(owed) > 0 {
+		if err := write(&filter.FilterChange{Removed: owed}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, s.snapshotPath(seq)); err != nil {
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+// the argument of a mutation, nil for the ones without
+func marshalPayload(msg proto.Message) []byte {
+	if msg == nil {
+		return nil
+	}
+	payload, _ := proto.Marshal(msg)
+	return payload
+}
+
+func encodeRecord(op byte, msg proto.Message) []byte {
+	payload := marshalPayload(msg)
+	rec := make([]byte, walHeaderSize+len(payload))
+	rec[0] = op
+	binary.LittleEndian.PutUint32(rec[1:5], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(rec[5:9], recordCRC(op, payload))
+	copy(rec[walHeaderSize:], payload)
+	return rec
+}
+
+func recordCRC(op byte, payload []byte) uint32 {
+	return crc32.Update(crc32.ChecksumIEEE([]byte{op}), crc32.IEEETable, payload)
+}
+
+// Apply every record of a log segment or snapshot to st. A torn or corrupt
+// record can only be the tail of a write that never finished, so the file
+// ends there.
+func replayFile(st *walState, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, walHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				log.Println("filter log", path, "ends in a torn record, dropping it")
+			} else if err != io.EOF {
+				return err
+			}
+			return nil
+		}
+
+		op := header[0]
+		payload := make([]byte, binary.LittleEndian.Uint32(header[1:5]))
+		if _, err := io.ReadFull(r, payload); err != nil {
+			log.Println("filter log", path, "ends in a torn record, dropping it")
+			return nil
+		}
+		if recordCRC(op, payload) != binary.LittleEndian.Uint32(header[5:9]) {
+			log.Println("filter log", path, "ends in a corrupt record, dropping it")
+			return nil
+		}
+
+		if err := st.apply(op, payload); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+}
+
+// the change in a walChange record
+func decodeChange(payload []byte) (*filter.FilterChange, error) {
+	c := &filter.FilterChange{}
+	if err := proto.Unmarshal(payload, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// The filter as the log has it: the items by itemID, a list of the ones
+// alike for items without a key, and the items out on a lease by lease ID.
+// Items without a key can be logged out before they're logged in, the
+// removals owed wait in owed for the item to come in, the same way.
+type walState struct {
+	items  map[string][]*filter.FilterItem
+	owed   map[string][]*filter.FilterItem
+	leases map[string]*filter.FilterItem
+}
+
+func newWalState() *walState {
+	return &walState{
+		items:  make(map[string][]*filter.FilterItem),
+		owed:   make(map[string][]*filter.FilterItem),
+		leases: make(map[string]*filter.FilterItem),
+	}
+}
+
+func (st *walState) apply(op byte, payload []byte) error {
+	switch op {
+	case walChange:
+		c, err := decodeChange(payload)
+		if err != nil {
+			return err
+		}
+		in, out := netChange(c)
+		for _, item := range out {
+			st.remove(item)
+		}
+		for _, item := range in {
+			st.insert(item)
+		}
+		if c.GetLeased() != "" && len(c.GetRemoved()) > 0 {
+			st.leases[c.GetLeased()] = c.GetRemoved()[0]
+		}
+		if c.GetReleased() != "" {
+			delete(st.leases, c.GetReleased())
+		}
+	case walClear:
+		// the leases went with it
+		*st = *newWalState()
+	default:
+		return fmt.Errorf("unknown log record %d", op)
+	}
+	return nil
+}
+
+// an item with a key replaces the one it has
+func (st *walState) insert(item *filter.FilterItem) {
+	id := itemID(item)
+	if item.GetKey() != "" {
+		st.items[id] = []*filter.FilterItem{item}
+	} else if owed := st.owed[id]; len(owed) > 1 {
+		st.owed[id] = owed[:len(owed)-1]
+	} else if len(owed) == 1 {
+		delete(st.owed, id)
+	} else {
+		st.items[id] = append(st.items[id], item)
+	}
+}
+
+func (st *walState) remove(item *filter.FilterItem) {
+	id := itemID(item)
+	if alike := st.items[id]; len(alike) > 1 {
+		st.items[id] = alike[:len(alike)-1]
+	} else if len(alike) == 1 {
+		delete(st.items, id)
+	} else if item.GetKey() == "" {
+		st.owed[id] = append(st.owed[id], item)
+	}
+}
+
+// the items owed a removal that can still come in by t, an expired one
+// doesn't make it into the filter anyway
+func (st *walState) owing(t int64) []*filter.FilterItem {
+	var items []*filter.FilterItem
+	for _, owed := range st.owed {
+		for _, item := range owed {
+			if !hasExpired(item, t) {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// Put the leased items back, like a Nack would: unless their key went in
+// again meanwhile
+func (st *walState) endLeases() {
+	for id, item := range st.leases {
+		if _, superseded := st.items[itemID(item)]; item.GetKey() == "" || !superseded {
+			st.insert(item)
+		}
+		delete(st.leases, id)
+	}
+}
+
+// the items that haven't expired by t
+func (st *walState) live(t int64) []*filter.FilterItem {
+	var items []*filter.FilterItem
+	for _, alike := range st.items {
+		for _, item := range alike {
+			if !hasExpired(item, t) {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// Works out what a mutation does to the filter under it: the mutation notes
+// in the change what it put in and what it got back, and what the filter
+// evicts meanwhile is caught through its OnEvict, which is passed on to our
+// own
+type recorder struct {
+	lk      sync.Mutex
+	running int // mutations
+	evicted []*filter.FilterItem
+	expired []*filter.FilterItem
+	onEvict atomic.Pointer[func(item *filter.FilterItem, reason EvictReason)]
+}
+
+// catch what app evicts, if it says
+func (r *recorder) watch(app ConcurrentDataStreamFilter) {
+	if inner, ok := As[EvictingFilter](app); ok {
+		inner.OnEvict(r.report)
+	}
+}
+
+func (r *recorder) report(item *filter.FilterItem, reason EvictReason) {
+	r.lk.Lock()
+	if r.running > 0 {
+		if reason == EvictExpired {
+			r.expired = append(r.expired, item)
+		} else {
+			r.evicted = append(r.evicted, item)
+		}
+	}
+	r.lk.Unlock()
+
+	if f := r.onEvict.Load(); f != nil {
+		(*f)(item, reason)
+	}
+}
+
+// Run a mutation and return the change it made. Inserted items that had
+// expired already were turned down, whatever else expires meanwhile is
+// nobody's doing and stays out of the change. With mutations running at once
+// the first of them to finish takes what they evicted so far, so an item
+// comes out in one change, if not the one that evicted it.
+func (r *recorder) record(apply func(c *filter.FilterChange) error) (*filter.FilterChange, error) {
+	r.lk.Lock()
+	r.running++
+	r.lk.Unlock()
+
+	c := &filter.FilterChange{}
+	err := apply(c)
+
+	r.lk.Lock()
+	evicted, expired := r.evicted, r.expired
+	r.running--
+	r.evicted, r.expired = nil, nil
+	r.lk.Unlock()
+
+	if len(expired) > 0 {
+		c.Inserted = without(c.Inserted, expired)
+	}
+	c.Removed = append(c.Removed, evicted...)
+	return c, err
+}
+
+// note item in c if the insert that returned res and err took it
+func noteInsert(c *filter.FilterChange, item *filter.FilterItem, res FilterInsertResult, err error) {
+	if err == nil && res.Outcome != InsertRejected {
+		c.Inserted = append(c.Inserted, item)
+	}
+}
+
+// note the items that came out of the filter in c
+func noteRemoved(c *filter.FilterChange, items ...*filter.FilterItem) {
+	for _, item := range items {
+		if item != nil {
+			c.Removed = append(c.Removed, item)
+		}
+	}
+}
+
+func unchanged(c *filter.FilterChange) bool {
+	return len(c.GetInserted()) == 0 && len(c.GetRemoved()) == 0 &&
+		c.GetLeased() == "" && c.GetReleased() == ""
+}
+
+// What c comes to for the filter before it: the items to take out of it,
+// then the ones to put in. The items went in before the ones that came out,
+// so an item that came out again is not put in, and one with a key takes its
+// key out of the filter before too, which it went in over.
+func netChange(c *filter.FilterChange) (in, out []*filter.FilterItem) {
+	in = make([]*filter.FilterItem, 0, len(c.GetInserted()))
+	keyAt := make(map[string]int) // where in in the item under a key is
+	for _, item := range c.GetInserted() {
+		if key := item.GetKey(); key != "" {
+			if i, ok := keyAt[key]; ok {
+				in[i] = item
+				continue
+			}
+			keyAt[key] = len(in)
+		}
+		in = append(in, item)
+	}
+
+	gone := make(map[int]bool)
+	alike := make(map[string][]int) // where in in the items without a key are
+	for i, item := range in {
+		if item.GetKey() == "" {
+			id := itemID(item)
+			alike[id] = append(alike[id], i)
+		}
+	}
+	for _, item := range c.GetRemoved() {
+		if key := item.GetKey(); key != "" {
+			if i, ok := keyAt[key]; ok {
+				if !sameVersion(in[i], item) {
+					// an older version, the new one went in over it
+					continue
+				}
+				gone[i] = true
+				delete(keyAt, key)
+			}
+			out = append(out, item)
+			continue
+		}
+		id := itemID(item)
+		if at := alike[id]; len(at) > 0 {
+			gone[at[len(at)-1]] = true
+			alike[id] = at[:len(at)-1]
+			continue
+		}
+		out = append(out, item)
+	}
+
+	kept := in[:0]
+	for i, item := range in {
+		if !gone[i] {
+			kept = append(kept, item)
+		}
+	}
+	return kept, out
+}
+
+// Take the items out of app, then put the items in, as netChange has them
+func changeApp(app ConcurrentDataStreamFilter, in, out []*filter.FilterItem) error {
+	if len(out) > 0 {
+		remover, ok := As[RemovingFilter](app)
+		if !ok {
+			return errNoRemove()
+		}
+		if _, err := remover.Remove(out); err != nil {
+			return err
+		}
+	}
+	if len(in) > 0 {
+		return app.InsertBatch(in)
+	}
+	return nil
+}
+
+// items less one each of drop, the same but maybe for the effective score
+func without(items, drop []*filter.FilterItem) []*filter.FilterItem {
+	n := make(map[string]int, len(drop))
+	for _, item := range drop {
+		n[itemBytes(item)]++
+	}
+	kept := items[:0:0]
+	for _, item := range items {
+		if b := itemBytes(item); n[b] > 0 {
+			n[b]--
+		} else {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// How the log tells items apart: by key, or by all of the item but its
+// effective score, which a filter with score decay hands out as of now
+func itemID(item *filter.FilterItem) string {
+	if key := item.GetKey(); key != "" {
+		return "k" + key
+	}
+	return "i" + itemBytes(item)
+}
+
+// the two items under the same key are the same version of it
+func sameVersion(a, b *filter.FilterItem) bool {
+	return itemBytes(a) == itemBytes(b)
+}
+
+// the item but its effective score, encoded
+func itemBytes(item *filter.FilterItem) string {
+	if item.EffectiveScore != nil {
+		item = proto.Clone(item).(*filter.FilterItem)
+		item.EffectiveScore = nil
+	}
+	b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(item)
+	return string(b)
+}
+
+// make renames and new files in dir survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func errClosed() error {
+	return status.Errorf(codes.Unavailable, "Filter is closed")
+}
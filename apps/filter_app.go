@@ -2,10 +2,14 @@ package apps
 
 import (
 	"log"
+	"runtime"
+	"sync/atomic"
+	"time"
 
 	"github.com/Jfroel/cdsf-microservice/proto/filter"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 /*
@@ -17,7 +21,9 @@ import (
  */
 
 type ConcurrentDataStreamFilter interface {
-	Insert(item *filter.FilterItem) error
+	Insert(item *filter.FilterItem) (FilterInsertResult, error)
+
+	InsertBatch(items []*filter.FilterItem) error
 
 	GetMax() (*filter.FilterItem, error)
 
@@ -27,43 +33,325 @@ type ConcurrentDataStreamFilter interface {
 
 	RemoveMin() (*filter.FilterItem, error)
 
+	// Take out up to k of the best items at once, best first
+	RemoveTopK(k int) ([]*filter.FilterItem, error)
+
+	// Take out the items scoring at least threshold at once, up to limit of
+	// them (0 for all), best first
+	RemoveAbove(threshold float32, limit int) ([]*filter.FilterItem, error)
+
 	GetSize() int
 
+	IsFull() bool
+
 	Clear() error
+
+	// Stop the filter's background work, it can't be used after
+	Close() error
+}
+
+// Filters that can also hand out items on a lease
+type LeasingFilter interface {
+	ConcurrentDataStreamFilter
+
+	LeaseMax(timeout time.Duration) (*filter.FilterItem, string, error)
+
+	Ack(id string) error
+
+	Nack(id string) error
+
+	// f runs whenever a leased item goes back into the filter
+	OnReturn(f func())
+}
+
+// Why the filter threw an item away
+type EvictReason int
+
+const (
+	EvictCapacity EvictReason = iota // pushed out of a full filter by better items
+	EvictLease                       // trimmed so the outstanding leases fit the capacity
+	EvictExpired                     // outlived its TTL
+	EvictRefused                     // turned down by the upstream a forwarder sends to
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictLease:
+		return "lease"
+	case EvictExpired:
+		return "expired"
+	case EvictRefused:
+		return "refused"
+	default:
+		return "capacity"
+	}
+}
+
+// Filters that report the items they throw away
+type EvictingFilter interface {
+	ConcurrentDataStreamFilter
+
+	// f gets every evicted item, it runs on the insert path and must not
+	// block
+	OnEvict(f func(item *filter.FilterItem, reason EvictReason))
+}
+
+// Filters that hold at most one item per key, see KeyedMaxMinHeap
+type KeyedFilter interface {
+	ConcurrentDataStreamFilter
+
+	// Move the item under key to score, returns the moved item
+	UpdateScore(key string, score float32) (*filter.FilterItem, error)
+
+	// Take the item under key out of the filter and return it
+	Delete(key string) (*filter.FilterItem, error)
+}
+
+// Filters whose items can be looked at in order without taking them out
+type QueryingFilter interface {
+	ConcurrentDataStreamFilter
+
+	// The k best items, best first
+	TopK(k int) ([]*filter.FilterItem, error)
+
+	// The k worst items, worst first
+	BottomK(k int) ([]*filter.FilterItem, error)
+
+	// Up to limit items scoring lo to hi, both included, best first. A
+	// limit of 0 is no limit.
+	Range(lo, hi float32, limit int) ([]*filter.FilterItem, error)
+}
+
+// Filters that can take given items out wherever they are, to make the
+// changes another filter logged or replicated
+type RemovingFilter interface {
+	ConcurrentDataStreamFilter
+
+	// Take out one item like each of items: the one under its key, or for
+	// an item without a key one that is the same but maybe for the
+	// effective score. Returns the items found.
+	Remove(items []*filter.FilterItem) ([]*filter.FilterItem, error)
+}
+
+// decorators around another app
+type wrapper interface {
+	Unwrap() ConcurrentDataStreamFilter
+}
+
+// Find the first app of type T in a stack of decorators, each one exposing
+// the app it wraps through Unwrap
+func As[T any](app ConcurrentDataStreamFilter) (T, bool) {
+	for app != nil {
+		if t, ok := app.(T); ok {
+			return t, true
+		}
+		u, ok := app.(wrapper)
+		if !ok {
+			break
+		}
+		app = u.Unwrap()
+	}
+	var zero T
+	return zero, false
 }
 
 // The app is just a wrapper around any MaxMinHeap implementation
 type CDSFApp struct {
-	heap MaxMinHeap
+	heap    FilterHeap
+	onEvict atomic.Pointer[func(item *filter.FilterItem, reason EvictReason)]
+	decay   *decay // nil unless scores decay
+}
+
+// Everything needed to build a filter, filled in from the flags in cmd/main.go
+type Config struct {
+	FilterType string // coarseRW, soa, subtree, skiplist, multiqueue or sharded
+	Capacity   int    // max number of items in the whole filter
+	ShardType  string // heap used by each shard of the sharded filter
+	Shards     int    // number of shards of the sharded filter
+	Relaxation int    // the multiqueue keeps Relaxation * GOMAXPROCS heaps
+
+	FlatCombining bool // batch updates through a flat combining decorator
+
+	DataDir          string        // WAL and snapshots go here, empty keeps the filter in memory only
+	Fsync            string        // always, interval or never
+	FsyncInterval    time.Duration // how often the interval policy fsyncs the WAL
+	SnapshotInterval time.Duration // how often the WAL is compacted into a snapshot, 0 never
+
+	Role string // standalone, primary or backup
+
+	DefaultTTL   time.Duration // TTL of items that come without one, 0 keeps them forever
+	ReapInterval time.Duration // how often expired items are swept out of the heaps, 0 never
+
+	HalfLife time.Duration // scores halve every HalfLife, 0 never, the sharded filter won't start with one
+
+	Keyed bool // clients send keyed items, only coarseRW and subtree index keys
 }
 
+// the filter types whose heap keeps a key index
+var keyedTypes = map[string]bool{"coarseRW": true, "subtree": true}
+
 // Change the Heap constructor to change the used implementaion
-func NewCDSFApp(lkType string, capacity int) *CDSFApp {
-	var heap MaxMinHeap
+func NewCDSFApp(cfg Config) *CDSFApp {
+	heap := NewMaxMinHeap(cfg.FilterType, cfg)
+	log.Println("filter max capacity: ", cfg.Capacity)
+	s := &CDSFApp{
+		heap:  heap,
+		decay: newDecay(cfg.HalfLife),
+	}
+	if s.decay != nil {
+		log.Println("score half-life: ", cfg.HalfLife)
+	}
+	heap.OnEvict(s.evicted)
+	if ttl, ok := heap.(*TTLMaxMinHeap); ok {
+		ttl.OnExpire(s.expired)
+	}
+	return s
+}
+
+// Build the filter named by cfg.FilterType, the sharded filter has its own
+// app, anything else is a CDSFApp around a single MaxMinHeap. Either way it
+// supports leases, it is recovered from and logged to cfg.DataDir if set, and
+// it replicates as cfg.Role says.
+func NewFilterApp(cfg Config) ConcurrentDataStreamFilter {
+	if cfg.Keyed && !keyedTypes[cfg.FilterType] {
+		log.Fatalf("filter type %s doesn't index keys, keyed items need coarseRW or subtree", cfg.FilterType)
+	}
+	if cfg.HalfLife > 0 && cfg.FilterType == "sharded" {
+		log.Fatalf("the sharded filter doesn't decay scores, a half-life needs another filter type")
+	}
+	var app ConcurrentDataStreamFilter
+	if cfg.FilterType == "sharded" {
+		app = NewShardedCDSFApp(cfg)
+	} else {
+		app = NewCDSFApp(cfg)
+	}
+	if cfg.DataDir != "" {
+		durable, err := NewDurableCDSFApp(app, cfg)
+		if err != nil {
+			log.Fatalf("failed to recover the filter from %s: %v", cfg.DataDir, err)
+		}
+		app = durable
+	}
+	switch cfg.Role {
+	case "", "standalone":
+	case "primary", "backup":
+		if cfg.DataDir == "" && !walkable(app) {
+			// a backup may be promoted and followed too
+			log.Fatalf("filter type %s can't be walked, replicating it needs a data dir", cfg.FilterType)
+		}
+		log.Println("replication role:", cfg.Role)
+		app = NewReplicatedCDSFApp(app, cfg.Role == "primary")
+	default:
+		panic("bad replication role to CDSF constructor")
+	}
+	ttl := NewTTLCDSFApp(app, cfg.DefaultTTL)
+	// the arrival has to be logged, or a replay would make items young again
+	ttl.stampArrival = cfg.HalfLife > 0
+	return NewLeasingCDSFApp(ttl, cfg.Capacity)
+}
+
+// Build a filter MaxMinHeap from its locking policy name, wrapped in a flat
+// combining decorator if cfg.FlatCombining is set, and in the expiry
+// decorator, reaping every cfg.ReapInterval
+func NewMaxMinHeap(lkType string, cfg Config) FilterHeap {
+	heap := newBaseMaxMinHeap(lkType, cfg)
+	if cfg.FlatCombining {
+		log.Println("flat combining: on")
+		heap = NewFlatCombiningMaxMinHeap(heap)
+	}
+	ttl := NewTTLMaxMinHeap(heap)
+	if cfg.ReapInterval > 0 {
+		go ttl.ReapEvery(cfg.ReapInterval)
+	}
+	return ttl
+}
+
+func newBaseMaxMinHeap(lkType string, cfg Config) FilterHeap {
 	switch lkType {
 	case "coarseRW":
 		log.Println("locking policy: coarse grain RW")
-		heap = NewCoarseRWMaxMinHeap(capacity)
+		return NewCoarseRWMaxMinHeap(cfg.Capacity)
 	case "subtree":
 		log.Println("locking policy: subtree")
-		panic("subtree locking not yet implemented")
+		return NewSubtreeMaxMinHeap(cfg.Capacity)
+	case "soa":
+		log.Println("locking policy: coarse grain RW, struct-of-arrays layout")
+		return NewSoAMaxMinHeap(cfg.Capacity)
+	case "skiplist":
+		log.Println("locking policy: lazy skip list")
+		return NewSkipListMaxMinHeap(cfg.Capacity)
+	case "multiqueue":
+		queues := cfg.Relaxation * runtime.GOMAXPROCS(0)
+		log.Println("locking policy: relaxed multiqueue over", queues, "heaps")
+		return NewMultiQueueMaxMinHeap(queues, cfg.Capacity)
 	default:
 		panic("bad arg to CDSF constructor")
 	}
-	log.Println("filter max capacity: ", capacity)
-	return &CDSFApp{
-		heap: heap,
+}
+
+func (s *CDSFApp) Insert(item *filter.FilterItem) (FilterInsertResult, error) {
+	if item == nil {
+		// the generic heaps can't tell a nil item from an empty slot
+		return FilterInsertResult{}, status.Errorf(codes.Internal, "Filter failed to insert item")
+	}
+	if !s.takesKeys([]*filter.FilterItem{item}) {
+		return FilterInsertResult{}, errNotKeyed()
 	}
+	if s.decay != nil {
+		if item.GetScore() < 0 {
+			return FilterInsertResult{}, errNegativeScore()
+		}
+		s.decay.rebase(s.heap, now())
+	}
+
+	s.rlock()
+	defer s.runlock()
+	if s.decay != nil {
+		// keyed off the epoch, which can't move while we hold the lock
+		item = s.decay.in(item, now())
+	}
+	res := s.heap.Insert(item)
+	if res.Outcome == InsertFailed {
+		return res, status.Errorf(codes.Internal, "Filter failed to insert item")
+	}
+	res.Evicted = s.out(res.Evicted)
+
+	return res, status.Errorf(codes.OK, "Item Inserted")
 }
 
-func (s *CDSFApp) Insert(item *filter.FilterItem) error {
+func (s *CDSFApp) InsertBatch(items []*filter.FilterItem) error {
+	if !validBatch(items) {
+		return status.Errorf(codes.Internal, "Filter failed to insert items")
+	}
+	if !s.takesKeys(items) {
+		return errNotKeyed()
+	}
+	if s.decay != nil {
+		for _, item := range items {
+			if item.GetScore() < 0 {
+				return errNegativeScore()
+			}
+		}
+		s.decay.rebase(s.heap, now())
+	}
 
-	ok := s.heap.Insert(item)
+	s.rlock()
+	defer s.runlock()
+	if s.decay != nil {
+		// keyed off the epoch, which can't move while we hold the lock
+		t := now()
+		in := make([]*filter.FilterItem, len(items))
+		for i, item := range items {
+			in[i] = s.decay.in(item, t)
+		}
+		items = in
+	}
+	ok := s.heap.InsertBatch(items)
 	if !ok {
-		return status.Errorf(codes.Internal, "Filter failed to insert item")
+		return status.Errorf(codes.Internal, "Filter failed to insert items")
 	}
 
-	return status.Errorf(codes.OK, "Item Inserted")
+	return status.Errorf(codes.OK, "Items Inserted")
 }
 
 func (s *CDSFApp) GetMax() (*filter.FilterItem, error) {
@@ -71,13 +359,15 @@ func (s *CDSFApp) GetMax() (*filter.FilterItem, error) {
 		return nil, status.Errorf(codes.Internal, "Filter is empty")
 	}
 
+	s.rlock()
+	defer s.runlock()
 	item := s.heap.GetMax()
 	if item == nil {
 		return nil, status.Errorf(codes.Internal,
 			"Filter failed to retrieve max item")
 	}
 
-	return item, status.Errorf(codes.OK, "Max item retrieved")
+	return s.out(item), status.Errorf(codes.OK, "Max item retrieved")
 }
 
 func (s *CDSFApp) GetMin() (*filter.FilterItem, error) {
@@ -85,13 +375,15 @@ func (s *CDSFApp) GetMin() (*filter.FilterItem, error) {
 		return nil, status.Errorf(codes.Internal, "Filter is empty")
 	}
 
+	s.rlock()
+	defer s.runlock()
 	item := s.heap.GetMin()
 	if item == nil {
 		return nil, status.Errorf(codes.Internal,
 			"Filter failed to retrieve min item")
 	}
 
-	return item, status.Errorf(codes.OK, "Min item retrieved")
+	return s.out(item), status.Errorf(codes.OK, "Min item retrieved")
 }
 
 func (s *CDSFApp) RemoveMax() (*filter.FilterItem, error) {
@@ -99,13 +391,15 @@ func (s *CDSFApp) RemoveMax() (*filter.FilterItem, error) {
 		return nil, status.Errorf(codes.Internal, "Filter is empty")
 	}
 
+	s.rlock()
+	defer s.runlock()
 	item := s.heap.RemoveMax()
 	if item == nil {
 		return nil, status.Errorf(codes.Internal,
 			"Filter failed to remove max item")
 	}
 
-	return item, status.Errorf(codes.OK, "Max item removed")
+	return s.out(item), status.Errorf(codes.OK, "Max item removed")
 }
 
 func (s *CDSFApp) RemoveMin() (*filter.FilterItem, error) {
@@ -113,23 +407,370 @@ func (s *CDSFApp) RemoveMin() (*filter.FilterItem, error) {
 		return nil, status.Errorf(codes.Internal, "Filter is empty")
 	}
 
+	s.rlock()
+	defer s.runlock()
 	item := s.heap.RemoveMin()
 	if item == nil {
 		return nil, status.Errorf(codes.Internal,
 			"Filter failed to remove min item")
 	}
 
-	return item, status.Errorf(codes.OK, "Min item removed")
+	return s.out(item), status.Errorf(codes.OK, "Min item removed")
+}
+
+func (s *CDSFApp) RemoveTopK(k int) ([]*filter.FilterItem, error) {
+	s.rlock()
+	defer s.runlock()
+	return s.outAll(s.heap.RemoveTopK(k)), status.Errorf(codes.OK, "Items removed")
+}
+
+// With score decay threshold is an effective score
+func (s *CDSFApp) RemoveAbove(threshold float32, limit int) ([]*filter.FilterItem, error) {
+	s.rlock()
+	defer s.runlock()
+	if s.decay != nil {
+		if threshold < 0 {
+			// nothing decays below 0
+			threshold = 0
+		}
+		threshold = s.decay.key(threshold, now())
+	}
+	items := s.heap.RemoveAbove(&filter.FilterItem{Score: threshold}, limit)
+	return s.outAll(items), status.Errorf(codes.OK, "Items removed")
 }
 
 func (s *CDSFApp) GetSize() int {
 	return s.heap.Size()
 }
 
+func (s *CDSFApp) IsFull() bool {
+	return s.heap.IsFull()
+}
+
 func (s *CDSFApp) Clear() error {
+	s.rlock()
+	defer s.runlock()
 	if s.heap.Clear() {
 		return status.Errorf(codes.OK, "Filtered cleared")
 	} else {
 		return status.Errorf(codes.OK, "Filter failed to clear")
 	}
 }
+
+// stop the reaper, if there is one
+func (s *CDSFApp) Close() error {
+	if ttl, ok := s.heap.(*TTLMaxMinHeap); ok {
+		ttl.Close()
+	}
+	return nil
+}
+
+// The item is replaced by a copy with the new score, the old one may still
+// be out with a reader. With score decay the item keeps its age.
+func (s *CDSFApp) UpdateScore(key string, score float32) (*filter.FilterItem, error) {
+	heap, ok := keyedHeap(s.heap)
+	if !ok {
+		return nil, errNotKeyed()
+	}
+	if s.decay != nil && score < 0 {
+		return nil, errNegativeScore()
+	}
+
+	s.rlock()
+	defer s.runlock()
+	item, ok := heap.Update(key, func(old *filter.FilterItem) *filter.FilterItem {
+		item := proto.Clone(old).(*filter.FilterItem)
+		item.Score = score
+		if s.decay != nil {
+			item.Score = s.decay.key(score, item.InsertedUnixMs)
+			item.EffectiveScore = &score
+		}
+		return item
+	})
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "No item with key %q", key)
+	}
+
+	return s.out(item), status.Errorf(codes.OK, "Item score updated")
+}
+
+func (s *CDSFApp) Delete(key string) (*filter.FilterItem, error) {
+	heap, ok := keyedHeap(s.heap)
+	if !ok {
+		return nil, errNotKeyed()
+	}
+
+	s.rlock()
+	defer s.runlock()
+	item, ok := heap.Delete(key)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "No item with key %q", key)
+	}
+
+	return s.out(item), status.Errorf(codes.OK, "Item deleted")
+}
+
+func (s *CDSFApp) TopK(k int) ([]*filter.FilterItem, error) {
+	s.rlock()
+	defer s.runlock()
+	return s.walk(true, k, nil, nil)
+}
+
+func (s *CDSFApp) BottomK(k int) ([]*filter.FilterItem, error) {
+	s.rlock()
+	defer s.runlock()
+	return s.walk(false, k, nil, nil)
+}
+
+// With score decay lo and hi are effective scores
+func (s *CDSFApp) Range(lo, hi float32, limit int) ([]*filter.FilterItem, error) {
+	s.rlock()
+	defer s.runlock()
+	if s.decay != nil {
+		if hi < 0 {
+			// nothing decays below 0
+			return nil, status.Errorf(codes.OK, "Items retrieved")
+		}
+		if lo < 0 {
+			lo = 0
+		}
+		// an item is above a new item's key exactly when its effective
+		// score is above the new item's score
+		t := now()
+		lo, hi = s.decay.key(lo, t), s.decay.key(hi, t)
+	}
+	return s.walk(true, limit,
+		func(item *filter.FilterItem) bool { return item.GetScore() > hi },
+		func(item *filter.FilterItem) bool { return item.GetScore() < lo })
+}
+
+func (s *CDSFApp) Remove(items []*filter.FilterItem) ([]*filter.FilterItem, error) {
+	s.rlock()
+	defer s.runlock()
+	// with score decay the heap holds items by their key, see out
+	removed, err := removeItems(s.heap, items, s.out)
+	return s.outAll(removed), err
+}
+
+// f gets every item the heap throws away to make room
+func (s *CDSFApp) OnEvict(f func(item *filter.FilterItem, reason EvictReason)) {
+	s.onEvict.Store(&f)
+}
+
+// a batch with a nil item is rejected as a whole, before any of it is inserted
+func validBatch(items []*filter.FilterItem) bool {
+	for _, item := range items {
+		if item == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// keyed items only go into a keyed heap, anywhere else they would pile up
+// under the same key
+func (s *CDSFApp) takesKeys(items []*filter.FilterItem) bool {
+	_, keyed := keyedHeap(s.heap)
+	return keyed || !hasKeys(items)
+}
+
+// see walkItems, with score decay the caller holds the read lock
+func (s *CDSFApp) walk(max bool, n int, skip, done func(item *filter.FilterItem) bool) ([]*filter.FilterItem, error) {
+	items, err := walkItems(s.heap, max, n, skip, done)
+	return s.outAll(items), err
+}
+
+// Up to n items (no limit for 0) walking heap from the max or the min,
+// leaving out what skip holds for and expired items, until done holds
+func walkItems(heap FilterHeap, max bool, n int, skip, done func(item *filter.FilterItem) bool) ([]*filter.FilterItem, error) {
+	walker, ok := walkingHeap(heap)
+	if !ok {
+		return nil, errNoQueries()
+	}
+
+	t := now()
+	var items []*filter.FilterItem
+	walker.Walk(max, skip, func(item *filter.FilterItem) bool {
+		if done != nil && done(item) {
+			return false
+		}
+		if !hasExpired(item, t) {
+			items = append(items, item)
+		}
+		return n == 0 || len(items) < n
+	})
+	return items, status.Errorf(codes.OK, "Items retrieved")
+}
+
+// whether the heaps under app can all be walked, which a new follower needs
+// unless the app is logged
+func walkable(app ConcurrentDataStreamFilter) bool {
+	if c, ok := As[*CDSFApp](app); ok {
+		_, ok := walkingHeap(c.heap)
+		return ok
+	}
+	if sharded, ok := As[*ShardedCDSFApp](app); ok {
+		for _, sh := range sharded.shards {
+			if _, ok := walkingHeap(sh.heap); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// the heap that walks, looking through the expiry and flat combining
+// decorators, which reads go straight through anyway
+func walkingHeap(heap FilterHeap) (WalkingFilterHeap, bool) {
+	for {
+		if walker, ok := heap.(WalkingFilterHeap); ok {
+			return walker, true
+		}
+		u, ok := heap.(interface{ Unwrap() FilterHeap })
+		if !ok {
+			return nil, false
+		}
+		heap = u.Unwrap()
+	}
+}
+
+// Take out one item of heap like each of items, see RemovingFilter, as
+// view has them. The heap is swept reapScan slots at a time, and again if an
+// item moved past the sweep.
+func removeItems(heap FilterHeap, items []*filter.FilterItem, view func(item *filter.FilterItem) *filter.FilterItem) ([]*filter.FilterItem, error) {
+	if ttl, ok := heap.(*TTLMaxMinHeap); ok {
+		heap = ttl.Unwrap()
+	}
+	sweeper, ok := sweepingHeap(heap)
+	if !ok {
+		return nil, errNoRemove()
+	}
+
+	want := make(map[string]int, len(items))
+	for _, item := range items {
+		want[itemID(item)]++
+	}
+	left := len(items)
+	drop := func(item *filter.FilterItem) bool {
+		id := itemID(view(item))
+		if want[id] == 0 {
+			return false
+		}
+		want[id]--
+		left--
+		return true
+	}
+
+	var removed []*filter.FilterItem
+	for left > 0 {
+		n := len(removed)
+		for from := 1; from != 0 && left > 0; {
+			var chunk []*filter.FilterItem
+			chunk, from = sweeper.RemoveWhere(drop, from, reapScan)
+			removed = append(removed, chunk...)
+		}
+		if len(removed) == n {
+			// the rest isn't there
+			break
+		}
+	}
+	return removed, status.Errorf(codes.OK, "Items removed")
+}
+
+func errNoRemove() error {
+	return status.Errorf(codes.Unimplemented, "Filter type can't take out given items")
+}
+
+func errNoQueries() error {
+	return status.Errorf(codes.Unimplemented, "Filter type doesn't support queries")
+}
+
+// The heap's key index, if it has one, looking through the expiry and flat
+// combining decorators. Inserts still go through them, they upsert in the
+// heap below, while moving and deleting by key go straight to it.
+func keyedHeap(heap FilterHeap) (KeyedFilterHeap, bool) {
+	for {
+		if keyed, ok := heap.(KeyedFilterHeap); ok {
+			return keyed, true
+		}
+		u, ok := heap.(interface{ Unwrap() FilterHeap })
+		if !ok {
+			return nil, false
+		}
+		heap = u.Unwrap()
+	}
+}
+
+func hasKeys(items []*filter.FilterItem) bool {
+	for _, item := range items {
+		if item.GetKey() != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func errNotKeyed() error {
+	return status.Errorf(codes.Unimplemented, "Filter type doesn't support keyed items")
+}
+
+// the app, or the one it decorates, that handles keyed items
+func keyedApp(app ConcurrentDataStreamFilter) (KeyedFilter, error) {
+	if keyed, ok := As[KeyedFilter](app); ok {
+		return keyed, nil
+	}
+	return nil, errNotKeyed()
+}
+
+// the app, or the one it decorates, that answers queries
+func queryingApp(app ConcurrentDataStreamFilter) (QueryingFilter, error) {
+	if q, ok := As[QueryingFilter](app); ok {
+		return q, nil
+	}
+	return nil, errNoQueries()
+}
+
+func (s *CDSFApp) evicted(item *filter.FilterItem) {
+	if f := s.onEvict.Load(); f != nil {
+		(*f)(s.out(item), EvictCapacity)
+	}
+}
+
+func (s *CDSFApp) expired(item *filter.FilterItem) {
+	if f := s.onEvict.Load(); f != nil {
+		(*f)(s.out(item), EvictExpired)
+	}
+}
+
+// with score decay the heap is shared, moving the epoch has it to itself
+func (s *CDSFApp) rlock() {
+	if s.decay != nil {
+		s.decay.lk.RLock()
+	}
+}
+
+func (s *CDSFApp) runlock() {
+	if s.decay != nil {
+		s.decay.lk.RUnlock()
+	}
+}
+
+// the item as it is handed out, the heap's own copy with score decay
+func (s *CDSFApp) out(item *filter.FilterItem) *filter.FilterItem {
+	if s.decay == nil {
+		return item
+	}
+	return s.decay.out(item, now())
+}
+
+func (s *CDSFApp) outAll(items []*filter.FilterItem) []*filter.FilterItem {
+	for i := range items {
+		items[i] = s.out(items[i])
+	}
+	return items
+}
+
+func errNegativeScore() error {
+	return status.Errorf(codes.InvalidArgument, "Filter with score decay takes no negative scores")
+}
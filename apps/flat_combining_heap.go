@@ -0,0 +1,207 @@
+package apps
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Flat combining decorator around any MaxMinHeap (Hendler et al., "Flat
+// Combining and the Synchronization-Parallelism Tradeoff").
+//
+// Insert, RemoveMax and RemoveMin are published to a shared list instead of
+// going straight to the heap. Whoever grabs the combiner lock drains the list
+// and applies the whole batch to the heap back to back, then hands every
+// caller its result. Under contention that turns one lock handoff per
+// operation into one per batch, and the heap stays hot in the combiner's
+// cache. Reads go straight to the wrapped heap.
+type FlatCombiningMaxMinHeap[T any] struct {
+	heap    MaxMinHeap[T]
+	lk      sync.Mutex                   // held by the current combiner
+	pending atomic.Pointer[fcRequest[T]] // published requests, newest first
+}
+
+type fcOp int
+
+const (
+	fcInsert fcOp = iota
+	fcInsertBatch
+	fcRemoveMax
+	fcRemoveMin
+	fcRemoveTopK
+	fcRemoveAbove
+)
+
+type fcRequest[T any] struct {
+	op    fcOp
+	item  T               // argument for inserts and RemoveAbove, result for removals
+	batch []T             // argument for batch inserts, result for batch removals
+	limit int             // argument for batch removals
+	res   InsertResult[T] // result for inserts
+	ok    bool            // result for batch inserts
+	next  *fcRequest[T]
+	done  atomic.Bool
+}
+
+// ctor
+func NewFlatCombiningMaxMinHeap[T any](heap MaxMinHeap[T]) *FlatCombiningMaxMinHeap[T] {
+	return &FlatCombiningMaxMinHeap[T]{
+		heap: heap,
+	}
+}
+
+// insert item into heap, returns what the wrapped heap did with it
+func (s *FlatCombiningMaxMinHeap[T]) Insert(item T) InsertResult[T] {
+	req := s.publish(&fcRequest[T]{op: fcInsert, item: item})
+	return req.res
+}
+
+// insert all items, the batch is applied as a whole by the combiner
+func (s *FlatCombiningMaxMinHeap[T]) InsertBatch(items []T) bool {
+	req := s.publish(&fcRequest[T]{op: fcInsertBatch, batch: items})
+	return req.ok
+}
+
+// Get the top ranked item, returns nil if the heap is empty
+func (s *FlatCombiningMaxMinHeap[T]) GetMax() T {
+	return s.heap.GetMax()
+}
+
+// Get the bottom ranked item, returns nil if the heap is empty
+func (s *FlatCombiningMaxMinHeap[T]) GetMin() T {
+	return s.heap.GetMin()
+}
+
+// Remove the top ranked item, returns nil if the heap is empty
+func (s *FlatCombiningMaxMinHeap[T]) RemoveMax() T {
+	req := s.publish(&fcRequest[T]{op: fcRemoveMax})
+	return req.item
+}
+
+// Remove the bottom ranked item, returns nil if the heap is empty
+func (s *FlatCombiningMaxMinHeap[T]) RemoveMin() T {
+	req := s.publish(&fcRequest[T]{op: fcRemoveMin})
+	return req.item
+}
+
+// Remove up to k of the top ranked items, applied as one by the combiner
+func (s *FlatCombiningMaxMinHeap[T]) RemoveTopK(k int) []T {
+	req := s.publish(&fcRequest[T]{op: fcRemoveTopK, limit: k})
+	return req.batch
+}
+
+// Remove the items ranking no lower than threshold, up to limit of them (0
+// for all), applied as one by the combiner
+func (s *FlatCombiningMaxMinHeap[T]) RemoveAbove(threshold T, limit int) []T {
+	req := s.publish(&fcRequest[T]{op: fcRemoveAbove, item: threshold, limit: limit})
+	return req.batch
+}
+
+func (s *FlatCombiningMaxMinHeap[T]) Clear() bool {
+	// keep the combiner out so no batch straddles the clear
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.heap.Clear()
+}
+
+// get the current size of the heap
+func (s *FlatCombiningMaxMinHeap[T]) Size() int {
+	return s.heap.Size()
+}
+
+func (s *FlatCombiningMaxMinHeap[T]) IsEmpty() bool {
+	return s.heap.IsEmpty()
+}
+
+func (s *FlatCombiningMaxMinHeap[T]) IsFull() bool {
+	return s.heap.IsFull()
+}
+
+// the wrapped heap does the evicting
+func (s *FlatCombiningMaxMinHeap[T]) OnEvict(f func(item T)) {
+	s.heap.OnEvict(f)
+}
+
+// Sweep the wrapped heap with the combiner kept out, see
+// SweepingMaxMinHeap. Nothing is taken out if the wrapped heap can't sweep.
+func (s *FlatCombiningMaxMinHeap[T]) RemoveWhere(drop func(item T) bool, from, scan int) ([]T, int) {
+	sweeper, ok := s.heap.(SweepingMaxMinHeap[T])
+	if !ok {
+		return nil, 0
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return sweeper.RemoveWhere(drop, from, scan)
+}
+
+func (s *FlatCombiningMaxMinHeap[T]) Unwrap() MaxMinHeap[T] {
+	return s.heap
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+// Push req onto the pending list and wait until some combiner, possibly
+// us, has applied it
+func (s *FlatCombiningMaxMinHeap[T]) publish(req *fcRequest[T]) *fcRequest[T] {
+	for {
+		head := s.pending.Load()
+		req.next = head
+		if s.pending.CompareAndSwap(head, req) {
+			break
+		}
+	}
+
+	// a request published right after the combiner's last pass would be
+	// stranded if we only waited, so keep trying to become the combiner
+	for !req.done.Load() {
+		if s.lk.TryLock() {
+			s.combine()
+			s.lk.Unlock()
+		} else {
+			runtime.Gosched()
+		}
+	}
+	return req
+}
+
+// apply everything published so far, caller holds lk
+func (s *FlatCombiningMaxMinHeap[T]) combine() {
+	for {
+		batch := s.pending.Swap(nil)
+		if batch == nil {
+			return
+		}
+
+		// the list is newest first, flip it so requests apply in order
+		var ordered *fcRequest[T]
+		for batch != nil {
+			next := batch.next
+			batch.next = ordered
+			ordered = batch
+			batch = next
+		}
+
+		for req := ordered; req != nil; {
+			// read next before done, the owner may reuse req right after
+			next := req.next
+			switch req.op {
+			case fcInsert:
+				req.res = s.heap.Insert(req.item)
+			case fcInsertBatch:
+				req.ok = s.heap.InsertBatch(req.batch)
+			case fcRemoveMax:
+				req.item = s.heap.RemoveMax()
+			case fcRemoveMin:
+				req.item = s.heap.RemoveMin()
+			case fcRemoveTopK:
+				req.batch = s.heap.RemoveTopK(req.limit)
+			case fcRemoveAbove:
+				req.batch = s.heap.RemoveAbove(req.item, req.limit)
+			}
+			req.done.Store(true)
+			req = next
+		}
+	}
+}
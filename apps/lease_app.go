@@ -0,0 +1,412 @@
+package apps
+
+import (
+	"bytes"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+ * Leasing App
+ *
+ * At-least-once consumption on top of any filter app. LeaseMax takes the max
+ * out of the filter and hides it for a visibility timeout, Ack drops it for
+ * good, and Nack or running out the timeout puts it back with its original
+ * score, unless its key was inserted again in the meantime. Leased items
+ * still count against the capacity: GetSize includes them and whatever an
+ * insert pushes past the capacity is trimmed off the bottom. A durable app
+ * below logs the leases, so their items come back after a restart.
+ */
+
+// how long a leased item stays hidden when the caller doesn't say
+const DefaultLeaseTimeout = 30 * time.Second
+
+type LeasingCDSFApp struct {
+	app      ConcurrentDataStreamFilter
+	capacity int
+	leased   atomic.Int64 // outstanding leases, counted against capacity
+	nextID   atomic.Uint64
+	leaseLk  sync.Mutex
+	leases   map[string]*lease
+	byKey    map[string]*lease // leases of keyed items, by the item's key
+	keyLk    sync.RWMutex      // keyed inserts vs. putting a keyed item back
+	trimLk   sync.Mutex        // one trimmer at a time, so we never evict too much
+	onReturn atomic.Pointer[func()]
+	onEvict  atomic.Pointer[func(item *filter.FilterItem, reason EvictReason)]
+}
+
+// Apps under the leasing app that keep the leases, see DurableCDSFApp
+type leaseLogger interface {
+	// RemoveMax for the lease id
+	leaseMax(id string) (*filter.FilterItem, error)
+
+	// Insert the item out on the lease id, which ends the lease
+	returnLease(id string, item *filter.FilterItem) (FilterInsertResult, error)
+
+	// the lease id ended without its item coming back
+	endLease(id string) error
+}
+
+type lease struct {
+	item       *filter.FilterItem
+	timer      *time.Timer // returns the item on expiry
+	superseded bool        // its key was inserted again, it doesn't go back
+}
+
+func NewLeasingCDSFApp(app ConcurrentDataStreamFilter, capacity int) *LeasingCDSFApp {
+	return &LeasingCDSFApp{
+		app:      app,
+		capacity: capacity,
+		leases:   make(map[string]*lease),
+		byKey:    make(map[string]*lease),
+	}
+}
+
+// Take the max out of the filter for timeout, returns it with the lease ID
+// that Acks or Nacks it
+func (s *LeasingCDSFApp) LeaseMax(timeout time.Duration) (*filter.FilterItem, string, error) {
+	if timeout <= 0 {
+		timeout = DefaultLeaseTimeout
+	}
+
+	// count the lease before the item leaves, so the capacity is never
+	// undercounted
+	s.leased.Add(1)
+	id := strconv.FormatUint(s.nextID.Add(1), 10)
+	item, err := leaseMax(s.app, id)
+	if err != nil {
+		s.leased.Add(-1)
+		return nil, "", err
+	}
+
+	s.leaseLk.Lock()
+	l := &lease{
+		item:  item,
+		timer: time.AfterFunc(timeout, func() { s.giveBack(id) }),
+	}
+	s.leases[id] = l
+	if key := item.GetKey(); key != "" {
+		s.byKey[key] = l
+	}
+	s.leaseLk.Unlock()
+
+	return item, id, status.Errorf(codes.OK, "Max item leased")
+}
+
+// done with the leased item, it is gone for good
+func (s *LeasingCDSFApp) Ack(id string) error {
+	l := s.takeLease(id)
+	if l == nil {
+		return status.Errorf(codes.NotFound, "Lease %s not found or expired", id)
+	}
+	s.leased.Add(-1)
+	if err := endLease(s.app, id); err != nil {
+		return err
+	}
+
+	return status.Errorf(codes.OK, "Lease acked")
+}
+
+// give the leased item back to the filter right away
+func (s *LeasingCDSFApp) Nack(id string) error {
+	if !s.giveBack(id) {
+		return status.Errorf(codes.NotFound, "Lease %s not found or expired", id)
+	}
+
+	return status.Errorf(codes.OK, "Lease nacked")
+}
+
+// f runs whenever a leased item goes back into the filter, by Nack or expiry
+func (s *LeasingCDSFApp) OnReturn(f func()) {
+	s.onReturn.Store(&f)
+}
+
+// f gets the items trimmed for the leases, and whatever the wrapped app
+// evicts if it reports that
+func (s *LeasingCDSFApp) OnEvict(f func(item *filter.FilterItem, reason EvictReason)) {
+	s.onEvict.Store(&f)
+	if inner, ok := As[EvictingFilter](s.app); ok {
+		inner.OnEvict(f)
+	}
+}
+
+// With leases out, the app itself may still have room while the filter as a
+// whole is full, so what the trim takes off the bottom counts as this
+// insert's eviction (or rejection, if it was the item itself)
+func (s *LeasingCDSFApp) Insert(item *filter.FilterItem) (FilterInsertResult, error) {
+	if item.GetKey() != "" {
+		s.keyLk.RLock()
+		s.supersede(item)
+		defer s.keyLk.RUnlock()
+	}
+	res, err := s.app.Insert(item)
+	for _, trimmed := range s.trim(item) {
+		if sameItem(trimmed, item) {
+			res = FilterInsertResult{Outcome: InsertRejected}
+			break
+		}
+		if res.Outcome == InsertStored {
+			res = FilterInsertResult{Outcome: InsertEvicted, Evicted: trimmed}
+		}
+	}
+	return res, err
+}
+
+func (s *LeasingCDSFApp) InsertBatch(items []*filter.FilterItem) error {
+	s.keyLk.RLock()
+	s.supersede(items...)
+	err := s.app.InsertBatch(items)
+	s.keyLk.RUnlock()
+	s.trim(nil)
+	return err
+}
+
+func (s *LeasingCDSFApp) GetMax() (*filter.FilterItem, error) {
+	return s.app.GetMax()
+}
+
+func (s *LeasingCDSFApp) GetMin() (*filter.FilterItem, error) {
+	return s.app.GetMin()
+}
+
+func (s *LeasingCDSFApp) RemoveMax() (*filter.FilterItem, error) {
+	return s.app.RemoveMax()
+}
+
+func (s *LeasingCDSFApp) RemoveMin() (*filter.FilterItem, error) {
+	return s.app.RemoveMin()
+}
+
+func (s *LeasingCDSFApp) RemoveTopK(k int) ([]*filter.FilterItem, error) {
+	return s.app.RemoveTopK(k)
+}
+
+func (s *LeasingCDSFApp) RemoveAbove(threshold float32, limit int) ([]*filter.FilterItem, error) {
+	return s.app.RemoveAbove(threshold, limit)
+}
+
+// stored plus leased items
+func (s *LeasingCDSFApp) GetSize() int {
+	return s.app.GetSize() + int(s.leased.Load())
+}
+
+func (s *LeasingCDSFApp) IsFull() bool {
+	return s.GetSize() >= s.capacity
+}
+
+// clears the outstanding leases too, acking them afterwards fails
+func (s *LeasingCDSFApp) Clear() error {
+	s.leaseLk.Lock()
+	for id, l := range s.leases {
+		l.timer.Stop()
+		delete(s.leases, id)
+		s.leased.Add(-1)
+	}
+	s.byKey = make(map[string]*lease)
+	s.leaseLk.Unlock()
+
+	return s.app.Clear()
+}
+
+// leased items are out of the filter, so they can't be found by key
+func (s *LeasingCDSFApp) UpdateScore(key string, score float32) (*filter.FilterItem, error) {
+	keyed, err := keyedApp(s.app)
+	if err != nil {
+		return nil, err
+	}
+	return keyed.UpdateScore(key, score)
+}
+
+func (s *LeasingCDSFApp) Delete(key string) (*filter.FilterItem, error) {
+	keyed, err := keyedApp(s.app)
+	if err != nil {
+		return nil, err
+	}
+	return keyed.Delete(key)
+}
+
+// nor do queries see them
+func (s *LeasingCDSFApp) TopK(k int) ([]*filter.FilterItem, error) {
+	q, err := queryingApp(s.app)
+	if err != nil {
+		return nil, err
+	}
+	return q.TopK(k)
+}
+
+func (s *LeasingCDSFApp) BottomK(k int) ([]*filter.FilterItem, error) {
+	q, err := queryingApp(s.app)
+	if err != nil {
+		return nil, err
+	}
+	return q.BottomK(k)
+}
+
+func (s *LeasingCDSFApp) Range(lo, hi float32, limit int) ([]*filter.FilterItem, error) {
+	q, err := queryingApp(s.app)
+	if err != nil {
+		return nil, err
+	}
+	return q.Range(lo, hi, limit)
+}
+
+// the outstanding leases no longer run out
+func (s *LeasingCDSFApp) Close() error {
+	s.leaseLk.Lock()
+	for _, l := range s.leases {
+		l.timer.Stop()
+	}
+	s.leaseLk.Unlock()
+
+	return s.app.Close()
+}
+
+func (s *LeasingCDSFApp) Unwrap() ConcurrentDataStreamFilter {
+	return s.app
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+// remove the lease, nil if it was acked, nacked or expired already
+func (s *LeasingCDSFApp) takeLease(id string) *lease {
+	s.leaseLk.Lock()
+	defer s.leaseLk.Unlock()
+
+	l, ok := s.leases[id]
+	if !ok {
+		return nil
+	}
+	delete(s.leases, id)
+	if key := l.item.GetKey(); key != "" && s.byKey[key] == l {
+		delete(s.byKey, key)
+	}
+	l.timer.Stop()
+	return l
+}
+
+// the keyed items are newer than whatever is leased under their keys, so
+// those don't go back
+func (s *LeasingCDSFApp) supersede(items ...*filter.FilterItem) {
+	s.leaseLk.Lock()
+	defer s.leaseLk.Unlock()
+	if len(s.byKey) == 0 {
+		return
+	}
+	for _, item := range items {
+		if l, ok := s.byKey[item.GetKey()]; ok {
+			l.superseded = true
+		}
+	}
+}
+
+// put a leased item back into the filter, false if the lease is gone
+func (s *LeasingCDSFApp) giveBack(id string) bool {
+	// a keyed insert either supersedes the lease before we take it, or
+	// lands after the item is back and overwrites it
+	s.keyLk.Lock()
+	l := s.takeLease(id)
+	if l == nil {
+		s.keyLk.Unlock()
+		return false
+	}
+	if l.superseded {
+		s.keyLk.Unlock()
+		s.leased.Add(-1)
+		if err := endLease(s.app, id); err != nil {
+			log.Println("filter failed to log the end of lease", id+":", err)
+		}
+		return true
+	}
+
+	// Its capacity slot is still reserved, but it may have expired while it
+	// was out, or an insert racing us filled the app up before trimming for
+	// the leases. The app reports what it evicts and what expired, the item
+	// being turned down (or failing to go in at all) is up to us.
+	res, err := returnLease(s.app, id, l.item)
+	s.keyLk.Unlock()
+	s.leased.Add(-1)
+	if err != nil || (res.Outcome == InsertRejected && !hasExpired(l.item, now())) {
+		s.report(l.item, EvictCapacity)
+	}
+	s.trim(nil)
+	if f := s.onReturn.Load(); f != nil {
+		(*f)()
+	}
+	return true
+}
+
+// Evict from the bottom until stored plus leased items fit the capacity,
+// returns the evicted items. They are reported through onEvict, except for
+// the item being inserted, whose insert comes back rejected instead.
+func (s *LeasingCDSFApp) trim(inserted *filter.FilterItem) []*filter.FilterItem {
+	if s.leased.Load() == 0 {
+		// the app keeps itself within capacity
+		return nil
+	}
+
+	s.trimLk.Lock()
+	defer s.trimLk.Unlock()
+	var trimmed []*filter.FilterItem
+	for s.GetSize() > s.capacity {
+		item, err := s.app.RemoveMin()
+		if err != nil {
+			break
+		}
+		trimmed = append(trimmed, item)
+		if inserted == nil || !sameItem(item, inserted) {
+			s.report(item, EvictLease)
+		}
+	}
+	return trimmed
+}
+
+// RemoveMax through the app that keeps the leases, if there is one
+func leaseMax(app ConcurrentDataStreamFilter, id string) (*filter.FilterItem, error) {
+	if l, ok := As[leaseLogger](app); ok {
+		return l.leaseMax(id)
+	}
+	return app.RemoveMax()
+}
+
+func returnLease(app ConcurrentDataStreamFilter, id string, item *filter.FilterItem) (FilterInsertResult, error) {
+	if l, ok := As[leaseLogger](app); ok {
+		return l.returnLease(id, item)
+	}
+	return app.Insert(item)
+}
+
+func endLease(app ConcurrentDataStreamFilter, id string) error {
+	if l, ok := As[leaseLogger](app); ok {
+		return l.endLease(id)
+	}
+	return nil
+}
+
+func (s *LeasingCDSFApp) report(item *filter.FilterItem, reason EvictReason) {
+	if f := s.onEvict.Load(); f != nil {
+		(*f)(item, reason)
+	}
+}
+
+// The trimmed item is the inserted one, or a copy of it from a filter with
+// score decay, which never hands out the items it holds. A copy has the same
+// arrival, score, key and data.
+func sameItem(trimmed, item *filter.FilterItem) bool {
+	if trimmed == item {
+		return true
+	}
+	return item.GetInsertedUnixMs() != 0 &&
+		trimmed.GetInsertedUnixMs() == item.GetInsertedUnixMs() &&
+		trimmed.GetScore() == item.GetScore() &&
+		trimmed.GetKey() == item.GetKey() &&
+		bytes.Equal(trimmed.GetData(), item.GetData())
+}
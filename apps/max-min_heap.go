@@ -1,24 +1,40 @@
 package apps
 
 import (
-	"math"
+	"container/heap"
+	"math/bits"
+	"math/rand"
+	"sync/atomic"
 
 	"github.com/Jfroel/cdsf-microservice/proto/filter"
 )
 
 /*
  * Max Min Heap Interface
+ *
+ * Generic over the stored item, the order comes from the less function the
+ * heap was built with. Getters and removals return the zero T when the heap
+ * is empty, so pointer items make the empty case easy to spot.
  */
-type MaxMinHeap interface {
-	Insert(item *filter.FilterItem) bool
+type MaxMinHeap[T any] interface {
+	Insert(item T) InsertResult[T]
 
-	GetMax() *filter.FilterItem
+	InsertBatch(items []T) bool
 
-	GetMin() *filter.FilterItem
+	GetMax() T
 
-	RemoveMax() *filter.FilterItem
+	GetMin() T
 
-	RemoveMin() *filter.FilterItem
+	RemoveMax() T
+
+	RemoveMin() T
+
+	// Remove up to k of the top ranked items in one go, best first
+	RemoveTopK(k int) []T
+
+	// Remove the top ranked items that rank no lower than threshold, up to
+	// limit of them (0 for all) in one go, best first
+	RemoveAbove(threshold T, limit int) []T
 
 	Clear() bool
 
@@ -27,17 +43,339 @@ type MaxMinHeap interface {
 	IsEmpty() bool
 
 	IsFull() bool
+
+	// f gets every item the heap throws away to make room, set it before
+	// the heap is shared. It runs under the heap's locks, so it must not
+	// block or call back into the heap.
+	OnEvict(f func(item T))
+}
+
+// The heap behind the gRPC filter
+type FilterHeap = MaxMinHeap[*filter.FilterItem]
+
+/*
+ * A heap that holds at most one item per key and finds it by key, so an
+ * item can be moved or taken out from the middle in O(log n). Inserting an
+ * item whose key is already there replaces the old one. Items with an empty
+ * key are never indexed.
+ */
+type KeyedMaxMinHeap[T any] interface {
+	MaxMinHeap[T]
+
+	// Replace the item under key with f of it, which must keep the key, and
+	// move it to its new rank. False if there is no such item.
+	Update(key string, f func(item T) T) (T, bool)
+
+	// Take the item under key out, false if there is no such item
+	Delete(key string) (T, bool)
+}
+
+type KeyedFilterHeap = KeyedMaxMinHeap[*filter.FilterItem]
+
+// A heap whose items can be looked at in order without taking them out
+type WalkingMaxMinHeap[T any] interface {
+	// Visit the items best first, from the max if max is set and from the
+	// min otherwise, until visit returns false. The items skip holds for
+	// are left out. skip may be nil, and must hold for every item better
+	// than one it holds for, so a heap can leave out whole subtrees, e.g.
+	// the items above a score when walking from the max. visit runs with
+	// the heap locked and must not call back into it.
+	Walk(max bool, skip func(item T) bool, visit func(item T) bool)
 }
 
-// todo: this can be done faster with checking the most significant 1-Bit
-// if the position is odd, its a max layer: 1->0b1, 4->0b100, 7->0b111
-// if the position is even, its a min layer: 2->0b10, 3->0b11, 8->0b1000
+type WalkingFilterHeap = WalkingMaxMinHeap[*filter.FilterItem]
+
+// What an insert did with the item
+type InsertOutcome int
+
+const (
+	InsertFailed   InsertOutcome = iota // not taken at all, e.g. a nil item
+	InsertStored                        // there was room for it
+	InsertRejected                      // a full heap's min was at least as good, dropped
+	InsertEvicted                       // stored, pushing the old min out
+	InsertUpdated                       // replaced the item with the same key
+)
+
+func (o InsertOutcome) String() string {
+	switch o {
+	case InsertStored:
+		return "stored"
+	case InsertRejected:
+		return "rejected"
+	case InsertEvicted:
+		return "evicted"
+	case InsertUpdated:
+		return "updated"
+	default:
+		return "failed"
+	}
+}
+
+// Result of an insert, Evicted is the item pushed out for InsertEvicted and
+// the zero T otherwise
+type InsertResult[T any] struct {
+	Outcome InsertOutcome
+	Evicted T
+}
+
+// The filter's insert result
+type FilterInsertResult = InsertResult[*filter.FilterItem]
+
+// the item was stored, with or without evicting another one
+func (r InsertResult[T]) Stored() bool {
+	return r.Outcome == InsertStored || r.Outcome == InsertEvicted || r.Outcome == InsertUpdated
+}
+
+// The filter's order, items rank by score
+func ScoreLess(a, b *filter.FilterItem) bool {
+	return a.GetScore() < b.GetScore()
+}
+
+// The filter's keys, "" for an item without one
+func ItemKey(item *filter.FilterItem) string {
+	return item.GetKey()
+}
 
-// https://pkg.go.dev/encoding/binary
+// the level is one less than the position of the most significant 1-Bit,
+// so an odd bit length is a max layer: 1->0b1, 4->0b100, 7->0b111
+// and an even one is a min layer: 2->0b10, 3->0b11, 8->0b1000
 func isMaxLevel(i int) bool {
 	// even levels (0-indexed) are max levels
+	return bits.Len(uint(i))%2 == 1
+}
+
+// Batch helpers, shared by the heaps that rebuild in place on InsertBatch.
+// Both work on the 1-based positions 1..n of whatever storage less and swap
+// index into.
+
+// Merging a small batch into a big heap one item at a time beats an O(n)
+// rebuild of the whole heap
+func batchByInserts(size, batch int) bool {
+	return batch*bits.Len(uint(size)) < size
+}
+
+// Move the k largest of positions 1..n to 1..k, in no particular order.
+// Quickselect, so expected O(n).
+func selectLargest(n, k int, less func(i, j int) bool, swap func(i, j int)) {
+	lo, hi := 1, n
+	for lo < hi {
+		// random pivot parked at lo, bigger items go left
+		swap(lo, lo+rand.Intn(hi-lo+1))
+		i, j := lo+1, hi
+		for {
+			for i <= j && less(lo, i) {
+				i++
+			}
+			for i <= j && less(j, lo) {
+				j--
+			}
+			if i >= j {
+				break
+			}
+			// both stop on items equal to the pivot, so lots of equal
+			// scores still split evenly
+			swap(i, j)
+			i++
+			j--
+		}
+		swap(lo, j)
+
+		if j == k {
+			return
+		} else if j < k {
+			lo = j + 1
+		} else {
+			hi = j - 1
+		}
+	}
+}
+
+// Turn positions 1..n into a max min heap in O(n), bottom up like Floyd's
+// heapify
+func heapify(n int, less func(i, j int) bool, swap func(i, j int)) {
+	for i := n / 2; i >= 1; i-- {
+		trickleDown(i, n, less, swap)
+	}
+}
+
+// percolate down from i, the subtrees below i are already heaps. Returns
+// where the item that was at i ended up.
+func trickleDown(i, n int, less func(i, j int) bool, swap func(i, j int)) int {
+	max := isMaxLevel(i)
+	// a before b in the order of i's level
+	before := func(a, b int) bool {
+		if max {
+			return less(b, a)
+		}
+		return less(a, b)
+	}
+
+	for 2*i <= n {
+		// the best of the children and grandchildren
+		m := 2 * i
+		for _, j := range [...]int{2*i + 1, 4 * i, 4*i + 1, 4*i + 2, 4*i + 3} {
+			if j > n {
+				break
+			}
+			if before(j, m) {
+				m = j
+			}
+		}
+
+		if !before(m, i) {
+			return i
+		}
+		swap(i, m)
+		if m <= 2*i+1 {
+			// m is a child, nothing below it can be out of order
+			return m
+		}
+
+		// m is a grandchild, its parent on the other kind of level may
+		// have to trade places with what we carry down
+		if p := m / 2; before(p, m) {
+			// ours stops at the parent, the parent's goes on
+			swap(m, p)
+			trickleDown(m, n, less, swap)
+			return p
+		}
+		i = m
+	}
+	return i
+}
+
+// percolate up from i, everything but i is in order
+func bubbleUp(i int, less func(i, j int) bool, swap func(i, j int)) {
+	if i == 1 {
+		return
+	}
+	// past its parent on the other kind of level it goes on up the levels
+	// of the parent's kind, else up its own
+	max := isMaxLevel(i)
+	if p := i / 2; max && less(i, p) || !max && less(p, i) {
+		swap(i, p)
+		i, max = p, !max
+	}
+	for gp := i / 4; gp > 0; gp = i / 4 {
+		if max && !less(gp, i) || !max && !less(i, gp) {
+			return
+		}
+		swap(i, gp)
+		i = gp
+	}
+}
+
+// RemoveTopK and RemoveAbove for heaps without one lock to take: one
+// RemoveMax after the other, as long as take (nil for any) says the max
+// qualifies. A max that changed in between and no longer does goes back in,
+// and if better items filled the heap up meanwhile it goes to evict instead.
+func removeEach(heap FilterHeap, limit int, take func(item *filter.FilterItem) bool, evict func(item *filter.FilterItem)) []*filter.FilterItem {
+	var items []*filter.FilterItem
+	for limit == 0 || len(items) < limit {
+		if take != nil && !take(heap.GetMax()) {
+			break
+		}
+		item := heap.RemoveMax()
+		if item == nil {
+			break
+		}
+		if take != nil && !take(item) {
+			switch heap.Insert(item).Outcome {
+			case InsertRejected, InsertFailed:
+				evict(item)
+			}
+			break
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// Claim up to n of the capacity slots counted by size, returns how many we
+// got, 0 if there are none left
+func claimSlots(size *atomic.Int64, capacity int, n int) int {
+	for {
+		used := size.Load()
+		free := int64(capacity) - used
+		if free <= 0 {
+			return 0
+		}
+		if free > int64(n) {
+			free = int64(n)
+		}
+		if size.CompareAndSwap(used, used+free) {
+			return int(free)
+		}
+	}
+}
+
+// whether an item scores at least as high as threshold
+func atLeast(threshold *filter.FilterItem) func(item *filter.FilterItem) bool {
+	return func(item *filter.FilterItem) bool {
+		return item != nil && item.GetScore() >= threshold.GetScore()
+	}
+}
+
+// Walk positions 1..n of a max min heap, see WalkingMaxMinHeap. The next
+// position is always the best one on a frontier: a position on a level
+// ordered our way is the best of its subtree, so visiting it adds its
+// children, ordered the other way, and their children, the best of what is
+// below those. The first k positions take O(k log k), no matter n.
+func walkHeap(n int, max bool, less func(i, j int) bool, skip func(i int) bool, visit func(i int) bool) {
+	if n == 0 {
+		return
+	}
+	f := &frontier{before: func(i, j int) bool {
+		if max {
+			return less(j, i)
+		}
+		return less(i, j)
+	}}
+	// a position on a level ordered our way is the best of its subtree
+	ours := func(i int) bool { return isMaxLevel(i) == max }
+	skipped := func(i int) bool { return skip != nil && skip(i) }
+	// A position on a level ordered the other way is the worst of its
+	// subtree, so if it's skipped all of the subtree is. If not, its
+	// children are the best of what is below it.
+	other := func(i int) {
+		if i > n || skipped(i) {
+			return
+		}
+		heap.Push(f, i)
+		for c := 2 * i; c <= 2*i+1 && c <= n; c++ {
+			heap.Push(f, c)
+		}
+	}
+
+	if ours(1) {
+		heap.Push(f, 1)
+	} else {
+		other(1)
+	}
+	for f.Len() > 0 {
+		i := heap.Pop(f).(int)
+		if !skipped(i) && !visit(i) {
+			return
+		}
+		if ours(i) {
+			other(2 * i)
+			other(2*i + 1)
+		}
+	}
+}
+
+// positions, best first, for container/heap
+type frontier struct {
+	pos    []int
+	before func(i, j int) bool
+}
 
-	// level is given by floor(log2(n))
-	level := int(math.Floor(math.Log2(float64(i))))
-	return level%2 == 0
+func (f *frontier) Len() int           { return len(f.pos) }
+func (f *frontier) Less(a, b int) bool { return f.before(f.pos[a], f.pos[b]) }
+func (f *frontier) Swap(a, b int)      { f.pos[a], f.pos[b] = f.pos[b], f.pos[a] }
+func (f *frontier) Push(x any)         { f.pos = append(f.pos, x.(int)) }
+func (f *frontier) Pop() any {
+	i := f.pos[len(f.pos)-1]
+	f.pos = f.pos[:len(f.pos)-1]
+	return i
 }
@@ -0,0 +1,336 @@
+package apps
+
+import (
+	"math/rand"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+)
+
+// Relaxed Max Min heap built from the MultiQueue idea: items go into one of
+// several independent heaps picked at random, and removals look at the heads
+// of two random heaps and take the better one. Removals are therefore not
+// exact, but the returned item is one of the top O(queues) items in
+// expectation, and no operation ever contends on a lock shared by the whole
+// structure. Evictions at capacity are relaxed the same way.
+//
+// GetMax and GetMin scan every head, so peeking stays exact.
+//
+// Like the shards of the sharded filter, every queue holds its share of the
+// capacity plus some slack, and an insert claims a slot in the queue it goes
+// to, so a queue never evicts on its own.
+type MultiQueueMaxMinHeap struct {
+	queues   []*shard
+	capacity int          // fixed capacity parameter, set at construction
+	size     atomic.Int64 // items stored or being stored across all queues
+	onEvict  func(item *filter.FilterItem)
+}
+
+// ctor
+func NewMultiQueueMaxMinHeap(queues int, capacity int) *MultiQueueMaxMinHeap {
+	if queues < 2 {
+		// the two random choices need two heads to pick from
+		queues = 2
+	}
+
+	s := &MultiQueueMaxMinHeap{
+		queues:   make([]*shard, queues),
+		capacity: capacity,
+	}
+	share := (capacity + queues - 1) / queues
+	for i := range s.queues {
+		s.queues[i] = &shard{
+			heap:     NewCoarseRWMaxMinHeap(share + share/shardSlack),
+			capacity: share + share/shardSlack,
+		}
+	}
+	return s
+}
+
+// insert item into heap, returns whether it was stored, rejected or
+// stored by evicting the min of the queue it looked at
+func (s *MultiQueueMaxMinHeap) Insert(item *filter.FilterItem) FilterInsertResult {
+	if item == nil {
+		return FilterInsertResult{Outcome: InsertFailed}
+	}
+	if s.capacity < 1 {
+		return FilterInsertResult{Outcome: InsertRejected}
+	}
+
+	for {
+		if s.reserve() {
+			s.place(item)
+			return FilterInsertResult{Outcome: InsertStored}
+		}
+
+		// full, evict the smaller of two random mins if item beats it
+		q := s.pickMin()
+		if q == nil {
+			q = s.anyNonEmpty()
+			if q == nil {
+				// only in-flight inserts left
+				runtime.Gosched()
+				continue
+			}
+		}
+		min := q.heap.GetMin()
+		if min == nil {
+			runtime.Gosched()
+			continue
+		}
+		if item.GetScore() <= min.GetScore() {
+			// don't insert this item
+			return FilterInsertResult{Outcome: InsertRejected}
+		}
+
+		evicted := q.heap.RemoveMin()
+		if evicted == nil {
+			// a consumer emptied the queue, which freed up some capacity
+			runtime.Gosched()
+			continue
+		}
+		// the slot of the evicted item passes on to whichever one we keep
+		q.size.Add(-1)
+		if item.GetScore() < evicted.GetScore() {
+			// the min moved before we got to it, keep the better of the two
+			s.place(evicted)
+			return FilterInsertResult{Outcome: InsertRejected}
+		}
+		s.place(item)
+		s.evict(evicted)
+		return FilterInsertResult{Outcome: InsertEvicted, Evicted: evicted}
+	}
+}
+
+// insert all items, returns boolean representing success. Every item still
+// goes to its own random queue, batching them up would skew the queues.
+func (s *MultiQueueMaxMinHeap) InsertBatch(items []*filter.FilterItem) bool {
+	for _, item := range items {
+		if s.Insert(item).Outcome == InsertRejected {
+			// a batch has no outcome per item, so the items that didn't
+			// make the cut get reported too
+			s.evict(item)
+		}
+	}
+	return true
+}
+
+// Get the top ranked item, returns nil if the heap is empty
+func (s *MultiQueueMaxMinHeap) GetMax() *filter.FilterItem {
+	var maxItem *filter.FilterItem
+	for _, q := range s.queues {
+		head := q.heap.GetMax()
+		if head != nil && (maxItem == nil || maxItem.GetScore() < head.GetScore()) {
+			maxItem = head
+		}
+	}
+	return maxItem
+}
+
+// Get the bottom ranked item, returns nil if the heap is empty
+func (s *MultiQueueMaxMinHeap) GetMin() *filter.FilterItem {
+	var minItem *filter.FilterItem
+	for _, q := range s.queues {
+		head := q.heap.GetMin()
+		if head != nil && (minItem == nil || head.GetScore() < minItem.GetScore()) {
+			minItem = head
+		}
+	}
+	return minItem
+}
+
+// Remove one of the top ranked items, returns nil if the heap is empty
+func (s *MultiQueueMaxMinHeap) RemoveMax() *filter.FilterItem {
+	for s.size.Load() > 0 {
+		q := s.pickMax()
+		if q == nil {
+			q = s.anyNonEmpty()
+			if q == nil {
+				// only in-flight inserts left
+				runtime.Gosched()
+				continue
+			}
+		}
+		if item := q.heap.RemoveMax(); item != nil {
+			s.took(q)
+			return item
+		}
+	}
+	return nil
+}
+
+// Remove one of the bottom ranked items, returns nil if the heap is empty
+func (s *MultiQueueMaxMinHeap) RemoveMin() *filter.FilterItem {
+	for s.size.Load() > 0 {
+		q := s.pickMin()
+		if q == nil {
+			q = s.anyNonEmpty()
+			if q == nil {
+				// only in-flight inserts left
+				runtime.Gosched()
+				continue
+			}
+		}
+		if item := q.heap.RemoveMin(); item != nil {
+			s.took(q)
+			return item
+		}
+	}
+	return nil
+}
+
+// Remove up to k of the top ranked items, as relaxed as RemoveMax, best
+// first as far as that goes
+func (s *MultiQueueMaxMinHeap) RemoveTopK(k int) []*filter.FilterItem {
+	if k <= 0 {
+		return nil
+	}
+	return removeEach(s, k, nil, s.evict)
+}
+
+// Remove items scoring at least threshold's score, up to limit of them (0
+// for all), as relaxed as RemoveMax
+func (s *MultiQueueMaxMinHeap) RemoveAbove(threshold *filter.FilterItem, limit int) []*filter.FilterItem {
+	return removeEach(s, limit, atLeast(threshold), s.evict)
+}
+
+func (s *MultiQueueMaxMinHeap) Clear() bool {
+	// drain instead of Clear() so inserts that already reserved a slot but
+	// have not reached their queue yet stay counted
+	for _, q := range s.queues {
+		for q.heap.RemoveMax() != nil {
+			s.took(q)
+		}
+	}
+	return true
+}
+
+// get the current size of the heap
+func (s *MultiQueueMaxMinHeap) Size() int {
+	return int(s.size.Load())
+}
+
+func (s *MultiQueueMaxMinHeap) IsEmpty() bool {
+	return s.size.Load() == 0
+}
+
+func (s *MultiQueueMaxMinHeap) IsFull() bool {
+	return int(s.size.Load()) == s.capacity
+}
+
+// f gets every item thrown away to make room, set it before sharing the heap
+func (s *MultiQueueMaxMinHeap) OnEvict(f func(item *filter.FilterItem)) {
+	s.onEvict = f
+}
+
+// Take out the items drop says to, one queue after the other, see
+// CoarseRWMaxMinHeap.RemoveWhere. from counts the slots of every queue before
+// the one being swept, so each queue is locked for scan slots at a time.
+func (s *MultiQueueMaxMinHeap) RemoveWhere(drop func(item *filter.FilterItem) bool, from, scan int) ([]*filter.FilterItem, int) {
+	if from < 1 {
+		from = 1
+	}
+	stride := s.queues[0].capacity + 1
+	i, pos := from/stride, from%stride
+	q := s.queues[i]
+	removed, next := q.heap.(SweepingFilterHeap).RemoveWhere(drop, pos, scan)
+	for range removed {
+		s.took(q)
+	}
+
+	switch {
+	case next != 0:
+		return removed, i*stride + next
+	case i+1 < len(s.queues):
+		return removed, (i+1)*stride + 1
+	default:
+		return removed, 0
+	}
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+func (s *MultiQueueMaxMinHeap) evict(item *filter.FilterItem) {
+	if s.onEvict != nil {
+		s.onEvict(item)
+	}
+}
+
+// claim one unit of the capacity, false if the heap is full
+func (s *MultiQueueMaxMinHeap) reserve() bool {
+	return claimSlots(&s.size, s.capacity, 1) == 1
+}
+
+// Put item into a random queue with room, the caller holds a slot of the
+// capacity for it
+func (s *MultiQueueMaxMinHeap) place(item *filter.FilterItem) {
+	for {
+		start := rand.Intn(len(s.queues))
+		for i := range s.queues {
+			q := s.queues[(start+i)%len(s.queues)]
+			if claimSlots(&q.size, q.capacity, 1) == 1 {
+				q.heap.Insert(item)
+				return
+			}
+		}
+		// a removal gives its queue slot back a moment before the capacity
+		runtime.Gosched()
+	}
+}
+
+// an item came out of q, its queue slot goes back first, see place
+func (s *MultiQueueMaxMinHeap) took(q *shard) {
+	q.size.Add(-1)
+	s.size.Add(-1)
+}
+
+// two distinct random queues
+func (s *MultiQueueMaxMinHeap) pickTwo() (*shard, *shard) {
+	i := rand.Intn(len(s.queues))
+	j := rand.Intn(len(s.queues) - 1)
+	if j >= i {
+		j++
+	}
+	return s.queues[i], s.queues[j]
+}
+
+// the better of two random heads for RemoveMax, nil if both are empty
+func (s *MultiQueueMaxMinHeap) pickMax() *shard {
+	a, b := s.pickTwo()
+	headA, headB := a.heap.GetMax(), b.heap.GetMax()
+	if headA == nil && headB == nil {
+		return nil
+	}
+	if headA == nil || (headB != nil && headA.GetScore() < headB.GetScore()) {
+		return b
+	}
+	return a
+}
+
+// the worse of two random tails for RemoveMin, nil if both are empty
+func (s *MultiQueueMaxMinHeap) pickMin() *shard {
+	a, b := s.pickTwo()
+	tailA, tailB := a.heap.GetMin(), b.heap.GetMin()
+	if tailA == nil && tailB == nil {
+		return nil
+	}
+	if tailA == nil || (tailB != nil && tailB.GetScore() < tailA.GetScore()) {
+		return b
+	}
+	return a
+}
+
+// fallback when the two random picks were empty
+func (s *MultiQueueMaxMinHeap) anyNonEmpty() *shard {
+	start := rand.Intn(len(s.queues))
+	for i := range s.queues {
+		q := s.queues[(start+i)%len(s.queues)]
+		if !q.heap.IsEmpty() {
+			return q
+		}
+	}
+	return nil
+}
@@ -0,0 +1,419 @@
+package apps
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+ * Replicated App
+ *
+ * Primary/backup replication of any filter app. The primary applies
+ * mutations one at a time and hands each of them, in that order, to every
+ * follower as what it did to the filter, in the write-ahead log's encoding:
+ * the items that went in, and the items that came out, evictions included. A
+ * backup takes out exactly those items, whatever its own clock, TTLs and
+ * score decay make of the filter, so it holds what the primary holds. A new
+ * follower is first sent the whole filter, a clear and then the items in
+ * batches. The items are read off a walk of the heaps, or off the
+ * write-ahead log for heaps that can't be walked, the filter is never taken
+ * apart for it and only mutations wait meanwhile.
+ *
+ * A backup only applies what the primary sends and rejects every other
+ * mutation, reads work on both. Replication is asynchronous, a backup that
+ * gets promoted after the primary died may miss its last few mutations.
+ */
+
+const (
+	followerBuffer = 4096 // records a follower may fall behind before it is dropped
+	followBatch    = 1024 // items per record when sending a follower the whole filter
+)
+
+type ReplicatedCDSFApp struct {
+	app       ConcurrentDataStreamFilter
+	rec       recorder
+	primary   atomic.Bool
+	mu        sync.RWMutex // mutations are exclusive, reads and new followers shared
+	followLk  sync.Mutex   // new followers join one at a time
+	followers map[*Follower]struct{}
+}
+
+// the primary's mutations as one backup sees them
+type Follower struct {
+	Records chan *filter.ReplicationRecord
+	Lagged  chan struct{} // closed once the follower fell too far behind and got dropped
+}
+
+func NewReplicatedCDSFApp(app ConcurrentDataStreamFilter, primary bool) *ReplicatedCDSFApp {
+	s := &ReplicatedCDSFApp{
+		app:       app,
+		followers: make(map[*Follower]struct{}),
+	}
+	s.primary.Store(primary)
+	s.rec.watch(app)
+	return s
+}
+
+func (s *ReplicatedCDSFApp) IsPrimary() bool {
+	return s.primary.Load()
+}
+
+// Turn a backup into a primary, it takes mutations from then on
+func (s *ReplicatedCDSFApp) Promote() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.primary.Store(true)
+
+	return status.Errorf(codes.OK, "Filter promoted to primary")
+}
+
+// Start following the primary. Returns the records that rebuild the filter
+// as it is now, the follower gets every mutation after them.
+func (s *ReplicatedCDSFApp) Follow() ([]*filter.ReplicationRecord, *Follower, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.primary.Load() {
+		return nil, nil, status.Errorf(codes.FailedPrecondition, "Filter is a backup, follow the primary")
+	}
+
+	items, err := s.items()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records := []*filter.ReplicationRecord{{Op: uint32(walClear)}}
+	for len(items) > 0 {
+		n := len(items)
+		if n > followBatch {
+			n = followBatch
+		}
+		records = append(records, &filter.ReplicationRecord{
+			Op:      uint32(walChange),
+			Payload: marshalPayload(&filter.FilterChange{Inserted: items[:n]}),
+		})
+		items = items[n:]
+	}
+
+	f := &Follower{
+		Records: make(chan *filter.ReplicationRecord, followerBuffer),
+		Lagged:  make(chan struct{}),
+	}
+	s.followLk.Lock()
+	s.followers[f] = struct{}{}
+	s.followLk.Unlock()
+	return records, f, status.Errorf(codes.OK, "Following")
+}
+
+func (s *ReplicatedCDSFApp) Unfollow(f *Follower) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.followers, f)
+}
+
+// apply a record from the primary, only backups take them
+func (s *ReplicatedCDSFApp) Apply(rec *filter.ReplicationRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.primary.Load() {
+		return status.Errorf(codes.FailedPrecondition, "Filter is the primary")
+	}
+	if err := s.apply(byte(rec.GetOp()), rec.GetPayload()); err != nil {
+		return status.Errorf(codes.InvalidArgument, "Filter failed to apply record: %v", err)
+	}
+	return nil
+}
+
+func (s *ReplicatedCDSFApp) Insert(item *filter.FilterItem) (FilterInsertResult, error) {
+	if item == nil {
+		// rejected anyway, nothing to replicate
+		return s.app.Insert(item)
+	}
+	var res FilterInsertResult
+	err := s.mutate(func(c *filter.FilterChange) (err error) {
+		res, err = s.app.Insert(item)
+		noteInsert(c, item, res, err)
+		return err
+	})
+	return res, err
+}
+
+func (s *ReplicatedCDSFApp) InsertBatch(items []*filter.FilterItem) error {
+	if !validBatch(items) {
+		return s.app.InsertBatch(items)
+	}
+	return s.mutate(func(c *filter.FilterChange) error {
+		err := s.app.InsertBatch(items)
+		if err == nil {
+			c.Inserted = append(c.Inserted, items...)
+		}
+		return err
+	})
+}
+
+func (s *ReplicatedCDSFApp) GetMax() (*filter.FilterItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.app.GetMax()
+}
+
+func (s *ReplicatedCDSFApp) GetMin() (*filter.FilterItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.app.GetMin()
+}
+
+func (s *ReplicatedCDSFApp) RemoveMax() (*filter.FilterItem, error) {
+	var item *filter.FilterItem
+	err := s.mutate(func(c *filter.FilterChange) (err error) {
+		item, err = s.app.RemoveMax()
+		noteRemoved(c, item)
+		return err
+	})
+	return item, err
+}
+
+func (s *ReplicatedCDSFApp) RemoveMin() (*filter.FilterItem, error) {
+	var item *filter.FilterItem
+	err := s.mutate(func(c *filter.FilterChange) (err error) {
+		item, err = s.app.RemoveMin()
+		noteRemoved(c, item)
+		return err
+	})
+	return item, err
+}
+
+func (s *ReplicatedCDSFApp) RemoveTopK(k int) ([]*filter.FilterItem, error) {
+	var items []*filter.FilterItem
+	err := s.mutate(func(c *filter.FilterChange) (err error) {
+		items, err = s.app.RemoveTopK(k)
+		noteRemoved(c, items...)
+		return err
+	})
+	return items, err
+}
+
+func (s *ReplicatedCDSFApp) RemoveAbove(threshold float32, limit int) ([]*filter.FilterItem, error) {
+	var items []*filter.FilterItem
+	err := s.mutate(func(c *filter.FilterChange) (err error) {
+		items, err = s.app.RemoveAbove(threshold, limit)
+		noteRemoved(c, items...)
+		return err
+	})
+	return items, err
+}
+
+func (s *ReplicatedCDSFApp) TopK(k int) ([]*filter.FilterItem, error) {
+	q, err := queryingApp(s.app)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return q.TopK(k)
+}
+
+func (s *ReplicatedCDSFApp) BottomK(k int) ([]*filter.FilterItem, error) {
+	q, err := queryingApp(s.app)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return q.BottomK(k)
+}
+
+func (s *ReplicatedCDSFApp) Range(lo, hi float32, limit int) ([]*filter.FilterItem, error) {
+	q, err := queryingApp(s.app)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return q.Range(lo, hi, limit)
+}
+
+func (s *ReplicatedCDSFApp) GetSize() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.app.GetSize()
+}
+
+func (s *ReplicatedCDSFApp) IsFull() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.app.IsFull()
+}
+
+func (s *ReplicatedCDSFApp) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.primary.Load() {
+		return errBackup()
+	}
+	err := s.app.Clear()
+	s.send(&filter.ReplicationRecord{Op: uint32(walClear)})
+	return err
+}
+
+func (s *ReplicatedCDSFApp) UpdateScore(key string, score float32) (*filter.FilterItem, error) {
+	keyed, err := keyedApp(s.app)
+	if err != nil {
+		return nil, err
+	}
+	var item *filter.FilterItem
+	err = s.mutate(func(c *filter.FilterChange) (err error) {
+		item, err = keyed.UpdateScore(key, score)
+		if item != nil {
+			// goes in over the old version
+			c.Inserted = append(c.Inserted, item)
+		}
+		return err
+	})
+	return item, err
+}
+
+func (s *ReplicatedCDSFApp) Delete(key string) (*filter.FilterItem, error) {
+	keyed, err := keyedApp(s.app)
+	if err != nil {
+		return nil, err
+	}
+	var item *filter.FilterItem
+	err = s.mutate(func(c *filter.FilterChange) (err error) {
+		item, err = keyed.Delete(key)
+		noteRemoved(c, item)
+		return err
+	})
+	return item, err
+}
+
+// f gets everything the wrapped app evicts
+func (s *ReplicatedCDSFApp) OnEvict(f func(item *filter.FilterItem, reason EvictReason)) {
+	s.rec.onEvict.Store(&f)
+}
+
+func (s *ReplicatedCDSFApp) Close() error {
+	return s.app.Close()
+}
+
+func (s *ReplicatedCDSFApp) Unwrap() ConcurrentDataStreamFilter {
+	return s.app
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+// apply a mutation on the primary and pass what it did on to the followers
+func (s *ReplicatedCDSFApp) mutate(apply func(c *filter.FilterChange) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.primary.Load() {
+		return errBackup()
+	}
+
+	c, err := s.rec.record(apply)
+	if !unchanged(c) {
+		s.send(&filter.ReplicationRecord{Op: uint32(walChange), Payload: marshalPayload(c)})
+	}
+	return err
+}
+
+// Everything in the filter, walked, or as the log has it if the heaps can't
+// be walked. Caller holds mu, shared is enough.
+func (s *ReplicatedCDSFApp) items() ([]*filter.FilterItem, error) {
+	if walkable(s.app) {
+		q, err := queryingApp(s.app)
+		if err != nil {
+			return nil, err
+		}
+		return q.TopK(0)
+	}
+	if durable, ok := As[*DurableCDSFApp](s.app); ok {
+		return durable.items()
+	}
+	return nil, errNoFollow()
+}
+
+// hand rec to every follower, caller holds mu
+func (s *ReplicatedCDSFApp) send(rec *filter.ReplicationRecord) {
+	for f := range s.followers {
+		select {
+		case f.Records <- rec:
+		default:
+			// never block the primary on a slow backup, it has to resync
+			delete(s.followers, f)
+			close(f.Lagged)
+		}
+	}
+}
+
+// make a change the primary sent, caller holds mu
+func (s *ReplicatedCDSFApp) apply(op byte, payload []byte) error {
+	switch op {
+	case walChange:
+		c, err := decodeChange(payload)
+		if err != nil {
+			return err
+		}
+		if durable, ok := As[*DurableCDSFApp](s.app); ok {
+			// logs it too
+			return durable.apply(c)
+		}
+		in, out := netChange(c)
+		return changeApp(s.app, in, out)
+	case walClear:
+		return s.app.Clear()
+	default:
+		return fmt.Errorf("unknown replication record %d", op)
+	}
+}
+
+// RemoveMax for the lease id, see leaseLogger. The backups keep the lease
+// too if they are durable.
+func (s *ReplicatedCDSFApp) leaseMax(id string) (*filter.FilterItem, error) {
+	var item *filter.FilterItem
+	err := s.mutate(func(c *filter.FilterChange) (err error) {
+		item, err = leaseMax(s.app, id)
+		if item != nil {
+			noteRemoved(c, item)
+			c.Leased = id
+		}
+		return err
+	})
+	return item, err
+}
+
+func (s *ReplicatedCDSFApp) returnLease(id string, item *filter.FilterItem) (FilterInsertResult, error) {
+	var res FilterInsertResult
+	err := s.mutate(func(c *filter.FilterChange) (err error) {
+		res, err = returnLease(s.app, id, item)
+		noteInsert(c, item, res, err)
+		c.Released = id
+		return err
+	})
+	return res, err
+}
+
+func (s *ReplicatedCDSFApp) endLease(id string) error {
+	return s.mutate(func(c *filter.FilterChange) error {
+		c.Released = id
+		return endLease(s.app, id)
+	})
+}
+
+func errNoFollow() error {
+	return status.Errorf(codes.Unimplemented, "Filter type can't be walked, a follower needs it logged to a data dir")
+}
+
+func errBackup() error {
+	return status.Errorf(codes.FailedPrecondition, "Filter is a read-only backup")
+}
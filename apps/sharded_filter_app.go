@@ -0,0 +1,513 @@
+package apps
+
+import (
+	"log"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+ * Sharded App
+ *
+ * Spreads inserts round robin over independent MaxMinHeaps so producers
+ * never contend on a single heap lock. The capacity is global: size counts
+ * the items across all shards and an insert has to reserve a slot before it
+ * touches a shard. Each shard only holds its share of the capacity plus some
+ * slack, and an insert also claims a slot in the shard it goes to, skipping
+ * the shards that are full. Removals look at every shard head to keep the global
+ * ordering exact, so they are serialized on rmLk; inserts never take it
+ * unless the filter is full and something has to be evicted.
+ *
+ * An insert holds landLk shared from claiming its slots until its item is in
+ * a shard. Evicting holds it alone, so the min it evicts is the min of every
+ * item counted, and a read that finds every shard empty while items are
+ * counted waits for them to land before it says the filter is empty.
+ */
+
+// a shard holds 1/shardSlack more than its share of the capacity, so round
+// robin rarely has to look past the next shard
+const shardSlack = 8
+
+type ShardedCDSFApp struct {
+	shards   []*shard
+	capacity int
+	size     atomic.Int64  // items stored or being stored across all shards
+	next     atomic.Uint64 // round robin shard picker
+	rmLk     sync.Mutex    // held by anything that takes items out of the shards
+	landLk   sync.RWMutex  // shared by inserts with slots claimed and items not in yet
+	onEvict  atomic.Pointer[func(item *filter.FilterItem, reason EvictReason)]
+}
+
+// one of the heaps of the sharded filter, or a queue of the multiqueue, with
+// the slots claimed in it
+type shard struct {
+	heap     FilterHeap
+	capacity int
+	size     atomic.Int64 // items stored or being stored in this shard
+}
+
+// There are more shard slots than global ones, so there is always a shard
+// with room for an item that got a global slot, and a shard never evicts on
+// its own
+func NewShardedCDSFApp(cfg Config) *ShardedCDSFApp {
+	if cfg.Shards < 1 {
+		panic("bad shard count to sharded CDSF constructor")
+	}
+
+	s := &ShardedCDSFApp{
+		shards:   make([]*shard, cfg.Shards),
+		capacity: cfg.Capacity,
+	}
+	share := (cfg.Capacity + cfg.Shards - 1) / cfg.Shards
+	shardCfg := cfg
+	shardCfg.Capacity = share + share/shardSlack
+	for i := range s.shards {
+		sh := &shard{
+			heap:     NewMaxMinHeap(cfg.ShardType, shardCfg),
+			capacity: shardCfg.Capacity,
+		}
+		if ttl, ok := sh.heap.(*TTLMaxMinHeap); ok {
+			ttl.OnExpire(func(item *filter.FilterItem) { s.expired(sh, item) })
+		}
+		s.shards[i] = sh
+	}
+
+	log.Println("filter shards: ", cfg.Shards)
+	log.Println("filter max capacity: ", cfg.Capacity)
+	return s
+}
+
+func (s *ShardedCDSFApp) Insert(item *filter.FilterItem) (FilterInsertResult, error) {
+	if item == nil {
+		return FilterInsertResult{}, status.Errorf(codes.Internal, "Filter failed to insert item")
+	}
+	if item.GetKey() != "" {
+		// the same key could land in any shard
+		return FilterInsertResult{}, errNotKeyed()
+	}
+	if len(s.live([]*filter.FilterItem{item})) == 0 {
+		return FilterInsertResult{Outcome: InsertRejected}, status.Errorf(codes.OK, "Item Inserted")
+	}
+
+	s.landLk.RLock()
+	if s.reserve() {
+		s.place([]*filter.FilterItem{item}, 1)
+		s.landLk.RUnlock()
+		return FilterInsertResult{Outcome: InsertStored}, status.Errorf(codes.OK, "Item Inserted")
+	}
+	s.landLk.RUnlock()
+
+	// full, evicting needs a stable view of the shard heads
+	s.rmLk.Lock()
+	defer s.rmLk.Unlock()
+
+	res := s.insertFull(item)
+
+	return res, status.Errorf(codes.OK, "Item Inserted")
+}
+
+func (s *ShardedCDSFApp) InsertBatch(items []*filter.FilterItem) error {
+	if !validBatch(items) {
+		return status.Errorf(codes.Internal, "Filter failed to insert items")
+	}
+	if hasKeys(items) {
+		return errNotKeyed()
+	}
+	items = s.live(items)
+
+	// whatever fits goes to the shards in one batch per shard
+	s.landLk.RLock()
+	n := s.reserveUpTo(len(items))
+	if n > 0 {
+		s.place(items[:n], (n+len(s.shards)-1)/len(s.shards))
+	}
+	s.landLk.RUnlock()
+	if n == len(items) {
+		return status.Errorf(codes.OK, "Items Inserted")
+	}
+
+	// the rest has to beat the global min, one at a time
+	s.rmLk.Lock()
+	defer s.rmLk.Unlock()
+
+	for _, item := range items[n:] {
+		if s.insertFull(item).Outcome == InsertRejected {
+			// a batch has no outcome per item, so the items that didn't
+			// make the cut get reported too
+			s.evicted(item)
+		}
+	}
+
+	return status.Errorf(codes.OK, "Items Inserted")
+}
+
+func (s *ShardedCDSFApp) GetMax() (*filter.FilterItem, error) {
+	maxShard := s.headShard(true)
+	if maxShard == nil {
+		return nil, status.Errorf(codes.Internal, "Filter is empty")
+	}
+
+	item := maxShard.heap.GetMax()
+	if item == nil {
+		return nil, status.Errorf(codes.Internal,
+			"Filter failed to retrieve max item")
+	}
+
+	return item, status.Errorf(codes.OK, "Max item retrieved")
+}
+
+func (s *ShardedCDSFApp) GetMin() (*filter.FilterItem, error) {
+	minShard := s.headShard(false)
+	if minShard == nil {
+		return nil, status.Errorf(codes.Internal, "Filter is empty")
+	}
+
+	item := minShard.heap.GetMin()
+	if item == nil {
+		return nil, status.Errorf(codes.Internal,
+			"Filter failed to retrieve min item")
+	}
+
+	return item, status.Errorf(codes.OK, "Min item retrieved")
+}
+
+func (s *ShardedCDSFApp) RemoveMax() (*filter.FilterItem, error) {
+	s.rmLk.Lock()
+	defer s.rmLk.Unlock()
+
+	maxShard := s.headShard(true)
+	if maxShard == nil {
+		return nil, status.Errorf(codes.Internal, "Filter is empty")
+	}
+
+	item := maxShard.heap.RemoveMax()
+	if item == nil {
+		return nil, status.Errorf(codes.Internal,
+			"Filter failed to remove max item")
+	}
+	s.took(maxShard, 1)
+
+	return item, status.Errorf(codes.OK, "Max item removed")
+}
+
+func (s *ShardedCDSFApp) RemoveMin() (*filter.FilterItem, error) {
+	s.rmLk.Lock()
+	defer s.rmLk.Unlock()
+
+	minShard := s.headShard(false)
+	if minShard == nil {
+		return nil, status.Errorf(codes.Internal, "Filter is empty")
+	}
+
+	item := minShard.heap.RemoveMin()
+	if item == nil {
+		return nil, status.Errorf(codes.Internal,
+			"Filter failed to remove min item")
+	}
+	s.took(minShard, 1)
+
+	return item, status.Errorf(codes.OK, "Min item removed")
+}
+
+func (s *ShardedCDSFApp) RemoveTopK(k int) ([]*filter.FilterItem, error) {
+	if k <= 0 {
+		return nil, status.Errorf(codes.OK, "Items removed")
+	}
+	return s.removeTop(k, float32(math.Inf(-1)))
+}
+
+func (s *ShardedCDSFApp) RemoveAbove(threshold float32, limit int) ([]*filter.FilterItem, error) {
+	return s.removeTop(limit, threshold)
+}
+
+func (s *ShardedCDSFApp) GetSize() int {
+	return int(s.size.Load())
+}
+
+func (s *ShardedCDSFApp) IsFull() bool {
+	return s.size.Load() >= int64(s.capacity)
+}
+
+func (s *ShardedCDSFApp) Clear() error {
+	s.rmLk.Lock()
+	defer s.rmLk.Unlock()
+
+	// drain instead of Clear() so inserts that already reserved a slot but
+	// have not reached their shard yet stay counted
+	for _, sh := range s.shards {
+		for sh.heap.RemoveMax() != nil {
+			s.took(sh, 1)
+		}
+	}
+
+	return status.Errorf(codes.OK, "Filtered cleared")
+}
+
+// stop the shards' reapers
+func (s *ShardedCDSFApp) Close() error {
+	for _, sh := range s.shards {
+		if ttl, ok := sh.heap.(*TTLMaxMinHeap); ok {
+			ttl.Close()
+		}
+	}
+	return nil
+}
+
+func (s *ShardedCDSFApp) TopK(k int) ([]*filter.FilterItem, error) {
+	return s.walk(true, k, nil, nil)
+}
+
+func (s *ShardedCDSFApp) BottomK(k int) ([]*filter.FilterItem, error) {
+	return s.walk(false, k, nil, nil)
+}
+
+func (s *ShardedCDSFApp) Range(lo, hi float32, limit int) ([]*filter.FilterItem, error) {
+	return s.walk(true, limit,
+		func(item *filter.FilterItem) bool { return item.GetScore() > hi },
+		func(item *filter.FilterItem) bool { return item.GetScore() < lo })
+}
+
+func (s *ShardedCDSFApp) Remove(items []*filter.FilterItem) ([]*filter.FilterItem, error) {
+	s.rmLk.Lock()
+	defer s.rmLk.Unlock()
+
+	var removed []*filter.FilterItem
+	for _, sh := range s.shards {
+		if len(items) == 0 {
+			break
+		}
+		found, err := removeItems(sh.heap, items, func(item *filter.FilterItem) *filter.FilterItem { return item })
+		if err != nil {
+			return removed, err
+		}
+		s.took(sh, len(found))
+		removed = append(removed, found...)
+		items = without(items, found)
+	}
+	return removed, status.Errorf(codes.OK, "Items removed")
+}
+
+// f gets every item pushed out of the full filter
+func (s *ShardedCDSFApp) OnEvict(f func(item *filter.FilterItem, reason EvictReason)) {
+	s.onEvict.Store(&f)
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+// claim one unit of the global capacity, false if the filter is full
+func (s *ShardedCDSFApp) reserve() bool {
+	return s.reserveUpTo(1) == 1
+}
+
+// claim up to n units of the global capacity, returns how many we got
+func (s *ShardedCDSFApp) reserveUpTo(n int) int {
+	return claimSlots(&s.size, s.capacity, n)
+}
+
+// Put items into the shards, up to chunk of them into each shard picked.
+// The caller holds a global slot for every item.
+func (s *ShardedCDSFApp) place(items []*filter.FilterItem, chunk int) {
+	for len(items) > 0 {
+		if chunk > len(items) {
+			chunk = len(items)
+		}
+		sh, n := s.pick(chunk)
+		if n == 1 {
+			sh.heap.Insert(items[0])
+		} else {
+			sh.heap.InsertBatch(items[:n])
+		}
+		items = items[n:]
+	}
+}
+
+// Claim up to n slots in the next shard round robin that has room, returns
+// the shard and how many slots it gave us
+func (s *ShardedCDSFApp) pick(n int) (*shard, int) {
+	for {
+		start := s.next.Add(1)
+		for i := range s.shards {
+			sh := s.shards[(start+uint64(i))%uint64(len(s.shards))]
+			if got := claimSlots(&sh.size, sh.capacity, n); got > 0 {
+				return sh, got
+			}
+		}
+		// the shard slot of something removed meanwhile is given back a
+		// moment before its global slot
+		runtime.Gosched()
+	}
+}
+
+// n items came out of sh, the shard slots go back first so whoever gets the
+// global slots finds room in some shard
+func (s *ShardedCDSFApp) took(sh *shard, n int) {
+	sh.size.Add(int64(-n))
+	s.size.Add(int64(-n))
+}
+
+// Insert into a filter that was full, caller holds rmLk. The inserts on
+// their way into a shard land first, so nothing counted is missed as the min.
+func (s *ShardedCDSFApp) insertFull(item *filter.FilterItem) FilterInsertResult {
+	s.landLk.Lock()
+	defer s.landLk.Unlock()
+
+	for {
+		if s.reserve() {
+			// something got removed while we were waiting
+			s.place([]*filter.FilterItem{item}, 1)
+			return FilterInsertResult{Outcome: InsertStored}
+		}
+
+		minShard := s.minShard()
+		if minShard == nil || item.GetScore() <= minShard.heap.GetMin().GetScore() {
+			// don't insert this item
+			return FilterInsertResult{Outcome: InsertRejected}
+		}
+
+		// swap the global min for the new item, its global slot passes on
+		evicted := minShard.heap.RemoveMin()
+		if evicted == nil {
+			// all the shard had left had expired, which made room
+			continue
+		}
+		minShard.size.Add(-1)
+		s.place([]*filter.FilterItem{item}, 1)
+		s.evicted(evicted)
+		return FilterInsertResult{Outcome: InsertEvicted, Evicted: evicted}
+	}
+}
+
+func (s *ShardedCDSFApp) evicted(item *filter.FilterItem) {
+	s.report(item, EvictCapacity)
+}
+
+// A shard dropped an expired item, which had a slot reserved: either it was
+// stored, or it expired on its way into the shard
+func (s *ShardedCDSFApp) expired(sh *shard, item *filter.FilterItem) {
+	s.took(sh, 1)
+	s.report(item, EvictExpired)
+}
+
+func (s *ShardedCDSFApp) report(item *filter.FilterItem, reason EvictReason) {
+	if f := s.onEvict.Load(); f != nil {
+		(*f)(item, reason)
+	}
+}
+
+// the items that haven't expired yet, the others are turned down before
+// they take up a slot
+func (s *ShardedCDSFApp) live(items []*filter.FilterItem) []*filter.FilterItem {
+	t := now()
+	live := items[:0:0]
+	for _, item := range items {
+		if hasExpired(item, t) {
+			s.report(item, EvictExpired)
+		} else {
+			live = append(live, item)
+		}
+	}
+	return live
+}
+
+// the best n items of what every shard's walk comes up with, see walkItems
+func (s *ShardedCDSFApp) walk(max bool, n int, skip, done func(item *filter.FilterItem) bool) ([]*filter.FilterItem, error) {
+	var all []*filter.FilterItem
+	for _, sh := range s.shards {
+		items, err := walkItems(sh.heap, max, n, skip, done)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if max {
+			return all[j].GetScore() < all[i].GetScore()
+		}
+		return all[i].GetScore() < all[j].GetScore()
+	})
+	if n > 0 && len(all) > n {
+		all = all[:n]
+	}
+	return all, status.Errorf(codes.OK, "Items retrieved")
+}
+
+// Take the max off the best shard while it scores at least min, up to
+// limit items (0 for all). The shards are separate heaps, so it's one item
+// at a time, but under one hold of the removal lock.
+func (s *ShardedCDSFApp) removeTop(limit int, min float32) ([]*filter.FilterItem, error) {
+	s.rmLk.Lock()
+	defer s.rmLk.Unlock()
+
+	threshold := &filter.FilterItem{Score: min}
+	var items []*filter.FilterItem
+	for limit == 0 || len(items) < limit {
+		sh := s.headShard(true)
+		if sh == nil {
+			break
+		}
+		removed := sh.heap.RemoveAbove(threshold, 1)
+		if len(removed) == 0 {
+			break
+		}
+		s.took(sh, 1)
+		items = append(items, removed...)
+	}
+	return items, status.Errorf(codes.OK, "Items removed")
+}
+
+// The shard holding the global max (or min), nil if all shards are empty.
+// If they look empty while items are counted, they are looked at again once
+// the inserts on their way have landed. Caller doesn't hold landLk.
+func (s *ShardedCDSFApp) headShard(max bool) *shard {
+	head := s.minShard
+	if max {
+		head = s.maxShard
+	}
+	if sh := head(); sh != nil || s.size.Load() == 0 {
+		return sh
+	}
+	s.landLk.Lock()
+	s.landLk.Unlock()
+	return head()
+}
+
+// the shard holding the global max, nil if all shards are empty
+func (s *ShardedCDSFApp) maxShard() *shard {
+	var best *shard
+	var bestItem *filter.FilterItem
+	for _, sh := range s.shards {
+		head := sh.heap.GetMax()
+		if head == nil {
+			continue
+		}
+		if bestItem == nil || bestItem.GetScore() < head.GetScore() {
+			best, bestItem = sh, head
+		}
+	}
+	return best
+}
+
+// the shard holding the global min, nil if all shards are empty
+func (s *ShardedCDSFApp) minShard() *shard {
+	var best *shard
+	var bestItem *filter.FilterItem
+	for _, sh := range s.shards {
+		head := sh.heap.GetMin()
+		if head == nil {
+			continue
+		}
+		if bestItem == nil || head.GetScore() < bestItem.GetScore() {
+			best, bestItem = sh, head
+		}
+	}
+	return best
+}
@@ -0,0 +1,461 @@
+package apps
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+)
+
+const skipListMaxLevel = 24 // plenty for 2^18 items at p = 1/2
+
+// Double-ended priority queue on a lazy concurrent skip list (Herlihy et al.,
+// "A Simple Optimistic Skiplist Algorithm"). Lookups never lock, inserts and
+// removals only lock the predecessors they splice, and removal is split into
+// logically marking a node and then unlinking it. The min is the first live
+// node and the max the last one, so both ends are reached in O(log n) without
+// the root contention of a heap.
+//
+// Nodes are ordered by score, ties by insertion order, which keeps every key
+// unique.
+type SkipListMaxMinHeap struct {
+	head     *skipNode
+	tail     *skipNode
+	capacity int           // fixed capacity parameter, set at construction
+	size     atomic.Int64  // items stored or being stored
+	seq      atomic.Uint64 // tie breaker for equal scores
+	onEvict  func(item *filter.FilterItem)
+}
+
+type skipNode struct {
+	item     *filter.FilterItem
+	score    float32
+	seq      uint64
+	topLevel int
+	next     []atomic.Pointer[skipNode]
+
+	lk          sync.Mutex
+	marked      atomic.Bool // logically removed
+	fullyLinked atomic.Bool // linked at every level
+}
+
+// ctor
+func NewSkipListMaxMinHeap(capacity int) *SkipListMaxMinHeap {
+	head := newSkipNode(nil, float32(math.Inf(-1)), 0, skipListMaxLevel-1)
+	tail := newSkipNode(nil, float32(math.Inf(1)), math.MaxUint64, skipListMaxLevel-1)
+	for i := range head.next {
+		head.next[i].Store(tail)
+	}
+	head.fullyLinked.Store(true)
+	tail.fullyLinked.Store(true)
+
+	return &SkipListMaxMinHeap{
+		head:     head,
+		tail:     tail,
+		capacity: capacity,
+	}
+}
+
+func newSkipNode(item *filter.FilterItem, score float32, seq uint64, topLevel int) *skipNode {
+	return &skipNode{
+		item:     item,
+		score:    score,
+		seq:      seq,
+		topLevel: topLevel,
+		next:     make([]atomic.Pointer[skipNode], topLevel+1),
+	}
+}
+
+// insert item into heap, returns whether it was stored, rejected or
+// stored by evicting the min
+func (s *SkipListMaxMinHeap) Insert(item *filter.FilterItem) FilterInsertResult {
+	if item == nil {
+		return FilterInsertResult{Outcome: InsertFailed}
+	}
+	if s.capacity < 1 {
+		return FilterInsertResult{Outcome: InsertRejected}
+	}
+
+	for {
+		if s.reserve() {
+			s.add(item)
+			return FilterInsertResult{Outcome: InsertStored}
+		}
+
+		// full, make room if item beats the current min
+		min := s.peekMin()
+		if min == nil {
+			// only in-flight inserts left, they hold all the capacity
+			runtime.Gosched()
+			continue
+		}
+		if item.GetScore() <= min.score {
+			// don't insert this item
+			return FilterInsertResult{Outcome: InsertRejected}
+		}
+
+		evicted := s.takeMin()
+		if evicted == nil {
+			continue
+		}
+		if item.GetScore() < evicted.GetScore() {
+			// the min moved before we got to it, keep the better of the two
+			s.add(evicted)
+			return FilterInsertResult{Outcome: InsertRejected}
+		}
+		s.add(item)
+		s.evict(evicted)
+		return FilterInsertResult{Outcome: InsertEvicted, Evicted: evicted}
+	}
+}
+
+// insert all items, returns boolean representing success. There is no
+// rebuild to win here, every item gets spliced in on its own.
+func (s *SkipListMaxMinHeap) InsertBatch(items []*filter.FilterItem) bool {
+	for _, item := range items {
+		if s.Insert(item).Outcome == InsertRejected {
+			// a batch has no outcome per item, so the items that didn't
+			// make the cut get reported too
+			s.evict(item)
+		}
+	}
+	return true
+}
+
+// Get the top ranked item, returns nil if the heap is empty
+func (s *SkipListMaxMinHeap) GetMax() *filter.FilterItem {
+	if n := s.peekMax(); n != nil {
+		return n.item
+	}
+	return nil
+}
+
+// Get the bottom ranked item, returns nil if the heap is empty
+func (s *SkipListMaxMinHeap) GetMin() *filter.FilterItem {
+	if n := s.peekMin(); n != nil {
+		return n.item
+	}
+	return nil
+}
+
+// Remove the top ranked item, returns nil if the heap is empty
+func (s *SkipListMaxMinHeap) RemoveMax() *filter.FilterItem {
+	for s.size.Load() > 0 {
+		if item := s.takeMax(); item != nil {
+			s.size.Add(-1)
+			return item
+		}
+		// only in-flight inserts left
+		runtime.Gosched()
+	}
+	return nil
+}
+
+// Remove the bottom ranked item, returns nil if the heap is empty
+func (s *SkipListMaxMinHeap) RemoveMin() *filter.FilterItem {
+	for s.size.Load() > 0 {
+		if item := s.takeMin(); item != nil {
+			s.size.Add(-1)
+			return item
+		}
+		// only in-flight inserts left
+		runtime.Gosched()
+	}
+	return nil
+}
+
+// Remove up to k of the top ranked items, best first. Nothing is locked, so
+// they come out one RemoveMax at a time.
+func (s *SkipListMaxMinHeap) RemoveTopK(k int) []*filter.FilterItem {
+	if k <= 0 {
+		return nil
+	}
+	return removeEach(s, k, nil, s.evict)
+}
+
+// Remove the items scoring at least threshold's score, up to limit of them
+// (0 for all), best first, one RemoveMax at a time
+func (s *SkipListMaxMinHeap) RemoveAbove(threshold *filter.FilterItem, limit int) []*filter.FilterItem {
+	return removeEach(s, limit, atLeast(threshold), s.evict)
+}
+
+func (s *SkipListMaxMinHeap) Clear() bool {
+	// drain so concurrent inserts stay counted correctly
+	for s.takeMin() != nil {
+		s.size.Add(-1)
+	}
+	return true
+}
+
+// get the current size of the heap
+func (s *SkipListMaxMinHeap) Size() int {
+	return int(s.size.Load())
+}
+
+func (s *SkipListMaxMinHeap) IsEmpty() bool {
+	return s.size.Load() == 0
+}
+
+func (s *SkipListMaxMinHeap) IsFull() bool {
+	return int(s.size.Load()) == s.capacity
+}
+
+// f gets every item thrown away to make room, set it before sharing the heap
+func (s *SkipListMaxMinHeap) OnEvict(f func(item *filter.FilterItem)) {
+	s.onEvict = f
+}
+
+// Visit the live nodes in order, see WalkingMaxMinHeap. Nothing is locked,
+// nodes that come and go meanwhile may or may not be visited. From the min
+// the bottom level is simply followed, from the max every step back is a
+// search, O(log n).
+func (s *SkipListMaxMinHeap) Walk(max bool, skip func(item *filter.FilterItem) bool, visit func(item *filter.FilterItem) bool) {
+	live := func(n *skipNode) bool {
+		return n.fullyLinked.Load() && !n.marked.Load() && (skip == nil || !skip(n.item))
+	}
+	if !max {
+		for n := s.head.next[0].Load(); n != s.tail; n = n.next[0].Load() {
+			if live(n) && !visit(n.item) {
+				return
+			}
+		}
+		return
+	}
+
+	var preds, succs [skipListMaxLevel]*skipNode
+	score, seq := s.tail.score, s.tail.seq
+	for {
+		// the node right before the last one on the bottom level
+		s.find(score, seq, preds[:], succs[:])
+		n := preds[0]
+		if n == s.head {
+			return
+		}
+		if live(n) && !visit(n.item) {
+			return
+		}
+		score, seq = n.score, n.seq
+	}
+}
+
+// Take out the items drop says to, see SweepingMaxMinHeap. Nothing is
+// locked but the nodes being removed, so the whole list is swept in one go
+// and from and scan are ignored. Nodes that come and go meanwhile may or may
+// not be looked at.
+func (s *SkipListMaxMinHeap) RemoveWhere(drop func(item *filter.FilterItem) bool, from, scan int) ([]*filter.FilterItem, int) {
+	var removed []*filter.FilterItem
+	for n := s.head.next[0].Load(); n != s.tail; n = n.next[0].Load() {
+		if drop(n.item) && s.claim(n) {
+			s.unlink(n)
+			s.size.Add(-1)
+			removed = append(removed, n.item)
+		}
+	}
+	return removed, 0
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+func (s *SkipListMaxMinHeap) evict(item *filter.FilterItem) {
+	if s.onEvict != nil {
+		s.onEvict(item)
+	}
+}
+
+// claim one unit of the capacity, false if the heap is full
+func (s *SkipListMaxMinHeap) reserve() bool {
+	for {
+		size := s.size.Load()
+		if size >= int64(s.capacity) {
+			return false
+		}
+		if s.size.CompareAndSwap(size, size+1) {
+			return true
+		}
+	}
+}
+
+// a < b in (score, seq) order
+func (n *skipNode) less(score float32, seq uint64) bool {
+	return n.score < score || (n.score == score && n.seq < seq)
+}
+
+// geometric level with p = 1/2
+func randomLevel() int {
+	level := bits.TrailingZeros64(rand.Uint64())
+	if level >= skipListMaxLevel {
+		level = skipListMaxLevel - 1
+	}
+	return level
+}
+
+// Fill preds and succs with the nodes right before and at/after the key on
+// every level, returns the highest level the key was found on or -1
+func (s *SkipListMaxMinHeap) find(score float32, seq uint64, preds, succs []*skipNode) int {
+	found := -1
+	pred := s.head
+	for level := skipListMaxLevel - 1; level >= 0; level-- {
+		curr := pred.next[level].Load()
+		for curr.less(score, seq) {
+			pred = curr
+			curr = pred.next[level].Load()
+		}
+		if found == -1 && curr.score == score && curr.seq == seq {
+			found = level
+		}
+		preds[level] = pred
+		succs[level] = curr
+	}
+	return found
+}
+
+func (s *SkipListMaxMinHeap) add(item *filter.FilterItem) {
+	score, seq := item.GetScore(), s.seq.Add(1)
+	topLevel := randomLevel()
+	var preds, succs [skipListMaxLevel]*skipNode
+
+	for {
+		s.find(score, seq, preds[:], succs[:])
+
+		// lock the predecessors bottom up and make sure nothing changed
+		highestLocked := -1
+		var prevPred *skipNode
+		valid := true
+		for level := 0; valid && level <= topLevel; level++ {
+			pred, succ := preds[level], succs[level]
+			if pred != prevPred {
+				pred.lk.Lock()
+				highestLocked = level
+				prevPred = pred
+			}
+			valid = !pred.marked.Load() && !succ.marked.Load() &&
+				pred.next[level].Load() == succ
+		}
+		if !valid {
+			unlockPreds(preds[:], highestLocked)
+			continue
+		}
+
+		n := newSkipNode(item, score, seq, topLevel)
+		for level := 0; level <= topLevel; level++ {
+			n.next[level].Store(succs[level])
+		}
+		for level := 0; level <= topLevel; level++ {
+			preds[level].next[level].Store(n)
+		}
+		n.fullyLinked.Store(true)
+
+		unlockPreds(preds[:], highestLocked)
+		return
+	}
+}
+
+func unlockPreds(preds []*skipNode, highestLocked int) {
+	var prevPred *skipNode
+	for level := 0; level <= highestLocked; level++ {
+		if preds[level] != prevPred {
+			preds[level].lk.Unlock()
+			prevPred = preds[level]
+		}
+	}
+}
+
+// mark n as removed, false if someone else got to it first
+func (s *SkipListMaxMinHeap) claim(n *skipNode) bool {
+	if !n.fullyLinked.Load() || n.marked.Load() {
+		return false
+	}
+	n.lk.Lock()
+	if n.marked.Load() {
+		n.lk.Unlock()
+		return false
+	}
+	n.marked.Store(true)
+	return true
+}
+
+// physically unlink a node claimed by us, its lock is still held
+func (s *SkipListMaxMinHeap) unlink(n *skipNode) {
+	var preds, succs [skipListMaxLevel]*skipNode
+	for {
+		s.find(n.score, n.seq, preds[:], succs[:])
+
+		highestLocked := -1
+		var prevPred *skipNode
+		valid := true
+		for level := 0; valid && level <= n.topLevel; level++ {
+			pred := preds[level]
+			if pred != prevPred {
+				pred.lk.Lock()
+				highestLocked = level
+				prevPred = pred
+			}
+			valid = !pred.marked.Load() && pred.next[level].Load() == n
+		}
+		if !valid {
+			unlockPreds(preds[:], highestLocked)
+			continue
+		}
+
+		for level := n.topLevel; level >= 0; level-- {
+			preds[level].next[level].Store(n.next[level].Load())
+		}
+		n.lk.Unlock()
+		unlockPreds(preds[:], highestLocked)
+		return
+	}
+}
+
+// first live node, nil if there is none
+func (s *SkipListMaxMinHeap) peekMin() *skipNode {
+	for n := s.head.next[0].Load(); n != s.tail; n = n.next[0].Load() {
+		if n.fullyLinked.Load() && !n.marked.Load() {
+			return n
+		}
+	}
+	return nil
+}
+
+// last live node, nil if there is none
+func (s *SkipListMaxMinHeap) peekMax() *skipNode {
+	var preds, succs [skipListMaxLevel]*skipNode
+	score, seq := s.tail.score, s.tail.seq
+	for {
+		// the node right before the key on the bottom level
+		s.find(score, seq, preds[:], succs[:])
+		n := preds[0]
+		if n == s.head {
+			return nil
+		}
+		if n.fullyLinked.Load() && !n.marked.Load() {
+			return n
+		}
+		score, seq = n.score, n.seq
+	}
+}
+
+func (s *SkipListMaxMinHeap) takeMin() *filter.FilterItem {
+	for n := s.peekMin(); n != nil; n = s.peekMin() {
+		if s.claim(n) {
+			s.unlink(n)
+			return n.item
+		}
+	}
+	return nil
+}
+
+func (s *SkipListMaxMinHeap) takeMax() *filter.FilterItem {
+	for n := s.peekMax(); n != nil; n = s.peekMax() {
+		if s.claim(n) {
+			s.unlink(n)
+			return n.item
+		}
+	}
+	return nil
+}
@@ -0,0 +1,500 @@
+package apps
+
+import (
+	"math"
+	"sync"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+)
+
+// Max Min heap with a struct-of-arrays layout, same coarse RW lock as
+// CoarseRWMaxMinHeap.
+//
+// The heap itself is two parallel arrays: the scores, stored inline so every
+// comparison stays inside one contiguous []float32, and a uint32 handle per
+// entry pointing into the items slab. Percolation only ever moves those 8
+// bytes of pointer-free data, so it never dereferences a protobuf message and
+// never hits a GC write barrier. The items slab only changes on insert and
+// removal, freed slots are recycled through a free list.
+//
+// Percolation is iterative and carries the moving entry in registers,
+// writing it back once it found its place instead of swapping at every step.
+type SoAMaxMinHeap struct {
+	scores   []float32            // heap ordered scores, index 0 is unused
+	handles  []uint32             // items slot of the entry at the same index
+	items    []*filter.FilterItem // payload slab, indexed by handle
+	free     []uint32             // unused slots in items
+	capacity int                  // fixed capacity parameter, set at construction
+	size     int                  // current number of items in the heap
+	rwLk     sync.RWMutex
+	onEvict  func(item *filter.FilterItem)
+}
+
+// ctor
+func NewSoAMaxMinHeap(capacity int) *SoAMaxMinHeap {
+	return &SoAMaxMinHeap{
+		scores:   make([]float32, capacity+1),
+		handles:  make([]uint32, capacity+1),
+		items:    make([]*filter.FilterItem, 0, capacity),
+		free:     make([]uint32, 0, capacity),
+		capacity: capacity,
+	}
+}
+
+// insert item into heap, returns whether it was stored, rejected or
+// stored by evicting the min
+func (s *SoAMaxMinHeap) Insert(item *filter.FilterItem) FilterInsertResult {
+	if item == nil {
+		return FilterInsertResult{Outcome: InsertFailed}
+	}
+
+	s.rwLk.Lock()
+	defer s.rwLk.Unlock()
+
+	return s.insert(item)
+}
+
+// insert all items under one lock, keeping the top capacity items of the
+// batch and the heap, returns boolean representing success
+func (s *SoAMaxMinHeap) InsertBatch(items []*filter.FilterItem) bool {
+	s.rwLk.Lock()
+	defer s.rwLk.Unlock()
+
+	if s.capacity < 1 {
+		for _, item := range items {
+			if item != nil {
+				s.evict(item)
+			}
+		}
+		return true
+	}
+	if batchByInserts(s.size, len(items)) {
+		for _, item := range items {
+			if item != nil && s.insert(item).Outcome == InsertRejected {
+				// a batch has no outcome per item, so the items that
+				// didn't make the cut get reported too
+				s.evict(item)
+			}
+		}
+		return true
+	}
+
+	// throw everything together, the arrays (and the slab) grow past the
+	// capacity until the selection cut them down again
+	scores, handles := s.scores[:s.size+1], s.handles[:s.size+1]
+	for _, item := range items {
+		if item != nil {
+			scores = append(scores, item.GetScore())
+			handles = append(handles, s.alloc(item))
+		}
+	}
+	less := func(i, j int) bool { return scores[i] < scores[j] }
+	swap := func(i, j int) {
+		scores[i], scores[j] = scores[j], scores[i]
+		handles[i], handles[j] = handles[j], handles[i]
+	}
+
+	n := len(scores) - 1
+	if n > s.capacity {
+		selectLargest(n, s.capacity, less, swap)
+		for _, h := range handles[s.capacity+1:] {
+			s.evict(s.items[h])
+			s.items[h] = nil
+			s.free = append(s.free, h)
+		}
+		n = s.capacity
+	}
+	s.scores, s.handles = scores[:s.capacity+1], handles[:s.capacity+1]
+	s.size = n
+	heapify(n, less, swap)
+
+	return true
+}
+
+// Get the top ranked item, returns nil if the heap is empty
+func (s *SoAMaxMinHeap) GetMax() *filter.FilterItem {
+	s.rwLk.RLock()
+	defer s.rwLk.RUnlock()
+
+	if s.size == 0 {
+		return nil
+	}
+	return s.items[s.handles[1]]
+}
+
+// Get the bottom ranked item, returns nil if the heap is empty
+func (s *SoAMaxMinHeap) GetMin() *filter.FilterItem {
+	s.rwLk.RLock()
+	defer s.rwLk.RUnlock()
+
+	if s.size == 0 {
+		return nil
+	}
+	return s.items[s.handles[s.indexOfMin()]]
+}
+
+// Remove the top ranked item, returns nil if the heap is empty
+func (s *SoAMaxMinHeap) RemoveMax() *filter.FilterItem {
+	s.rwLk.Lock()
+	defer s.rwLk.Unlock()
+
+	if s.size == 0 {
+		return nil
+	}
+	return s.removeAt(1)
+}
+
+// Remove the bottom ranked item, returns nil if the heap is empty
+func (s *SoAMaxMinHeap) RemoveMin() *filter.FilterItem {
+	s.rwLk.Lock()
+	defer s.rwLk.Unlock()
+
+	if s.size == 0 {
+		return nil
+	}
+	return s.removeAt(s.indexOfMin())
+}
+
+// Remove up to k of the top ranked items under one lock, best first
+func (s *SoAMaxMinHeap) RemoveTopK(k int) []*filter.FilterItem {
+	if k <= 0 {
+		return nil
+	}
+	return s.removeTop(k, float32(math.Inf(-1)))
+}
+
+// Remove the items scoring at least threshold's score under one lock, up to
+// limit of them (0 for all), best first
+func (s *SoAMaxMinHeap) RemoveAbove(threshold *filter.FilterItem, limit int) []*filter.FilterItem {
+	return s.removeTop(limit, threshold.GetScore())
+}
+
+func (s *SoAMaxMinHeap) Clear() bool {
+	s.rwLk.Lock()
+	defer s.rwLk.Unlock()
+
+	// drop the payloads so the GC can have them
+	for i := range s.items {
+		s.items[i] = nil
+	}
+	s.items = s.items[:0]
+	s.free = s.free[:0]
+	s.size = 0
+	return true
+}
+
+// get the current size of the heap
+func (s *SoAMaxMinHeap) Size() int {
+	s.rwLk.RLock()
+	defer s.rwLk.RUnlock()
+	return s.size
+}
+
+func (s *SoAMaxMinHeap) IsEmpty() bool {
+	s.rwLk.RLock()
+	defer s.rwLk.RUnlock()
+	return s.size == 0
+}
+
+func (s *SoAMaxMinHeap) IsFull() bool {
+	s.rwLk.RLock()
+	defer s.rwLk.RUnlock()
+	return s.size == s.capacity
+}
+
+// f gets every item thrown away to make room, set it before sharing the heap
+func (s *SoAMaxMinHeap) OnEvict(f func(item *filter.FilterItem)) {
+	s.onEvict = f
+}
+
+// Visit the items best first under the read lock, see WalkingMaxMinHeap
+func (s *SoAMaxMinHeap) Walk(max bool, skip func(item *filter.FilterItem) bool, visit func(item *filter.FilterItem) bool) {
+	s.rwLk.RLock()
+	defer s.rwLk.RUnlock()
+
+	less := func(i, j int) bool { return s.scores[i] < s.scores[j] }
+	var skipAt func(i int) bool
+	if skip != nil {
+		skipAt = func(i int) bool { return skip(s.items[s.handles[i]]) }
+	}
+	walkHeap(s.size, max, less, skipAt, func(i int) bool { return visit(s.items[s.handles[i]]) })
+}
+
+// Take out the items drop says to, looking at no more than scan slots from
+// position from on, see CoarseRWMaxMinHeap.RemoveWhere
+func (s *SoAMaxMinHeap) RemoveWhere(drop func(item *filter.FilterItem) bool, from, scan int) ([]*filter.FilterItem, int) {
+	s.rwLk.Lock()
+	defer s.rwLk.Unlock()
+
+	if from < 1 {
+		from = 1
+	}
+	dropAt := func(i int) bool { return drop(s.items[s.handles[i]]) }
+	var removed []*filter.FilterItem
+	for i := from; i < from+scan; i++ {
+		if i > s.size {
+			return removed, 0
+		}
+		for i <= s.size && dropAt(i) {
+			// the last entry takes its place and may move up past i, so
+			// it has to be one we keep
+			for s.size > i && dropAt(s.size) {
+				removed = append(removed, s.removeAt(s.size))
+			}
+			removed = append(removed, s.removeAt(i))
+		}
+	}
+	return removed, from + scan
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+// helpers expect the locks to already be held
+
+// insert with the write lock held
+func (s *SoAMaxMinHeap) insert(item *filter.FilterItem) FilterInsertResult {
+	score := item.GetScore()
+	if s.size >= s.capacity {
+		if s.capacity < 1 {
+			return FilterInsertResult{Outcome: InsertRejected}
+		}
+		m := s.indexOfMin()
+		if score <= s.scores[m] {
+			// don't insert this item
+			return FilterInsertResult{Outcome: InsertRejected}
+		}
+
+		// overwrite the min in place, reusing its slot in the slab
+		h := s.handles[m]
+		res := FilterInsertResult{Outcome: InsertEvicted, Evicted: s.items[h]}
+		s.evict(res.Evicted)
+		s.items[h] = item
+		if m != 1 && s.scores[1] < score {
+			// the new item is the new max, the old max takes the min's place
+			s.scores[m], s.handles[m] = s.scores[1], s.handles[1]
+			s.scores[1], s.handles[1] = score, h
+			s.pushDownMin(m)
+		} else {
+			s.scores[m] = score
+			s.pushDown(m)
+		}
+		return res
+	}
+
+	s.size++
+	s.scores[s.size] = score
+	s.handles[s.size] = s.alloc(item)
+	s.pushUp(s.size)
+	return FilterInsertResult{Outcome: InsertStored}
+}
+
+func (s *SoAMaxMinHeap) evict(item *filter.FilterItem) {
+	if s.onEvict != nil {
+		s.onEvict(item)
+	}
+}
+
+// put item in a free slot of the slab and return its handle
+func (s *SoAMaxMinHeap) alloc(item *filter.FilterItem) uint32 {
+	if n := len(s.free); n > 0 {
+		h := s.free[n-1]
+		s.free = s.free[:n-1]
+		s.items[h] = item
+		return h
+	}
+	s.items = append(s.items, item)
+	return uint32(len(s.items) - 1)
+}
+
+// take the entry at i out of the heap and return its item
+func (s *SoAMaxMinHeap) removeAt(i int) *filter.FilterItem {
+	h := s.handles[i]
+	item := s.items[h]
+	s.items[h] = nil
+	s.free = append(s.free, h)
+
+	// the last entry fills the hole, and may have to sink or, anywhere
+	// below the top, rise from there
+	last := s.size
+	s.size--
+	if i != last {
+		s.scores[i], s.handles[i] = s.scores[last], s.handles[last]
+		s.pushUp(s.pushDown(i))
+	}
+	return item
+}
+
+// take the max out while it scores at least min, up to limit items (0 for
+// all)
+func (s *SoAMaxMinHeap) removeTop(limit int, min float32) []*filter.FilterItem {
+	s.rwLk.Lock()
+	defer s.rwLk.Unlock()
+
+	var items []*filter.FilterItem
+	for s.size > 0 && (limit == 0 || len(items) < limit) && s.scores[1] >= min {
+		items = append(items, s.removeAt(1))
+	}
+	return items
+}
+
+func (s *SoAMaxMinHeap) indexOfMin() int {
+	switch {
+	case s.size <= 2:
+		return s.size
+	case s.scores[3] < s.scores[2]:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// move the entry at i up to its place, nothing below i is out of order
+func (s *SoAMaxMinHeap) pushUp(i int) {
+	if i == 1 {
+		return
+	}
+	score, h := s.scores[i], s.handles[i]
+
+	// first settle which half of the levels the entry belongs to
+	parent := i / 2
+	max := isMaxLevel(i)
+	if max && score < s.scores[parent] {
+		s.scores[i], s.handles[i] = s.scores[parent], s.handles[parent]
+		i, max = parent, false
+	} else if !max && s.scores[parent] < score {
+		s.scores[i], s.handles[i] = s.scores[parent], s.handles[parent]
+		i, max = parent, true
+	}
+
+	// then bubble up through the grandparents on those levels
+	for gp := i / 4; gp > 0; gp = i / 4 {
+		if max && !(s.scores[gp] < score) || !max && !(score < s.scores[gp]) {
+			break
+		}
+		s.scores[i], s.handles[i] = s.scores[gp], s.handles[gp]
+		i = gp
+	}
+	s.scores[i], s.handles[i] = score, h
+}
+
+// move the entry at i down to its place, returns where it ended up
+func (s *SoAMaxMinHeap) pushDown(i int) int {
+	if isMaxLevel(i) {
+		return s.pushDownMax(i)
+	}
+	return s.pushDownMin(i)
+}
+
+func (s *SoAMaxMinHeap) pushDownMax(i int) int {
+	score, h := s.scores[i], s.handles[i]
+	at := 0 // where the entry stopped, if it got swapped into a parent
+	for 2*i <= s.size {
+		m := s.largestChildOrGrandchild(i)
+		if !(score < s.scores[m]) {
+			break
+		}
+		s.scores[i], s.handles[i] = s.scores[m], s.handles[m]
+		if m <= 2*i+1 {
+			// m is a child, nothing below it can be out of order
+			i = m
+			break
+		}
+		// m is a grandchild, its parent on the min level may be smaller
+		// than what we carry down
+		if p := m / 2; score < s.scores[p] {
+			score, s.scores[p] = s.scores[p], score
+			h, s.handles[p] = s.handles[p], h
+			if at == 0 {
+				at = p
+			}
+		}
+		i = m
+	}
+	s.scores[i], s.handles[i] = score, h
+	if at == 0 {
+		at = i
+	}
+	return at
+}
+
+func (s *SoAMaxMinHeap) pushDownMin(i int) int {
+	score, h := s.scores[i], s.handles[i]
+	at := 0 // where the entry stopped, if it got swapped into a parent
+	for 2*i <= s.size {
+		m := s.smallestChildOrGrandchild(i)
+		if !(s.scores[m] < score) {
+			break
+		}
+		s.scores[i], s.handles[i] = s.scores[m], s.handles[m]
+		if m <= 2*i+1 {
+			// m is a child, nothing below it can be out of order
+			i = m
+			break
+		}
+		// m is a grandchild, its parent on the max level may be larger
+		// than what we carry down
+		if p := m / 2; s.scores[p] < score {
+			score, s.scores[p] = s.scores[p], score
+			h, s.handles[p] = s.handles[p], h
+			if at == 0 {
+				at = p
+			}
+		}
+		i = m
+	}
+	s.scores[i], s.handles[i] = score, h
+	if at == 0 {
+		at = i
+	}
+	return at
+}
+
+// the largest of the up to 6 children and grandchildren of i, i has children
+func (s *SoAMaxMinHeap) largestChildOrGrandchild(i int) int {
+	m := 2 * i
+	end := 2*i + 1
+	if end > s.size {
+		end = s.size
+	}
+	for j := m + 1; j <= end; j++ {
+		if s.scores[m] < s.scores[j] {
+			m = j
+		}
+	}
+	end = 4*i + 3
+	if end > s.size {
+		end = s.size
+	}
+	for j := 4 * i; j <= end; j++ {
+		if s.scores[m] < s.scores[j] {
+			m = j
+		}
+	}
+	return m
+}
+
+// the smallest of the up to 6 children and grandchildren of i, i has children
+func (s *SoAMaxMinHeap) smallestChildOrGrandchild(i int) int {
+	m := 2 * i
+	end := 2*i + 1
+	if end > s.size {
+		end = s.size
+	}
+	for j := m + 1; j <= end; j++ {
+		if s.scores[j] < s.scores[m] {
+			m = j
+		}
+	}
+	end = 4*i + 3
+	if end > s.size {
+		end = s.size
+	}
+	for j := 4 * i; j <= end; j++ {
+		if s.scores[j] < s.scores[m] {
+			m = j
+		}
+	}
+	return m
+}
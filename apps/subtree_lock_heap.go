@@ -0,0 +1,739 @@
+package apps
+
+import (
+	"math/bits"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+)
+
+// Max Min heap with one lock per node instead of one lock for the whole heap.
+//
+// Every operation enters through the root and walks down the tree with
+// hand-over-hand locking, so a lock is only ever acquired while holding one
+// of its ancestors and operations cannot overtake each other on a path.
+// Inserts go top-down too (the path to the new slot is known up front), which
+// lets producers and consumers pipeline through disjoint subtrees instead of
+// queueing on a single lock.
+//
+// Slots 1..size are either filled or reserved by an insert that is still on
+// its way down. A nil item means the slot is empty or reserved, and the
+// percolation code treats both as "no item here".
+//
+// Keyed items are indexed by key. A key comes and goes with its item under
+// the root lock, so an insert finds out there at once whether it replaces an
+// item. Where the item is gets updated as it moves, and is exact whenever no
+// operation is under way. Replacing, moving or deleting a keyed item in the
+// middle of the heap can't be done top-down, so those wait out every other
+// operation on keyLk and have the heap to themselves.
+type SubtreeMaxMinHeap struct {
+	nodes    []subtreeNode // underlying storage for the heap, index 0 is unused
+	capacity int           // fixed capacity parameter, set at construction
+	size     atomic.Int64  // only modified while holding the root lock
+	onEvict  func(item *filter.FilterItem)
+	keyLk    sync.RWMutex   // shared by every operation, held alone to work in the middle
+	idxLk    sync.Mutex     // keyed items move in disjoint subtrees at once
+	index    map[string]int // where each keyed item is, or will land
+}
+
+type subtreeNode struct {
+	lk   sync.Mutex
+	item *filter.FilterItem
+}
+
+// ctor
+func NewSubtreeMaxMinHeap(capacity int) *SubtreeMaxMinHeap {
+	return &SubtreeMaxMinHeap{
+		nodes:    make([]subtreeNode, capacity+1),
+		capacity: capacity,
+		index:    make(map[string]int),
+	}
+}
+
+// insert item into heap, returns whether it was stored, rejected, stored by
+// evicting the min or replaced the item with the same key
+func (s *SubtreeMaxMinHeap) Insert(item *filter.FilterItem) FilterInsertResult {
+	if item == nil {
+		return FilterInsertResult{Outcome: InsertFailed}
+	}
+	if s.capacity < 1 {
+		return FilterInsertResult{Outcome: InsertRejected}
+	}
+
+	s.keyLk.RLock()
+	res, ok := s.insert(item)
+	s.keyLk.RUnlock()
+	if ok {
+		return res
+	}
+
+	// the item it replaces may be anywhere
+	s.keyLk.Lock()
+	defer s.keyLk.Unlock()
+	if i, ok := s.index[item.GetKey()]; ok {
+		s.nodes[i].item = item
+		s.fix(i)
+		return FilterInsertResult{Outcome: InsertUpdated}
+	}
+	// gone meanwhile
+	res, _ = s.insert(item)
+	return res
+}
+
+// insert all items, keeping the top capacity items of the batch and the
+// heap, returns boolean representing success
+func (s *SubtreeMaxMinHeap) InsertBatch(items []*filter.FilterItem) bool {
+	if s.capacity < 1 {
+		for _, item := range items {
+			if item != nil {
+				s.evict(item)
+			}
+		}
+		return true
+	}
+	if batchByInserts(s.Size(), len(items)) {
+		// small batches can pipeline with everyone else
+		for _, item := range items {
+			if s.Insert(item).Outcome == InsertRejected {
+				// a batch has no outcome per item, so the items that
+				// didn't make the cut get reported too
+				s.evict(item)
+			}
+		}
+		return true
+	}
+
+	// grabbing every lock in order waits out all in-flight operations, and
+	// the free slots are out of reach as long as we hold the root
+	s.keyLk.RLock()
+	defer s.keyLk.RUnlock()
+	s.lock(1)
+	size := int(s.size.Load())
+	s.lockRange(2, size)
+
+	// throw everything together, cut it down to capacity and rebuild
+	all := make([]*filter.FilterItem, 1, size+len(items)+1)
+	for i := 1; i <= size; i++ {
+		all = append(all, s.nodes[i].item)
+	}
+	// keyed items already there take the place of theirs, within the batch
+	// the last one with a key wins
+	seen := make(map[string]int)
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		k := item.GetKey()
+		if i, ok := s.index[k]; ok && k != "" {
+			all[i] = item
+		} else if j, ok := seen[k]; ok {
+			all[j] = item
+		} else {
+			if k != "" {
+				seen[k] = len(all)
+			}
+			all = append(all, item)
+		}
+	}
+	less := func(i, j int) bool { return all[i].GetScore() < all[j].GetScore() }
+	swap := func(i, j int) { all[i], all[j] = all[j], all[i] }
+
+	n := len(all) - 1
+	if n > s.capacity {
+		selectLargest(n, s.capacity, less, swap)
+		for _, item := range all[s.capacity+1:] {
+			s.evict(item)
+		}
+		n = s.capacity
+	}
+	heapify(n, less, swap)
+
+	for i := 1; i <= n; i++ {
+		s.nodes[i].item = all[i]
+	}
+	s.size.Store(int64(n))
+	s.reindex()
+
+	s.unlock(1)
+	s.unlockRange(2, size)
+	return true
+}
+
+// Get the top ranked item, returns nil if the heap is empty
+func (s *SubtreeMaxMinHeap) GetMax() *filter.FilterItem {
+	s.keyLk.RLock()
+	defer s.keyLk.RUnlock()
+	s.lock(1)
+	defer s.unlock(1)
+
+	return s.nodes[1].item
+}
+
+// Get the bottom ranked item, returns nil if the heap is empty
+func (s *SubtreeMaxMinHeap) GetMin() *filter.FilterItem {
+	s.keyLk.RLock()
+	defer s.keyLk.RUnlock()
+	s.lock(1)
+	s.lockRange(2, 3)
+	defer s.unlockRange(1, 3)
+
+	return s.nodes[s.indexOfMin(1)].item
+}
+
+// Remove the top ranked item, returns nil if the heap is empty
+func (s *SubtreeMaxMinHeap) RemoveMax() *filter.FilterItem {
+	s.keyLk.RLock()
+	defer s.keyLk.RUnlock()
+	s.lock(1)
+
+	size := int(s.size.Load())
+	if size == 0 {
+		// zero-element heap
+		s.unlock(1)
+		return nil
+	}
+	s.size.Store(int64(size - 1))
+
+	retItem := s.nodes[1].item
+	s.forget(retItem)
+	if size == 1 {
+		// one-element heap
+		s.nodes[1].item = nil
+		s.unlock(1)
+		return retItem
+	}
+
+	// take item from end of the heap and add to top, then percolate down
+	s.nodes[1].item = s.takeLast(size)
+	s.place(1)
+	s.percolateDown(1)
+
+	return retItem
+}
+
+// Remove the bottom ranked item, returns nil if the heap is empty
+func (s *SubtreeMaxMinHeap) RemoveMin() *filter.FilterItem {
+	s.keyLk.RLock()
+	defer s.keyLk.RUnlock()
+	s.lock(1)
+
+	size := int(s.size.Load())
+	if size == 0 {
+		// zero-element heap
+		s.unlock(1)
+		return nil
+	}
+	s.size.Store(int64(size - 1))
+
+	if size == 1 {
+		// one-element heap
+		retItem := s.nodes[1].item
+		s.forget(retItem)
+		s.nodes[1].item = nil
+		s.unlock(1)
+		return retItem
+	}
+
+	// two or more elements, the min is one of the root's children
+	s.lockRange(2, 3)
+	toRemove := s.indexOfMin(1)
+	retItem := s.nodes[toRemove].item
+	s.forget(retItem)
+
+	if toRemove == size {
+		// the min is also the last item, nothing to fill the hole with
+		s.nodes[toRemove].item = nil
+		s.unlockRange(1, 3)
+		return retItem
+	}
+
+	// take item from end of the heap and put it where the min was
+	if size <= 3 {
+		// we already hold the last slot
+		s.nodes[toRemove].item = s.nodes[size].item
+		s.nodes[size].item = nil
+	} else {
+		s.nodes[toRemove].item = s.takeLast(size)
+	}
+	s.place(toRemove)
+	s.unlock(1)
+	s.unlockRange(5-toRemove, 5-toRemove) // the sibling of toRemove
+	s.percolateDown(toRemove)
+
+	return retItem
+}
+
+// Remove up to k of the top ranked items, best first. There is no lock
+// covering the whole heap, so they come out one RemoveMax at a time.
+func (s *SubtreeMaxMinHeap) RemoveTopK(k int) []*filter.FilterItem {
+	if k <= 0 {
+		return nil
+	}
+	return removeEach(s, k, nil, s.evict)
+}
+
+// Remove the items scoring at least threshold's score, up to limit of them
+// (0 for all), best first, one RemoveMax at a time
+func (s *SubtreeMaxMinHeap) RemoveAbove(threshold *filter.FilterItem, limit int) []*filter.FilterItem {
+	return removeEach(s, limit, atLeast(threshold), s.evict)
+}
+
+func (s *SubtreeMaxMinHeap) Clear() bool {
+	// grabbing every lock in order waits out all in-flight operations
+	s.keyLk.RLock()
+	defer s.keyLk.RUnlock()
+	s.lock(1)
+	size := int(s.size.Load())
+	s.lockRange(2, size)
+
+	for i := 1; i <= size; i++ {
+		s.nodes[i].item = nil
+	}
+	s.size.Store(0)
+	s.reindex()
+
+	s.unlock(1)
+	s.unlockRange(2, size)
+	return true
+}
+
+// get the current size of the heap
+func (s *SubtreeMaxMinHeap) Size() int {
+	return int(s.size.Load())
+}
+
+func (s *SubtreeMaxMinHeap) IsEmpty() bool {
+	return s.size.Load() == 0
+}
+
+func (s *SubtreeMaxMinHeap) IsFull() bool {
+	return int(s.size.Load()) == s.capacity
+}
+
+// f gets every item thrown away to make room, set it before sharing the heap
+func (s *SubtreeMaxMinHeap) OnEvict(f func(item *filter.FilterItem)) {
+	s.onEvict = f
+}
+
+// Visit the items best first, see WalkingMaxMinHeap. No lock covers the
+// frontier, so the whole heap is locked like a batch insert does.
+func (s *SubtreeMaxMinHeap) Walk(max bool, skip func(item *filter.FilterItem) bool, visit func(item *filter.FilterItem) bool) {
+	s.keyLk.RLock()
+	defer s.keyLk.RUnlock()
+	s.lock(1)
+	size := int(s.size.Load())
+	s.lockRange(2, size)
+	defer s.unlock(1)
+	defer s.unlockRange(2, size)
+
+	var skipAt func(i int) bool
+	if skip != nil {
+		skipAt = func(i int) bool { return skip(s.nodes[i].item) }
+	}
+	walkHeap(size, max, s.smaller, skipAt, func(i int) bool { return visit(s.nodes[i].item) })
+}
+
+// Replace the item under key with f of it and move it to its new rank,
+// returns the new item and false if there is no such item. f must keep the
+// key. Done with the heap to ourselves, see SubtreeMaxMinHeap.
+func (s *SubtreeMaxMinHeap) Update(key string, f func(item *filter.FilterItem) *filter.FilterItem) (*filter.FilterItem, bool) {
+	s.keyLk.Lock()
+	defer s.keyLk.Unlock()
+
+	i, ok := s.index[key]
+	if !ok || key == "" {
+		return nil, false
+	}
+	s.nodes[i].item = f(s.nodes[i].item)
+	s.fix(i)
+	return s.nodes[s.index[key]].item, true
+}
+
+// Remove the item under key, returns it and false if there is no such item
+func (s *SubtreeMaxMinHeap) Delete(key string) (*filter.FilterItem, bool) {
+	s.keyLk.Lock()
+	defer s.keyLk.Unlock()
+
+	i, ok := s.index[key]
+	if !ok || key == "" {
+		return nil, false
+	}
+	item := s.nodes[i].item
+	s.forget(item)
+
+	// the last item fills the hole and finds its rank from there
+	last := int(s.size.Load())
+	s.nodes[i].item = s.nodes[last].item
+	s.nodes[last].item = nil
+	s.size.Store(int64(last - 1))
+	if i < last {
+		s.place(i)
+		s.fix(i)
+	}
+	return item, true
+}
+
+// Take out the items drop says to, see SweepingMaxMinHeap. No lock covers
+// a run of slots, so the whole heap is locked like a batch insert does, swept
+// and rebuilt in one go, and from and scan are ignored.
+func (s *SubtreeMaxMinHeap) RemoveWhere(drop func(item *filter.FilterItem) bool, from, scan int) ([]*filter.FilterItem, int) {
+	s.keyLk.RLock()
+	defer s.keyLk.RUnlock()
+	s.lock(1)
+	size := int(s.size.Load())
+	s.lockRange(2, size)
+	defer s.unlock(1)
+	defer s.unlockRange(2, size)
+
+	kept := make([]*filter.FilterItem, 1, size+1)
+	var removed []*filter.FilterItem
+	for i := 1; i <= size; i++ {
+		if item := s.nodes[i].item; drop(item) {
+			removed = append(removed, item)
+		} else {
+			kept = append(kept, item)
+		}
+	}
+	if len(removed) == 0 {
+		return nil, 0
+	}
+
+	less := func(i, j int) bool { return kept[i].GetScore() < kept[j].GetScore() }
+	swap := func(i, j int) { kept[i], kept[j] = kept[j], kept[i] }
+	n := len(kept) - 1
+	heapify(n, less, swap)
+	for i := 1; i <= size; i++ {
+		if i <= n {
+			s.nodes[i].item = kept[i]
+		} else {
+			s.nodes[i].item = nil
+		}
+	}
+	s.size.Store(int64(n))
+	s.reindex()
+	return removed, 0
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+// Insert holding keyLk shared. False, with nothing done, if item's key is
+// there already and the item under it has to be replaced in place.
+func (s *SubtreeMaxMinHeap) insert(item *filter.FilterItem) (FilterInsertResult, bool) {
+	s.lock(1)
+	if s.indexed(item) {
+		s.unlock(1)
+		return FilterInsertResult{}, false
+	}
+	size := int(s.size.Load())
+
+	if size >= s.capacity {
+		return s.replaceMin(item, size), true
+	}
+
+	// reserve the next slot, it gets filled once we get down there
+	target := size + 1
+	s.size.Store(int64(target))
+	s.track(item, target)
+	s.insertTopDown(item, target)
+
+	return FilterInsertResult{Outcome: InsertStored}, true
+}
+
+// Locks are always taken in increasing index order, a node's ancestors have
+// smaller indices than the node itself, so this can never deadlock.
+
+func (s *SubtreeMaxMinHeap) lock(i int) {
+	s.nodes[i].lk.Lock()
+}
+
+func (s *SubtreeMaxMinHeap) unlock(i int) {
+	s.nodes[i].lk.Unlock()
+}
+
+// lock the nodes in [lo, hi] that exist
+func (s *SubtreeMaxMinHeap) lockRange(lo, hi int) {
+	for i := lo; i <= hi && i < len(s.nodes); i++ {
+		s.nodes[i].lk.Lock()
+	}
+}
+
+func (s *SubtreeMaxMinHeap) unlockRange(lo, hi int) {
+	for i := lo; i <= hi && i < len(s.nodes); i++ {
+		s.nodes[i].lk.Unlock()
+	}
+}
+
+// index of the min item among i and its children, caller holds all three
+func (s *SubtreeMaxMinHeap) indexOfMin(i int) int {
+	minIndex := i
+	for j := 2 * i; j < len(s.nodes) && j < 2*i+2; j++ {
+		if s.nodes[j].item == nil {
+			continue
+		}
+		if minIndex == i || s.smaller(j, minIndex) {
+			minIndex = j
+		}
+	}
+	return minIndex
+}
+
+// Take the item out of the last slot. The caller holds the root so no new
+// slots can be reserved, but the last slot may still be reserved by an insert
+// that entered before us. That insert is below the root and never waits on
+// us, so spin until it lands.
+func (s *SubtreeMaxMinHeap) takeLast(last int) *filter.FilterItem {
+	for {
+		s.lock(last)
+		item := s.nodes[last].item
+		if item != nil {
+			s.nodes[last].item = nil
+			s.unlock(last)
+			return item
+		}
+		s.unlock(last)
+		runtime.Gosched()
+	}
+}
+
+// Walk the path from the root to target, holding the root lock on entry.
+// Max levels keep the bigger of the node and the carried item, min levels the
+// smaller; whatever is left over is carried one level down. Every node above
+// the carried item therefore bounds it correctly, which still holds after the
+// operations behind us rearrange those nodes.
+func (s *SubtreeMaxMinHeap) insertTopDown(item *filter.FilterItem, target int) {
+	depth := bits.Len(uint(target)) - 1
+	i := 1
+	for d := 1; d <= depth; d++ {
+		if isMaxLevel(i) {
+			if s.nodes[i].item.GetScore() < item.GetScore() {
+				s.nodes[i].item, item = item, s.nodes[i].item
+				s.place(i)
+			}
+		} else {
+			if item.GetScore() < s.nodes[i].item.GetScore() {
+				s.nodes[i].item, item = item, s.nodes[i].item
+				s.place(i)
+			}
+		}
+
+		next := target >> (depth - d)
+		s.lock(next)
+		s.unlock(i)
+		i = next
+	}
+
+	s.nodes[i].item = item
+	s.place(i)
+	s.unlock(i)
+}
+
+func (s *SubtreeMaxMinHeap) evict(item *filter.FilterItem) {
+	if s.onEvict != nil {
+		s.onEvict(item)
+	}
+}
+
+// The heap is full, swap out the min for item if item is bigger.
+// The caller holds the root lock, it is released before returning.
+func (s *SubtreeMaxMinHeap) replaceMin(item *filter.FilterItem, size int) FilterInsertResult {
+	if size == 1 {
+		// one-element heap, the root is also the min
+		res := FilterInsertResult{Outcome: InsertRejected}
+		if min := s.nodes[1].item; min.GetScore() < item.GetScore() {
+			res = FilterInsertResult{Outcome: InsertEvicted, Evicted: min}
+			s.forget(min)
+			s.nodes[1].item = item
+			s.place(1)
+			s.evict(min)
+		}
+		s.unlock(1)
+		return res
+	}
+
+	s.lockRange(2, 3)
+	toReplace := s.indexOfMin(1)
+	evicted := s.nodes[toReplace].item
+	if item.GetScore() <= evicted.GetScore() {
+		// don't insert this item
+		s.unlockRange(1, 3)
+		return FilterInsertResult{Outcome: InsertRejected}
+	}
+
+	// a new max goes to the root, the old root then fills the hole
+	s.forget(evicted)
+	if s.nodes[1].item.GetScore() < item.GetScore() {
+		s.nodes[1].item, item = item, s.nodes[1].item
+		s.place(1)
+	}
+	s.nodes[toReplace].item = item
+	s.place(toReplace)
+	s.evict(evicted)
+
+	s.unlock(1)
+	s.unlockRange(5-toReplace, 5-toReplace) // the sibling of toReplace
+	s.percolateDown(toReplace)
+	return FilterInsertResult{Outcome: InsertEvicted, Evicted: evicted}
+}
+
+// The caller holds the lock on i, it is released before returning
+func (s *SubtreeMaxMinHeap) percolateDown(i int) {
+	for i != 0 {
+		if isMaxLevel(i) {
+			i = s.percolateDownMax(i)
+		} else {
+			i = s.percolateDownMin(i)
+		}
+	}
+}
+
+// Locks the children and grandchildren of i, moves i one step down the heap
+// and returns the node to continue from (still locked), or 0 when done.
+func (s *SubtreeMaxMinHeap) percolateDownMax(i int) int {
+	s.lockRange(2*i, 2*i+1)
+	s.lockRange(4*i, 4*i+3)
+
+	next := 0
+	m := s.largestChildOrGrandchild(i)
+	if m > i*2+1 {
+		// m is a grandchild of i
+		if s.smaller(i, m) { // h[m] > h[i]
+			s.swap(m, i)
+			parentOfM := m / 2
+			if s.smaller(m, parentOfM) {
+				s.swap(m, parentOfM)
+			}
+			next = m
+		}
+	} else if m != 0 && s.smaller(i, m) {
+		s.swap(m, i)
+	}
+
+	s.unlockNeighborhood(i, next)
+	return next
+}
+
+// Mirror of percolateDownMax
+func (s *SubtreeMaxMinHeap) percolateDownMin(i int) int {
+	s.lockRange(2*i, 2*i+1)
+	s.lockRange(4*i, 4*i+3)
+
+	next := 0
+	m := s.smallestChildOrGrandchild(i)
+	if m > i*2+1 {
+		// m is a grandchild of i
+		if s.smaller(m, i) { // h[m] < h[i]
+			s.swap(m, i)
+			parentOfM := m / 2
+			if s.smaller(parentOfM, m) {
+				s.swap(m, parentOfM)
+			}
+			next = m
+		}
+	} else if m != 0 && s.smaller(m, i) {
+		s.swap(m, i)
+	}
+
+	s.unlockNeighborhood(i, next)
+	return next
+}
+
+// unlock i, its children and its grandchildren, except for keep
+func (s *SubtreeMaxMinHeap) unlockNeighborhood(i, keep int) {
+	s.unlock(i)
+	for _, j := range [6]int{2 * i, 2*i + 1, 4 * i, 4*i + 1, 4*i + 2, 4*i + 3} {
+		if j != keep && j < len(s.nodes) {
+			s.unlock(j)
+		}
+	}
+}
+
+func (s *SubtreeMaxMinHeap) largestChildOrGrandchild(i int) int {
+	maxIndex := 0
+	for _, j := range [6]int{2 * i, 2*i + 1, 4 * i, 4*i + 1, 4*i + 2, 4*i + 3} {
+		if j >= len(s.nodes) || s.nodes[j].item == nil {
+			continue
+		}
+		if maxIndex == 0 || s.smaller(maxIndex, j) {
+			maxIndex = j
+		}
+	}
+	return maxIndex
+}
+
+func (s *SubtreeMaxMinHeap) smallestChildOrGrandchild(i int) int {
+	minIndex := 0
+	for _, j := range [6]int{2 * i, 2*i + 1, 4 * i, 4*i + 1, 4*i + 2, 4*i + 3} {
+		if j >= len(s.nodes) || s.nodes[j].item == nil {
+			continue
+		}
+		if minIndex == 0 || s.smaller(j, minIndex) {
+			minIndex = j
+		}
+	}
+	return minIndex
+}
+
+func (s *SubtreeMaxMinHeap) swap(i, j int) {
+	s.nodes[i].item, s.nodes[j].item = s.nodes[j].item, s.nodes[i].item
+	s.place(i)
+	s.place(j)
+}
+
+// The item at i changed its rank, move it to where it belongs. Only with
+// keyLk held alone, nothing is locked and every slot is filled.
+func (s *SubtreeMaxMinHeap) fix(i int) {
+	bubbleUp(trickleDown(i, int(s.size.Load()), s.smaller, s.swap), s.smaller, s.swap)
+}
+
+// whether item has a key that is there already, caller holds the root
+func (s *SubtreeMaxMinHeap) indexed(item *filter.FilterItem) bool {
+	k := item.GetKey()
+	if k == "" {
+		return false
+	}
+	s.idxLk.Lock()
+	defer s.idxLk.Unlock()
+	_, ok := s.index[k]
+	return ok
+}
+
+// record that the item at i is there
+func (s *SubtreeMaxMinHeap) place(i int) {
+	s.track(s.nodes[i].item, i)
+}
+
+// record that item is at i, or headed there
+func (s *SubtreeMaxMinHeap) track(item *filter.FilterItem, i int) {
+	if k := item.GetKey(); k != "" {
+		s.idxLk.Lock()
+		s.index[k] = i
+		s.idxLk.Unlock()
+	}
+}
+
+// the item left the heap
+func (s *SubtreeMaxMinHeap) forget(item *filter.FilterItem) {
+	if k := item.GetKey(); k != "" {
+		s.idxLk.Lock()
+		delete(s.index, k)
+		s.idxLk.Unlock()
+	}
+}
+
+// rebuild the index after the heap was rebuilt, caller holds every lock
+func (s *SubtreeMaxMinHeap) reindex() {
+	s.idxLk.Lock()
+	s.index = make(map[string]int)
+	s.idxLk.Unlock()
+	for i := 1; i <= int(s.size.Load()); i++ {
+		s.place(i)
+	}
+}
+
+func (s *SubtreeMaxMinHeap) smaller(a, b int) bool {
+	return s.nodes[a].item.GetScore() < s.nodes[b].item.GetScore()
+}
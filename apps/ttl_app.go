@@ -0,0 +1,117 @@
+package apps
+
+import (
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+)
+
+/*
+ * TTL App
+ *
+ * Turns the TTL an item comes with, or the filter's default TTL, into an
+ * absolute expiry before the item goes any further, so the write-ahead log
+ * and the backups get when it expires rather than how long it had left, and
+ * a replay doesn't extend its life. The heaps drop the items once they
+ * expire, see TTLMaxMinHeap. For a filter with score decay it stamps the
+ * items with their arrival the same way.
+ */
+
+type TTLCDSFApp struct {
+	app        ConcurrentDataStreamFilter
+	defaultTTL time.Duration // 0 keeps items without a TTL forever
+
+	stampArrival bool // set inserted_unix_ms, for score decay
+}
+
+func NewTTLCDSFApp(app ConcurrentDataStreamFilter, defaultTTL time.Duration) *TTLCDSFApp {
+	return &TTLCDSFApp{
+		app:        app,
+		defaultTTL: defaultTTL,
+	}
+}
+
+func (s *TTLCDSFApp) Insert(item *filter.FilterItem) (FilterInsertResult, error) {
+	s.stamp(item, now())
+	return s.app.Insert(item)
+}
+
+func (s *TTLCDSFApp) InsertBatch(items []*filter.FilterItem) error {
+	t := now()
+	for _, item := range items {
+		s.stamp(item, t)
+	}
+	return s.app.InsertBatch(items)
+}
+
+func (s *TTLCDSFApp) GetMax() (*filter.FilterItem, error) {
+	return s.app.GetMax()
+}
+
+func (s *TTLCDSFApp) GetMin() (*filter.FilterItem, error) {
+	return s.app.GetMin()
+}
+
+func (s *TTLCDSFApp) RemoveMax() (*filter.FilterItem, error) {
+	return s.app.RemoveMax()
+}
+
+func (s *TTLCDSFApp) RemoveMin() (*filter.FilterItem, error) {
+	return s.app.RemoveMin()
+}
+
+func (s *TTLCDSFApp) RemoveTopK(k int) ([]*filter.FilterItem, error) {
+	return s.app.RemoveTopK(k)
+}
+
+func (s *TTLCDSFApp) RemoveAbove(threshold float32, limit int) ([]*filter.FilterItem, error) {
+	return s.app.RemoveAbove(threshold, limit)
+}
+
+func (s *TTLCDSFApp) GetSize() int {
+	return s.app.GetSize()
+}
+
+func (s *TTLCDSFApp) IsFull() bool {
+	return s.app.IsFull()
+}
+
+func (s *TTLCDSFApp) Clear() error {
+	return s.app.Clear()
+}
+
+func (s *TTLCDSFApp) Close() error {
+	return s.app.Close()
+}
+
+func (s *TTLCDSFApp) Unwrap() ConcurrentDataStreamFilter {
+	return s.app
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+// Set the item's expiry from its TTL or the default, in place: the filter
+// owns what it is given, and the leasing app above us tells its items apart
+// by pointer. An item with an expiry (or arrival) already, e.g. one back
+// from a lease, keeps it.
+func (s *TTLCDSFApp) stamp(item *filter.FilterItem, t int64) {
+	if item == nil {
+		return
+	}
+	if s.stampArrival && item.GetInsertedUnixMs() == 0 {
+		item.InsertedUnixMs = t
+	}
+	if item.GetExpiresUnixMs() != 0 {
+		return
+	}
+	ttl := time.Duration(item.GetTtlMs()) * time.Millisecond
+	if ttl <= 0 {
+		ttl = s.defaultTTL
+	}
+	if ttl > 0 {
+		item.ExpiresUnixMs = t + ttl.Milliseconds()
+		item.TtlMs = 0
+	}
+}
@@ -0,0 +1,290 @@
+package apps
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+)
+
+// slots a reaper pass looks at per lock acquisition
+const reapScan = 256
+
+// Expiry decorator around any filter heap. Items past their
+// expires_unix_ms are never handed out: the getters and removals drop
+// expired items they find at either end, and inserts turn down items that
+// are expired already. Reap drops the rest, a few slots at a time if the
+// wrapped heap can remove from the middle, only off the ends otherwise.
+// Expiry goes by the wall clock, so a removal replayed later may find other
+// items expired, which is why the write-ahead log and the backups get the
+// items a removal took out rather than the removal.
+type TTLMaxMinHeap struct {
+	heap     FilterHeap
+	onExpire func(item *filter.FilterItem)
+	done     chan struct{} // closed to stop ReapEvery
+}
+
+// A heap that can take items out from anywhere, see
+// CoarseRWMaxMinHeap.RemoveWhere
+type SweepingMaxMinHeap[T any] interface {
+	RemoveWhere(drop func(item T) bool, from, scan int) ([]T, int)
+}
+
+type SweepingFilterHeap = SweepingMaxMinHeap[*filter.FilterItem]
+
+// ctor
+func NewTTLMaxMinHeap(heap FilterHeap) *TTLMaxMinHeap {
+	return &TTLMaxMinHeap{
+		heap: heap,
+		done: make(chan struct{}),
+	}
+}
+
+// rejects an item that is expired already
+func (s *TTLMaxMinHeap) Insert(item *filter.FilterItem) FilterInsertResult {
+	if hasExpired(item, now()) {
+		s.expire(item)
+		return FilterInsertResult{Outcome: InsertRejected}
+	}
+	return s.heap.Insert(item)
+}
+
+// the batch goes in without the items that are expired already
+func (s *TTLMaxMinHeap) InsertBatch(items []*filter.FilterItem) bool {
+	t := now()
+	live := items[:0:0]
+	for _, item := range items {
+		if hasExpired(item, t) {
+			s.expire(item)
+		} else {
+			live = append(live, item)
+		}
+	}
+	return s.heap.InsertBatch(live)
+}
+
+func (s *TTLMaxMinHeap) GetMax() *filter.FilterItem {
+	return s.get(true)
+}
+
+func (s *TTLMaxMinHeap) GetMin() *filter.FilterItem {
+	return s.get(false)
+}
+
+func (s *TTLMaxMinHeap) RemoveMax() *filter.FilterItem {
+	return s.remove(true)
+}
+
+func (s *TTLMaxMinHeap) RemoveMin() *filter.FilterItem {
+	return s.remove(false)
+}
+
+// expired items on the way are dropped and don't count towards k
+func (s *TTLMaxMinHeap) RemoveTopK(k int) []*filter.FilterItem {
+	if k <= 0 {
+		return nil
+	}
+	return s.removeLive(k, s.heap.RemoveTopK)
+}
+
+func (s *TTLMaxMinHeap) RemoveAbove(threshold *filter.FilterItem, limit int) []*filter.FilterItem {
+	return s.removeLive(limit, func(n int) []*filter.FilterItem {
+		return s.heap.RemoveAbove(threshold, n)
+	})
+}
+
+func (s *TTLMaxMinHeap) Clear() bool {
+	return s.heap.Clear()
+}
+
+// expired items count until they're reaped
+func (s *TTLMaxMinHeap) Size() int {
+	return s.heap.Size()
+}
+
+func (s *TTLMaxMinHeap) IsEmpty() bool {
+	return s.heap.IsEmpty()
+}
+
+func (s *TTLMaxMinHeap) IsFull() bool {
+	return s.heap.IsFull()
+}
+
+func (s *TTLMaxMinHeap) OnEvict(f func(item *filter.FilterItem)) {
+	s.heap.OnEvict(f)
+}
+
+// f gets every item dropped for being expired, set it before sharing the
+// heap
+func (s *TTLMaxMinHeap) OnExpire(f func(item *filter.FilterItem)) {
+	s.onExpire = f
+}
+
+// Drop every expired item, returns how many. The wrapped heap is only
+// locked for reapScan slots at a time.
+func (s *TTLMaxMinHeap) Reap() int {
+	t := now()
+	expired := func(item *filter.FilterItem) bool { return hasExpired(item, t) }
+
+	sweeper, ok := sweepingHeap(s.heap)
+	if !ok {
+		// only the ends can be reached
+		n := 0
+		for _, max := range []bool{true, false} {
+			for hasExpired(s.peek(max), t) && s.dropEnd(max, t) {
+				n++
+			}
+		}
+		return n
+	}
+
+	n := 0
+	for from := 1; from != 0; {
+		var removed []*filter.FilterItem
+		removed, from = sweeper.RemoveWhere(expired, from, reapScan)
+		for _, item := range removed {
+			s.expire(item)
+		}
+		n += len(removed)
+		// let the inserts and removals waiting on the lock in
+		runtime.Gosched()
+	}
+	return n
+}
+
+// Reap every interval until Close
+func (s *TTLMaxMinHeap) ReapEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Reap()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// stop ReapEvery
+func (s *TTLMaxMinHeap) Close() {
+	close(s.done)
+}
+
+func (s *TTLMaxMinHeap) Unwrap() FilterHeap {
+	return s.heap
+}
+
+// Whether item is past its expires_unix_ms, which a filter drops it for
+func Expired(item *filter.FilterItem) bool {
+	return hasExpired(item, now())
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+func now() int64 {
+	return time.Now().UnixMilli()
+}
+
+// item was to expire at or before t
+func hasExpired(item *filter.FilterItem, t int64) bool {
+	e := item.GetExpiresUnixMs()
+	return e != 0 && e <= t
+}
+
+// the heap if it can sweep, a flat combining decorator only if the heap it
+// wraps can
+func sweepingHeap(heap FilterHeap) (SweepingFilterHeap, bool) {
+	if fc, ok := heap.(*FlatCombiningMaxMinHeap[*filter.FilterItem]); ok {
+		if _, ok := fc.Unwrap().(SweepingFilterHeap); !ok {
+			return nil, false
+		}
+	}
+	sweeper, ok := heap.(SweepingFilterHeap)
+	return sweeper, ok
+}
+
+func (s *TTLMaxMinHeap) expire(item *filter.FilterItem) {
+	if s.onExpire != nil {
+		s.onExpire(item)
+	}
+}
+
+func (s *TTLMaxMinHeap) peek(max bool) *filter.FilterItem {
+	if max {
+		return s.heap.GetMax()
+	}
+	return s.heap.GetMin()
+}
+
+func (s *TTLMaxMinHeap) get(max bool) *filter.FilterItem {
+	for {
+		item := s.peek(max)
+		t := now()
+		if !hasExpired(item, t) {
+			return item
+		}
+		s.dropEnd(max, t)
+	}
+}
+
+func (s *TTLMaxMinHeap) remove(max bool) *filter.FilterItem {
+	for {
+		var item *filter.FilterItem
+		if max {
+			item = s.heap.RemoveMax()
+		} else {
+			item = s.heap.RemoveMin()
+		}
+		if !hasExpired(item, now()) {
+			return item
+		}
+		s.expire(item)
+	}
+}
+
+// Remove what's missing of limit live items (0 for all) until there are
+// that many or the wrapped heap runs out, dropping the expired ones
+func (s *TTLMaxMinHeap) removeLive(limit int, remove func(n int) []*filter.FilterItem) []*filter.FilterItem {
+	var live []*filter.FilterItem
+	for {
+		n := 0
+		if limit > 0 {
+			n = limit - len(live)
+		}
+		removed := remove(n)
+		t := now()
+		for _, item := range removed {
+			if hasExpired(item, t) {
+				s.expire(item)
+			} else {
+				live = append(live, item)
+			}
+		}
+		if limit == 0 || len(removed) < n || len(live) == limit {
+			return live
+		}
+	}
+}
+
+// Take the max (or min) out if it is expired, false if it isn't. A live
+// item that got there in the meantime goes back in.
+func (s *TTLMaxMinHeap) dropEnd(max bool, t int64) bool {
+	var item *filter.FilterItem
+	if max {
+		item = s.heap.RemoveMax()
+	} else {
+		item = s.heap.RemoveMin()
+	}
+	if item == nil {
+		return false
+	}
+	if !hasExpired(item, t) {
+		s.heap.Insert(item)
+		return false
+	}
+	s.expire(item)
+	return true
+}
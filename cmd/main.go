@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"math"
 	"os"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Jfroel/cdsf-microservice/apps"
 	"github.com/Jfroel/cdsf-microservice/services"
 )
 
@@ -23,17 +28,49 @@ func main() {
 	var (
 		proxyPort      = flag.Int("proxyport", 9090, "proxy server port")
 		filterPort     = flag.Int("filterport", 9091, "filter service port")
-		filterAddr     = flag.String("filteraddr", "filter:9091", "filter service address")
+		filterAddr     = flag.String("filteraddr", "filter:9091", "filter service address, a comma separated list makes the proxy coordinate them as shards")
+		bands          = flag.String("bands", "", "score partitioned filters for the proxy, highest band first: addr@lo,addr@lo,...,addr")
 		filterCapacity = flag.Int("filter_capacity", levelToSize(18), "maximum number of items allowed in the filter service")
-		filterType     = flag.String("filter_type", "subtree", "locking style for the filter: coarseRW or subtree")
+		filterType     = flag.String("filter_type", "subtree", "locking style for the filter: coarseRW, soa, subtree, skiplist, multiqueue or sharded")
+		shardType      = flag.String("shard_type", "coarseRW", "locking style of each shard when -filter_type is sharded")
+		shards         = flag.Int("shards", 8, "number of heaps the sharded filter spreads items over, usually one per cpu")
+		relaxation     = flag.Int("relaxation", 2, "multiqueue heaps per cpu, more heaps trade ordering for throughput")
+		flatCombining  = flag.Bool("flat_combining", false, "batch inserts and removals on the heap (or each shard) through a flat combiner")
 		cpus           = flag.Int("cpus", 8, "number of cpus the filter can use")
+
+		dataDir          = flag.String("data_dir", "", "directory for the filter's write-ahead log and snapshots, empty keeps the filter in memory only")
+		fsync            = flag.String("fsync", "interval", "when the write-ahead log is fsynced: always (once for the calls that come in during the one before), interval or never")
+		fsyncInterval    = flag.Duration("fsync_interval", time.Second, "how often the write-ahead log is fsynced with -fsync interval")
+		snapshotInterval = flag.Duration("snapshot_interval", 5*time.Minute, "how often the write-ahead log is compacted into a snapshot, 0 never")
+
+		upstreamAddr     = flag.String("upstream", "filter:9091", "filter service the forwarder drains its best items into")
+		forwardRate      = flag.Float64("forward_rate", 0, "items per second the forwarder sends below -forward_threshold, 0 is none if a threshold is set and unpaced otherwise")
+		forwardThreshold = flag.String("forward_threshold", "", "items scoring at least this are forwarded right away, empty for no threshold")
+		forwardBatch     = flag.Int("forward_batch", 64, "most items the forwarder sends upstream in one call")
+		forwardBackoff   = flag.Duration("forward_backoff", 10*time.Second, "longest wait between retries while the upstream is down")
+
+		role        = flag.String("role", "standalone", "replication role of the filter: standalone, primary or backup")
+		primaryAddr = flag.String("primary_addr", "filter-primary:9091", "primary filter address a backup follows")
+
+		evictLog     = flag.String("evict_log", "", "file the filter appends its evicted items to as JSON lines, empty for none")
+		evictForward = flag.String("evict_forward", "", "filter service the filter inserts its evicted items into, empty for none")
+
+		defaultTTL   = flag.Duration("default_ttl", 0, "how long items that come without a TTL stay in the filter, 0 forever")
+		reapInterval = flag.Duration("reap_interval", time.Second, "how often expired items are swept out of the filter, 0 only drops them when they reach the top or bottom")
+
+		halfLife = flag.Duration("half_life", 0, "scores decay by half every half_life, so old items age out of the top, 0 never, the sharded filter type won't start with one")
+
+		keyed = flag.Bool("keyed", false, "clients send keyed items, the filter won't start unless -filter_type is coarseRW or subtree, which index keys")
 	)
 
-	// Parse the flags
-	flag.Parse()
+	// Parse the flags, they come after the command
+	if len(os.Args) < 2 {
+		log.Fatalf("usage: %s proxy|filter|forwarder [flags]", os.Args[0])
+	}
+	var cmd = os.Args[1]
+	flag.CommandLine.Parse(os.Args[2:])
 
 	var srv server
-	var cmd = os.Args[1]
 
 	runtime.GOMAXPROCS(*cpus)
 
@@ -41,18 +78,92 @@ func main() {
 	switch cmd {
 	case "proxy":
 		// Create a new frontend service with the specified ports and addresses
+		if *bands != "" {
+			p, err := services.NewBandProxy(*proxyPort, *bands, "1")
+			if err != nil {
+				log.Fatalf("bad -bands: %v", err)
+			}
+			srv = p
+			break
+		}
 		srv = services.NewProxy(
 			*proxyPort,
-			*filterAddr,
+			strings.Split(*filterAddr, ","),
 			"1",
 		)
-	case "filter":
-		srv = services.NewFilter(
-			"filter",
+	case "filter", "forwarder":
+		f := services.NewFilter(
+			cmd,
 			*filterPort,
-			*filterType,
-			*filterCapacity,
+			apps.NewFilterApp(apps.Config{
+				FilterType: *filterType,
+				Capacity:   *filterCapacity,
+				ShardType:  *shardType,
+				Shards:     *shards,
+				Relaxation: *relaxation,
+
+				FlatCombining: *flatCombining,
+
+				DataDir:          *dataDir,
+				Fsync:            *fsync,
+				FsyncInterval:    *fsyncInterval,
+				SnapshotInterval: *snapshotInterval,
+
+				Role: *role,
+
+				DefaultTTL:   *defaultTTL,
+				ReapInterval: *reapInterval,
+
+				HalfLife: *halfLife,
+
+				Keyed: *keyed,
+			}),
 		)
+		if *evictLog != "" {
+			l, err := services.OpenEvictionLog(*evictLog)
+			if err != nil {
+				log.Fatalf("failed to open the eviction log: %v", err)
+			}
+			f.AddEvictionSink(l)
+		}
+		if *evictForward != "" {
+			fwd := services.NewEvictionForwarder(*evictForward)
+			f.AddEvictionSink(fwd)
+			go func() {
+				log.Printf("forwarding evictions to %s", *evictForward)
+				if err := fwd.Run(context.Background()); err != nil {
+					log.Fatalf("eviction forwarder error: %v", err)
+				}
+			}()
+		}
+		if *role == "backup" {
+			if err := f.FollowPrimary(context.Background(), *primaryAddr); err != nil {
+				log.Fatalf("failed to follow the primary: %v", err)
+			}
+		}
+		if cmd == "forwarder" {
+			cfg := services.ForwarderConfig{
+				Rate:       *forwardRate,
+				Batch:      *forwardBatch,
+				MaxBackoff: *forwardBackoff,
+			}
+			if *forwardThreshold != "" {
+				threshold, err := strconv.ParseFloat(*forwardThreshold, 32)
+				if err != nil {
+					log.Fatalf("bad -forward_threshold: %v", err)
+				}
+				t := float32(threshold)
+				cfg.Threshold = &t
+			}
+			fwd := services.NewForwarder(f, *upstreamAddr, cfg)
+			go func() {
+				log.Printf("forwarding to %s", *upstreamAddr)
+				if err := fwd.Run(context.Background()); err != nil {
+					log.Fatalf("forwarder error: %v", err)
+				}
+			}()
+		}
+		srv = f
 	default:
 		// If an unknown command is provided, log an error and exit
 		log.Fatalf("unknown cmd: %s", cmd)
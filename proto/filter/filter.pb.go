@@ -20,6 +20,115 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// what the filter did with an inserted item
+type InsertOutcome int32
+
+const (
+	InsertOutcome_STORED   InsertOutcome = 0 // there was room for it
+	InsertOutcome_REJECTED InsertOutcome = 1 // at or below the min of a full filter, dropped
+	InsertOutcome_EVICTED  InsertOutcome = 2 // stored, pushing the min out
+	InsertOutcome_UPDATED  InsertOutcome = 3 // replaced the item with the same key
+	InsertOutcome_FAILED   InsertOutcome = 4 // not taken at all, the insert returned an error
+)
+
+// Enum value maps for InsertOutcome.
+var (
+	InsertOutcome_name = map[int32]string{
+		0: "STORED",
+		1: "REJECTED",
+		2: "EVICTED",
+		3: "UPDATED",
+		4: "FAILED",
+	}
+	InsertOutcome_value = map[string]int32{
+		"STORED":   0,
+		"REJECTED": 1,
+		"EVICTED":  2,
+		"UPDATED":  3,
+		"FAILED":   4,
+	}
+)
+
+func (x InsertOutcome) Enum() *InsertOutcome {
+	p := new(InsertOutcome)
+	*p = x
+	return p
+}
+
+func (x InsertOutcome) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (InsertOutcome) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_filter_filter_proto_enumTypes[0].Descriptor()
+}
+
+func (InsertOutcome) Type() protoreflect.EnumType {
+	return &file_proto_filter_filter_proto_enumTypes[0]
+}
+
+func (x InsertOutcome) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use InsertOutcome.Descriptor instead.
+func (InsertOutcome) EnumDescriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{0}
+}
+
+// why the filter threw an item away
+type EvictReason int32
+
+const (
+	EvictReason_CAPACITY EvictReason = 0 // pushed out of a full filter by better items
+	EvictReason_LEASE    EvictReason = 1 // trimmed so the outstanding leases fit the capacity
+	EvictReason_EXPIRED  EvictReason = 2 // outlived its TTL
+	EvictReason_REFUSED  EvictReason = 3 // turned down by the upstream a forwarder sends to
+)
+
+// Enum value maps for EvictReason.
+var (
+	EvictReason_name = map[int32]string{
+		0: "CAPACITY",
+		1: "LEASE",
+		2: "EXPIRED",
+		3: "REFUSED",
+	}
+	EvictReason_value = map[string]int32{
+		"CAPACITY": 0,
+		"LEASE":    1,
+		"EXPIRED":  2,
+		"REFUSED":  3,
+	}
+)
+
+func (x EvictReason) Enum() *EvictReason {
+	p := new(EvictReason)
+	*p = x
+	return p
+}
+
+func (x EvictReason) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EvictReason) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_filter_filter_proto_enumTypes[1].Descriptor()
+}
+
+func (EvictReason) Type() protoreflect.EnumType {
+	return &file_proto_filter_filter_proto_enumTypes[1]
+}
+
+func (x EvictReason) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EvictReason.Descriptor instead.
+func (EvictReason) EnumDescriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{1}
+}
+
 type FilterItem struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -27,6 +136,19 @@ type FilterItem struct {
 
 	Score float32 `protobuf:"fixed32,1,opt,name=score,proto3" json:"score,omitempty"` // [0, 1] 0% to 100%
 	Data  []byte  `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	// optional, a filter holds at most one item per key and inserting a key
+	// it already has replaces that item
+	Key string `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`
+	// optional, the item expires ttl_ms after it's inserted or at
+	// expires_unix_ms. The filter turns a TTL into an expiry on insert, an
+	// item without either gets the filter's default TTL, if any.
+	TtlMs         int64 `protobuf:"varint,4,opt,name=ttl_ms,json=ttlMs,proto3" json:"ttl_ms,omitempty"`
+	ExpiresUnixMs int64 `protobuf:"varint,5,opt,name=expires_unix_ms,json=expiresUnixMs,proto3" json:"expires_unix_ms,omitempty"`
+	// set by a filter with score decay: when the item came in, and on items it
+	// hands out the score decayed by the item's age, which is what the filter
+	// orders by. score stays the score the item came in with.
+	InsertedUnixMs int64    `protobuf:"varint,6,opt,name=inserted_unix_ms,json=insertedUnixMs,proto3" json:"inserted_unix_ms,omitempty"`
+	EffectiveScore *float32 `protobuf:"fixed32,7,opt,name=effective_score,json=effectiveScore,proto3,oneof" json:"effective_score,omitempty"`
 }
 
 func (x *FilterItem) Reset() {
@@ -75,6 +197,41 @@ func (x *FilterItem) GetData() []byte {
 	return nil
 }
 
+func (x *FilterItem) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *FilterItem) GetTtlMs() int64 {
+	if x != nil {
+		return x.TtlMs
+	}
+	return 0
+}
+
+func (x *FilterItem) GetExpiresUnixMs() int64 {
+	if x != nil {
+		return x.ExpiresUnixMs
+	}
+	return 0
+}
+
+func (x *FilterItem) GetInsertedUnixMs() int64 {
+	if x != nil {
+		return x.InsertedUnixMs
+	}
+	return 0
+}
+
+func (x *FilterItem) GetEffectiveScore() float32 {
+	if x != nil && x.EffectiveScore != nil {
+		return *x.EffectiveScore
+	}
+	return 0
+}
+
 type InsertItemRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -122,12 +279,18 @@ func (x *InsertItemRequest) GetItem() *FilterItem {
 	return nil
 }
 
+// threshold is the score an item has to beat to get in, the filter's min
+// once it is full, unset (0) while it has room. evicted is the item pushed
+// out by an EVICTED insert.
 type InsertItemResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Success bool `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Success   bool          `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Threshold *float32      `protobuf:"fixed32,2,opt,name=threshold,proto3,oneof" json:"threshold,omitempty"`
+	Outcome   InsertOutcome `protobuf:"varint,3,opt,name=outcome,proto3,enum=filter.InsertOutcome" json:"outcome,omitempty"`
+	Evicted   *FilterItem   `protobuf:"bytes,4,opt,name=evicted,proto3" json:"evicted,omitempty"`
 }
 
 func (x *InsertItemResponse) Reset() {
@@ -169,6 +332,193 @@ func (x *InsertItemResponse) GetSuccess() bool {
 	return false
 }
 
+func (x *InsertItemResponse) GetThreshold() float32 {
+	if x != nil && x.Threshold != nil {
+		return *x.Threshold
+	}
+	return 0
+}
+
+func (x *InsertItemResponse) GetOutcome() InsertOutcome {
+	if x != nil {
+		return x.Outcome
+	}
+	return InsertOutcome_STORED
+}
+
+func (x *InsertItemResponse) GetEvicted() *FilterItem {
+	if x != nil {
+		return x.Evicted
+	}
+	return nil
+}
+
+type InsertItemsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items []*FilterItem `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+}
+
+func (x *InsertItemsRequest) Reset() {
+	*x = InsertItemsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *InsertItemsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertItemsRequest) ProtoMessage() {}
+
+func (x *InsertItemsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertItemsRequest.ProtoReflect.Descriptor instead.
+func (*InsertItemsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *InsertItemsRequest) GetItems() []*FilterItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type InsertItemsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Success   bool     `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Threshold *float32 `protobuf:"fixed32,2,opt,name=threshold,proto3,oneof" json:"threshold,omitempty"`
+}
+
+func (x *InsertItemsResponse) Reset() {
+	*x = InsertItemsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *InsertItemsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertItemsResponse) ProtoMessage() {}
+
+func (x *InsertItemsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertItemsResponse.ProtoReflect.Descriptor instead.
+func (*InsertItemsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *InsertItemsResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *InsertItemsResponse) GetThreshold() float32 {
+	if x != nil && x.Threshold != nil {
+		return *x.Threshold
+	}
+	return 0
+}
+
+// summary of an InsertStream, sent once the producer closes the stream
+type InsertStreamResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Accepted int64 `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"` // items stored, including the ones that evicted another item
+	Rejected int64 `protobuf:"varint,2,opt,name=rejected,proto3" json:"rejected,omitempty"` // items at or below the min of a full filter
+	Evicted  int64 `protobuf:"varint,3,opt,name=evicted,proto3" json:"evicted,omitempty"`   // items pushed out to make room
+}
+
+func (x *InsertStreamResponse) Reset() {
+	*x = InsertStreamResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *InsertStreamResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertStreamResponse) ProtoMessage() {}
+
+func (x *InsertStreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertStreamResponse.ProtoReflect.Descriptor instead.
+func (*InsertStreamResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *InsertStreamResponse) GetAccepted() int64 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
+}
+
+func (x *InsertStreamResponse) GetRejected() int64 {
+	if x != nil {
+		return x.Rejected
+	}
+	return 0
+}
+
+func (x *InsertStreamResponse) GetEvicted() int64 {
+	if x != nil {
+		return x.Evicted
+	}
+	return 0
+}
+
 type GetMaxItemRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -178,7 +528,7 @@ type GetMaxItemRequest struct {
 func (x *GetMaxItemRequest) Reset() {
 	*x = GetMaxItemRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_filter_filter_proto_msgTypes[3]
+		mi := &file_proto_filter_filter_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -191,7 +541,7 @@ func (x *GetMaxItemRequest) String() string {
 func (*GetMaxItemRequest) ProtoMessage() {}
 
 func (x *GetMaxItemRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_filter_filter_proto_msgTypes[3]
+	mi := &file_proto_filter_filter_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -204,7 +554,7 @@ func (x *GetMaxItemRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetMaxItemRequest.ProtoReflect.Descriptor instead.
 func (*GetMaxItemRequest) Descriptor() ([]byte, []int) {
-	return file_proto_filter_filter_proto_rawDescGZIP(), []int{3}
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{6}
 }
 
 type GetMaxItemResponse struct {
@@ -218,7 +568,7 @@ type GetMaxItemResponse struct {
 func (x *GetMaxItemResponse) Reset() {
 	*x = GetMaxItemResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_filter_filter_proto_msgTypes[4]
+		mi := &file_proto_filter_filter_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -231,7 +581,7 @@ func (x *GetMaxItemResponse) String() string {
 func (*GetMaxItemResponse) ProtoMessage() {}
 
 func (x *GetMaxItemResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_filter_filter_proto_msgTypes[4]
+	mi := &file_proto_filter_filter_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -244,7 +594,7 @@ func (x *GetMaxItemResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetMaxItemResponse.ProtoReflect.Descriptor instead.
 func (*GetMaxItemResponse) Descriptor() ([]byte, []int) {
-	return file_proto_filter_filter_proto_rawDescGZIP(), []int{4}
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *GetMaxItemResponse) GetItem() *FilterItem {
@@ -263,7 +613,7 @@ type GetMinItemRequest struct {
 func (x *GetMinItemRequest) Reset() {
 	*x = GetMinItemRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_filter_filter_proto_msgTypes[5]
+		mi := &file_proto_filter_filter_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -276,7 +626,7 @@ func (x *GetMinItemRequest) String() string {
 func (*GetMinItemRequest) ProtoMessage() {}
 
 func (x *GetMinItemRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_filter_filter_proto_msgTypes[5]
+	mi := &file_proto_filter_filter_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -289,7 +639,7 @@ func (x *GetMinItemRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetMinItemRequest.ProtoReflect.Descriptor instead.
 func (*GetMinItemRequest) Descriptor() ([]byte, []int) {
-	return file_proto_filter_filter_proto_rawDescGZIP(), []int{5}
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{8}
 }
 
 type GetMinItemResponse struct {
@@ -303,7 +653,7 @@ type GetMinItemResponse struct {
 func (x *GetMinItemResponse) Reset() {
 	*x = GetMinItemResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_filter_filter_proto_msgTypes[6]
+		mi := &file_proto_filter_filter_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -316,7 +666,7 @@ func (x *GetMinItemResponse) String() string {
 func (*GetMinItemResponse) ProtoMessage() {}
 
 func (x *GetMinItemResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_filter_filter_proto_msgTypes[6]
+	mi := &file_proto_filter_filter_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -329,7 +679,7 @@ func (x *GetMinItemResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetMinItemResponse.ProtoReflect.Descriptor instead.
 func (*GetMinItemResponse) Descriptor() ([]byte, []int) {
-	return file_proto_filter_filter_proto_rawDescGZIP(), []int{6}
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *GetMinItemResponse) GetItem() *FilterItem {
@@ -343,12 +693,15 @@ type RemoveMaxItemRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
+
+	WaitMs   int64    `protobuf:"varint,1,opt,name=wait_ms,json=waitMs,proto3" json:"wait_ms,omitempty"`              // how long to wait for a qualifying item, 0 fails right away
+	MinScore *float32 `protobuf:"fixed32,2,opt,name=min_score,json=minScore,proto3,oneof" json:"min_score,omitempty"` // only take an item scoring at least this
 }
 
 func (x *RemoveMaxItemRequest) Reset() {
 	*x = RemoveMaxItemRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_filter_filter_proto_msgTypes[7]
+		mi := &file_proto_filter_filter_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -361,7 +714,7 @@ func (x *RemoveMaxItemRequest) String() string {
 func (*RemoveMaxItemRequest) ProtoMessage() {}
 
 func (x *RemoveMaxItemRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_filter_filter_proto_msgTypes[7]
+	mi := &file_proto_filter_filter_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -374,7 +727,21 @@ func (x *RemoveMaxItemRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RemoveMaxItemRequest.ProtoReflect.Descriptor instead.
 func (*RemoveMaxItemRequest) Descriptor() ([]byte, []int) {
-	return file_proto_filter_filter_proto_rawDescGZIP(), []int{7}
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *RemoveMaxItemRequest) GetWaitMs() int64 {
+	if x != nil {
+		return x.WaitMs
+	}
+	return 0
+}
+
+func (x *RemoveMaxItemRequest) GetMinScore() float32 {
+	if x != nil && x.MinScore != nil {
+		return *x.MinScore
+	}
+	return 0
 }
 
 type RemoveMaxItemResponse struct {
@@ -388,7 +755,7 @@ type RemoveMaxItemResponse struct {
 func (x *RemoveMaxItemResponse) Reset() {
 	*x = RemoveMaxItemResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_filter_filter_proto_msgTypes[8]
+		mi := &file_proto_filter_filter_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -401,7 +768,7 @@ func (x *RemoveMaxItemResponse) String() string {
 func (*RemoveMaxItemResponse) ProtoMessage() {}
 
 func (x *RemoveMaxItemResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_filter_filter_proto_msgTypes[8]
+	mi := &file_proto_filter_filter_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -414,7 +781,7 @@ func (x *RemoveMaxItemResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RemoveMaxItemResponse.ProtoReflect.Descriptor instead.
 func (*RemoveMaxItemResponse) Descriptor() ([]byte, []int) {
-	return file_proto_filter_filter_proto_rawDescGZIP(), []int{8}
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *RemoveMaxItemResponse) GetItem() *FilterItem {
@@ -428,12 +795,15 @@ type RemoveMinItemRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
+
+	WaitMs   int64    `protobuf:"varint,1,opt,name=wait_ms,json=waitMs,proto3" json:"wait_ms,omitempty"`              // how long to wait for a qualifying item, 0 fails right away
+	MinScore *float32 `protobuf:"fixed32,2,opt,name=min_score,json=minScore,proto3,oneof" json:"min_score,omitempty"` // only take an item scoring at least this
 }
 
 func (x *RemoveMinItemRequest) Reset() {
 	*x = RemoveMinItemRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_filter_filter_proto_msgTypes[9]
+		mi := &file_proto_filter_filter_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -446,7 +816,7 @@ func (x *RemoveMinItemRequest) String() string {
 func (*RemoveMinItemRequest) ProtoMessage() {}
 
 func (x *RemoveMinItemRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_filter_filter_proto_msgTypes[9]
+	mi := &file_proto_filter_filter_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -459,7 +829,21 @@ func (x *RemoveMinItemRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RemoveMinItemRequest.ProtoReflect.Descriptor instead.
 func (*RemoveMinItemRequest) Descriptor() ([]byte, []int) {
-	return file_proto_filter_filter_proto_rawDescGZIP(), []int{9}
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *RemoveMinItemRequest) GetWaitMs() int64 {
+	if x != nil {
+		return x.WaitMs
+	}
+	return 0
+}
+
+func (x *RemoveMinItemRequest) GetMinScore() float32 {
+	if x != nil && x.MinScore != nil {
+		return *x.MinScore
+	}
+	return 0
 }
 
 type RemoveMinItemResponse struct {
@@ -473,7 +857,7 @@ type RemoveMinItemResponse struct {
 func (x *RemoveMinItemResponse) Reset() {
 	*x = RemoveMinItemResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_filter_filter_proto_msgTypes[10]
+		mi := &file_proto_filter_filter_proto_msgTypes[13]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -486,7 +870,7 @@ func (x *RemoveMinItemResponse) String() string {
 func (*RemoveMinItemResponse) ProtoMessage() {}
 
 func (x *RemoveMinItemResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_filter_filter_proto_msgTypes[10]
+	mi := &file_proto_filter_filter_proto_msgTypes[13]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -499,7 +883,7 @@ func (x *RemoveMinItemResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RemoveMinItemResponse.ProtoReflect.Descriptor instead.
 func (*RemoveMinItemResponse) Descriptor() ([]byte, []int) {
-	return file_proto_filter_filter_proto_rawDescGZIP(), []int{10}
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *RemoveMinItemResponse) GetItem() *FilterItem {
@@ -509,29 +893,31 @@ func (x *RemoveMinItemResponse) GetItem() *FilterItem {
 	return nil
 }
 
-type GetSizeRequest struct {
+type LeaseMaxItemRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
+
+	TimeoutMs int64 `protobuf:"varint,1,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"` // how long the item stays hidden, 0 for the default 30s
 }
 
-func (x *GetSizeRequest) Reset() {
-	*x = GetSizeRequest{}
+func (x *LeaseMaxItemRequest) Reset() {
+	*x = LeaseMaxItemRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_filter_filter_proto_msgTypes[11]
+		mi := &file_proto_filter_filter_proto_msgTypes[14]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *GetSizeRequest) String() string {
+func (x *LeaseMaxItemRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetSizeRequest) ProtoMessage() {}
+func (*LeaseMaxItemRequest) ProtoMessage() {}
 
-func (x *GetSizeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_filter_filter_proto_msgTypes[11]
+func (x *LeaseMaxItemRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[14]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -542,36 +928,44 @@ func (x *GetSizeRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetSizeRequest.ProtoReflect.Descriptor instead.
-func (*GetSizeRequest) Descriptor() ([]byte, []int) {
-	return file_proto_filter_filter_proto_rawDescGZIP(), []int{11}
+// Deprecated: Use LeaseMaxItemRequest.ProtoReflect.Descriptor instead.
+func (*LeaseMaxItemRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{14}
 }
 
-type GetSizeResponse struct {
+func (x *LeaseMaxItemRequest) GetTimeoutMs() int64 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+type LeaseMaxItemResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Size int32 `protobuf:"varint,1,opt,name=size,proto3" json:"size,omitempty"`
+	Item    *FilterItem `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+	LeaseId string      `protobuf:"bytes,2,opt,name=lease_id,json=leaseId,proto3" json:"lease_id,omitempty"`
 }
 
-func (x *GetSizeResponse) Reset() {
-	*x = GetSizeResponse{}
+func (x *LeaseMaxItemResponse) Reset() {
+	*x = LeaseMaxItemResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_filter_filter_proto_msgTypes[12]
+		mi := &file_proto_filter_filter_proto_msgTypes[15]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *GetSizeResponse) String() string {
+func (x *LeaseMaxItemResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetSizeResponse) ProtoMessage() {}
+func (*LeaseMaxItemResponse) ProtoMessage() {}
 
-func (x *GetSizeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_filter_filter_proto_msgTypes[12]
+func (x *LeaseMaxItemResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[15]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -582,41 +976,50 @@ func (x *GetSizeResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetSizeResponse.ProtoReflect.Descriptor instead.
-func (*GetSizeResponse) Descriptor() ([]byte, []int) {
-	return file_proto_filter_filter_proto_rawDescGZIP(), []int{12}
+// Deprecated: Use LeaseMaxItemResponse.ProtoReflect.Descriptor instead.
+func (*LeaseMaxItemResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{15}
 }
 
-func (x *GetSizeResponse) GetSize() int32 {
+func (x *LeaseMaxItemResponse) GetItem() *FilterItem {
 	if x != nil {
-		return x.Size
+		return x.Item
 	}
-	return 0
+	return nil
 }
 
-type ClearRequest struct {
+func (x *LeaseMaxItemResponse) GetLeaseId() string {
+	if x != nil {
+		return x.LeaseId
+	}
+	return ""
+}
+
+type AckRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
+
+	LeaseId string `protobuf:"bytes,1,opt,name=lease_id,json=leaseId,proto3" json:"lease_id,omitempty"`
 }
 
-func (x *ClearRequest) Reset() {
-	*x = ClearRequest{}
+func (x *AckRequest) Reset() {
+	*x = AckRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_filter_filter_proto_msgTypes[13]
+		mi := &file_proto_filter_filter_proto_msgTypes[16]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *ClearRequest) String() string {
+func (x *AckRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ClearRequest) ProtoMessage() {}
+func (*AckRequest) ProtoMessage() {}
 
-func (x *ClearRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_filter_filter_proto_msgTypes[13]
+func (x *AckRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[16]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -627,12 +1030,19 @@ func (x *ClearRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ClearRequest.ProtoReflect.Descriptor instead.
-func (*ClearRequest) Descriptor() ([]byte, []int) {
-	return file_proto_filter_filter_proto_rawDescGZIP(), []int{13}
+// Deprecated: Use AckRequest.ProtoReflect.Descriptor instead.
+func (*AckRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{16}
 }
 
-type ClearResponse struct {
+func (x *AckRequest) GetLeaseId() string {
+	if x != nil {
+		return x.LeaseId
+	}
+	return ""
+}
+
+type AckResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
@@ -640,23 +1050,23 @@ type ClearResponse struct {
 	Success bool `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
 }
 
-func (x *ClearResponse) Reset() {
-	*x = ClearResponse{}
+func (x *AckResponse) Reset() {
+	*x = AckResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_filter_filter_proto_msgTypes[14]
+		mi := &file_proto_filter_filter_proto_msgTypes[17]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *ClearResponse) String() string {
+func (x *AckResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ClearResponse) ProtoMessage() {}
+func (*AckResponse) ProtoMessage() {}
 
-func (x *ClearResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_filter_filter_proto_msgTypes[14]
+func (x *AckResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[17]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -667,234 +1077,2522 @@ func (x *ClearResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ClearResponse.ProtoReflect.Descriptor instead.
-func (*ClearResponse) Descriptor() ([]byte, []int) {
-	return file_proto_filter_filter_proto_rawDescGZIP(), []int{14}
+// Deprecated: Use AckResponse.ProtoReflect.Descriptor instead.
+func (*AckResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{17}
 }
 
-func (x *ClearResponse) GetSuccess() bool {
+func (x *AckResponse) GetSuccess() bool {
 	if x != nil {
 		return x.Success
 	}
 	return false
 }
 
-var File_proto_filter_filter_proto protoreflect.FileDescriptor
+type NackRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
 
-var file_proto_filter_filter_proto_rawDesc = []byte{
-	0x0a, 0x19, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2f, 0x66,
-	0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06, 0x66, 0x69, 0x6c,
-	0x74, 0x65, 0x72, 0x22, 0x36, 0x0a, 0x0a, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65,
-	0x6d, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x02,
-	0x52, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x3b, 0x0a, 0x11, 0x49,
-	0x6e, 0x73, 0x65, 0x72, 0x74, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
-	0x12, 0x26, 0x0a, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12,
-	0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74,
-	0x65, 0x6d, 0x52, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x22, 0x2e, 0x0a, 0x12, 0x49, 0x6e, 0x73, 0x65,
-	0x72, 0x74, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18,
-	0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52,
-	0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x22, 0x13, 0x0a, 0x11, 0x47, 0x65, 0x74, 0x4d,
-	0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x3c, 0x0a,
-	0x12, 0x47, 0x65, 0x74, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f,
-	0x6e, 0x73, 0x65, 0x12, 0x26, 0x0a, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65,
-	0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x22, 0x13, 0x0a, 0x11, 0x47,
-	0x65, 0x74, 0x4d, 0x69, 0x6e, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
-	0x22, 0x3c, 0x0a, 0x12, 0x47, 0x65, 0x74, 0x4d, 0x69, 0x6e, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65,
-	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x26, 0x0a, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69,
-	0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x22, 0x16,
-	0x0a, 0x14, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x3f, 0x0a, 0x15, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65,
-	0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
-	0x26, 0x0a, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e,
-	0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65,
-	0x6d, 0x52, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x22, 0x16, 0x0a, 0x14, 0x52, 0x65, 0x6d, 0x6f, 0x76,
-	0x65, 0x4d, 0x69, 0x6e, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22,
-	0x3f, 0x0a, 0x15, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x69, 0x6e, 0x49, 0x74, 0x65, 0x6d,
-	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x26, 0x0a, 0x04, 0x69, 0x74, 0x65, 0x6d,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e,
-	0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x04, 0x69, 0x74, 0x65, 0x6d,
-	0x22, 0x10, 0x0a, 0x0e, 0x47, 0x65, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x22, 0x25, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x73,
-	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x05, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x22, 0x0e, 0x0a, 0x0c, 0x43, 0x6c, 0x65,
-	0x61, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x29, 0x0a, 0x0d, 0x43, 0x6c, 0x65,
-	0x61, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75,
-	0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63,
-	0x63, 0x65, 0x73, 0x73, 0x32, 0xfa, 0x03, 0x0a, 0x0d, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x53,
-	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x45, 0x0a, 0x0a, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74,
-	0x49, 0x74, 0x65, 0x6d, 0x12, 0x19, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x49, 0x6e,
-	0x73, 0x65, 0x72, 0x74, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
-	0x1a, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x49,
-	0x74, 0x65, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x45, 0x0a,
-	0x0a, 0x47, 0x65, 0x74, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x12, 0x19, 0x2e, 0x66, 0x69,
-	0x6c, 0x74, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e,
-	0x47, 0x65, 0x74, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
-	0x73, 0x65, 0x22, 0x00, 0x12, 0x45, 0x0a, 0x0a, 0x47, 0x65, 0x74, 0x4d, 0x69, 0x6e, 0x49, 0x74,
-	0x65, 0x6d, 0x12, 0x19, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x4d,
-	0x69, 0x6e, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e,
-	0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x4d, 0x69, 0x6e, 0x49, 0x74, 0x65,
-	0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x4e, 0x0a, 0x0d, 0x52,
-	0x65, 0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x12, 0x1c, 0x2e, 0x66,
-	0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x61, 0x78, 0x49,
-	0x74, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x66, 0x69, 0x6c,
-	0x74, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65,
-	0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x4e, 0x0a, 0x0d, 0x52,
-	0x65, 0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x69, 0x6e, 0x49, 0x74, 0x65, 0x6d, 0x12, 0x1c, 0x2e, 0x66,
-	0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x69, 0x6e, 0x49,
-	0x74, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x66, 0x69, 0x6c,
-	0x74, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x69, 0x6e, 0x49, 0x74, 0x65,
-	0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x3c, 0x0a, 0x07, 0x47,
-	0x65, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x16, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e,
-	0x47, 0x65, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17,
-	0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x52,
-	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x36, 0x0a, 0x05, 0x43, 0x6c, 0x65,
-	0x61, 0x72, 0x12, 0x14, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x43, 0x6c, 0x65, 0x61,
-	0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x15, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65,
-	0x72, 0x2e, 0x43, 0x6c, 0x65, 0x61, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22,
-	0x00, 0x42, 0x10, 0x5a, 0x0e, 0x2e, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x66, 0x69, 0x6c,
-	0x74, 0x65, 0x72, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	LeaseId string `protobuf:"bytes,1,opt,name=lease_id,json=leaseId,proto3" json:"lease_id,omitempty"`
 }
 
-var (
-	file_proto_filter_filter_proto_rawDescOnce sync.Once
-	file_proto_filter_filter_proto_rawDescData = file_proto_filter_filter_proto_rawDesc
-)
+func (x *NackRequest) Reset() {
+	*x = NackRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
 
-func file_proto_filter_filter_proto_rawDescGZIP() []byte {
-	file_proto_filter_filter_proto_rawDescOnce.Do(func() {
-		file_proto_filter_filter_proto_rawDescData = protoimpl.X.CompressGZIP(file_proto_filter_filter_proto_rawDescData)
-	})
-	return file_proto_filter_filter_proto_rawDescData
+func (x *NackRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-var file_proto_filter_filter_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
-var file_proto_filter_filter_proto_goTypes = []interface{}{
-	(*FilterItem)(nil),            // 0: filter.FilterItem
-	(*InsertItemRequest)(nil),     // 1: filter.InsertItemRequest
-	(*InsertItemResponse)(nil),    // 2: filter.InsertItemResponse
-	(*GetMaxItemRequest)(nil),     // 3: filter.GetMaxItemRequest
-	(*GetMaxItemResponse)(nil),    // 4: filter.GetMaxItemResponse
-	(*GetMinItemRequest)(nil),     // 5: filter.GetMinItemRequest
-	(*GetMinItemResponse)(nil),    // 6: filter.GetMinItemResponse
-	(*RemoveMaxItemRequest)(nil),  // 7: filter.RemoveMaxItemRequest
-	(*RemoveMaxItemResponse)(nil), // 8: filter.RemoveMaxItemResponse
-	(*RemoveMinItemRequest)(nil),  // 9: filter.RemoveMinItemRequest
-	(*RemoveMinItemResponse)(nil), // 10: filter.RemoveMinItemResponse
-	(*GetSizeRequest)(nil),        // 11: filter.GetSizeRequest
-	(*GetSizeResponse)(nil),       // 12: filter.GetSizeResponse
-	(*ClearRequest)(nil),          // 13: filter.ClearRequest
-	(*ClearResponse)(nil),         // 14: filter.ClearResponse
+func (*NackRequest) ProtoMessage() {}
+
+func (x *NackRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
-var file_proto_filter_filter_proto_depIdxs = []int32{
-	0,  // 0: filter.InsertItemRequest.item:type_name -> filter.FilterItem
-	0,  // 1: filter.GetMaxItemResponse.item:type_name -> filter.FilterItem
-	0,  // 2: filter.GetMinItemResponse.item:type_name -> filter.FilterItem
-	0,  // 3: filter.RemoveMaxItemResponse.item:type_name -> filter.FilterItem
-	0,  // 4: filter.RemoveMinItemResponse.item:type_name -> filter.FilterItem
-	1,  // 5: filter.FilterService.InsertItem:input_type -> filter.InsertItemRequest
-	3,  // 6: filter.FilterService.GetMaxItem:input_type -> filter.GetMaxItemRequest
-	5,  // 7: filter.FilterService.GetMinItem:input_type -> filter.GetMinItemRequest
-	7,  // 8: filter.FilterService.RemoveMaxItem:input_type -> filter.RemoveMaxItemRequest
-	9,  // 9: filter.FilterService.RemoveMinItem:input_type -> filter.RemoveMinItemRequest
-	11, // 10: filter.FilterService.GetSize:input_type -> filter.GetSizeRequest
-	13, // 11: filter.FilterService.Clear:input_type -> filter.ClearRequest
-	2,  // 12: filter.FilterService.InsertItem:output_type -> filter.InsertItemResponse
-	4,  // 13: filter.FilterService.GetMaxItem:output_type -> filter.GetMaxItemResponse
-	6,  // 14: filter.FilterService.GetMinItem:output_type -> filter.GetMinItemResponse
-	8,  // 15: filter.FilterService.RemoveMaxItem:output_type -> filter.RemoveMaxItemResponse
-	10, // 16: filter.FilterService.RemoveMinItem:output_type -> filter.RemoveMinItemResponse
-	12, // 17: filter.FilterService.GetSize:output_type -> filter.GetSizeResponse
-	14, // 18: filter.FilterService.Clear:output_type -> filter.ClearResponse
-	12, // [12:19] is the sub-list for method output_type
-	5,  // [5:12] is the sub-list for method input_type
-	5,  // [5:5] is the sub-list for extension type_name
-	5,  // [5:5] is the sub-list for extension extendee
-	0,  // [0:5] is the sub-list for field type_name
+
+// Deprecated: Use NackRequest.ProtoReflect.Descriptor instead.
+func (*NackRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{18}
 }
 
-func init() { file_proto_filter_filter_proto_init() }
-func file_proto_filter_filter_proto_init() {
-	if File_proto_filter_filter_proto != nil {
-		return
+func (x *NackRequest) GetLeaseId() string {
+	if x != nil {
+		return x.LeaseId
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_proto_filter_filter_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*FilterItem); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_proto_filter_filter_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*InsertItemRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_proto_filter_filter_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*InsertItemResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_proto_filter_filter_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GetMaxItemRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_proto_filter_filter_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GetMaxItemResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
+	return ""
+}
+
+type NackResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Success bool `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+}
+
+func (x *NackResponse) Reset() {
+	*x = NackResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NackResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NackResponse) ProtoMessage() {}
+
+func (x *NackResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		file_proto_filter_filter_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GetMinItemRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NackResponse.ProtoReflect.Descriptor instead.
+func (*NackResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *NackResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+// The first request of a subscription says how to pace it, the ones after it
+// only hand back credit
+type SubscribeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Rate   float64 `protobuf:"fixed64,1,opt,name=rate,proto3" json:"rate,omitempty"`    // items per second, 0 leaves it to window
+	Window int32   `protobuf:"varint,2,opt,name=window,proto3" json:"window,omitempty"` // with a rate, items the consumer may get in one burst (at least 1),
+	// without, items it may have outstanding (0 for no limit)
+	Credit int32 `protobuf:"varint,3,opt,name=credit,proto3" json:"credit,omitempty"` // items the consumer is done with, each lets one more out
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *SubscribeRequest) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+func (x *SubscribeRequest) GetWindow() int32 {
+	if x != nil {
+		return x.Window
+	}
+	return 0
+}
+
+func (x *SubscribeRequest) GetCredit() int32 {
+	if x != nil {
+		return x.Credit
+	}
+	return 0
+}
+
+type SubscribeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Item *FilterItem `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+}
+
+func (x *SubscribeResponse) Reset() {
+	*x = SubscribeResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SubscribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeResponse) ProtoMessage() {}
+
+func (x *SubscribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeResponse.ProtoReflect.Descriptor instead.
+func (*SubscribeResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *SubscribeResponse) GetItem() *FilterItem {
+	if x != nil {
+		return x.Item
+	}
+	return nil
+}
+
+// interval_ms is how often removals are checked for, 100 by default
+type WatchThresholdRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	IntervalMs int64 `protobuf:"varint,1,opt,name=interval_ms,json=intervalMs,proto3" json:"interval_ms,omitempty"`
+}
+
+func (x *WatchThresholdRequest) Reset() {
+	*x = WatchThresholdRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchThresholdRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchThresholdRequest) ProtoMessage() {}
+
+func (x *WatchThresholdRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchThresholdRequest.ProtoReflect.Descriptor instead.
+func (*WatchThresholdRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *WatchThresholdRequest) GetIntervalMs() int64 {
+	if x != nil {
+		return x.IntervalMs
+	}
+	return 0
+}
+
+type ThresholdUpdate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Threshold *float32 `protobuf:"fixed32,1,opt,name=threshold,proto3,oneof" json:"threshold,omitempty"`
+}
+
+func (x *ThresholdUpdate) Reset() {
+	*x = ThresholdUpdate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[23]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ThresholdUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ThresholdUpdate) ProtoMessage() {}
+
+func (x *ThresholdUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[23]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ThresholdUpdate.ProtoReflect.Descriptor instead.
+func (*ThresholdUpdate) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ThresholdUpdate) GetThreshold() float32 {
+	if x != nil && x.Threshold != nil {
+		return *x.Threshold
+	}
+	return 0
+}
+
+type WatchEvictionsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *WatchEvictionsRequest) Reset() {
+	*x = WatchEvictionsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[24]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchEvictionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEvictionsRequest) ProtoMessage() {}
+
+func (x *WatchEvictionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[24]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEvictionsRequest.ProtoReflect.Descriptor instead.
+func (*WatchEvictionsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{24}
+}
+
+// missed is how many evictions the watcher lost before this one for falling
+// behind
+type Eviction struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Item       *FilterItem `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+	Reason     EvictReason `protobuf:"varint,2,opt,name=reason,proto3,enum=filter.EvictReason" json:"reason,omitempty"`
+	TimeUnixMs int64       `protobuf:"varint,3,opt,name=time_unix_ms,json=timeUnixMs,proto3" json:"time_unix_ms,omitempty"`
+	Missed     int64       `protobuf:"varint,4,opt,name=missed,proto3" json:"missed,omitempty"`
+}
+
+func (x *Eviction) Reset() {
+	*x = Eviction{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[25]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Eviction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Eviction) ProtoMessage() {}
+
+func (x *Eviction) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[25]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Eviction.ProtoReflect.Descriptor instead.
+func (*Eviction) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *Eviction) GetItem() *FilterItem {
+	if x != nil {
+		return x.Item
+	}
+	return nil
+}
+
+func (x *Eviction) GetReason() EvictReason {
+	if x != nil {
+		return x.Reason
+	}
+	return EvictReason_CAPACITY
+}
+
+func (x *Eviction) GetTimeUnixMs() int64 {
+	if x != nil {
+		return x.TimeUnixMs
+	}
+	return 0
+}
+
+func (x *Eviction) GetMissed() int64 {
+	if x != nil {
+		return x.Missed
+	}
+	return 0
+}
+
+type GetSizeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetSizeRequest) Reset() {
+	*x = GetSizeRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[26]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetSizeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSizeRequest) ProtoMessage() {}
+
+func (x *GetSizeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[26]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSizeRequest.ProtoReflect.Descriptor instead.
+func (*GetSizeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{26}
+}
+
+// evicted and expired count the items the filter threw away since it
+// started, the ones pushed out for better items (or leases) and the ones
+// that outlived their TTL
+type GetSizeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Size    int32 `protobuf:"varint,1,opt,name=size,proto3" json:"size,omitempty"`
+	Evicted int64 `protobuf:"varint,2,opt,name=evicted,proto3" json:"evicted,omitempty"`
+	Expired int64 `protobuf:"varint,3,opt,name=expired,proto3" json:"expired,omitempty"`
+}
+
+func (x *GetSizeResponse) Reset() {
+	*x = GetSizeResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[27]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetSizeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSizeResponse) ProtoMessage() {}
+
+func (x *GetSizeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[27]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSizeResponse.ProtoReflect.Descriptor instead.
+func (*GetSizeResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *GetSizeResponse) GetSize() int32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *GetSizeResponse) GetEvicted() int64 {
+	if x != nil {
+		return x.Evicted
+	}
+	return 0
+}
+
+func (x *GetSizeResponse) GetExpired() int64 {
+	if x != nil {
+		return x.Expired
+	}
+	return 0
+}
+
+type ClearRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ClearRequest) Reset() {
+	*x = ClearRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[28]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClearRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClearRequest) ProtoMessage() {}
+
+func (x *ClearRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[28]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClearRequest.ProtoReflect.Descriptor instead.
+func (*ClearRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{28}
+}
+
+type ClearResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Success bool `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+}
+
+func (x *ClearResponse) Reset() {
+	*x = ClearResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[29]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClearResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClearResponse) ProtoMessage() {}
+
+func (x *ClearResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[29]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClearResponse.ProtoReflect.Descriptor instead.
+func (*ClearResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *ClearResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+// What one mutation did to a filter, as the write-ahead log and the backups
+// get it: the items that went in, then the ones that came out. Items are told
+// apart by their key, or if they have none by everything but the effective
+// score.
+type FilterChange struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Inserted []*FilterItem `protobuf:"bytes,1,rep,name=inserted,proto3" json:"inserted,omitempty"`
+	Removed  []*FilterItem `protobuf:"bytes,2,rep,name=removed,proto3" json:"removed,omitempty"`
+	Leased   string        `protobuf:"bytes,3,opt,name=leased,proto3" json:"leased,omitempty"`     // the removed item went out on this lease
+	Released string        `protobuf:"bytes,4,opt,name=released,proto3" json:"released,omitempty"` // this lease is over, acked or back in the filter
+}
+
+func (x *FilterChange) Reset() {
+	*x = FilterChange{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[30]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FilterChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterChange) ProtoMessage() {}
+
+func (x *FilterChange) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[30]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterChange.ProtoReflect.Descriptor instead.
+func (*FilterChange) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *FilterChange) GetInserted() []*FilterItem {
+	if x != nil {
+		return x.Inserted
+	}
+	return nil
+}
+
+func (x *FilterChange) GetRemoved() []*FilterItem {
+	if x != nil {
+		return x.Removed
+	}
+	return nil
+}
+
+func (x *FilterChange) GetLeased() string {
+	if x != nil {
+		return x.Leased
+	}
+	return ""
+}
+
+func (x *FilterChange) GetReleased() string {
+	if x != nil {
+		return x.Released
+	}
+	return ""
+}
+
+// One mutation of the primary, in the same encoding as the write-ahead log
+type ReplicationRecord struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Op      uint32 `protobuf:"varint,1,opt,name=op,proto3" json:"op,omitempty"`
+	Payload []byte `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (x *ReplicationRecord) Reset() {
+	*x = ReplicationRecord{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[31]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReplicationRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplicationRecord) ProtoMessage() {}
+
+func (x *ReplicationRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[31]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplicationRecord.ProtoReflect.Descriptor instead.
+func (*ReplicationRecord) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *ReplicationRecord) GetOp() uint32 {
+	if x != nil {
+		return x.Op
+	}
+	return 0
+}
+
+func (x *ReplicationRecord) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+type ReplicateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ReplicateRequest) Reset() {
+	*x = ReplicateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[32]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReplicateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplicateRequest) ProtoMessage() {}
+
+func (x *ReplicateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[32]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplicateRequest.ProtoReflect.Descriptor instead.
+func (*ReplicateRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{32}
+}
+
+type PromoteRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *PromoteRequest) Reset() {
+	*x = PromoteRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[33]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PromoteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PromoteRequest) ProtoMessage() {}
+
+func (x *PromoteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[33]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PromoteRequest.ProtoReflect.Descriptor instead.
+func (*PromoteRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{33}
+}
+
+type PromoteResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Success bool `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+}
+
+func (x *PromoteResponse) Reset() {
+	*x = PromoteResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[34]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PromoteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PromoteResponse) ProtoMessage() {}
+
+func (x *PromoteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[34]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PromoteResponse.ProtoReflect.Descriptor instead.
+func (*PromoteResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *PromoteResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+// The score band [lo, hi) a filter owns, an unset end is unbounded
+type GetBandRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetBandRequest) Reset() {
+	*x = GetBandRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[35]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetBandRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBandRequest) ProtoMessage() {}
+
+func (x *GetBandRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[35]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBandRequest.ProtoReflect.Descriptor instead.
+func (*GetBandRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{35}
+}
+
+type GetBandResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Lo *float32 `protobuf:"fixed32,1,opt,name=lo,proto3,oneof" json:"lo,omitempty"`
+	Hi *float32 `protobuf:"fixed32,2,opt,name=hi,proto3,oneof" json:"hi,omitempty"`
+}
+
+func (x *GetBandResponse) Reset() {
+	*x = GetBandResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[36]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetBandResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBandResponse) ProtoMessage() {}
+
+func (x *GetBandResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[36]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBandResponse.ProtoReflect.Descriptor instead.
+func (*GetBandResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *GetBandResponse) GetLo() float32 {
+	if x != nil && x.Lo != nil {
+		return *x.Lo
+	}
+	return 0
+}
+
+func (x *GetBandResponse) GetHi() float32 {
+	if x != nil && x.Hi != nil {
+		return *x.Hi
+	}
+	return 0
+}
+
+type SetBandRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Lo *float32 `protobuf:"fixed32,1,opt,name=lo,proto3,oneof" json:"lo,omitempty"`
+	Hi *float32 `protobuf:"fixed32,2,opt,name=hi,proto3,oneof" json:"hi,omitempty"`
+}
+
+func (x *SetBandRequest) Reset() {
+	*x = SetBandRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[37]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SetBandRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetBandRequest) ProtoMessage() {}
+
+func (x *SetBandRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[37]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetBandRequest.ProtoReflect.Descriptor instead.
+func (*SetBandRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *SetBandRequest) GetLo() float32 {
+	if x != nil && x.Lo != nil {
+		return *x.Lo
+	}
+	return 0
+}
+
+func (x *SetBandRequest) GetHi() float32 {
+	if x != nil && x.Hi != nil {
+		return *x.Hi
+	}
+	return 0
+}
+
+// the items that fell outside the new band, taken out of the filter
+type SetBandResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Moved []*FilterItem `protobuf:"bytes,1,rep,name=moved,proto3" json:"moved,omitempty"`
+}
+
+func (x *SetBandResponse) Reset() {
+	*x = SetBandResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[38]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SetBandResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetBandResponse) ProtoMessage() {}
+
+func (x *SetBandResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[38]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetBandResponse.ProtoReflect.Descriptor instead.
+func (*SetBandResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *SetBandResponse) GetMoved() []*FilterItem {
+	if x != nil {
+		return x.Moved
+	}
+	return nil
+}
+
+// Move the item under key to a new score, the response has the moved item
+type UpdateScoreRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key   string  `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Score float32 `protobuf:"fixed32,2,opt,name=score,proto3" json:"score,omitempty"`
+}
+
+func (x *UpdateScoreRequest) Reset() {
+	*x = UpdateScoreRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[39]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UpdateScoreRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateScoreRequest) ProtoMessage() {}
+
+func (x *UpdateScoreRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[39]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateScoreRequest.ProtoReflect.Descriptor instead.
+func (*UpdateScoreRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *UpdateScoreRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *UpdateScoreRequest) GetScore() float32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+type UpdateScoreResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Item *FilterItem `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+}
+
+func (x *UpdateScoreResponse) Reset() {
+	*x = UpdateScoreResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[40]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UpdateScoreResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateScoreResponse) ProtoMessage() {}
+
+func (x *UpdateScoreResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[40]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateScoreResponse.ProtoReflect.Descriptor instead.
+func (*UpdateScoreResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *UpdateScoreResponse) GetItem() *FilterItem {
+	if x != nil {
+		return x.Item
+	}
+	return nil
+}
+
+type DeleteByKeyRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+}
+
+func (x *DeleteByKeyRequest) Reset() {
+	*x = DeleteByKeyRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[41]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteByKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteByKeyRequest) ProtoMessage() {}
+
+func (x *DeleteByKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[41]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteByKeyRequest.ProtoReflect.Descriptor instead.
+func (*DeleteByKeyRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *DeleteByKeyRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+type DeleteByKeyResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Item *FilterItem `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+}
+
+func (x *DeleteByKeyResponse) Reset() {
+	*x = DeleteByKeyResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[42]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteByKeyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteByKeyResponse) ProtoMessage() {}
+
+func (x *DeleteByKeyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[42]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteByKeyResponse.ProtoReflect.Descriptor instead.
+func (*DeleteByKeyResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *DeleteByKeyResponse) GetItem() *FilterItem {
+	if x != nil {
+		return x.Item
+	}
+	return nil
+}
+
+// Take the best items out at once, best first: RemoveTopK k of them,
+// RemoveAbove the ones scoring at least threshold, up to limit (0 for all).
+// Either comes back empty, not failed, if nothing qualifies.
+type RemoveTopKRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	K int32 `protobuf:"varint,1,opt,name=k,proto3" json:"k,omitempty"`
+}
+
+func (x *RemoveTopKRequest) Reset() {
+	*x = RemoveTopKRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[43]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RemoveTopKRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveTopKRequest) ProtoMessage() {}
+
+func (x *RemoveTopKRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[43]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveTopKRequest.ProtoReflect.Descriptor instead.
+func (*RemoveTopKRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *RemoveTopKRequest) GetK() int32 {
+	if x != nil {
+		return x.K
+	}
+	return 0
+}
+
+type RemoveTopKResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items []*FilterItem `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+}
+
+func (x *RemoveTopKResponse) Reset() {
+	*x = RemoveTopKResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[44]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RemoveTopKResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveTopKResponse) ProtoMessage() {}
+
+func (x *RemoveTopKResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[44]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveTopKResponse.ProtoReflect.Descriptor instead.
+func (*RemoveTopKResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *RemoveTopKResponse) GetItems() []*FilterItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type RemoveAboveRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Threshold float32 `protobuf:"fixed32,1,opt,name=threshold,proto3" json:"threshold,omitempty"`
+	Limit     int32   `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+func (x *RemoveAboveRequest) Reset() {
+	*x = RemoveAboveRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[45]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RemoveAboveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveAboveRequest) ProtoMessage() {}
+
+func (x *RemoveAboveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[45]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveAboveRequest.ProtoReflect.Descriptor instead.
+func (*RemoveAboveRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *RemoveAboveRequest) GetThreshold() float32 {
+	if x != nil {
+		return x.Threshold
+	}
+	return 0
+}
+
+func (x *RemoveAboveRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type RemoveAboveResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items []*FilterItem `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+}
+
+func (x *RemoveAboveResponse) Reset() {
+	*x = RemoveAboveResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[46]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RemoveAboveResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveAboveResponse) ProtoMessage() {}
+
+func (x *RemoveAboveResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[46]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveAboveResponse.ProtoReflect.Descriptor instead.
+func (*RemoveAboveResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *RemoveAboveResponse) GetItems() []*FilterItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+// Drain takes the best items out batch_size at a time (100 by default) and
+// streams each batch, until limit items (0 for no limit) are out or nothing
+// scoring at least min_score is left
+type DrainRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	MinScore  *float32 `protobuf:"fixed32,1,opt,name=min_score,json=minScore,proto3,oneof" json:"min_score,omitempty"`
+	Limit     int32    `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	BatchSize int32    `protobuf:"varint,3,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"`
+}
+
+func (x *DrainRequest) Reset() {
+	*x = DrainRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[47]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DrainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DrainRequest) ProtoMessage() {}
+
+func (x *DrainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[47]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DrainRequest.ProtoReflect.Descriptor instead.
+func (*DrainRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *DrainRequest) GetMinScore() float32 {
+	if x != nil && x.MinScore != nil {
+		return *x.MinScore
+	}
+	return 0
+}
+
+func (x *DrainRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *DrainRequest) GetBatchSize() int32 {
+	if x != nil {
+		return x.BatchSize
+	}
+	return 0
+}
+
+type DrainResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items []*FilterItem `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+}
+
+func (x *DrainResponse) Reset() {
+	*x = DrainResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[48]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DrainResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DrainResponse) ProtoMessage() {}
+
+func (x *DrainResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[48]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DrainResponse.ProtoReflect.Descriptor instead.
+func (*DrainResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *DrainResponse) GetItems() []*FilterItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+// Look at items without taking them out. Top-K comes best first, bottom-K
+// worst first, a range best first.
+type GetTopKRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	K int32 `protobuf:"varint,1,opt,name=k,proto3" json:"k,omitempty"`
+}
+
+func (x *GetTopKRequest) Reset() {
+	*x = GetTopKRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[49]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetTopKRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTopKRequest) ProtoMessage() {}
+
+func (x *GetTopKRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[49]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTopKRequest.ProtoReflect.Descriptor instead.
+func (*GetTopKRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *GetTopKRequest) GetK() int32 {
+	if x != nil {
+		return x.K
+	}
+	return 0
+}
+
+type GetTopKResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items []*FilterItem `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+}
+
+func (x *GetTopKResponse) Reset() {
+	*x = GetTopKResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[50]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetTopKResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTopKResponse) ProtoMessage() {}
+
+func (x *GetTopKResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[50]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTopKResponse.ProtoReflect.Descriptor instead.
+func (*GetTopKResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *GetTopKResponse) GetItems() []*FilterItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type GetBottomKRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	K int32 `protobuf:"varint,1,opt,name=k,proto3" json:"k,omitempty"`
+}
+
+func (x *GetBottomKRequest) Reset() {
+	*x = GetBottomKRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[51]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetBottomKRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBottomKRequest) ProtoMessage() {}
+
+func (x *GetBottomKRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[51]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBottomKRequest.ProtoReflect.Descriptor instead.
+func (*GetBottomKRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *GetBottomKRequest) GetK() int32 {
+	if x != nil {
+		return x.K
+	}
+	return 0
+}
+
+type GetBottomKResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items []*FilterItem `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+}
+
+func (x *GetBottomKResponse) Reset() {
+	*x = GetBottomKResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[52]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetBottomKResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBottomKResponse) ProtoMessage() {}
+
+func (x *GetBottomKResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[52]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBottomKResponse.ProtoReflect.Descriptor instead.
+func (*GetBottomKResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{52}
+}
+
+func (x *GetBottomKResponse) GetItems() []*FilterItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+// items scoring min_score to max_score, both included, up to limit of them,
+// 0 for all
+type GetRangeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	MinScore float32 `protobuf:"fixed32,1,opt,name=min_score,json=minScore,proto3" json:"min_score,omitempty"`
+	MaxScore float32 `protobuf:"fixed32,2,opt,name=max_score,json=maxScore,proto3" json:"max_score,omitempty"`
+	Limit    int32   `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+func (x *GetRangeRequest) Reset() {
+	*x = GetRangeRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[53]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetRangeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRangeRequest) ProtoMessage() {}
+
+func (x *GetRangeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[53]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRangeRequest.ProtoReflect.Descriptor instead.
+func (*GetRangeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{53}
+}
+
+func (x *GetRangeRequest) GetMinScore() float32 {
+	if x != nil {
+		return x.MinScore
+	}
+	return 0
+}
+
+func (x *GetRangeRequest) GetMaxScore() float32 {
+	if x != nil {
+		return x.MaxScore
+	}
+	return 0
+}
+
+func (x *GetRangeRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetRangeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items []*FilterItem `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+}
+
+func (x *GetRangeResponse) Reset() {
+	*x = GetRangeResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_filter_proto_msgTypes[54]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetRangeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRangeResponse) ProtoMessage() {}
+
+func (x *GetRangeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_filter_proto_msgTypes[54]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRangeResponse.ProtoReflect.Descriptor instead.
+func (*GetRangeResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filter_filter_proto_rawDescGZIP(), []int{54}
+}
+
+func (x *GetRangeResponse) GetItems() []*FilterItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+var File_proto_filter_filter_proto protoreflect.FileDescriptor
+
+var file_proto_filter_filter_proto_rawDesc = []byte{
+	0x0a, 0x19, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2f, 0x66,
+	0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06, 0x66, 0x69, 0x6c,
+	0x74, 0x65, 0x72, 0x22, 0xf3, 0x01, 0x0a, 0x0a, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74,
+	0x65, 0x6d, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x02, 0x52, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x15,
+	0x0a, 0x06, 0x74, 0x74, 0x6c, 0x5f, 0x6d, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05,
+	0x74, 0x74, 0x6c, 0x4d, 0x73, 0x12, 0x26, 0x0a, 0x0f, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73,
+	0x5f, 0x75, 0x6e, 0x69, 0x78, 0x5f, 0x6d, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d,
+	0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x55, 0x6e, 0x69, 0x78, 0x4d, 0x73, 0x12, 0x28, 0x0a,
+	0x10, 0x69, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x65, 0x64, 0x5f, 0x75, 0x6e, 0x69, 0x78, 0x5f, 0x6d,
+	0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e, 0x69, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x65,
+	0x64, 0x55, 0x6e, 0x69, 0x78, 0x4d, 0x73, 0x12, 0x2c, 0x0a, 0x0f, 0x65, 0x66, 0x66, 0x65, 0x63,
+	0x74, 0x69, 0x76, 0x65, 0x5f, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x02,
+	0x48, 0x00, 0x52, 0x0e, 0x65, 0x66, 0x66, 0x65, 0x63, 0x74, 0x69, 0x76, 0x65, 0x53, 0x63, 0x6f,
+	0x72, 0x65, 0x88, 0x01, 0x01, 0x42, 0x12, 0x0a, 0x10, 0x5f, 0x65, 0x66, 0x66, 0x65, 0x63, 0x74,
+	0x69, 0x76, 0x65, 0x5f, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x22, 0x3b, 0x0a, 0x11, 0x49, 0x6e, 0x73,
+	0x65, 0x72, 0x74, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x26,
+	0x0a, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66,
+	0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d,
+	0x52, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x22, 0xbe, 0x01, 0x0a, 0x12, 0x49, 0x6e, 0x73, 0x65, 0x72,
+	0x74, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a,
+	0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
+	0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x21, 0x0a, 0x09, 0x74, 0x68, 0x72, 0x65, 0x73,
+	0x68, 0x6f, 0x6c, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x48, 0x00, 0x52, 0x09, 0x74, 0x68,
+	0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x88, 0x01, 0x01, 0x12, 0x2f, 0x0a, 0x07, 0x6f, 0x75,
+	0x74, 0x63, 0x6f, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x15, 0x2e, 0x66, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x2e, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x4f, 0x75, 0x74, 0x63, 0x6f,
+	0x6d, 0x65, 0x52, 0x07, 0x6f, 0x75, 0x74, 0x63, 0x6f, 0x6d, 0x65, 0x12, 0x2c, 0x0a, 0x07, 0x65,
+	0x76, 0x69, 0x63, 0x74, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66,
+	0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d,
+	0x52, 0x07, 0x65, 0x76, 0x69, 0x63, 0x74, 0x65, 0x64, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x74, 0x68,
+	0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x22, 0x3e, 0x0a, 0x12, 0x49, 0x6e, 0x73, 0x65, 0x72,
+	0x74, 0x49, 0x74, 0x65, 0x6d, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x28, 0x0a,
+	0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66,
+	0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d,
+	0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x22, 0x60, 0x0a, 0x13, 0x49, 0x6e, 0x73, 0x65, 0x72,
+	0x74, 0x49, 0x74, 0x65, 0x6d, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18,
+	0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x21, 0x0a, 0x09, 0x74, 0x68, 0x72, 0x65,
+	0x73, 0x68, 0x6f, 0x6c, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x48, 0x00, 0x52, 0x09, 0x74,
+	0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x88, 0x01, 0x01, 0x42, 0x0c, 0x0a, 0x0a, 0x5f,
+	0x74, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x22, 0x68, 0x0a, 0x14, 0x49, 0x6e, 0x73,
+	0x65, 0x72, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x1a, 0x0a, 0x08, 0x61, 0x63, 0x63, 0x65, 0x70, 0x74, 0x65, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x08, 0x61, 0x63, 0x63, 0x65, 0x70, 0x74, 0x65, 0x64, 0x12, 0x1a, 0x0a,
+	0x08, 0x72, 0x65, 0x6a, 0x65, 0x63, 0x74, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x08, 0x72, 0x65, 0x6a, 0x65, 0x63, 0x74, 0x65, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x65, 0x76, 0x69,
+	0x63, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x65, 0x76, 0x69, 0x63,
+	0x74, 0x65, 0x64, 0x22, 0x13, 0x0a, 0x11, 0x47, 0x65, 0x74, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65,
+	0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x3c, 0x0a, 0x12, 0x47, 0x65, 0x74, 0x4d,
+	0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x26,
+	0x0a, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66,
+	0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d,
+	0x52, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x22, 0x13, 0x0a, 0x11, 0x47, 0x65, 0x74, 0x4d, 0x69, 0x6e,
+	0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x3c, 0x0a, 0x12, 0x47,
+	0x65, 0x74, 0x4d, 0x69, 0x6e, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x26, 0x0a, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49,
+	0x74, 0x65, 0x6d, 0x52, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x22, 0x5f, 0x0a, 0x14, 0x52, 0x65, 0x6d,
+	0x6f, 0x76, 0x65, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x17, 0x0a, 0x07, 0x77, 0x61, 0x69, 0x74, 0x5f, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x06, 0x77, 0x61, 0x69, 0x74, 0x4d, 0x73, 0x12, 0x20, 0x0a, 0x09, 0x6d, 0x69,
+	0x6e, 0x5f, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x48, 0x00, 0x52,
+	0x08, 0x6d, 0x69, 0x6e, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x88, 0x01, 0x01, 0x42, 0x0c, 0x0a, 0x0a,
+	0x5f, 0x6d, 0x69, 0x6e, 0x5f, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x22, 0x3f, 0x0a, 0x15, 0x52, 0x65,
+	0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x26, 0x0a, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65,
+	0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x22, 0x5f, 0x0a, 0x14, 0x52,
+	0x65, 0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x69, 0x6e, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x77, 0x61, 0x69, 0x74, 0x5f, 0x6d, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x77, 0x61, 0x69, 0x74, 0x4d, 0x73, 0x12, 0x20, 0x0a, 0x09,
+	0x6d, 0x69, 0x6e, 0x5f, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x48,
+	0x00, 0x52, 0x08, 0x6d, 0x69, 0x6e, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x88, 0x01, 0x01, 0x42, 0x0c,
+	0x0a, 0x0a, 0x5f, 0x6d, 0x69, 0x6e, 0x5f, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x22, 0x3f, 0x0a, 0x15,
+	0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x69, 0x6e, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x26, 0x0a, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c,
+	0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x22, 0x34, 0x0a,
+	0x13, 0x4c, 0x65, 0x61, 0x73, 0x65, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x5f,
+	0x6d, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75,
+	0x74, 0x4d, 0x73, 0x22, 0x59, 0x0a, 0x14, 0x4c, 0x65, 0x61, 0x73, 0x65, 0x4d, 0x61, 0x78, 0x49,
+	0x74, 0x65, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x26, 0x0a, 0x04, 0x69,
+	0x74, 0x65, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74,
+	0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x04, 0x69,
+	0x74, 0x65, 0x6d, 0x12, 0x19, 0x0a, 0x08, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x5f, 0x69, 0x64, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x49, 0x64, 0x22, 0x27,
+	0x0a, 0x0a, 0x41, 0x63, 0x6b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x19, 0x0a, 0x08,
+	0x6c, 0x65, 0x61, 0x73, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x6c, 0x65, 0x61, 0x73, 0x65, 0x49, 0x64, 0x22, 0x27, 0x0a, 0x0b, 0x41, 0x63, 0x6b, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73,
+	0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73,
+	0x22, 0x28, 0x0a, 0x0b, 0x4e, 0x61, 0x63, 0x6b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x19, 0x0a, 0x08, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x49, 0x64, 0x22, 0x28, 0x0a, 0x0c, 0x4e, 0x61,
+	0x63, 0x6b, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75,
+	0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63,
+	0x63, 0x65, 0x73, 0x73, 0x22, 0x56, 0x0a, 0x10, 0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x61, 0x74, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x72, 0x61, 0x74, 0x65, 0x12, 0x16, 0x0a, 0x06,
+	0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x77, 0x69,
+	0x6e, 0x64, 0x6f, 0x77, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x72, 0x65, 0x64, 0x69, 0x74, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x63, 0x72, 0x65, 0x64, 0x69, 0x74, 0x22, 0x3b, 0x0a, 0x11,
+	0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x26, 0x0a, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49,
+	0x74, 0x65, 0x6d, 0x52, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x22, 0x38, 0x0a, 0x15, 0x57, 0x61, 0x74,
+	0x63, 0x68, 0x54, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x5f, 0x6d,
+	0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61,
+	0x6c, 0x4d, 0x73, 0x22, 0x42, 0x0a, 0x0f, 0x54, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64,
+	0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x21, 0x0a, 0x09, 0x74, 0x68, 0x72, 0x65, 0x73, 0x68,
+	0x6f, 0x6c, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x02, 0x48, 0x00, 0x52, 0x09, 0x74, 0x68, 0x72,
+	0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x88, 0x01, 0x01, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x74, 0x68,
+	0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x22, 0x17, 0x0a, 0x15, 0x57, 0x61, 0x74, 0x63, 0x68,
+	0x45, 0x76, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x22, 0x99, 0x01, 0x0a, 0x08, 0x45, 0x76, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x26, 0x0a,
+	0x04, 0x69, 0x74, 0x65, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x52,
+	0x04, 0x69, 0x74, 0x65, 0x6d, 0x12, 0x2b, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x13, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x45,
+	0x76, 0x69, 0x63, 0x74, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73,
+	0x6f, 0x6e, 0x12, 0x20, 0x0a, 0x0c, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x75, 0x6e, 0x69, 0x78, 0x5f,
+	0x6d, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x74, 0x69, 0x6d, 0x65, 0x55, 0x6e,
+	0x69, 0x78, 0x4d, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x69, 0x73, 0x73, 0x65, 0x64, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x6d, 0x69, 0x73, 0x73, 0x65, 0x64, 0x22, 0x10, 0x0a, 0x0e,
+	0x47, 0x65, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x59,
+	0x0a, 0x0f, 0x47, 0x65, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x04, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x65, 0x76, 0x69, 0x63, 0x74, 0x65, 0x64,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x65, 0x76, 0x69, 0x63, 0x74, 0x65, 0x64, 0x12,
+	0x18, 0x0a, 0x07, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x07, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x64, 0x22, 0x0e, 0x0a, 0x0c, 0x43, 0x6c, 0x65,
+	0x61, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x29, 0x0a, 0x0d, 0x43, 0x6c, 0x65,
+	0x61, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75,
+	0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63,
+	0x63, 0x65, 0x73, 0x73, 0x22, 0xa0, 0x01, 0x0a, 0x0c, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x43,
+	0x68, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x2e, 0x0a, 0x08, 0x69, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x65,
+	0x64, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72,
+	0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x08, 0x69, 0x6e, 0x73,
+	0x65, 0x72, 0x74, 0x65, 0x64, 0x12, 0x2c, 0x0a, 0x07, 0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x64,
+	0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e,
+	0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x07, 0x72, 0x65, 0x6d, 0x6f,
+	0x76, 0x65, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x64, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x72,
+	0x65, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x72,
+	0x65, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x64, 0x22, 0x3d, 0x0a, 0x11, 0x52, 0x65, 0x70, 0x6c, 0x69,
+	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x12, 0x0e, 0x0a, 0x02,
+	0x6f, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x02, 0x6f, 0x70, 0x12, 0x18, 0x0a, 0x07,
+	0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70,
+	0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x22, 0x12, 0x0a, 0x10, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x10, 0x0a, 0x0e, 0x50, 0x72,
+	0x6f, 0x6d, 0x6f, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x2b, 0x0a, 0x0f,
+	0x50, 0x72, 0x6f, 0x6d, 0x6f, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x18, 0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x22, 0x10, 0x0a, 0x0e, 0x47, 0x65, 0x74,
+	0x42, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x49, 0x0a, 0x0f, 0x47,
+	0x65, 0x74, 0x42, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x13,
+	0x0a, 0x02, 0x6c, 0x6f, 0x18, 0x01, 0x20, 0x01, 0x28, 0x02, 0x48, 0x00, 0x52, 0x02, 0x6c, 0x6f,
+	0x88, 0x01, 0x01, 0x12, 0x13, 0x0a, 0x02, 0x68, 0x69, 0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x48,
+	0x01, 0x52, 0x02, 0x68, 0x69, 0x88, 0x01, 0x01, 0x42, 0x05, 0x0a, 0x03, 0x5f, 0x6c, 0x6f, 0x42,
+	0x05, 0x0a, 0x03, 0x5f, 0x68, 0x69, 0x22, 0x48, 0x0a, 0x0e, 0x53, 0x65, 0x74, 0x42, 0x61, 0x6e,
+	0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x13, 0x0a, 0x02, 0x6c, 0x6f, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x02, 0x48, 0x00, 0x52, 0x02, 0x6c, 0x6f, 0x88, 0x01, 0x01, 0x12, 0x13, 0x0a,
+	0x02, 0x68, 0x69, 0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x48, 0x01, 0x52, 0x02, 0x68, 0x69, 0x88,
+	0x01, 0x01, 0x42, 0x05, 0x0a, 0x03, 0x5f, 0x6c, 0x6f, 0x42, 0x05, 0x0a, 0x03, 0x5f, 0x68, 0x69,
+	0x22, 0x3b, 0x0a, 0x0f, 0x53, 0x65, 0x74, 0x42, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x28, 0x0a, 0x05, 0x6d, 0x6f, 0x76, 0x65, 0x64, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74,
+	0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x05, 0x6d, 0x6f, 0x76, 0x65, 0x64, 0x22, 0x3c, 0x0a,
+	0x12, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x02, 0x52, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x22, 0x3d, 0x0a, 0x13, 0x55,
+	0x70, 0x64, 0x61, 0x74, 0x65, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x26, 0x0a, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72,
+	0x49, 0x74, 0x65, 0x6d, 0x52, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x22, 0x26, 0x0a, 0x12, 0x44, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x42, 0x79, 0x4b, 0x65, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
+	0x65, 0x79, 0x22, 0x3d, 0x0a, 0x13, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x42, 0x79, 0x4b, 0x65,
+	0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x26, 0x0a, 0x04, 0x69, 0x74, 0x65,
+	0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72,
+	0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x04, 0x69, 0x74, 0x65,
+	0x6d, 0x22, 0x21, 0x0a, 0x11, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x54, 0x6f, 0x70, 0x4b, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0c, 0x0a, 0x01, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x01, 0x6b, 0x22, 0x3e, 0x0a, 0x12, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x54, 0x6f,
+	0x70, 0x4b, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x28, 0x0a, 0x05, 0x69, 0x74,
+	0x65, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74,
+	0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x05, 0x69,
+	0x74, 0x65, 0x6d, 0x73, 0x22, 0x48, 0x0a, 0x12, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x41, 0x62,
+	0x6f, 0x76, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x68,
+	0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x02, 0x52, 0x09, 0x74,
+	0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69,
+	0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x22, 0x3f,
+	0x0a, 0x13, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x41, 0x62, 0x6f, 0x76, 0x65, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x28, 0x0a, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x22,
+	0x73, 0x0a, 0x0c, 0x44, 0x72, 0x61, 0x69, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x20, 0x0a, 0x09, 0x6d, 0x69, 0x6e, 0x5f, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x02, 0x48, 0x00, 0x52, 0x08, 0x6d, 0x69, 0x6e, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x88, 0x01,
+	0x01, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x62, 0x61, 0x74, 0x63, 0x68,
+	0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x09, 0x62, 0x61, 0x74,
+	0x63, 0x68, 0x53, 0x69, 0x7a, 0x65, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x6d, 0x69, 0x6e, 0x5f, 0x73,
+	0x63, 0x6f, 0x72, 0x65, 0x22, 0x39, 0x0a, 0x0d, 0x44, 0x72, 0x61, 0x69, 0x6e, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x28, 0x0a, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x22,
+	0x1e, 0x0a, 0x0e, 0x47, 0x65, 0x74, 0x54, 0x6f, 0x70, 0x4b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x0c, 0x0a, 0x01, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x01, 0x6b, 0x22,
+	0x3b, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x54, 0x6f, 0x70, 0x4b, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x28, 0x0a, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65,
+	0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x22, 0x21, 0x0a, 0x11,
+	0x47, 0x65, 0x74, 0x42, 0x6f, 0x74, 0x74, 0x6f, 0x6d, 0x4b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x0c, 0x0a, 0x01, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x01, 0x6b, 0x22,
+	0x3e, 0x0a, 0x12, 0x47, 0x65, 0x74, 0x42, 0x6f, 0x74, 0x74, 0x6f, 0x6d, 0x4b, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x28, 0x0a, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x22,
+	0x61, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x6d, 0x69, 0x6e, 0x5f, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x02, 0x52, 0x08, 0x6d, 0x69, 0x6e, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x12,
+	0x1b, 0x0a, 0x09, 0x6d, 0x61, 0x78, 0x5f, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x02, 0x52, 0x08, 0x6d, 0x61, 0x78, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x12, 0x14, 0x0a, 0x05,
+	0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6c, 0x69, 0x6d,
+	0x69, 0x74, 0x22, 0x3c, 0x0a, 0x10, 0x47, 0x65, 0x74, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x28, 0x0a, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x18,
+	0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x46,
+	0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73,
+	0x2a, 0x4f, 0x0a, 0x0d, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x4f, 0x75, 0x74, 0x63, 0x6f, 0x6d,
+	0x65, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x54, 0x4f, 0x52, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0c, 0x0a,
+	0x08, 0x52, 0x45, 0x4a, 0x45, 0x43, 0x54, 0x45, 0x44, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x45,
+	0x56, 0x49, 0x43, 0x54, 0x45, 0x44, 0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x50, 0x44, 0x41,
+	0x54, 0x45, 0x44, 0x10, 0x03, 0x12, 0x0a, 0x0a, 0x06, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10,
+	0x04, 0x2a, 0x40, 0x0a, 0x0b, 0x45, 0x76, 0x69, 0x63, 0x74, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e,
+	0x12, 0x0c, 0x0a, 0x08, 0x43, 0x41, 0x50, 0x41, 0x43, 0x49, 0x54, 0x59, 0x10, 0x00, 0x12, 0x09,
+	0x0a, 0x05, 0x4c, 0x45, 0x41, 0x53, 0x45, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x45, 0x58, 0x50,
+	0x49, 0x52, 0x45, 0x44, 0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x52, 0x45, 0x46, 0x55, 0x53, 0x45,
+	0x44, 0x10, 0x03, 0x32, 0xc0, 0x0e, 0x0a, 0x0d, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x53, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x45, 0x0a, 0x0a, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x49,
+	0x74, 0x65, 0x6d, 0x12, 0x19, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x49, 0x6e, 0x73,
+	0x65, 0x72, 0x74, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a,
+	0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x49, 0x74,
+	0x65, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x48, 0x0a, 0x0b,
+	0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x49, 0x74, 0x65, 0x6d, 0x73, 0x12, 0x1a, 0x2e, 0x66, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x2e, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x49, 0x74, 0x65, 0x6d, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72,
+	0x2e, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x49, 0x74, 0x65, 0x6d, 0x73, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x44, 0x0a, 0x0c, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74,
+	0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x12, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e,
+	0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x49, 0x74, 0x65, 0x6d, 0x1a, 0x1c, 0x2e, 0x66, 0x69, 0x6c,
+	0x74, 0x65, 0x72, 0x2e, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x28, 0x01, 0x12, 0x45, 0x0a, 0x0a,
+	0x47, 0x65, 0x74, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x12, 0x19, 0x2e, 0x66, 0x69, 0x6c,
+	0x74, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x47,
+	0x65, 0x74, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x22, 0x00, 0x12, 0x45, 0x0a, 0x0a, 0x47, 0x65, 0x74, 0x4d, 0x69, 0x6e, 0x49, 0x74, 0x65,
+	0x6d, 0x12, 0x19, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x4d, 0x69,
+	0x6e, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x66,
+	0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x4d, 0x69, 0x6e, 0x49, 0x74, 0x65, 0x6d,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x4e, 0x0a, 0x0d, 0x52, 0x65,
+	0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x12, 0x1c, 0x2e, 0x66, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x61, 0x78, 0x49, 0x74,
+	0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x66, 0x69, 0x6c, 0x74,
+	0x65, 0x72, 0x2e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x4e, 0x0a, 0x0d, 0x52, 0x65,
+	0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x69, 0x6e, 0x49, 0x74, 0x65, 0x6d, 0x12, 0x1c, 0x2e, 0x66, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x69, 0x6e, 0x49, 0x74,
+	0x65, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x66, 0x69, 0x6c, 0x74,
+	0x65, 0x72, 0x2e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x4d, 0x69, 0x6e, 0x49, 0x74, 0x65, 0x6d,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x4b, 0x0a, 0x0c, 0x4c, 0x65,
+	0x61, 0x73, 0x65, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x12, 0x1b, 0x2e, 0x66, 0x69, 0x6c,
+	0x74, 0x65, 0x72, 0x2e, 0x4c, 0x65, 0x61, 0x73, 0x65, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72,
+	0x2e, 0x4c, 0x65, 0x61, 0x73, 0x65, 0x4d, 0x61, 0x78, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x30, 0x0a, 0x03, 0x41, 0x63, 0x6b, 0x12, 0x12,
+	0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x41, 0x63, 0x6b, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x13, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x41, 0x63, 0x6b, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x33, 0x0a, 0x04, 0x4e, 0x61, 0x63,
+	0x6b, 0x12, 0x13, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x4e, 0x61, 0x63, 0x6b, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x14, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e,
+	0x4e, 0x61, 0x63, 0x6b, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x46,
+	0x0a, 0x09, 0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x12, 0x18, 0x2e, 0x66, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x2e, 0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x53,
+	0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x12, 0x45, 0x0a, 0x0a, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65,
+	0x54, 0x6f, 0x70, 0x4b, 0x12, 0x19, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x52, 0x65,
+	0x6d, 0x6f, 0x76, 0x65, 0x54, 0x6f, 0x70, 0x4b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x1a, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x54,
+	0x6f, 0x70, 0x4b, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x48, 0x0a,
+	0x0b, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x41, 0x62, 0x6f, 0x76, 0x65, 0x12, 0x1a, 0x2e, 0x66,
+	0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x41, 0x62, 0x6f, 0x76,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65,
+	0x72, 0x2e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x41, 0x62, 0x6f, 0x76, 0x65, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x38, 0x0a, 0x05, 0x44, 0x72, 0x61, 0x69, 0x6e,
+	0x12, 0x14, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x44, 0x72, 0x61, 0x69, 0x6e, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x15, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e,
+	0x44, 0x72, 0x61, 0x69, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x30,
+	0x01, 0x12, 0x4c, 0x0a, 0x0e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x54, 0x68, 0x72, 0x65, 0x73, 0x68,
+	0x6f, 0x6c, 0x64, 0x12, 0x1d, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x57, 0x61, 0x74,
+	0x63, 0x68, 0x54, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x17, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x54, 0x68, 0x72, 0x65,
+	0x73, 0x68, 0x6f, 0x6c, 0x64, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x22, 0x00, 0x30, 0x01, 0x12,
+	0x45, 0x0a, 0x0e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x45, 0x76, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x12, 0x1d, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68,
+	0x45, 0x76, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x10, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x45, 0x76, 0x69, 0x63, 0x74, 0x69,
+	0x6f, 0x6e, 0x22, 0x00, 0x30, 0x01, 0x12, 0x3c, 0x0a, 0x07, 0x47, 0x65, 0x74, 0x53, 0x69, 0x7a,
+	0x65, 0x12, 0x16, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x53, 0x69,
+	0x7a, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x66, 0x69, 0x6c, 0x74,
+	0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x22, 0x00, 0x12, 0x36, 0x0a, 0x05, 0x43, 0x6c, 0x65, 0x61, 0x72, 0x12, 0x14, 0x2e,
+	0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x43, 0x6c, 0x65, 0x61, 0x72, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x15, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x43, 0x6c, 0x65,
+	0x61, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x48, 0x0a, 0x0b,
+	0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x12, 0x1a, 0x2e, 0x66, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x53, 0x63, 0x6f, 0x72, 0x65,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72,
+	0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x48, 0x0a, 0x0b, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65,
+	0x42, 0x79, 0x4b, 0x65, 0x79, 0x12, 0x1a, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x44,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x42, 0x79, 0x4b, 0x65, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1b, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74,
+	0x65, 0x42, 0x79, 0x4b, 0x65, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00,
+	0x12, 0x3c, 0x0a, 0x07, 0x47, 0x65, 0x74, 0x54, 0x6f, 0x70, 0x4b, 0x12, 0x16, 0x2e, 0x66, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x54, 0x6f, 0x70, 0x4b, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74,
+	0x54, 0x6f, 0x70, 0x4b, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x45,
+	0x0a, 0x0a, 0x47, 0x65, 0x74, 0x42, 0x6f, 0x74, 0x74, 0x6f, 0x6d, 0x4b, 0x12, 0x19, 0x2e, 0x66,
+	0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x42, 0x6f, 0x74, 0x74, 0x6f, 0x6d, 0x4b,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72,
+	0x2e, 0x47, 0x65, 0x74, 0x42, 0x6f, 0x74, 0x74, 0x6f, 0x6d, 0x4b, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x3f, 0x0a, 0x08, 0x47, 0x65, 0x74, 0x52, 0x61, 0x6e, 0x67,
+	0x65, 0x12, 0x17, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x52, 0x61,
+	0x6e, 0x67, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x18, 0x2e, 0x66, 0x69, 0x6c,
+	0x74, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x44, 0x0a, 0x09, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x12, 0x18, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x70,
+	0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e,
+	0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x22, 0x00, 0x30, 0x01, 0x12, 0x3c, 0x0a, 0x07,
+	0x50, 0x72, 0x6f, 0x6d, 0x6f, 0x74, 0x65, 0x12, 0x16, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72,
+	0x2e, 0x50, 0x72, 0x6f, 0x6d, 0x6f, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x17, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x50, 0x72, 0x6f, 0x6d, 0x6f, 0x74, 0x65,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x3c, 0x0a, 0x07, 0x47, 0x65,
+	0x74, 0x42, 0x61, 0x6e, 0x64, 0x12, 0x16, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x47,
+	0x65, 0x74, 0x42, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e,
+	0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x42, 0x61, 0x6e, 0x64, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x3c, 0x0a, 0x07, 0x53, 0x65, 0x74, 0x42,
+	0x61, 0x6e, 0x64, 0x12, 0x16, 0x2e, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x2e, 0x53, 0x65, 0x74,
+	0x42, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x66, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x2e, 0x53, 0x65, 0x74, 0x42, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x42, 0x10, 0x5a, 0x0e, 0x2e, 0x2f, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x2f, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_proto_filter_filter_proto_rawDescOnce sync.Once
+	file_proto_filter_filter_proto_rawDescData = file_proto_filter_filter_proto_rawDesc
+)
+
+func file_proto_filter_filter_proto_rawDescGZIP() []byte {
+	file_proto_filter_filter_proto_rawDescOnce.Do(func() {
+		file_proto_filter_filter_proto_rawDescData = protoimpl.X.CompressGZIP(file_proto_filter_filter_proto_rawDescData)
+	})
+	return file_proto_filter_filter_proto_rawDescData
+}
+
+var file_proto_filter_filter_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_proto_filter_filter_proto_msgTypes = make([]protoimpl.MessageInfo, 55)
+var file_proto_filter_filter_proto_goTypes = []interface{}{
+	(InsertOutcome)(0),            // 0: filter.InsertOutcome
+	(EvictReason)(0),              // 1: filter.EvictReason
+	(*FilterItem)(nil),            // 2: filter.FilterItem
+	(*InsertItemRequest)(nil),     // 3: filter.InsertItemRequest
+	(*InsertItemResponse)(nil),    // 4: filter.InsertItemResponse
+	(*InsertItemsRequest)(nil),    // 5: filter.InsertItemsRequest
+	(*InsertItemsResponse)(nil),   // 6: filter.InsertItemsResponse
+	(*InsertStreamResponse)(nil),  // 7: filter.InsertStreamResponse
+	(*GetMaxItemRequest)(nil),     // 8: filter.GetMaxItemRequest
+	(*GetMaxItemResponse)(nil),    // 9: filter.GetMaxItemResponse
+	(*GetMinItemRequest)(nil),     // 10: filter.GetMinItemRequest
+	(*GetMinItemResponse)(nil),    // 11: filter.GetMinItemResponse
+	(*RemoveMaxItemRequest)(nil),  // 12: filter.RemoveMaxItemRequest
+	(*RemoveMaxItemResponse)(nil), // 13: filter.RemoveMaxItemResponse
+	(*RemoveMinItemRequest)(nil),  // 14: filter.RemoveMinItemRequest
+	(*RemoveMinItemResponse)(nil), // 15: filter.RemoveMinItemResponse
+	(*LeaseMaxItemRequest)(nil),   // 16: filter.LeaseMaxItemRequest
+	(*LeaseMaxItemResponse)(nil),  // 17: filter.LeaseMaxItemResponse
+	(*AckRequest)(nil),            // 18: filter.AckRequest
+	(*AckResponse)(nil),           // 19: filter.AckResponse
+	(*NackRequest)(nil),           // 20: filter.NackRequest
+	(*NackResponse)(nil),          // 21: filter.NackResponse
+	(*SubscribeRequest)(nil),      // 22: filter.SubscribeRequest
+	(*SubscribeResponse)(nil),     // 23: filter.SubscribeResponse
+	(*WatchThresholdRequest)(nil), // 24: filter.WatchThresholdRequest
+	(*ThresholdUpdate)(nil),       // 25: filter.ThresholdUpdate
+	(*WatchEvictionsRequest)(nil), // 26: filter.WatchEvictionsRequest
+	(*Eviction)(nil),              // 27: filter.Eviction
+	(*GetSizeRequest)(nil),        // 28: filter.GetSizeRequest
+	(*GetSizeResponse)(nil),       // 29: filter.GetSizeResponse
+	(*ClearRequest)(nil),          // 30: filter.ClearRequest
+	(*ClearResponse)(nil),         // 31: filter.ClearResponse
+	(*FilterChange)(nil),          // 32: filter.FilterChange
+	(*ReplicationRecord)(nil),     // 33: filter.ReplicationRecord
+	(*ReplicateRequest)(nil),      // 34: filter.ReplicateRequest
+	(*PromoteRequest)(nil),        // 35: filter.PromoteRequest
+	(*PromoteResponse)(nil),       // 36: filter.PromoteResponse
+	(*GetBandRequest)(nil),        // 37: filter.GetBandRequest
+	(*GetBandResponse)(nil),       // 38: filter.GetBandResponse
+	(*SetBandRequest)(nil),        // 39: filter.SetBandRequest
+	(*SetBandResponse)(nil),       // 40: filter.SetBandResponse
+	(*UpdateScoreRequest)(nil),    // 41: filter.UpdateScoreRequest
+	(*UpdateScoreResponse)(nil),   // 42: filter.UpdateScoreResponse
+	(*DeleteByKeyRequest)(nil),    // 43: filter.DeleteByKeyRequest
+	(*DeleteByKeyResponse)(nil),   // 44: filter.DeleteByKeyResponse
+	(*RemoveTopKRequest)(nil),     // 45: filter.RemoveTopKRequest
+	(*RemoveTopKResponse)(nil),    // 46: filter.RemoveTopKResponse
+	(*RemoveAboveRequest)(nil),    // 47: filter.RemoveAboveRequest
+	(*RemoveAboveResponse)(nil),   // 48: filter.RemoveAboveResponse
+	(*DrainRequest)(nil),          // 49: filter.DrainRequest
+	(*DrainResponse)(nil),         // 50: filter.DrainResponse
+	(*GetTopKRequest)(nil),        // 51: filter.GetTopKRequest
+	(*GetTopKResponse)(nil),       // 52: filter.GetTopKResponse
+	(*GetBottomKRequest)(nil),     // 53: filter.GetBottomKRequest
+	(*GetBottomKResponse)(nil),    // 54: filter.GetBottomKResponse
+	(*GetRangeRequest)(nil),       // 55: filter.GetRangeRequest
+	(*GetRangeResponse)(nil),      // 56: filter.GetRangeResponse
+}
+var file_proto_filter_filter_proto_depIdxs = []int32{
+	2,  // 0: filter.InsertItemRequest.item:type_name -> filter.FilterItem
+	0,  // 1: filter.InsertItemResponse.outcome:type_name -> filter.InsertOutcome
+	2,  // 2: filter.InsertItemResponse.evicted:type_name -> filter.FilterItem
+	2,  // 3: filter.InsertItemsRequest.items:type_name -> filter.FilterItem
+	2,  // 4: filter.GetMaxItemResponse.item:type_name -> filter.FilterItem
+	2,  // 5: filter.GetMinItemResponse.item:type_name -> filter.FilterItem
+	2,  // 6: filter.RemoveMaxItemResponse.item:type_name -> filter.FilterItem
+	2,  // 7: filter.RemoveMinItemResponse.item:type_name -> filter.FilterItem
+	2,  // 8: filter.LeaseMaxItemResponse.item:type_name -> filter.FilterItem
+	2,  // 9: filter.SubscribeResponse.item:type_name -> filter.FilterItem
+	2,  // 10: filter.Eviction.item:type_name -> filter.FilterItem
+	1,  // 11: filter.Eviction.reason:type_name -> filter.EvictReason
+	2,  // 12: filter.FilterChange.inserted:type_name -> filter.FilterItem
+	2,  // 13: filter.FilterChange.removed:type_name -> filter.FilterItem
+	2,  // 14: filter.SetBandResponse.moved:type_name -> filter.FilterItem
+	2,  // 15: filter.UpdateScoreResponse.item:type_name -> filter.FilterItem
+	2,  // 16: filter.DeleteByKeyResponse.item:type_name -> filter.FilterItem
+	2,  // 17: filter.RemoveTopKResponse.items:type_name -> filter.FilterItem
+	2,  // 18: filter.RemoveAboveResponse.items:type_name -> filter.FilterItem
+	2,  // 19: filter.DrainResponse.items:type_name -> filter.FilterItem
+	2,  // 20: filter.GetTopKResponse.items:type_name -> filter.FilterItem
+	2,  // 21: filter.GetBottomKResponse.items:type_name -> filter.FilterItem
+	2,  // 22: filter.GetRangeResponse.items:type_name -> filter.FilterItem
+	3,  // 23: filter.FilterService.InsertItem:input_type -> filter.InsertItemRequest
+	5,  // 24: filter.FilterService.InsertItems:input_type -> filter.InsertItemsRequest
+	2,  // 25: filter.FilterService.InsertStream:input_type -> filter.FilterItem
+	8,  // 26: filter.FilterService.GetMaxItem:input_type -> filter.GetMaxItemRequest
+	10, // 27: filter.FilterService.GetMinItem:input_type -> filter.GetMinItemRequest
+	12, // 28: filter.FilterService.RemoveMaxItem:input_type -> filter.RemoveMaxItemRequest
+	14, // 29: filter.FilterService.RemoveMinItem:input_type -> filter.RemoveMinItemRequest
+	16, // 30: filter.FilterService.LeaseMaxItem:input_type -> filter.LeaseMaxItemRequest
+	18, // 31: filter.FilterService.Ack:input_type -> filter.AckRequest
+	20, // 32: filter.FilterService.Nack:input_type -> filter.NackRequest
+	22, // 33: filter.FilterService.Subscribe:input_type -> filter.SubscribeRequest
+	45, // 34: filter.FilterService.RemoveTopK:input_type -> filter.RemoveTopKRequest
+	47, // 35: filter.FilterService.RemoveAbove:input_type -> filter.RemoveAboveRequest
+	49, // 36: filter.FilterService.Drain:input_type -> filter.DrainRequest
+	24, // 37: filter.FilterService.WatchThreshold:input_type -> filter.WatchThresholdRequest
+	26, // 38: filter.FilterService.WatchEvictions:input_type -> filter.WatchEvictionsRequest
+	28, // 39: filter.FilterService.GetSize:input_type -> filter.GetSizeRequest
+	30, // 40: filter.FilterService.Clear:input_type -> filter.ClearRequest
+	41, // 41: filter.FilterService.UpdateScore:input_type -> filter.UpdateScoreRequest
+	43, // 42: filter.FilterService.DeleteByKey:input_type -> filter.DeleteByKeyRequest
+	51, // 43: filter.FilterService.GetTopK:input_type -> filter.GetTopKRequest
+	53, // 44: filter.FilterService.GetBottomK:input_type -> filter.GetBottomKRequest
+	55, // 45: filter.FilterService.GetRange:input_type -> filter.GetRangeRequest
+	34, // 46: filter.FilterService.Replicate:input_type -> filter.ReplicateRequest
+	35, // 47: filter.FilterService.Promote:input_type -> filter.PromoteRequest
+	37, // 48: filter.FilterService.GetBand:input_type -> filter.GetBandRequest
+	39, // 49: filter.FilterService.SetBand:input_type -> filter.SetBandRequest
+	4,  // 50: filter.FilterService.InsertItem:output_type -> filter.InsertItemResponse
+	6,  // 51: filter.FilterService.InsertItems:output_type -> filter.InsertItemsResponse
+	7,  // 52: filter.FilterService.InsertStream:output_type -> filter.InsertStreamResponse
+	9,  // 53: filter.FilterService.GetMaxItem:output_type -> filter.GetMaxItemResponse
+	11, // 54: filter.FilterService.GetMinItem:output_type -> filter.GetMinItemResponse
+	13, // 55: filter.FilterService.RemoveMaxItem:output_type -> filter.RemoveMaxItemResponse
+	15, // 56: filter.FilterService.RemoveMinItem:output_type -> filter.RemoveMinItemResponse
+	17, // 57: filter.FilterService.LeaseMaxItem:output_type -> filter.LeaseMaxItemResponse
+	19, // 58: filter.FilterService.Ack:output_type -> filter.AckResponse
+	21, // 59: filter.FilterService.Nack:output_type -> filter.NackResponse
+	23, // 60: filter.FilterService.Subscribe:output_type -> filter.SubscribeResponse
+	46, // 61: filter.FilterService.RemoveTopK:output_type -> filter.RemoveTopKResponse
+	48, // 62: filter.FilterService.RemoveAbove:output_type -> filter.RemoveAboveResponse
+	50, // 63: filter.FilterService.Drain:output_type -> filter.DrainResponse
+	25, // 64: filter.FilterService.WatchThreshold:output_type -> filter.ThresholdUpdate
+	27, // 65: filter.FilterService.WatchEvictions:output_type -> filter.Eviction
+	29, // 66: filter.FilterService.GetSize:output_type -> filter.GetSizeResponse
+	31, // 67: filter.FilterService.Clear:output_type -> filter.ClearResponse
+	42, // 68: filter.FilterService.UpdateScore:output_type -> filter.UpdateScoreResponse
+	44, // 69: filter.FilterService.DeleteByKey:output_type -> filter.DeleteByKeyResponse
+	52, // 70: filter.FilterService.GetTopK:output_type -> filter.GetTopKResponse
+	54, // 71: filter.FilterService.GetBottomK:output_type -> filter.GetBottomKResponse
+	56, // 72: filter.FilterService.GetRange:output_type -> filter.GetRangeResponse
+	33, // 73: filter.FilterService.Replicate:output_type -> filter.ReplicationRecord
+	36, // 74: filter.FilterService.Promote:output_type -> filter.PromoteResponse
+	38, // 75: filter.FilterService.GetBand:output_type -> filter.GetBandResponse
+	40, // 76: filter.FilterService.SetBand:output_type -> filter.SetBandResponse
+	50, // [50:77] is the sub-list for method output_type
+	23, // [23:50] is the sub-list for method input_type
+	23, // [23:23] is the sub-list for extension type_name
+	23, // [23:23] is the sub-list for extension extendee
+	0,  // [0:23] is the sub-list for field type_name
+}
+
+func init() { file_proto_filter_filter_proto_init() }
+func file_proto_filter_filter_proto_init() {
+	if File_proto_filter_filter_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_proto_filter_filter_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FilterItem); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*InsertItemRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*InsertItemResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*InsertItemsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*InsertItemsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*InsertStreamResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
 		}
 		file_proto_filter_filter_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetMaxItemRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetMaxItemResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetMinItemRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*GetMinItemResponse); i {
 			case 0:
 				return &v.state
@@ -906,8 +3604,176 @@ func file_proto_filter_filter_proto_init() {
 				return nil
 			}
 		}
-		file_proto_filter_filter_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*RemoveMaxItemRequest); i {
+		file_proto_filter_filter_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RemoveMaxItemRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RemoveMaxItemResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RemoveMinItemRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RemoveMinItemResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LeaseMaxItemRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LeaseMaxItemResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AckRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AckResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NackRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NackResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SubscribeRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SubscribeResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WatchThresholdRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ThresholdUpdate); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WatchEvictionsRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -918,8 +3784,8 @@ func file_proto_filter_filter_proto_init() {
 				return nil
 			}
 		}
-		file_proto_filter_filter_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*RemoveMaxItemResponse); i {
+		file_proto_filter_filter_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Eviction); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -930,8 +3796,8 @@ func file_proto_filter_filter_proto_init() {
 				return nil
 			}
 		}
-		file_proto_filter_filter_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*RemoveMinItemRequest); i {
+		file_proto_filter_filter_proto_msgTypes[26].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetSizeRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -942,8 +3808,8 @@ func file_proto_filter_filter_proto_init() {
 				return nil
 			}
 		}
-		file_proto_filter_filter_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*RemoveMinItemResponse); i {
+		file_proto_filter_filter_proto_msgTypes[27].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetSizeResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -954,8 +3820,8 @@ func file_proto_filter_filter_proto_init() {
 				return nil
 			}
 		}
-		file_proto_filter_filter_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GetSizeRequest); i {
+		file_proto_filter_filter_proto_msgTypes[28].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClearRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -966,8 +3832,8 @@ func file_proto_filter_filter_proto_init() {
 				return nil
 			}
 		}
-		file_proto_filter_filter_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GetSizeResponse); i {
+		file_proto_filter_filter_proto_msgTypes[29].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClearResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -978,8 +3844,8 @@ func file_proto_filter_filter_proto_init() {
 				return nil
 			}
 		}
-		file_proto_filter_filter_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ClearRequest); i {
+		file_proto_filter_filter_proto_msgTypes[30].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FilterChange); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -990,8 +3856,284 @@ func file_proto_filter_filter_proto_init() {
 				return nil
 			}
 		}
-		file_proto_filter_filter_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ClearResponse); i {
+		file_proto_filter_filter_proto_msgTypes[31].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReplicationRecord); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[32].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReplicateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[33].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PromoteRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[34].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PromoteResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[35].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetBandRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[36].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetBandResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[37].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SetBandRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[38].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SetBandResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[39].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UpdateScoreRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[40].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UpdateScoreResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[41].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteByKeyRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[42].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteByKeyResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[43].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RemoveTopKRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[44].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RemoveTopKResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[45].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RemoveAboveRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[46].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RemoveAboveResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[47].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DrainRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[48].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DrainResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[49].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetTopKRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[50].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetTopKResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[51].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetBottomKRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[52].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetBottomKResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[53].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetRangeRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_filter_proto_msgTypes[54].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetRangeResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1003,18 +4145,28 @@ func file_proto_filter_filter_proto_init() {
 			}
 		}
 	}
+	file_proto_filter_filter_proto_msgTypes[0].OneofWrappers = []interface{}{}
+	file_proto_filter_filter_proto_msgTypes[2].OneofWrappers = []interface{}{}
+	file_proto_filter_filter_proto_msgTypes[4].OneofWrappers = []interface{}{}
+	file_proto_filter_filter_proto_msgTypes[10].OneofWrappers = []interface{}{}
+	file_proto_filter_filter_proto_msgTypes[12].OneofWrappers = []interface{}{}
+	file_proto_filter_filter_proto_msgTypes[23].OneofWrappers = []interface{}{}
+	file_proto_filter_filter_proto_msgTypes[36].OneofWrappers = []interface{}{}
+	file_proto_filter_filter_proto_msgTypes[37].OneofWrappers = []interface{}{}
+	file_proto_filter_filter_proto_msgTypes[47].OneofWrappers = []interface{}{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_proto_filter_filter_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   15,
+			NumEnums:      2,
+			NumMessages:   55,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_proto_filter_filter_proto_goTypes,
 		DependencyIndexes: file_proto_filter_filter_proto_depIdxs,
+		EnumInfos:         file_proto_filter_filter_proto_enumTypes,
 		MessageInfos:      file_proto_filter_filter_proto_msgTypes,
 	}.Build()
 	File_proto_filter_filter_proto = out.File
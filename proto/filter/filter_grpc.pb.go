@@ -23,12 +23,37 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type FilterServiceClient interface {
 	InsertItem(ctx context.Context, in *InsertItemRequest, opts ...grpc.CallOption) (*InsertItemResponse, error)
+	InsertItems(ctx context.Context, in *InsertItemsRequest, opts ...grpc.CallOption) (*InsertItemsResponse, error)
+	InsertStream(ctx context.Context, opts ...grpc.CallOption) (FilterService_InsertStreamClient, error)
 	GetMaxItem(ctx context.Context, in *GetMaxItemRequest, opts ...grpc.CallOption) (*GetMaxItemResponse, error)
 	GetMinItem(ctx context.Context, in *GetMinItemRequest, opts ...grpc.CallOption) (*GetMinItemResponse, error)
 	RemoveMaxItem(ctx context.Context, in *RemoveMaxItemRequest, opts ...grpc.CallOption) (*RemoveMaxItemResponse, error)
 	RemoveMinItem(ctx context.Context, in *RemoveMinItemRequest, opts ...grpc.CallOption) (*RemoveMinItemResponse, error)
+	LeaseMaxItem(ctx context.Context, in *LeaseMaxItemRequest, opts ...grpc.CallOption) (*LeaseMaxItemResponse, error)
+	Ack(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (*AckResponse, error)
+	Nack(ctx context.Context, in *NackRequest, opts ...grpc.CallOption) (*NackResponse, error)
+	Subscribe(ctx context.Context, opts ...grpc.CallOption) (FilterService_SubscribeClient, error)
+	RemoveTopK(ctx context.Context, in *RemoveTopKRequest, opts ...grpc.CallOption) (*RemoveTopKResponse, error)
+	RemoveAbove(ctx context.Context, in *RemoveAboveRequest, opts ...grpc.CallOption) (*RemoveAboveResponse, error)
+	Drain(ctx context.Context, in *DrainRequest, opts ...grpc.CallOption) (FilterService_DrainClient, error)
+	WatchThreshold(ctx context.Context, in *WatchThresholdRequest, opts ...grpc.CallOption) (FilterService_WatchThresholdClient, error)
+	WatchEvictions(ctx context.Context, in *WatchEvictionsRequest, opts ...grpc.CallOption) (FilterService_WatchEvictionsClient, error)
 	GetSize(ctx context.Context, in *GetSizeRequest, opts ...grpc.CallOption) (*GetSizeResponse, error)
 	Clear(ctx context.Context, in *ClearRequest, opts ...grpc.CallOption) (*ClearResponse, error)
+	// keyed items, NOT_FOUND if no item has the key
+	UpdateScore(ctx context.Context, in *UpdateScoreRequest, opts ...grpc.CallOption) (*UpdateScoreResponse, error)
+	DeleteByKey(ctx context.Context, in *DeleteByKeyRequest, opts ...grpc.CallOption) (*DeleteByKeyResponse, error)
+	// non-destructive queries, UNIMPLEMENTED for filter types that can't walk
+	// their heap
+	GetTopK(ctx context.Context, in *GetTopKRequest, opts ...grpc.CallOption) (*GetTopKResponse, error)
+	GetBottomK(ctx context.Context, in *GetBottomKRequest, opts ...grpc.CallOption) (*GetBottomKResponse, error)
+	GetRange(ctx context.Context, in *GetRangeRequest, opts ...grpc.CallOption) (*GetRangeResponse, error)
+	// replication, backups follow the primary's Replicate stream
+	Replicate(ctx context.Context, in *ReplicateRequest, opts ...grpc.CallOption) (FilterService_ReplicateClient, error)
+	Promote(ctx context.Context, in *PromoteRequest, opts ...grpc.CallOption) (*PromoteResponse, error)
+	// band ownership for score partitioned filters
+	GetBand(ctx context.Context, in *GetBandRequest, opts ...grpc.CallOption) (*GetBandResponse, error)
+	SetBand(ctx context.Context, in *SetBandRequest, opts ...grpc.CallOption) (*SetBandResponse, error)
 }
 
 type filterServiceClient struct {
@@ -48,6 +73,49 @@ func (c *filterServiceClient) InsertItem(ctx context.Context, in *InsertItemRequ
 	return out, nil
 }
 
+func (c *filterServiceClient) InsertItems(ctx context.Context, in *InsertItemsRequest, opts ...grpc.CallOption) (*InsertItemsResponse, error) {
+	out := new(InsertItemsResponse)
+	err := c.cc.Invoke(ctx, "/filter.FilterService/InsertItems", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) InsertStream(ctx context.Context, opts ...grpc.CallOption) (FilterService_InsertStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FilterService_ServiceDesc.Streams[0], "/filter.FilterService/InsertStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &filterServiceInsertStreamClient{stream}
+	return x, nil
+}
+
+type FilterService_InsertStreamClient interface {
+	Send(*FilterItem) error
+	CloseAndRecv() (*InsertStreamResponse, error)
+	grpc.ClientStream
+}
+
+type filterServiceInsertStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *filterServiceInsertStreamClient) Send(m *FilterItem) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *filterServiceInsertStreamClient) CloseAndRecv() (*InsertStreamResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(InsertStreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *filterServiceClient) GetMaxItem(ctx context.Context, in *GetMaxItemRequest, opts ...grpc.CallOption) (*GetMaxItemResponse, error) {
 	out := new(GetMaxItemResponse)
 	err := c.cc.Invoke(ctx, "/filter.FilterService/GetMaxItem", in, out, opts...)
@@ -84,6 +152,178 @@ func (c *filterServiceClient) RemoveMinItem(ctx context.Context, in *RemoveMinIt
 	return out, nil
 }
 
+func (c *filterServiceClient) LeaseMaxItem(ctx context.Context, in *LeaseMaxItemRequest, opts ...grpc.CallOption) (*LeaseMaxItemResponse, error) {
+	out := new(LeaseMaxItemResponse)
+	err := c.cc.Invoke(ctx, "/filter.FilterService/LeaseMaxItem", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) Ack(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (*AckResponse, error) {
+	out := new(AckResponse)
+	err := c.cc.Invoke(ctx, "/filter.FilterService/Ack", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) Nack(ctx context.Context, in *NackRequest, opts ...grpc.CallOption) (*NackResponse, error) {
+	out := new(NackResponse)
+	err := c.cc.Invoke(ctx, "/filter.FilterService/Nack", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) Subscribe(ctx context.Context, opts ...grpc.CallOption) (FilterService_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FilterService_ServiceDesc.Streams[1], "/filter.FilterService/Subscribe", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &filterServiceSubscribeClient{stream}
+	return x, nil
+}
+
+type FilterService_SubscribeClient interface {
+	Send(*SubscribeRequest) error
+	Recv() (*SubscribeResponse, error)
+	grpc.ClientStream
+}
+
+type filterServiceSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *filterServiceSubscribeClient) Send(m *SubscribeRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *filterServiceSubscribeClient) Recv() (*SubscribeResponse, error) {
+	m := new(SubscribeResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *filterServiceClient) RemoveTopK(ctx context.Context, in *RemoveTopKRequest, opts ...grpc.CallOption) (*RemoveTopKResponse, error) {
+	out := new(RemoveTopKResponse)
+	err := c.cc.Invoke(ctx, "/filter.FilterService/RemoveTopK", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) RemoveAbove(ctx context.Context, in *RemoveAboveRequest, opts ...grpc.CallOption) (*RemoveAboveResponse, error) {
+	out := new(RemoveAboveResponse)
+	err := c.cc.Invoke(ctx, "/filter.FilterService/RemoveAbove", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) Drain(ctx context.Context, in *DrainRequest, opts ...grpc.CallOption) (FilterService_DrainClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FilterService_ServiceDesc.Streams[2], "/filter.FilterService/Drain", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &filterServiceDrainClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type FilterService_DrainClient interface {
+	Recv() (*DrainResponse, error)
+	grpc.ClientStream
+}
+
+type filterServiceDrainClient struct {
+	grpc.ClientStream
+}
+
+func (x *filterServiceDrainClient) Recv() (*DrainResponse, error) {
+	m := new(DrainResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *filterServiceClient) WatchThreshold(ctx context.Context, in *WatchThresholdRequest, opts ...grpc.CallOption) (FilterService_WatchThresholdClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FilterService_ServiceDesc.Streams[3], "/filter.FilterService/WatchThreshold", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &filterServiceWatchThresholdClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type FilterService_WatchThresholdClient interface {
+	Recv() (*ThresholdUpdate, error)
+	grpc.ClientStream
+}
+
+type filterServiceWatchThresholdClient struct {
+	grpc.ClientStream
+}
+
+func (x *filterServiceWatchThresholdClient) Recv() (*ThresholdUpdate, error) {
+	m := new(ThresholdUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *filterServiceClient) WatchEvictions(ctx context.Context, in *WatchEvictionsRequest, opts ...grpc.CallOption) (FilterService_WatchEvictionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FilterService_ServiceDesc.Streams[4], "/filter.FilterService/WatchEvictions", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &filterServiceWatchEvictionsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type FilterService_WatchEvictionsClient interface {
+	Recv() (*Eviction, error)
+	grpc.ClientStream
+}
+
+type filterServiceWatchEvictionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *filterServiceWatchEvictionsClient) Recv() (*Eviction, error) {
+	m := new(Eviction)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *filterServiceClient) GetSize(ctx context.Context, in *GetSizeRequest, opts ...grpc.CallOption) (*GetSizeResponse, error) {
 	out := new(GetSizeResponse)
 	err := c.cc.Invoke(ctx, "/filter.FilterService/GetSize", in, out, opts...)
@@ -102,17 +342,146 @@ func (c *filterServiceClient) Clear(ctx context.Context, in *ClearRequest, opts
 	return out, nil
 }
 
+func (c *filterServiceClient) UpdateScore(ctx context.Context, in *UpdateScoreRequest, opts ...grpc.CallOption) (*UpdateScoreResponse, error) {
+	out := new(UpdateScoreResponse)
+	err := c.cc.Invoke(ctx, "/filter.FilterService/UpdateScore", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) DeleteByKey(ctx context.Context, in *DeleteByKeyRequest, opts ...grpc.CallOption) (*DeleteByKeyResponse, error) {
+	out := new(DeleteByKeyResponse)
+	err := c.cc.Invoke(ctx, "/filter.FilterService/DeleteByKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) GetTopK(ctx context.Context, in *GetTopKRequest, opts ...grpc.CallOption) (*GetTopKResponse, error) {
+	out := new(GetTopKResponse)
+	err := c.cc.Invoke(ctx, "/filter.FilterService/GetTopK", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) GetBottomK(ctx context.Context, in *GetBottomKRequest, opts ...grpc.CallOption) (*GetBottomKResponse, error) {
+	out := new(GetBottomKResponse)
+	err := c.cc.Invoke(ctx, "/filter.FilterService/GetBottomK", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) GetRange(ctx context.Context, in *GetRangeRequest, opts ...grpc.CallOption) (*GetRangeResponse, error) {
+	out := new(GetRangeResponse)
+	err := c.cc.Invoke(ctx, "/filter.FilterService/GetRange", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) Replicate(ctx context.Context, in *ReplicateRequest, opts ...grpc.CallOption) (FilterService_ReplicateClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FilterService_ServiceDesc.Streams[5], "/filter.FilterService/Replicate", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &filterServiceReplicateClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type FilterService_ReplicateClient interface {
+	Recv() (*ReplicationRecord, error)
+	grpc.ClientStream
+}
+
+type filterServiceReplicateClient struct {
+	grpc.ClientStream
+}
+
+func (x *filterServiceReplicateClient) Recv() (*ReplicationRecord, error) {
+	m := new(ReplicationRecord)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *filterServiceClient) Promote(ctx context.Context, in *PromoteRequest, opts ...grpc.CallOption) (*PromoteResponse, error) {
+	out := new(PromoteResponse)
+	err := c.cc.Invoke(ctx, "/filter.FilterService/Promote", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) GetBand(ctx context.Context, in *GetBandRequest, opts ...grpc.CallOption) (*GetBandResponse, error) {
+	out := new(GetBandResponse)
+	err := c.cc.Invoke(ctx, "/filter.FilterService/GetBand", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) SetBand(ctx context.Context, in *SetBandRequest, opts ...grpc.CallOption) (*SetBandResponse, error) {
+	out := new(SetBandResponse)
+	err := c.cc.Invoke(ctx, "/filter.FilterService/SetBand", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // FilterServiceServer is the server API for FilterService service.
 // All implementations must embed UnimplementedFilterServiceServer
 // for forward compatibility
 type FilterServiceServer interface {
 	InsertItem(context.Context, *InsertItemRequest) (*InsertItemResponse, error)
+	InsertItems(context.Context, *InsertItemsRequest) (*InsertItemsResponse, error)
+	InsertStream(FilterService_InsertStreamServer) error
 	GetMaxItem(context.Context, *GetMaxItemRequest) (*GetMaxItemResponse, error)
 	GetMinItem(context.Context, *GetMinItemRequest) (*GetMinItemResponse, error)
 	RemoveMaxItem(context.Context, *RemoveMaxItemRequest) (*RemoveMaxItemResponse, error)
 	RemoveMinItem(context.Context, *RemoveMinItemRequest) (*RemoveMinItemResponse, error)
+	LeaseMaxItem(context.Context, *LeaseMaxItemRequest) (*LeaseMaxItemResponse, error)
+	Ack(context.Context, *AckRequest) (*AckResponse, error)
+	Nack(context.Context, *NackRequest) (*NackResponse, error)
+	Subscribe(FilterService_SubscribeServer) error
+	RemoveTopK(context.Context, *RemoveTopKRequest) (*RemoveTopKResponse, error)
+	RemoveAbove(context.Context, *RemoveAboveRequest) (*RemoveAboveResponse, error)
+	Drain(*DrainRequest, FilterService_DrainServer) error
+	WatchThreshold(*WatchThresholdRequest, FilterService_WatchThresholdServer) error
+	WatchEvictions(*WatchEvictionsRequest, FilterService_WatchEvictionsServer) error
 	GetSize(context.Context, *GetSizeRequest) (*GetSizeResponse, error)
 	Clear(context.Context, *ClearRequest) (*ClearResponse, error)
+	// keyed items, NOT_FOUND if no item has the key
+	UpdateScore(context.Context, *UpdateScoreRequest) (*UpdateScoreResponse, error)
+	DeleteByKey(context.Context, *DeleteByKeyRequest) (*DeleteByKeyResponse, error)
+	// non-destructive queries, UNIMPLEMENTED for filter types that can't walk
+	// their heap
+	GetTopK(context.Context, *GetTopKRequest) (*GetTopKResponse, error)
+	GetBottomK(context.Context, *GetBottomKRequest) (*GetBottomKResponse, error)
+	GetRange(context.Context, *GetRangeRequest) (*GetRangeResponse, error)
+	// replication, backups follow the primary's Replicate stream
+	Replicate(*ReplicateRequest, FilterService_ReplicateServer) error
+	Promote(context.Context, *PromoteRequest) (*PromoteResponse, error)
+	// band ownership for score partitioned filters
+	GetBand(context.Context, *GetBandRequest) (*GetBandResponse, error)
+	SetBand(context.Context, *SetBandRequest) (*SetBandResponse, error)
 	mustEmbedUnimplementedFilterServiceServer()
 }
 
@@ -123,6 +492,12 @@ type UnimplementedFilterServiceServer struct {
 func (UnimplementedFilterServiceServer) InsertItem(context.Context, *InsertItemRequest) (*InsertItemResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method InsertItem not implemented")
 }
+func (UnimplementedFilterServiceServer) InsertItems(context.Context, *InsertItemsRequest) (*InsertItemsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InsertItems not implemented")
+}
+func (UnimplementedFilterServiceServer) InsertStream(FilterService_InsertStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method InsertStream not implemented")
+}
 func (UnimplementedFilterServiceServer) GetMaxItem(context.Context, *GetMaxItemRequest) (*GetMaxItemResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetMaxItem not implemented")
 }
@@ -135,12 +510,66 @@ func (UnimplementedFilterServiceServer) RemoveMaxItem(context.Context, *RemoveMa
 func (UnimplementedFilterServiceServer) RemoveMinItem(context.Context, *RemoveMinItemRequest) (*RemoveMinItemResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RemoveMinItem not implemented")
 }
+func (UnimplementedFilterServiceServer) LeaseMaxItem(context.Context, *LeaseMaxItemRequest) (*LeaseMaxItemResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LeaseMaxItem not implemented")
+}
+func (UnimplementedFilterServiceServer) Ack(context.Context, *AckRequest) (*AckResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Ack not implemented")
+}
+func (UnimplementedFilterServiceServer) Nack(context.Context, *NackRequest) (*NackResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Nack not implemented")
+}
+func (UnimplementedFilterServiceServer) Subscribe(FilterService_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedFilterServiceServer) RemoveTopK(context.Context, *RemoveTopKRequest) (*RemoveTopKResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveTopK not implemented")
+}
+func (UnimplementedFilterServiceServer) RemoveAbove(context.Context, *RemoveAboveRequest) (*RemoveAboveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveAbove not implemented")
+}
+func (UnimplementedFilterServiceServer) Drain(*DrainRequest, FilterService_DrainServer) error {
+	return status.Errorf(codes.Unimplemented, "method Drain not implemented")
+}
+func (UnimplementedFilterServiceServer) WatchThreshold(*WatchThresholdRequest, FilterService_WatchThresholdServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchThreshold not implemented")
+}
+func (UnimplementedFilterServiceServer) WatchEvictions(*WatchEvictionsRequest, FilterService_WatchEvictionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvictions not implemented")
+}
 func (UnimplementedFilterServiceServer) GetSize(context.Context, *GetSizeRequest) (*GetSizeResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetSize not implemented")
 }
 func (UnimplementedFilterServiceServer) Clear(context.Context, *ClearRequest) (*ClearResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Clear not implemented")
 }
+func (UnimplementedFilterServiceServer) UpdateScore(context.Context, *UpdateScoreRequest) (*UpdateScoreResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateScore not implemented")
+}
+func (UnimplementedFilterServiceServer) DeleteByKey(context.Context, *DeleteByKeyRequest) (*DeleteByKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteByKey not implemented")
+}
+func (UnimplementedFilterServiceServer) GetTopK(context.Context, *GetTopKRequest) (*GetTopKResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTopK not implemented")
+}
+func (UnimplementedFilterServiceServer) GetBottomK(context.Context, *GetBottomKRequest) (*GetBottomKResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBottomK not implemented")
+}
+func (UnimplementedFilterServiceServer) GetRange(context.Context, *GetRangeRequest) (*GetRangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRange not implemented")
+}
+func (UnimplementedFilterServiceServer) Replicate(*ReplicateRequest, FilterService_ReplicateServer) error {
+	return status.Errorf(codes.Unimplemented, "method Replicate not implemented")
+}
+func (UnimplementedFilterServiceServer) Promote(context.Context, *PromoteRequest) (*PromoteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Promote not implemented")
+}
+func (UnimplementedFilterServiceServer) GetBand(context.Context, *GetBandRequest) (*GetBandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBand not implemented")
+}
+func (UnimplementedFilterServiceServer) SetBand(context.Context, *SetBandRequest) (*SetBandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetBand not implemented")
+}
 func (UnimplementedFilterServiceServer) mustEmbedUnimplementedFilterServiceServer() {}
 
 // UnsafeFilterServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -172,6 +601,50 @@ func _FilterService_InsertItem_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _FilterService_InsertItems_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InsertItemsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).InsertItems(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filter.FilterService/InsertItems",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).InsertItems(ctx, req.(*InsertItemsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_InsertStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FilterServiceServer).InsertStream(&filterServiceInsertStreamServer{stream})
+}
+
+type FilterService_InsertStreamServer interface {
+	SendAndClose(*InsertStreamResponse) error
+	Recv() (*FilterItem, error)
+	grpc.ServerStream
+}
+
+type filterServiceInsertStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *filterServiceInsertStreamServer) SendAndClose(m *InsertStreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *filterServiceInsertStreamServer) Recv() (*FilterItem, error) {
+	m := new(FilterItem)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _FilterService_GetMaxItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetMaxItemRequest)
 	if err := dec(in); err != nil {
@@ -244,6 +717,185 @@ func _FilterService_RemoveMinItem_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _FilterService_LeaseMaxItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaseMaxItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).LeaseMaxItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filter.FilterService/LeaseMaxItem",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).LeaseMaxItem(ctx, req.(*LeaseMaxItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_Ack_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).Ack(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filter.FilterService/Ack",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).Ack(ctx, req.(*AckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_Nack_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).Nack(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filter.FilterService/Nack",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).Nack(ctx, req.(*NackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FilterServiceServer).Subscribe(&filterServiceSubscribeServer{stream})
+}
+
+type FilterService_SubscribeServer interface {
+	Send(*SubscribeResponse) error
+	Recv() (*SubscribeRequest, error)
+	grpc.ServerStream
+}
+
+type filterServiceSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *filterServiceSubscribeServer) Send(m *SubscribeResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *filterServiceSubscribeServer) Recv() (*SubscribeRequest, error) {
+	m := new(SubscribeRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _FilterService_RemoveTopK_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveTopKRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).RemoveTopK(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filter.FilterService/RemoveTopK",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).RemoveTopK(ctx, req.(*RemoveTopKRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_RemoveAbove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveAboveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).RemoveAbove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filter.FilterService/RemoveAbove",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).RemoveAbove(ctx, req.(*RemoveAboveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_Drain_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DrainRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FilterServiceServer).Drain(m, &filterServiceDrainServer{stream})
+}
+
+type FilterService_DrainServer interface {
+	Send(*DrainResponse) error
+	grpc.ServerStream
+}
+
+type filterServiceDrainServer struct {
+	grpc.ServerStream
+}
+
+func (x *filterServiceDrainServer) Send(m *DrainResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _FilterService_WatchThreshold_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchThresholdRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FilterServiceServer).WatchThreshold(m, &filterServiceWatchThresholdServer{stream})
+}
+
+type FilterService_WatchThresholdServer interface {
+	Send(*ThresholdUpdate) error
+	grpc.ServerStream
+}
+
+type filterServiceWatchThresholdServer struct {
+	grpc.ServerStream
+}
+
+func (x *filterServiceWatchThresholdServer) Send(m *ThresholdUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _FilterService_WatchEvictions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEvictionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FilterServiceServer).WatchEvictions(m, &filterServiceWatchEvictionsServer{stream})
+}
+
+type FilterService_WatchEvictionsServer interface {
+	Send(*Eviction) error
+	grpc.ServerStream
+}
+
+type filterServiceWatchEvictionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *filterServiceWatchEvictionsServer) Send(m *Eviction) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _FilterService_GetSize_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetSizeRequest)
 	if err := dec(in); err != nil {
@@ -280,6 +932,171 @@ func _FilterService_Clear_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _FilterService_UpdateScore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateScoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).UpdateScore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filter.FilterService/UpdateScore",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).UpdateScore(ctx, req.(*UpdateScoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_DeleteByKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteByKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).DeleteByKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filter.FilterService/DeleteByKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).DeleteByKey(ctx, req.(*DeleteByKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_GetTopK_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTopKRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).GetTopK(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filter.FilterService/GetTopK",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).GetTopK(ctx, req.(*GetTopKRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_GetBottomK_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBottomKRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).GetBottomK(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filter.FilterService/GetBottomK",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).GetBottomK(ctx, req.(*GetBottomKRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_GetRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).GetRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filter.FilterService/GetRange",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).GetRange(ctx, req.(*GetRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_Replicate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReplicateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FilterServiceServer).Replicate(m, &filterServiceReplicateServer{stream})
+}
+
+type FilterService_ReplicateServer interface {
+	Send(*ReplicationRecord) error
+	grpc.ServerStream
+}
+
+type filterServiceReplicateServer struct {
+	grpc.ServerStream
+}
+
+func (x *filterServiceReplicateServer) Send(m *ReplicationRecord) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _FilterService_Promote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PromoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).Promote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filter.FilterService/Promote",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).Promote(ctx, req.(*PromoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_GetBand_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBandRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).GetBand(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filter.FilterService/GetBand",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).GetBand(ctx, req.(*GetBandRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_SetBand_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetBandRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).SetBand(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filter.FilterService/SetBand",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).SetBand(ctx, req.(*SetBandRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // FilterService_ServiceDesc is the grpc.ServiceDesc for FilterService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -291,6 +1108,10 @@ var FilterService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "InsertItem",
 			Handler:    _FilterService_InsertItem_Handler,
 		},
+		{
+			MethodName: "InsertItems",
+			Handler:    _FilterService_InsertItems_Handler,
+		},
 		{
 			MethodName: "GetMaxItem",
 			Handler:    _FilterService_GetMaxItem_Handler,
@@ -307,6 +1128,26 @@ var FilterService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RemoveMinItem",
 			Handler:    _FilterService_RemoveMinItem_Handler,
 		},
+		{
+			MethodName: "LeaseMaxItem",
+			Handler:    _FilterService_LeaseMaxItem_Handler,
+		},
+		{
+			MethodName: "Ack",
+			Handler:    _FilterService_Ack_Handler,
+		},
+		{
+			MethodName: "Nack",
+			Handler:    _FilterService_Nack_Handler,
+		},
+		{
+			MethodName: "RemoveTopK",
+			Handler:    _FilterService_RemoveTopK_Handler,
+		},
+		{
+			MethodName: "RemoveAbove",
+			Handler:    _FilterService_RemoveAbove_Handler,
+		},
 		{
 			MethodName: "GetSize",
 			Handler:    _FilterService_GetSize_Handler,
@@ -315,7 +1156,71 @@ var FilterService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Clear",
 			Handler:    _FilterService_Clear_Handler,
 		},
+		{
+			MethodName: "UpdateScore",
+			Handler:    _FilterService_UpdateScore_Handler,
+		},
+		{
+			MethodName: "DeleteByKey",
+			Handler:    _FilterService_DeleteByKey_Handler,
+		},
+		{
+			MethodName: "GetTopK",
+			Handler:    _FilterService_GetTopK_Handler,
+		},
+		{
+			MethodName: "GetBottomK",
+			Handler:    _FilterService_GetBottomK_Handler,
+		},
+		{
+			MethodName: "GetRange",
+			Handler:    _FilterService_GetRange_Handler,
+		},
+		{
+			MethodName: "Promote",
+			Handler:    _FilterService_Promote_Handler,
+		},
+		{
+			MethodName: "GetBand",
+			Handler:    _FilterService_GetBand_Handler,
+		},
+		{
+			MethodName: "SetBand",
+			Handler:    _FilterService_SetBand_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "InsertStream",
+			Handler:       _FilterService_InsertStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Subscribe",
+			Handler:       _FilterService_Subscribe_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Drain",
+			Handler:       _FilterService_Drain_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchThreshold",
+			Handler:       _FilterService_WatchThreshold_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchEvictions",
+			Handler:       _FilterService_WatchEvictions_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Replicate",
+			Handler:       _FilterService_Replicate_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/filter/filter.proto",
 }
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"math"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The score band [lo, hi) a filter owns in a score partitioned topology,
+// the whole line by default
+type band struct {
+	lo, hi float32
+}
+
+var everything = band{float32(math.Inf(-1)), float32(math.Inf(1))}
+
+func (b band) contains(score float32) bool {
+	return b.lo <= score && (score < b.hi || math.IsInf(float64(b.hi), 1))
+}
+
+// whether any score from lo to hi, both included, is in the band
+func (b band) overlaps(lo, hi float32) bool {
+	return b.lo <= hi && (lo < b.hi || math.IsInf(float64(b.hi), 1))
+}
+
+// unbounded ends travel as unset fields
+func bandFromProto(lo, hi *float32) band {
+	b := everything
+	if lo != nil {
+		b.lo = *lo
+	}
+	if hi != nil {
+		b.hi = *hi
+	}
+	return b
+}
+
+func (b band) bounds() (*float32, *float32) {
+	var lo, hi *float32
+	if !math.IsInf(float64(b.lo), -1) {
+		lo = &b.lo
+	}
+	if !math.IsInf(float64(b.hi), 1) {
+		hi = &b.hi
+	}
+	return lo, hi
+}
+
+// run insert if every item is in the filter's band, the band can't change
+// underneath it
+func (s *Filter) inBand(items []*filter.FilterItem, insert func() error) error {
+	s.bandLk.RLock()
+	defer s.bandLk.RUnlock()
+
+	for _, item := range items {
+		if item != nil && !s.band.contains(item.GetScore()) {
+			return status.Errorf(codes.OutOfRange,
+				"Item score %v is outside the filter's band [%v, %v)", item.GetScore(), s.band.lo, s.band.hi)
+		}
+	}
+	return insert()
+}
+
+func (s *Filter) GetBand(ctx context.Context, req *filter.GetBandRequest) (*filter.GetBandResponse, error) {
+	s.bandLk.RLock()
+	lo, hi := s.band.bounds()
+	s.bandLk.RUnlock()
+
+	return &filter.GetBandResponse{Lo: lo, Hi: hi}, nil
+}
+
+// Own the new band from now on, everything outside it is taken out of the
+// filter and handed back for its new owner
+func (s *Filter) SetBand(ctx context.Context, req *filter.SetBandRequest) (*filter.SetBandResponse, error) {
+	b := bandFromProto(req.Lo, req.Hi)
+	if !(b.lo < b.hi) {
+		return nil, status.Errorf(codes.InvalidArgument, "Band [%v, %v) is empty", b.lo, b.hi)
+	}
+
+	// no insert is halfway through the old band check once this returns
+	s.bandLk.Lock()
+	s.band = b
+	s.bandLk.Unlock()
+
+	resp := &filter.SetBandResponse{}
+	for {
+		item, err := s.app.GetMax()
+		if err != nil || b.contains(item.GetScore()) {
+			break
+		}
+		if item, err = s.app.RemoveMax(); err != nil {
+			break
+		}
+		if b.contains(item.GetScore()) {
+			// someone took the one we saw, this one stays
+			s.app.Insert(item)
+			break
+		}
+		resp.Moved = append(resp.Moved, item)
+	}
+	for {
+		item, err := s.app.GetMin()
+		if err != nil || b.contains(item.GetScore()) {
+			break
+		}
+		if item, err = s.app.RemoveMin(); err != nil {
+			break
+		}
+		if b.contains(item.GetScore()) {
+			s.app.Insert(item)
+			break
+		}
+		resp.Moved = append(resp.Moved, item)
+	}
+	return resp, nil
+}
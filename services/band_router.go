@@ -0,0 +1,587 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Score partitioned filters behind one logical filter. Every filter owns a
+// band of scores, inserts go to the band their score falls in, and removals
+// only touch the highest (or lowest) band that isn't empty, so unlike the
+// hash sharded Cluster a RemoveMax usually costs one filter. A hot band can
+// be split onto a new filter, or its boundary with the band below moved.
+type BandRouter struct {
+	lk      sync.RWMutex // band changes are exclusive, routed calls shared
+	bands   []*routerBand
+	members map[string]filter.FilterServiceClient // every filter that ever owned a band, by address
+}
+
+type routerBand struct {
+	band
+	addr   string
+	client filter.FilterServiceClient
+}
+
+// one band of the topology as the proxy shows it
+type BandInfo struct {
+	Addr string   `json:"addr"`
+	Lo   *float32 `json:"lo,omitempty"`
+	Hi   *float32 `json:"hi,omitempty"`
+	Size int32    `json:"size"`
+
+	Evicted int64 `json:"evicted"`
+	Expired int64 `json:"expired"`
+}
+
+// Build the router from "addr@lo,addr@lo,...,addr", highest band first. Each
+// band reaches up to the lower bound of the one before it, the first one is
+// unbounded above and the last one below. Every filter is told its band
+// before this returns, waiting for it to come up if it has to.
+func NewBandRouter(ctx context.Context, spec string) (*BandRouter, error) {
+	r := &BandRouter{members: make(map[string]filter.FilterServiceClient)}
+
+	hi := float32(math.Inf(1))
+	parts := strings.Split(spec, ",")
+	for i, part := range parts {
+		addr, loStr, bounded := strings.Cut(part, "@")
+		lo := float32(math.Inf(-1))
+		if i < len(parts)-1 {
+			if !bounded {
+				return nil, fmt.Errorf("band %q needs a lower bound", part)
+			}
+			f, err := strconv.ParseFloat(loStr, 32)
+			if err != nil {
+				return nil, fmt.Errorf("band %q: %v", part, err)
+			}
+			lo = float32(f)
+		} else if bounded {
+			return nil, fmt.Errorf("the last band %q is unbounded below", part)
+		}
+		if !(lo < hi) {
+			return nil, fmt.Errorf("bands have to go from the highest down, %q doesn't", part)
+		}
+
+		r.bands = append(r.bands, &routerBand{band: band{lo, hi}, addr: addr, client: r.member(addr)})
+		hi = lo
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	var moved []*filter.FilterItem
+	for _, b := range r.bands {
+		res, err := r.resize(ctx, b, b.band, grpc.WaitForReady(true))
+		if err != nil {
+			return nil, err
+		}
+		moved = append(moved, res.gaveUp...)
+	}
+	return r, r.insertMoved(ctx, moved)
+}
+
+// A keyed item's old version may sit in another band, it's dropped from
+// there once the new one is in
+func (r *BandRouter) InsertItem(ctx context.Context, in *filter.InsertItemRequest, opts ...grpc.CallOption) (*filter.InsertItemResponse, error) {
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+	b := r.bandOf(in.GetItem().GetScore())
+	resp, err := b.client.InsertItem(ctx, in, opts...)
+	if err != nil || in.GetItem().GetKey() == "" {
+		return resp, err
+	}
+	if _, err := r.deleteElsewhere(ctx, b, in.GetItem().GetKey(), opts...); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *BandRouter) GetMaxItem(ctx context.Context, in *filter.GetMaxItemRequest, opts ...grpc.CallOption) (*filter.GetMaxItemResponse, error) {
+	var resp *filter.GetMaxItemResponse
+	err := r.firstNonEmpty(true, func(b *routerBand) (err error) {
+		resp, err = b.client.GetMaxItem(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (r *BandRouter) GetMinItem(ctx context.Context, in *filter.GetMinItemRequest, opts ...grpc.CallOption) (*filter.GetMinItemResponse, error) {
+	var resp *filter.GetMinItemResponse
+	err := r.firstNonEmpty(false, func(b *routerBand) (err error) {
+		resp, err = b.client.GetMinItem(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (r *BandRouter) RemoveMaxItem(ctx context.Context, in *filter.RemoveMaxItemRequest, opts ...grpc.CallOption) (*filter.RemoveMaxItemResponse, error) {
+	item, err := r.removeWait(ctx, true, in.GetWaitMs(), in.MinScore)
+	if err != nil {
+		return nil, err
+	}
+	return &filter.RemoveMaxItemResponse{Item: item}, nil
+}
+
+func (r *BandRouter) RemoveMinItem(ctx context.Context, in *filter.RemoveMinItemRequest, opts ...grpc.CallOption) (*filter.RemoveMinItemResponse, error) {
+	item, err := r.removeWait(ctx, false, in.GetWaitMs(), in.MinScore)
+	if err != nil {
+		return nil, err
+	}
+	return &filter.RemoveMinItemResponse{Item: item}, nil
+}
+
+// lease from the highest band that has anything, the lease ID says which
+// filter it was
+func (r *BandRouter) LeaseMaxItem(ctx context.Context, in *filter.LeaseMaxItemRequest, opts ...grpc.CallOption) (*filter.LeaseMaxItemResponse, error) {
+	var resp *filter.LeaseMaxItemResponse
+	err := r.firstNonEmpty(true, func(b *routerBand) (err error) {
+		resp, err = b.client.LeaseMaxItem(ctx, in, opts...)
+		if err == nil {
+			resp.LeaseId = b.addr + "/" + resp.GetLeaseId()
+		}
+		return err
+	})
+	return resp, err
+}
+
+func (r *BandRouter) Ack(ctx context.Context, in *filter.AckRequest, opts ...grpc.CallOption) (*filter.AckResponse, error) {
+	client, id, err := r.splitLease(in.GetLeaseId())
+	if err != nil {
+		return nil, err
+	}
+	return client.Ack(ctx, &filter.AckRequest{LeaseId: id}, opts...)
+}
+
+func (r *BandRouter) Nack(ctx context.Context, in *filter.NackRequest, opts ...grpc.CallOption) (*filter.NackResponse, error) {
+	client, id, err := r.splitLease(in.GetLeaseId())
+	if err != nil {
+		return nil, err
+	}
+	return client.Nack(ctx, &filter.NackRequest{LeaseId: id}, opts...)
+}
+
+func (r *BandRouter) GetSize(ctx context.Context, in *filter.GetSizeRequest, opts ...grpc.CallOption) (*filter.GetSizeResponse, error) {
+	resp := &filter.GetSizeResponse{}
+	for _, b := range r.Bands(ctx) {
+		if b.Size < 0 {
+			return nil, status.Errorf(codes.Unavailable, "Filter %s did not report its size", b.Addr)
+		}
+		resp.Size += b.Size
+		resp.Evicted += b.Evicted
+		resp.Expired += b.Expired
+	}
+	return resp, nil
+}
+
+func (r *BandRouter) Clear(ctx context.Context, in *filter.ClearRequest, opts ...grpc.CallOption) (*filter.ClearResponse, error) {
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+
+	resp := &filter.ClearResponse{Success: true}
+	for _, b := range r.bands {
+		if _, err := b.client.Clear(ctx, in, opts...); err != nil {
+			resp.Success = false
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+// The bands are asked from the highest down until there are k items
+func (r *BandRouter) GetTopK(ctx context.Context, in *filter.GetTopKRequest, opts ...grpc.CallOption) (*filter.GetTopKResponse, error) {
+	items, err := r.collect(true, int(in.GetK()), func(b *routerBand, n int) ([]*filter.FilterItem, error) {
+		resp, err := b.client.GetTopK(ctx, &filter.GetTopKRequest{K: int32(n)}, opts...)
+		return resp.GetItems(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &filter.GetTopKResponse{Items: items}, nil
+}
+
+func (r *BandRouter) GetBottomK(ctx context.Context, in *filter.GetBottomKRequest, opts ...grpc.CallOption) (*filter.GetBottomKResponse, error) {
+	items, err := r.collect(false, int(in.GetK()), func(b *routerBand, n int) ([]*filter.FilterItem, error) {
+		resp, err := b.client.GetBottomK(ctx, &filter.GetBottomKRequest{K: int32(n)}, opts...)
+		return resp.GetItems(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &filter.GetBottomKResponse{Items: items}, nil
+}
+
+// Only the bands overlapping the range are asked
+func (r *BandRouter) GetRange(ctx context.Context, in *filter.GetRangeRequest, opts ...grpc.CallOption) (*filter.GetRangeResponse, error) {
+	items, err := r.collect(true, int(in.GetLimit()), func(b *routerBand, n int) ([]*filter.FilterItem, error) {
+		if !b.overlaps(in.GetMinScore(), in.GetMaxScore()) {
+			return nil, nil
+		}
+		resp, err := b.client.GetRange(ctx, &filter.GetRangeRequest{
+			MinScore: in.GetMinScore(),
+			MaxScore: in.GetMaxScore(),
+			Limit:    int32(n),
+		}, opts...)
+		return resp.GetItems(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &filter.GetRangeResponse{Items: items}, nil
+}
+
+// An item whose new score is in another band moves to that band's filter
+func (r *BandRouter) UpdateScore(ctx context.Context, in *filter.UpdateScoreRequest, opts ...grpc.CallOption) (*filter.UpdateScoreResponse, error) {
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+
+	b := r.bandOf(in.GetScore())
+	resp, err := b.client.UpdateScore(ctx, in, opts...)
+	if status.Code(err) != codes.NotFound {
+		return resp, err
+	}
+
+	old, err := r.deleteElsewhere(ctx, b, in.GetKey(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	if old == nil {
+		return nil, status.Errorf(codes.NotFound, "No item with key %q", in.GetKey())
+	}
+	item := proto.Clone(old).(*filter.FilterItem)
+	item.Score = in.GetScore()
+	if _, err := b.client.InsertItem(ctx, &filter.InsertItemRequest{Item: item}, opts...); err != nil {
+		return nil, err
+	}
+	return &filter.UpdateScoreResponse{Item: item}, nil
+}
+
+func (r *BandRouter) DeleteByKey(ctx context.Context, in *filter.DeleteByKeyRequest, opts ...grpc.CallOption) (*filter.DeleteByKeyResponse, error) {
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+
+	item, err := r.deleteElsewhere(ctx, nil, in.GetKey(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, status.Errorf(codes.NotFound, "No item with key %q", in.GetKey())
+	}
+	return &filter.DeleteByKeyResponse{Item: item}, nil
+}
+
+// The bands, highest first, with the size of each. A band that couldn't
+// report has size -1.
+func (r *BandRouter) Bands(ctx context.Context) []BandInfo {
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+
+	infos := make([]BandInfo, len(r.bands))
+	for i, b := range r.bands {
+		infos[i].Addr = b.addr
+		infos[i].Lo, infos[i].Hi = b.bounds()
+		infos[i].Size = -1
+		if resp, err := b.client.GetSize(ctx, &filter.GetSizeRequest{}); err == nil {
+			infos[i].Size = resp.GetSize()
+			infos[i].Evicted, infos[i].Expired = resp.GetEvicted(), resp.GetExpired()
+		}
+	}
+	return infos
+}
+
+// Split band i at the score at, the filter at addr takes over the upper part
+// and gets the items in it. If that fails part way, both filters get their
+// old bands and items back.
+func (r *BandRouter) Split(ctx context.Context, i int, at float32, addr string) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if i < 0 || i >= len(r.bands) {
+		return status.Errorf(codes.InvalidArgument, "No band %d", i)
+	}
+	b := r.bands[i]
+	if !(b.lo < at && at < b.hi) {
+		return status.Errorf(codes.InvalidArgument, "%v does not split band [%v, %v)", at, b.lo, b.hi)
+	}
+	for _, other := range r.bands {
+		if other.addr == addr {
+			return status.Errorf(codes.InvalidArgument, "Filter %s already owns a band", addr)
+		}
+	}
+
+	upper := &routerBand{addr: addr, client: r.member(addr)}
+	resp, err := upper.client.GetBand(ctx, &filter.GetBandRequest{})
+	if err != nil {
+		return err
+	}
+	upper.band = bandFromProto(resp.Lo, resp.Hi)
+
+	// the new owner first, so the items have somewhere to go
+	grown, err := r.resize(ctx, upper, band{at, b.hi})
+	if err != nil {
+		return err
+	}
+	shrunk, err := r.resize(ctx, b, band{b.lo, at})
+	if err != nil {
+		return r.undo(ctx, err, grown)
+	}
+	if err := r.give(ctx, upper, shrunk.gaveUp); err != nil {
+		return r.undo(ctx, err, grown, shrunk)
+	}
+
+	r.bands = append(r.bands, nil)
+	copy(r.bands[i+1:], r.bands[i:])
+	r.bands[i] = upper
+	return r.insertMoved(ctx, grown.gaveUp)
+}
+
+// Move the boundary between band i and the band below it to at, the items
+// that change bands move with it. If that fails part way, both filters get
+// their old bands and items back.
+func (r *BandRouter) MoveBoundary(ctx context.Context, i int, at float32) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if i < 0 || i+1 >= len(r.bands) {
+		return status.Errorf(codes.InvalidArgument, "No boundary below band %d", i)
+	}
+	upper, lower := r.bands[i], r.bands[i+1]
+	if !(lower.lo < at && at < upper.hi) {
+		return status.Errorf(codes.InvalidArgument, "%v is outside bands [%v, %v)", at, lower.lo, upper.hi)
+	}
+
+	// grow the receiving band before the giving one shrinks
+	grow, shrink := lower, upper
+	growTo, shrinkTo := band{lower.lo, at}, band{at, upper.hi}
+	if at < upper.lo {
+		grow, shrink = upper, lower
+		growTo, shrinkTo = band{at, upper.hi}, band{lower.lo, at}
+	}
+	grown, err := r.resize(ctx, grow, growTo)
+	if err != nil {
+		return err
+	}
+	shrunk, err := r.resize(ctx, shrink, shrinkTo)
+	if err != nil {
+		return r.undo(ctx, err, grown)
+	}
+	if err := r.give(ctx, grow, shrunk.gaveUp); err != nil {
+		return r.undo(ctx, err, grown, shrunk)
+	}
+	return r.insertMoved(ctx, grown.gaveUp)
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+func (r *BandRouter) member(addr string) filter.FilterServiceClient {
+	client, ok := r.members[addr]
+	if !ok {
+		client = filter.NewFilterServiceClient(dial(addr))
+		r.members[addr] = client
+	}
+	return client
+}
+
+// the band score falls in, caller holds lk
+func (r *BandRouter) bandOf(score float32) *routerBand {
+	for _, b := range r.bands {
+		if b.contains(score) {
+			return b
+		}
+	}
+	// NaN, let the lowest band turn it down
+	return r.bands[len(r.bands)-1]
+}
+
+// hand b its new band and return the items it gave up, caller holds lk
+func (r *BandRouter) resize(ctx context.Context, b *routerBand, to band, opts ...grpc.CallOption) (resized, error) {
+	lo, hi := to.bounds()
+	resp, err := b.client.SetBand(ctx, &filter.SetBandRequest{Lo: lo, Hi: hi}, opts...)
+	if err != nil {
+		return resized{}, err
+	}
+	from := b.band
+	b.band = to
+	return resized{b: b, from: from, gaveUp: resp.GetMoved()}, nil
+}
+
+// what a resize did, so it can be undone
+type resized struct {
+	b      *routerBand
+	from   band                 // the band before
+	gaveUp []*filter.FilterItem // the items it handed back
+}
+
+// insert items into b, which they all belong to now, caller holds lk
+func (r *BandRouter) give(ctx context.Context, b *routerBand, items []*filter.FilterItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	_, err := b.client.InsertItems(ctx, &filter.InsertItemsRequest{Items: items})
+	return err
+}
+
+// Undo the resizes of a band change that failed with cause, last first. Each
+// band gets its old band back along with the items it gave up, and whatever
+// it hands back on the way goes where it belongs once all are undone.
+// Returns cause, with the rollback's error if that failed too. Caller holds lk.
+func (r *BandRouter) undo(ctx context.Context, cause error, done ...resized) error {
+	var back []*filter.FilterItem
+	for i := len(done) - 1; i >= 0; i-- {
+		undone, err := r.resize(ctx, done[i].b, done[i].from)
+		if err == nil {
+			err = r.give(ctx, done[i].b, done[i].gaveUp)
+		}
+		if err != nil {
+			return status.Errorf(status.Code(cause), "%v, and rolling the bands back failed: %v", status.Convert(cause).Message(), err)
+		}
+		back = append(back, undone.gaveUp...)
+	}
+	if err := r.insertMoved(ctx, back); err != nil {
+		return status.Errorf(status.Code(cause), "%v, and rolling the bands back failed: %v", status.Convert(cause).Message(), err)
+	}
+	return cause
+}
+
+// send items to the bands they belong to now, caller holds lk
+func (r *BandRouter) insertMoved(ctx context.Context, items []*filter.FilterItem) error {
+	batches := make(map[*routerBand][]*filter.FilterItem)
+	for _, item := range items {
+		b := r.bandOf(item.GetScore())
+		batches[b] = append(batches[b], item)
+	}
+	for b, batch := range batches {
+		if _, err := b.client.InsertItems(ctx, &filter.InsertItemsRequest{Items: batch}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Drop key from every band but skip, returns what was dropped or nil if no
+// band had it. Caller holds lk.
+func (r *BandRouter) deleteElsewhere(ctx context.Context, skip *routerBand, key string, opts ...grpc.CallOption) (*filter.FilterItem, error) {
+	for _, b := range r.bands {
+		if b == skip {
+			continue
+		}
+		resp, err := b.client.DeleteByKey(ctx, &filter.DeleteByKeyRequest{Key: key}, opts...)
+		if err == nil {
+			return resp.GetItem(), nil
+		}
+		if status.Code(err) != codes.NotFound {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// Run query on the bands from the highest (or lowest) down, each asked for
+// what's still missing of n items (0 for no limit), until there are n
+func (r *BandRouter) collect(max bool, n int, query func(b *routerBand, n int) ([]*filter.FilterItem, error)) ([]*filter.FilterItem, error) {
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+
+	var items []*filter.FilterItem
+	for i := range r.bands {
+		b := r.bands[i]
+		if !max {
+			b = r.bands[len(r.bands)-1-i]
+		}
+		missing := 0
+		if n > 0 {
+			missing = n - len(items)
+		}
+		got, err := query(b, missing)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, got...)
+		if n > 0 && len(items) >= n {
+			break
+		}
+	}
+	return items, nil
+}
+
+// run f on the bands from the highest (or lowest) down until it succeeds,
+// fails like the last band did if they are all empty
+func (r *BandRouter) firstNonEmpty(max bool, f func(b *routerBand) error) error {
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+
+	var err error
+	for i := range r.bands {
+		b := r.bands[i]
+		if !max {
+			b = r.bands[len(r.bands)-1-i]
+		}
+		if err = f(b); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// Remove from the highest (or lowest) band that has anything, parking on all
+// of them if nothing qualifies and the caller wants to wait
+func (r *BandRouter) removeWait(ctx context.Context, max bool, waitMs int64, minScore *float32) (*filter.FilterItem, error) {
+	r.lk.RLock()
+	shards := make([]filter.FilterServiceClient, len(r.bands))
+	for i, b := range r.bands {
+		if max {
+			shards[i] = b.client
+		} else {
+			shards[len(r.bands)-1-i] = b.client
+		}
+	}
+	r.lk.RUnlock()
+
+	item, err := removeFirst(ctx, shards, max, minScore)
+	if err == nil || waitMs <= 0 {
+		return item, err
+	}
+	return removeAny(ctx, shards, max, waitMs, minScore, err)
+}
+
+// Remove from the first shard that isn't empty. It is peeked at first, a
+// shard that only fails min_score has the best items, so it's the answer.
+func removeFirst(ctx context.Context, shards []filter.FilterServiceClient, max bool, minScore *float32) (*filter.FilterItem, error) {
+	var err error
+	for _, shard := range shards {
+		if max {
+			_, err = shard.GetMaxItem(ctx, &filter.GetMaxItemRequest{})
+		} else {
+			_, err = shard.GetMinItem(ctx, &filter.GetMinItemRequest{})
+		}
+		if err == nil {
+			return removeFrom(ctx, shard, max, 0, minScore)
+		}
+	}
+	return nil, err
+}
+
+// "<filter address>/<lease ID on that filter>"
+func (r *BandRouter) splitLease(leaseID string) (filter.FilterServiceClient, string, error) {
+	i := strings.LastIndex(leaseID, "/")
+	if i < 0 {
+		return nil, "", status.Errorf(codes.NotFound, "Lease %s not found or expired", leaseID)
+	}
+
+	r.lk.RLock()
+	client, ok := r.members[leaseID[:i]]
+	r.lk.RUnlock()
+	if !ok {
+		return nil, "", status.Errorf(codes.NotFound, "Lease %s not found or expired", leaseID)
+	}
+	return client, leaseID[i+1:], nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/grpc"
+)
+
+// how long the client waits before watching the threshold again after the
+// stream broke
+const watchRetry = time.Second
+
+// Producer side client of one filter. It keeps track of the filter's
+// admission threshold, from a WatchThreshold stream and from every insert
+// response, and drops items that can't beat it without sending them.
+// Everything else goes straight to the filter.
+type FilterClient struct {
+	filter.FilterServiceClient
+	threshold atomic.Pointer[float32] // nil while the filter has room, or we don't know
+	dropped   atomic.Int64
+}
+
+func NewFilterClient(ctx context.Context, addr string) *FilterClient {
+	return WrapFilterClient(ctx, filter.NewFilterServiceClient(dial(addr)))
+}
+
+// Pre-filter the inserts going through client, the threshold is watched
+// until ctx is done
+func WrapFilterClient(ctx context.Context, client filter.FilterServiceClient) *FilterClient {
+	c := &FilterClient{FilterServiceClient: client}
+	go c.watch(ctx)
+	return c
+}
+
+// the last threshold heard of, nil if the filter has room
+func (c *FilterClient) Threshold() *float32 {
+	return c.threshold.Load()
+}
+
+// number of items dropped without sending them
+func (c *FilterClient) Dropped() int64 {
+	return c.dropped.Load()
+}
+
+// An item the filter would turn down isn't sent, the response says it was
+// rejected just like the filter would. Keyed items always go, they may update
+// the item under their key whatever their score.
+func (c *FilterClient) InsertItem(ctx context.Context, in *filter.InsertItemRequest, opts ...grpc.CallOption) (*filter.InsertItemResponse, error) {
+	if t := c.threshold.Load(); t != nil && in.GetItem() != nil && !beats(in.GetItem(), *t) {
+		c.dropped.Add(1)
+		return &filter.InsertItemResponse{Success: true, Threshold: t, Outcome: filter.InsertOutcome_REJECTED}, nil
+	}
+
+	resp, err := c.FilterServiceClient.InsertItem(ctx, in, opts...)
+	if err == nil {
+		c.threshold.Store(resp.Threshold)
+	}
+	return resp, err
+}
+
+// only the items that can beat the threshold, or are keyed, are sent
+func (c *FilterClient) InsertItems(ctx context.Context, in *filter.InsertItemsRequest, opts ...grpc.CallOption) (*filter.InsertItemsResponse, error) {
+	t := c.threshold.Load()
+	if t != nil {
+		var kept []*filter.FilterItem
+		for _, item := range in.GetItems() {
+			if item == nil || beats(item, *t) {
+				kept = append(kept, item)
+			}
+		}
+		c.dropped.Add(int64(len(in.GetItems()) - len(kept)))
+		if len(kept) == 0 {
+			return &filter.InsertItemsResponse{Success: true, Threshold: t}, nil
+		}
+		in = &filter.InsertItemsRequest{Items: kept}
+	}
+
+	resp, err := c.FilterServiceClient.InsertItems(ctx, in, opts...)
+	if err == nil {
+		c.threshold.Store(resp.Threshold)
+	}
+	return resp, err
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+// follow the filter's threshold until ctx is done
+func (c *FilterClient) watch(ctx context.Context) {
+	for {
+		stream, err := c.FilterServiceClient.WatchThreshold(ctx, &filter.WatchThresholdRequest{})
+		if err == nil {
+			for {
+				update, err := stream.Recv()
+				if err != nil {
+					break
+				}
+				c.threshold.Store(update.Threshold)
+			}
+		}
+
+		// a stale threshold could drop items the filter wants, send
+		// everything until we hear from it again
+		c.threshold.Store(nil)
+		select {
+		case <-time.After(watchRetry):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// whether the filter may still want item with its threshold at t
+func beats(item *filter.FilterItem, t float32) bool {
+	return item.GetKey() != "" || item.GetScore() > t
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Several filter shards behind one logical filter. Inserts are hashed over
+// the shards, reads look at every shard's head and removals take from the
+// shard with the best one. Each shard keeps the top of what it was sent, so
+// with skewed hashing the cluster only approximates the global top items, and
+// a removal racing another client may get the runner-up instead of the max.
+type Cluster struct {
+	shards []filter.FilterServiceClient
+}
+
+func NewCluster(addrs []string) *Cluster {
+	c := &Cluster{}
+	for _, addr := range addrs {
+		c.shards = append(c.shards, filter.NewFilterServiceClient(dial(addr)))
+	}
+	return c
+}
+
+func (c *Cluster) InsertItem(ctx context.Context, in *filter.InsertItemRequest, opts ...grpc.CallOption) (*filter.InsertItemResponse, error) {
+	return c.shards[c.shardOf(in.GetItem())].InsertItem(ctx, in, opts...)
+}
+
+func (c *Cluster) GetMaxItem(ctx context.Context, in *filter.GetMaxItemRequest, opts ...grpc.CallOption) (*filter.GetMaxItemResponse, error) {
+	_, item, err := c.bestShard(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	return &filter.GetMaxItemResponse{Item: item}, nil
+}
+
+func (c *Cluster) GetMinItem(ctx context.Context, in *filter.GetMinItemRequest, opts ...grpc.CallOption) (*filter.GetMinItemResponse, error) {
+	_, item, err := c.bestShard(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return &filter.GetMinItemResponse{Item: item}, nil
+}
+
+func (c *Cluster) RemoveMaxItem(ctx context.Context, in *filter.RemoveMaxItemRequest, opts ...grpc.CallOption) (*filter.RemoveMaxItemResponse, error) {
+	item, err := c.removeWait(ctx, true, in.GetWaitMs(), in.MinScore)
+	if err != nil {
+		return nil, err
+	}
+	return &filter.RemoveMaxItemResponse{Item: item}, nil
+}
+
+func (c *Cluster) RemoveMinItem(ctx context.Context, in *filter.RemoveMinItemRequest, opts ...grpc.CallOption) (*filter.RemoveMinItemResponse, error) {
+	item, err := c.removeWait(ctx, false, in.GetWaitMs(), in.MinScore)
+	if err != nil {
+		return nil, err
+	}
+	return &filter.RemoveMinItemResponse{Item: item}, nil
+}
+
+// lease on the shard with the best max, the lease ID says which one it was
+func (c *Cluster) LeaseMaxItem(ctx context.Context, in *filter.LeaseMaxItemRequest, opts ...grpc.CallOption) (*filter.LeaseMaxItemResponse, error) {
+	i, _, err := c.bestShard(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.shards[i].LeaseMaxItem(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	resp.LeaseId = fmt.Sprintf("%d/%s", i, resp.GetLeaseId())
+	return resp, nil
+}
+
+func (c *Cluster) Ack(ctx context.Context, in *filter.AckRequest, opts ...grpc.CallOption) (*filter.AckResponse, error) {
+	i, id, err := c.splitLease(in.GetLeaseId())
+	if err != nil {
+		return nil, err
+	}
+	return c.shards[i].Ack(ctx, &filter.AckRequest{LeaseId: id}, opts...)
+}
+
+func (c *Cluster) Nack(ctx context.Context, in *filter.NackRequest, opts ...grpc.CallOption) (*filter.NackResponse, error) {
+	i, id, err := c.splitLease(in.GetLeaseId())
+	if err != nil {
+		return nil, err
+	}
+	return c.shards[i].Nack(ctx, &filter.NackRequest{LeaseId: id}, opts...)
+}
+
+func (c *Cluster) GetSize(ctx context.Context, in *filter.GetSizeRequest, opts ...grpc.CallOption) (*filter.GetSizeResponse, error) {
+	sizes := make([]*filter.GetSizeResponse, len(c.shards))
+	err := c.each(func(i int, shard filter.FilterServiceClient) (err error) {
+		sizes[i], err = shard.GetSize(ctx, in, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &filter.GetSizeResponse{}
+	for _, size := range sizes {
+		resp.Size += size.GetSize()
+		resp.Evicted += size.GetEvicted()
+		resp.Expired += size.GetExpired()
+	}
+	return resp, nil
+}
+
+func (c *Cluster) Clear(ctx context.Context, in *filter.ClearRequest, opts ...grpc.CallOption) (*filter.ClearResponse, error) {
+	err := c.each(func(i int, shard filter.FilterServiceClient) error {
+		_, err := shard.Clear(ctx, in, opts...)
+		return err
+	})
+	return &filter.ClearResponse{Success: err == nil}, err
+}
+
+// a key always hashes to the same shard, so it lives on that one only
+func (c *Cluster) UpdateScore(ctx context.Context, in *filter.UpdateScoreRequest, opts ...grpc.CallOption) (*filter.UpdateScoreResponse, error) {
+	return c.shards[c.shardOfKey(in.GetKey())].UpdateScore(ctx, in, opts...)
+}
+
+// The best k of every shard's top k
+func (c *Cluster) GetTopK(ctx context.Context, in *filter.GetTopKRequest, opts ...grpc.CallOption) (*filter.GetTopKResponse, error) {
+	items, err := c.gather(func(shard filter.FilterServiceClient) ([]*filter.FilterItem, error) {
+		resp, err := shard.GetTopK(ctx, in, opts...)
+		return resp.GetItems(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &filter.GetTopKResponse{Items: bestOf(items, true, int(in.GetK()))}, nil
+}
+
+func (c *Cluster) GetBottomK(ctx context.Context, in *filter.GetBottomKRequest, opts ...grpc.CallOption) (*filter.GetBottomKResponse, error) {
+	items, err := c.gather(func(shard filter.FilterServiceClient) ([]*filter.FilterItem, error) {
+		resp, err := shard.GetBottomK(ctx, in, opts...)
+		return resp.GetItems(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &filter.GetBottomKResponse{Items: bestOf(items, false, int(in.GetK()))}, nil
+}
+
+func (c *Cluster) GetRange(ctx context.Context, in *filter.GetRangeRequest, opts ...grpc.CallOption) (*filter.GetRangeResponse, error) {
+	items, err := c.gather(func(shard filter.FilterServiceClient) ([]*filter.FilterItem, error) {
+		resp, err := shard.GetRange(ctx, in, opts...)
+		return resp.GetItems(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &filter.GetRangeResponse{Items: bestOf(items, true, int(in.GetLimit()))}, nil
+}
+
+func (c *Cluster) DeleteByKey(ctx context.Context, in *filter.DeleteByKeyRequest, opts ...grpc.CallOption) (*filter.DeleteByKeyResponse, error) {
+	return c.shards[c.shardOfKey(in.GetKey())].DeleteByKey(ctx, in, opts...)
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+// hash the score and the data, so equal data still spreads over the shards.
+// Keyed items hash by key instead, so a new version replaces the old one.
+func (c *Cluster) shardOf(item *filter.FilterItem) int {
+	if item.GetKey() != "" {
+		return c.shardOfKey(item.GetKey())
+	}
+	h := fnv.New32a()
+	var score [4]byte
+	binary.LittleEndian.PutUint32(score[:], math.Float32bits(item.GetScore()))
+	h.Write(score[:])
+	h.Write(item.GetData())
+	return int(h.Sum32() % uint32(len(c.shards)))
+}
+
+func (c *Cluster) shardOfKey(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(c.shards)))
+}
+
+// call f on every shard at once, returns the first error
+func (c *Cluster) each(f func(i int, shard filter.FilterServiceClient) error) error {
+	errs := make(chan error, len(c.shards))
+	for i, shard := range c.shards {
+		i, shard := i, shard
+		go func() { errs <- f(i, shard) }()
+	}
+
+	var first error
+	for range c.shards {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// every shard's answer to a query, put together
+func (c *Cluster) gather(query func(shard filter.FilterServiceClient) ([]*filter.FilterItem, error)) ([]*filter.FilterItem, error) {
+	answers := make([][]*filter.FilterItem, len(c.shards))
+	err := c.each(func(i int, shard filter.FilterServiceClient) (err error) {
+		answers[i], err = query(shard)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var items []*filter.FilterItem
+	for _, answer := range answers {
+		items = append(items, answer...)
+	}
+	return items, nil
+}
+
+// the n best (or worst) of items, in that order, all of them for n 0
+func bestOf(items []*filter.FilterItem, max bool, n int) []*filter.FilterItem {
+	sort.SliceStable(items, func(i, j int) bool {
+		if max {
+			return apps.EffectiveScore(items[j]) < apps.EffectiveScore(items[i])
+		}
+		return apps.EffectiveScore(items[i]) < apps.EffectiveScore(items[j])
+	})
+	if n > 0 && len(items) > n {
+		items = items[:n]
+	}
+	return items
+}
+
+// the shard with the best max (or min) right now and that item, fails like
+// an empty filter if every shard is empty, and like the shard if one fails
+// otherwise
+func (c *Cluster) bestShard(ctx context.Context, max bool) (int, *filter.FilterItem, error) {
+	heads := make([]*filter.FilterItem, len(c.shards))
+	errs := make([]error, len(c.shards))
+	c.each(func(i int, shard filter.FilterServiceClient) error {
+		if max {
+			resp, err := shard.GetMaxItem(ctx, &filter.GetMaxItemRequest{})
+			heads[i], errs[i] = resp.GetItem(), err
+		} else {
+			resp, err := shard.GetMinItem(ctx, &filter.GetMinItemRequest{})
+			heads[i], errs[i] = resp.GetItem(), err
+		}
+		return nil
+	})
+
+	best := -1
+	for i, head := range heads {
+		if errs[i] != nil {
+			if !isEmpty(errs[i]) {
+				return -1, nil, errs[i]
+			}
+			continue
+		}
+		if best < 0 || max && apps.EffectiveScore(heads[best]) < apps.EffectiveScore(head) ||
+			!max && apps.EffectiveScore(head) < apps.EffectiveScore(heads[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return -1, nil, errs[0]
+	}
+	return best, heads[best], nil
+}
+
+// remove from the shard with the best head, without waiting
+func (c *Cluster) removeBest(ctx context.Context, max bool, minScore *float32) (*filter.FilterItem, error) {
+	i, head, err := c.bestShard(ctx, max)
+	if err != nil {
+		return nil, err
+	}
+	if minScore != nil && apps.EffectiveScore(head) < *minScore {
+		return nil, status.Errorf(codes.Internal,
+			"Filter has no item scoring at least %v", *minScore)
+	}
+	return c.remove(ctx, i, max, 0, minScore)
+}
+
+// Remove like a single filter would, waiting up to waitMs if nothing
+// qualifies
+func (c *Cluster) removeWait(ctx context.Context, max bool, waitMs int64, minScore *float32) (*filter.FilterItem, error) {
+	item, err := c.removeBest(ctx, max, minScore)
+	if err == nil || waitMs <= 0 {
+		return item, err
+	}
+	return removeAny(ctx, c.shards, max, waitMs, minScore, err)
+}
+
+func (c *Cluster) remove(ctx context.Context, i int, max bool, waitMs int64, minScore *float32) (*filter.FilterItem, error) {
+	return removeFrom(ctx, c.shards[i], max, waitMs, minScore)
+}
+
+// Park a waiting removal on every shard at once. The first item to show up
+// wins and anything the other shards handed out meanwhile goes back. Fails
+// with err if no shard had anything to say.
+func removeAny(ctx context.Context, shards []filter.FilterServiceClient, max bool, waitMs int64, minScore *float32, err error) (*filter.FilterItem, error) {
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		i    int
+		item *filter.FilterItem
+		err  error
+	}
+	results := make(chan result, len(shards))
+	for i := range shards {
+		i := i
+		go func() {
+			item, err := removeFrom(waitCtx, shards[i], max, waitMs, minScore)
+			results <- result{i, item, err}
+		}()
+	}
+
+	var won *filter.FilterItem
+	var waitErr error
+	for range shards {
+		r := <-results
+		if r.err != nil {
+			if waitErr == nil && status.Code(r.err) != codes.Canceled {
+				waitErr = r.err
+			}
+			continue
+		}
+		if won == nil {
+			won = r.item
+			cancel()
+		} else {
+			putBackOn(shards[r.i], r.i, r.item)
+		}
+	}
+	if won != nil {
+		return won, nil
+	}
+	if ctx.Err() != nil {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if waitErr != nil {
+		return nil, waitErr
+	}
+	return nil, err
+}
+
+// Give a shard back an item it handed out, the cluster has nowhere to report
+// one that doesn't make it back but the log
+func putBackOn(shard filter.FilterServiceClient, i int, item *filter.FilterItem) {
+	resp, err := shard.InsertItem(context.Background(), &filter.InsertItemRequest{Item: item})
+	switch {
+	case err != nil:
+		log.Printf("cluster failed to put an item back on shard %d: %v", i, err)
+	case resp.GetOutcome() == filter.InsertOutcome_REJECTED && !apps.Expired(item):
+		log.Printf("cluster lost an item putting it back on shard %d, it filled up meanwhile", i)
+	}
+}
+
+// a shard failed only because it had nothing
+func isEmpty(err error) bool {
+	s := status.Convert(err)
+	return s.Code() == codes.Internal && s.Message() == "Filter is empty"
+}
+
+func removeFrom(ctx context.Context, shard filter.FilterServiceClient, max bool, waitMs int64, minScore *float32) (*filter.FilterItem, error) {
+	if max {
+		resp, err := shard.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{WaitMs: waitMs, MinScore: minScore})
+		return resp.GetItem(), err
+	}
+	resp, err := shard.RemoveMinItem(ctx, &filter.RemoveMinItemRequest{WaitMs: waitMs, MinScore: minScore})
+	return resp.GetItem(), err
+}
+
+// "<shard>/<lease ID on that shard>"
+func (c *Cluster) splitLease(leaseID string) (int, string, error) {
+	shard, id, ok := strings.Cut(leaseID, "/")
+	i, err := strconv.Atoi(shard)
+	if !ok || err != nil || i < 0 || i >= len(c.shards) {
+		return 0, "", status.Errorf(codes.NotFound, "Lease %s not found or expired", leaseID)
+	}
+	return i, id, nil
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	evictionBuffer       = 4096             // evictions a sink can fall behind by before they're dropped
+	evictionForwardBatch = 64               // most evictions forwarded in one call
+	evictionMaxBackoff   = 10 * time.Second // longest wait while the dead-letter filter is down
+)
+
+// Somewhere the filter's evicted items go. Evicted runs on the insert path,
+// with the heap possibly locked, so it must not block.
+type EvictionSink interface {
+	Evicted(ev *filter.Eviction)
+}
+
+// Send every eviction from now on to sink as well
+func (s *Filter) AddEvictionSink(sink EvictionSink) {
+	s.evictLk.Lock()
+	defer s.evictLk.Unlock()
+	s.evictSinks[sink] = struct{}{}
+}
+
+func (s *Filter) RemoveEvictionSink(sink EvictionSink) {
+	s.evictLk.Lock()
+	defer s.evictLk.Unlock()
+	delete(s.evictSinks, sink)
+}
+
+// Stream every item the filter throws away from now on, with the reason.
+// The headers go out once the watcher is registered, so a client that waits
+// for them misses nothing after. A watcher that can't keep up loses
+// evictions, the next one it gets says how many.
+func (s *Filter) WatchEvictions(req *filter.WatchEvictionsRequest, stream filter.FilterService_WatchEvictionsServer) error {
+	q := newEvictionQueue()
+	s.AddEvictionSink(q)
+	defer s.RemoveEvictionSink(q)
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	for {
+		select {
+		case ev := <-q.ch:
+			if err := stream.Send(q.withMissed(ev)); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Appends every eviction to a file as one JSON object per line
+type EvictionLog struct {
+	*evictionQueue
+	f    *os.File
+	stop chan struct{}
+	done chan struct{}
+}
+
+// what a line of the eviction log looks like, data is base64
+type evictionLine struct {
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+	Score  float32   `json:"score"`
+	Data   []byte    `json:"data,omitempty"`
+	Missed int64     `json:"missed,omitempty"`
+
+	EffectiveScore *float32 `json:"effective_score,omitempty"` // with score decay
+}
+
+func OpenEvictionLog(path string) (*EvictionLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	l := &EvictionLog{
+		evictionQueue: newEvictionQueue(),
+		f:             f,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go l.run()
+	return l, nil
+}
+
+// Write out what's queued and close the file, evictions after this are lost
+func (l *EvictionLog) Close() error {
+	close(l.stop)
+	<-l.done
+	return l.f.Close()
+}
+
+// Inserts every eviction into another FilterService, a dead-letter filter
+// that keeps the best of what this one had to let go
+type EvictionForwarder struct {
+	*evictionQueue
+	target filter.FilterServiceClient
+}
+
+func NewEvictionForwarder(addr string) *EvictionForwarder {
+	return &EvictionForwarder{
+		evictionQueue: newEvictionQueue(),
+		target:        filter.NewFilterServiceClient(dial(addr)),
+	}
+}
+
+// Forward until ctx is done. While the target is down the evictions queue
+// up, and the ones that don't fit the queue are lost.
+func (f *EvictionForwarder) Run(ctx context.Context) error {
+	backoff := minBackoff
+	for {
+		var batch []*filter.FilterItem
+		select {
+		case ev := <-f.ch:
+			batch = append(batch, ev.GetItem())
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		for len(batch) < evictionForwardBatch && len(f.ch) > 0 {
+			batch = append(batch, (<-f.ch).GetItem())
+		}
+
+		for {
+			err := f.send(ctx, batch)
+			if err == nil {
+				backoff = minBackoff
+				break
+			}
+			if code := status.Code(err); code == codes.InvalidArgument || code == codes.OutOfRange {
+				log.Printf("eviction forwarder dropped %d items the target won't take: %v", len(batch), err)
+				break
+			}
+
+			log.Printf("eviction forwarder failed to reach the target, retrying in %v: %v", backoff, err)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			backoff *= 2
+			if backoff > evictionMaxBackoff {
+				backoff = evictionMaxBackoff
+			}
+		}
+		if n := f.missed.Swap(0); n > 0 {
+			log.Printf("eviction forwarder fell behind and lost %d items", n)
+		}
+	}
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+// hooked into the app, counts item and hands it to every sink
+func (s *Filter) evicted(item *filter.FilterItem, reason apps.EvictReason) {
+	if reason == apps.EvictExpired {
+		s.expiredCount.Add(1)
+	} else {
+		s.evictedCount.Add(1)
+	}
+
+	s.evictLk.RLock()
+	defer s.evictLk.RUnlock()
+	if len(s.evictSinks) == 0 {
+		return
+	}
+
+	ev := &filter.Eviction{
+		Item:       item,
+		Reason:     evictReason(reason),
+		TimeUnixMs: time.Now().UnixMilli(),
+	}
+	for sink := range s.evictSinks {
+		sink.Evicted(ev)
+	}
+}
+
+func evictReason(r apps.EvictReason) filter.EvictReason {
+	switch r {
+	case apps.EvictLease:
+		return filter.EvictReason_LEASE
+	case apps.EvictExpired:
+		return filter.EvictReason_EXPIRED
+	case apps.EvictRefused:
+		return filter.EvictReason_REFUSED
+	default:
+		return filter.EvictReason_CAPACITY
+	}
+}
+
+// Buffer between the insert path and a sink that may be slow, evictions
+// that don't fit are counted and dropped
+type evictionQueue struct {
+	ch     chan *filter.Eviction
+	missed atomic.Int64
+}
+
+func newEvictionQueue() *evictionQueue {
+	return &evictionQueue{ch: make(chan *filter.Eviction, evictionBuffer)}
+}
+
+func (q *evictionQueue) Evicted(ev *filter.Eviction) {
+	select {
+	case q.ch <- ev:
+	default:
+		q.missed.Add(1)
+	}
+}
+
+// ev with the evictions lost since the last one, ev itself is shared with
+// the other sinks so it's copied
+func (q *evictionQueue) withMissed(ev *filter.Eviction) *filter.Eviction {
+	n := q.missed.Swap(0)
+	if n == 0 {
+		return ev
+	}
+	return &filter.Eviction{
+		Item:       ev.GetItem(),
+		Reason:     ev.GetReason(),
+		TimeUnixMs: ev.GetTimeUnixMs(),
+		Missed:     n,
+	}
+}
+
+func (l *EvictionLog) run() {
+	defer close(l.done)
+	w := bufio.NewWriter(l.f)
+	enc := json.NewEncoder(w)
+	write := func(ev *filter.Eviction) {
+		ev = l.withMissed(ev)
+		err := enc.Encode(evictionLine{
+			Time:   time.UnixMilli(ev.GetTimeUnixMs()).UTC(),
+			Reason: strings.ToLower(ev.GetReason().String()),
+			Score:  ev.GetItem().GetScore(),
+			Data:   ev.GetItem().GetData(),
+			Missed: ev.GetMissed(),
+
+			EffectiveScore: ev.GetItem().EffectiveScore,
+		})
+		if err != nil {
+			log.Println("failed to write the eviction log:", err)
+		}
+	}
+	flush := func() {
+		if err := w.Flush(); err != nil {
+			log.Println("failed to write the eviction log:", err)
+		}
+	}
+
+	for {
+		select {
+		case ev := <-l.ch:
+			write(ev)
+			if len(l.ch) == 0 {
+				// caught up, get it on disk
+				flush()
+			}
+		case <-l.stop:
+			for len(l.ch) > 0 {
+				write(<-l.ch)
+			}
+			flush()
+			return
+		}
+	}
+}
+
+func (f *EvictionForwarder) send(ctx context.Context, batch []*filter.FilterItem) error {
+	ctx, cancel := context.WithTimeout(ctx, forwardTimeout)
+	defer cancel()
+	_, err := f.target.InsertItems(ctx, &filter.InsertItemsRequest{Items: batch})
+	return err
+}
@@ -3,27 +3,58 @@ package services
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"sync"
+	"sync/atomic"
 
 	"github.com/Jfroel/cdsf-microservice/apps"
 	"github.com/Jfroel/cdsf-microservice/proto/filter"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// items a Drain takes out at a time when the request doesn't say
+const defaultDrainBatch = 100
+
 type Filter struct {
 	name string
 	port int
 	filter.FilterServiceServer
-	app apps.ConcurrentDataStreamFilter
+	app      apps.ConcurrentDataStreamFilter
+	arrivals arrivals // wakes up consumers waiting on an empty filter
+
+	followLk      sync.Mutex
+	stopFollowing context.CancelFunc // set while a backup follows its primary
+	following     chan struct{}      // closed once it stopped
+
+	bandLk sync.RWMutex // inserts check the band under the read lock
+	band   band
+
+	evictLk      sync.RWMutex
+	evictSinks   map[EvictionSink]struct{} // where evicted items go
+	evictedCount atomic.Int64              // items pushed out for better ones or leases
+	expiredCount atomic.Int64              // items that outlived their TTL
 }
 
-func NewFilter(name string, port int, lkType string, capacity int) *Filter {
-	return &Filter{
-		name: name,
-		port: port,
-		app:  apps.NewCDSFApp(lkType, capacity),
+func NewFilter(name string, port int, app apps.ConcurrentDataStreamFilter) *Filter {
+	s := &Filter{
+		name:       name,
+		port:       port,
+		app:        app,
+		band:       everything,
+		evictSinks: make(map[EvictionSink]struct{}),
+	}
+	if leaser, ok := app.(apps.LeasingFilter); ok {
+		// items coming back from a lease wake up waiting consumers too
+		leaser.OnReturn(s.arrivals.notify)
 	}
+	if evicter, ok := apps.As[apps.EvictingFilter](app); ok {
+		evicter.OnEvict(s.evicted)
+	}
+	return s
 }
 
 func (s *Filter) Run() error {
@@ -47,13 +78,61 @@ func (s *Filter) Run() error {
 func (s *Filter) InsertItem(ctx context.Context, req *filter.InsertItemRequest) (*filter.InsertItemResponse, error) {
 	resp := &filter.InsertItemResponse{Success: true}
 	item := req.GetItem()
-	err := s.app.Insert(item)
+	res, err := s.insert(item)
+	if err != nil {
+		resp.Success = false
+	}
+	resp.Outcome, resp.Evicted = insertOutcome(res.Outcome), res.Evicted
+	resp.Threshold = s.threshold()
+	s.arrivals.notify()
+	return resp, err
+}
+
+func (s *Filter) InsertItems(ctx context.Context, req *filter.InsertItemsRequest) (*filter.InsertItemsResponse, error) {
+	resp := &filter.InsertItemsResponse{Success: true}
+	err := s.inBand(req.GetItems(), func() error {
+		return s.app.InsertBatch(req.GetItems())
+	})
 	if err != nil {
 		resp.Success = false
 	}
+	resp.Threshold = s.threshold()
+	s.arrivals.notify()
 	return resp, err
 }
 
+// Items are taken off the stream one at a time, the next one only once the
+// last one is in the filter. A slow heap therefore stops reading, the
+// stream's flow control window fills up and the producer's Send blocks.
+func (s *Filter) InsertStream(stream filter.FilterService_InsertStreamServer) error {
+	resp := &filter.InsertStreamResponse{}
+	for {
+		item, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			return err
+		}
+
+		res, err := s.insert(item)
+		if err != nil {
+			return err
+		}
+		s.arrivals.notify()
+
+		switch res.Outcome {
+		case apps.InsertStored, apps.InsertUpdated:
+			resp.Accepted++
+		case apps.InsertRejected:
+			resp.Rejected++
+		case apps.InsertEvicted:
+			resp.Accepted++
+			resp.Evicted++
+		}
+	}
+}
+
 func (s *Filter) GetMaxItem(ctx context.Context, req *filter.GetMaxItemRequest) (*filter.GetMaxItemResponse, error) {
 	resp := &filter.GetMaxItemResponse{}
 	item, err := s.app.GetMax()
@@ -76,7 +155,7 @@ func (s *Filter) GetMinItem(ctx context.Context, req *filter.GetMinItemRequest)
 
 func (s *Filter) RemoveMaxItem(ctx context.Context, req *filter.RemoveMaxItemRequest) (*filter.RemoveMaxItemResponse, error) {
 	resp := &filter.RemoveMaxItemResponse{}
-	item, err := s.app.RemoveMax()
+	item, err := s.removeItem(ctx, true, req.GetWaitMs(), req.MinScore)
 	if err != nil {
 		return resp, err
 	}
@@ -86,7 +165,7 @@ func (s *Filter) RemoveMaxItem(ctx context.Context, req *filter.RemoveMaxItemReq
 
 func (s *Filter) RemoveMinItem(ctx context.Context, req *filter.RemoveMinItemRequest) (*filter.RemoveMinItemResponse, error) {
 	resp := &filter.RemoveMinItemResponse{}
-	item, err := s.app.RemoveMin()
+	item, err := s.removeItem(ctx, false, req.GetWaitMs(), req.MinScore)
 	if err != nil {
 		return resp, err
 	}
@@ -94,11 +173,81 @@ func (s *Filter) RemoveMinItem(ctx context.Context, req *filter.RemoveMinItemReq
 	return resp, err
 }
 
+func (s *Filter) RemoveTopK(ctx context.Context, req *filter.RemoveTopKRequest) (*filter.RemoveTopKResponse, error) {
+	resp := &filter.RemoveTopKResponse{}
+	if req.GetK() <= 0 {
+		return resp, status.Errorf(codes.InvalidArgument, "k has to be positive")
+	}
+	items, err := s.app.RemoveTopK(int(req.GetK()))
+	resp.Items = items
+	return resp, err
+}
+
+func (s *Filter) RemoveAbove(ctx context.Context, req *filter.RemoveAboveRequest) (*filter.RemoveAboveResponse, error) {
+	resp := &filter.RemoveAboveResponse{}
+	if req.GetLimit() < 0 {
+		return resp, status.Errorf(codes.InvalidArgument, "Limit can't be negative")
+	}
+	items, err := s.app.RemoveAbove(req.GetThreshold(), int(req.GetLimit()))
+	resp.Items = items
+	return resp, err
+}
+
+// One batch at a time, each taken out at once and sent before the next one
+// is, so a slow consumer holds up the drain rather than the filter
+func (s *Filter) Drain(req *filter.DrainRequest, stream filter.FilterService_DrainServer) error {
+	if req.GetLimit() < 0 || req.GetBatchSize() < 0 {
+		return status.Errorf(codes.InvalidArgument, "Limit and batch size can't be negative")
+	}
+	batchSize := int(req.GetBatchSize())
+	if batchSize == 0 {
+		batchSize = defaultDrainBatch
+	}
+
+	left := int(req.GetLimit())
+	for {
+		n := batchSize
+		if req.GetLimit() > 0 && left < n {
+			n = left
+		}
+		var items []*filter.FilterItem
+		var err error
+		if req.MinScore != nil {
+			items, err = s.app.RemoveAbove(req.GetMinScore(), n)
+		} else {
+			items, err = s.app.RemoveTopK(n)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(items) == 0 {
+			// nothing qualifying left, a short batch doesn't say so, the
+			// multiqueue's can come back short anyway
+			return nil
+		}
+		if err := stream.Send(&filter.DrainResponse{Items: items}); err != nil {
+			// never made it out, don't lose them
+			for _, item := range items {
+				s.putBack(item)
+			}
+			return err
+		}
+		left -= len(items)
+		if req.GetLimit() > 0 && left == 0 {
+			// all the consumer asked for
+			return nil
+		}
+	}
+}
+
 func (s *Filter) GetSize(ctx context.Context, req *filter.GetSizeRequest) (*filter.GetSizeResponse, error) {
 	resp := &filter.GetSizeResponse{}
 	size := s.app.GetSize()
 
 	resp.Size = int32(size)
+	resp.Evicted = s.evictedCount.Load()
+	resp.Expired = s.expiredCount.Load()
 	return resp, nil
 }
 
@@ -110,3 +259,135 @@ func (s *Filter) Clear(ctx context.Context, req *filter.ClearRequest) (*filter.C
 	}
 	return resp, err
 }
+
+// The new score has to be in the filter's band, like an insert
+func (s *Filter) UpdateScore(ctx context.Context, req *filter.UpdateScoreRequest) (*filter.UpdateScoreResponse, error) {
+	resp := &filter.UpdateScoreResponse{}
+	keyed, ok := apps.As[apps.KeyedFilter](s.app)
+	if !ok {
+		return resp, status.Errorf(codes.Unimplemented, "Filter type doesn't support keyed items")
+	}
+	var item *filter.FilterItem
+	err := s.inBand([]*filter.FilterItem{{Score: req.GetScore()}}, func() (err error) {
+		item, err = keyed.UpdateScore(req.GetKey(), req.GetScore())
+		return err
+	})
+	if err != nil {
+		return resp, err
+	}
+	// a consumer waiting for a min score may want it now
+	s.arrivals.notify()
+	resp.Item = item
+	return resp, err
+}
+
+func (s *Filter) DeleteByKey(ctx context.Context, req *filter.DeleteByKeyRequest) (*filter.DeleteByKeyResponse, error) {
+	resp := &filter.DeleteByKeyResponse{}
+	keyed, ok := apps.As[apps.KeyedFilter](s.app)
+	if !ok {
+		return resp, status.Errorf(codes.Unimplemented, "Filter type doesn't support keyed items")
+	}
+	item, err := keyed.Delete(req.GetKey())
+	if err != nil {
+		return resp, err
+	}
+	resp.Item = item
+	return resp, err
+}
+
+func (s *Filter) GetTopK(ctx context.Context, req *filter.GetTopKRequest) (*filter.GetTopKResponse, error) {
+	resp := &filter.GetTopKResponse{}
+	q, err := s.querying(req.GetK())
+	if err != nil {
+		return resp, err
+	}
+	resp.Items, err = q.TopK(int(req.GetK()))
+	return resp, err
+}
+
+func (s *Filter) GetBottomK(ctx context.Context, req *filter.GetBottomKRequest) (*filter.GetBottomKResponse, error) {
+	resp := &filter.GetBottomKResponse{}
+	q, err := s.querying(req.GetK())
+	if err != nil {
+		return resp, err
+	}
+	resp.Items, err = q.BottomK(int(req.GetK()))
+	return resp, err
+}
+
+func (s *Filter) GetRange(ctx context.Context, req *filter.GetRangeRequest) (*filter.GetRangeResponse, error) {
+	resp := &filter.GetRangeResponse{}
+	if req.GetLimit() < 0 {
+		return resp, status.Errorf(codes.InvalidArgument, "Range limit can't be negative")
+	}
+	q, ok := apps.As[apps.QueryingFilter](s.app)
+	if !ok {
+		return resp, errNoQueries()
+	}
+	var err error
+	resp.Items, err = q.Range(req.GetMinScore(), req.GetMaxScore(), int(req.GetLimit()))
+	return resp, err
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+// the app that answers a top or bottom k query
+func (s *Filter) querying(k int32) (apps.QueryingFilter, error) {
+	if k <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "k has to be positive")
+	}
+	q, ok := apps.As[apps.QueryingFilter](s.app)
+	if !ok {
+		return nil, errNoQueries()
+	}
+	return q, nil
+}
+
+func errNoQueries() error {
+	return status.Errorf(codes.Unimplemented, "Filter type doesn't support queries")
+}
+
+// insert one item if it falls in the band
+func (s *Filter) insert(item *filter.FilterItem) (res apps.FilterInsertResult, err error) {
+	err = s.inBand([]*filter.FilterItem{item}, func() error {
+		res, err = s.app.Insert(item)
+		return err
+	})
+	return res, err
+}
+
+// Put an item that was taken out for a consumer who never got it back into
+// the filter. The app reports what the insert evicts and an item that
+// expired meanwhile, one that no longer makes the cut is reported from here.
+func (s *Filter) putBack(item *filter.FilterItem) {
+	res, err := s.app.Insert(item)
+	switch {
+	case err != nil:
+		log.Println("filter failed to put an item back:", err)
+		s.evicted(item, apps.EvictCapacity)
+	case res.Outcome == apps.InsertRejected:
+		if !apps.Expired(item) {
+			s.evicted(item, apps.EvictCapacity)
+		}
+	case res.Outcome == apps.InsertEvicted:
+		// the app reported the item it pushed out
+	}
+	s.arrivals.notify()
+}
+
+func insertOutcome(o apps.InsertOutcome) filter.InsertOutcome {
+	switch o {
+	case apps.InsertRejected:
+		return filter.InsertOutcome_REJECTED
+	case apps.InsertEvicted:
+		return filter.InsertOutcome_EVICTED
+	case apps.InsertUpdated:
+		return filter.InsertOutcome_UPDATED
+	case apps.InsertFailed:
+		return filter.InsertOutcome_FAILED
+	default:
+		return filter.InsertOutcome_STORED
+	}
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	forwardTimeout = 5 * time.Second        // per upstream call
+	minBackoff     = 100 * time.Millisecond // first wait after the upstream failed
+)
+
+// What a Forwarder sends upstream and how fast
+type ForwarderConfig struct {
+	Rate       float64       // items per second below Threshold, 0 is never if Threshold is set, else unpaced
+	Threshold  *float32      // items scoring at least this go right away, nil for none
+	Batch      int           // most items per upstream call
+	MaxBackoff time.Duration // longest wait between retries while the upstream is down
+}
+
+// Drains the best items of a local filter into an upstream FilterService,
+// so a small filter near the producers only passes its best items on. While
+// the upstream is down the items stay in the local filter, which keeps the
+// best of them within its capacity until they can go.
+type Forwarder struct {
+	local    *Filter
+	upstream filter.FilterServiceClient
+	cfg      ForwarderConfig
+	credit   *credit // pacing below the threshold, nil if those never go
+}
+
+func NewForwarder(local *Filter, upstreamAddr string, cfg ForwarderConfig) *Forwarder {
+	if cfg.Batch < 1 {
+		cfg.Batch = 1
+	}
+	if cfg.MaxBackoff < minBackoff {
+		cfg.MaxBackoff = minBackoff
+	}
+	f := &Forwarder{
+		local:    local,
+		upstream: filter.NewFilterServiceClient(dial(upstreamAddr)),
+		cfg:      cfg,
+	}
+	if cfg.Threshold == nil || cfg.Rate > 0 {
+		f.credit = newCredit(cfg.Rate, 1)
+	}
+	return f
+}
+
+// Forward until ctx is done
+func (f *Forwarder) Run(ctx context.Context) error {
+	backoff := minBackoff
+	for {
+		item, err := f.next(ctx)
+		if err != nil {
+			return err
+		}
+		batch := []*filter.FilterItem{item}
+		for len(batch) < f.cfg.Batch {
+			item, _ := f.pick()
+			if item == nil {
+				break
+			}
+			batch = append(batch, item)
+		}
+
+		err = f.send(ctx, batch)
+		if refused(err) {
+			// one item the upstream won't take turns the whole batch down
+			batch, err = f.sendEach(ctx, batch)
+		}
+		if err == nil {
+			backoff = minBackoff
+			continue
+		}
+
+		// keep them until the upstream is back
+		log.Printf("forwarder failed to reach the upstream, retrying in %v: %v", backoff, err)
+		f.local.app.InsertBatch(batch)
+		f.local.arrivals.notify()
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+		if backoff > f.cfg.MaxBackoff {
+			backoff = f.cfg.MaxBackoff
+		}
+	}
+}
+
+///////////////////////////////////
+// private helper functions
+///////////////////////////////////
+
+func (f *Forwarder) send(ctx context.Context, batch []*filter.FilterItem) error {
+	ctx, cancel := context.WithTimeout(ctx, forwardTimeout)
+	defer cancel()
+	_, err := f.upstream.InsertItems(ctx, &filter.InsertItemsRequest{Items: batch})
+	return err
+}
+
+// Send the items of a refused batch one at a time, the ones the upstream won't
+// take go to the local filter's eviction sinks. Returns the items left over
+// if the upstream fails any other way.
+func (f *Forwarder) sendEach(ctx context.Context, batch []*filter.FilterItem) ([]*filter.FilterItem, error) {
+	for i, item := range batch {
+		err := f.send(ctx, []*filter.FilterItem{item})
+		if refused(err) {
+			log.Printf("forwarder dropped an item the upstream won't take: %v", err)
+			f.local.evicted(item, apps.EvictRefused)
+			continue
+		}
+		if err != nil {
+			return batch[i:], err
+		}
+	}
+	return nil, nil
+}
+
+// the upstream is up but won't take what was sent
+func refused(err error) bool {
+	code := status.Code(err)
+	return code == codes.InvalidArgument || code == codes.OutOfRange
+}
+
+// wait for the next item to forward
+func (f *Forwarder) next(ctx context.Context) (*filter.FilterItem, error) {
+	for {
+		arrived := f.local.arrivals.wait()
+		item, wait := f.pick()
+		if item != nil {
+			return item, nil
+		}
+
+		var timer *time.Timer
+		var credited <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			credited = timer.C
+		}
+		select {
+		case <-arrived:
+		case <-credited:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// The best item if it may go right now. Otherwise nil and how long until the
+// pacing allows the next one, 0 if that's not what holds it up.
+func (f *Forwarder) pick() (*filter.FilterItem, time.Duration) {
+	if f.cfg.Threshold != nil {
+		if item, err := f.local.tryRemove(true, f.cfg.Threshold); err == nil {
+			return item, 0
+		}
+	}
+	if f.credit == nil {
+		return nil, 0
+	}
+	if wait := f.credit.tryTake(); wait > 0 {
+		return nil, wait
+	}
+	item, err := f.local.tryRemove(true, nil)
+	if err != nil {
+		f.credit.refund()
+		return nil, 0
+	}
+	return item, 0
+}
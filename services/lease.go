@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Filter) leaser() (apps.LeasingFilter, error) {
+	leaser, ok := s.app.(apps.LeasingFilter)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "Filter does not support leases")
+	}
+	return leaser, nil
+}
+
+func (s *Filter) LeaseMaxItem(ctx context.Context, req *filter.LeaseMaxItemRequest) (*filter.LeaseMaxItemResponse, error) {
+	resp := &filter.LeaseMaxItemResponse{}
+	leaser, err := s.leaser()
+	if err != nil {
+		return resp, err
+	}
+
+	item, id, err := leaser.LeaseMax(time.Duration(req.GetTimeoutMs()) * time.Millisecond)
+	if err != nil {
+		return resp, err
+	}
+	resp.Item = item
+	resp.LeaseId = id
+	return resp, err
+}
+
+func (s *Filter) Ack(ctx context.Context, req *filter.AckRequest) (*filter.AckResponse, error) {
+	resp := &filter.AckResponse{Success: true}
+	leaser, err := s.leaser()
+	if err != nil {
+		resp.Success = false
+		return resp, err
+	}
+
+	err = leaser.Ack(req.GetLeaseId())
+	if err != nil {
+		resp.Success = false
+	}
+	return resp, err
+}
+
+func (s *Filter) Nack(ctx context.Context, req *filter.NackRequest) (*filter.NackResponse, error) {
+	resp := &filter.NackResponse{Success: true}
+	leaser, err := s.leaser()
+	if err != nil {
+		resp.Success = false
+		return resp, err
+	}
+
+	err = leaser.Nack(req.GetLeaseId())
+	if err != nil {
+		resp.Success = false
+	}
+	return resp, err
+}
@@ -1,32 +1,88 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Proxy struct {
 	port         int
-	filterClient filter.FilterServiceClient
+	filterClient filterAPI
+	bands        *BandRouter // only for a score partitioned topology
 	ID           string
 }
 
+// the FilterService calls behind the proxy's endpoints, served by a single
+// filter or a whole Cluster of them
+type filterAPI interface {
+	InsertItem(ctx context.Context, in *filter.InsertItemRequest, opts ...grpc.CallOption) (*filter.InsertItemResponse, error)
+	GetMaxItem(ctx context.Context, in *filter.GetMaxItemRequest, opts ...grpc.CallOption) (*filter.GetMaxItemResponse, error)
+	GetMinItem(ctx context.Context, in *filter.GetMinItemRequest, opts ...grpc.CallOption) (*filter.GetMinItemResponse, error)
+	RemoveMaxItem(ctx context.Context, in *filter.RemoveMaxItemRequest, opts ...grpc.CallOption) (*filter.RemoveMaxItemResponse, error)
+	RemoveMinItem(ctx context.Context, in *filter.RemoveMinItemRequest, opts ...grpc.CallOption) (*filter.RemoveMinItemResponse, error)
+	LeaseMaxItem(ctx context.Context, in *filter.LeaseMaxItemRequest, opts ...grpc.CallOption) (*filter.LeaseMaxItemResponse, error)
+	Ack(ctx context.Context, in *filter.AckRequest, opts ...grpc.CallOption) (*filter.AckResponse, error)
+	Nack(ctx context.Context, in *filter.NackRequest, opts ...grpc.CallOption) (*filter.NackResponse, error)
+	GetSize(ctx context.Context, in *filter.GetSizeRequest, opts ...grpc.CallOption) (*filter.GetSizeResponse, error)
+	Clear(ctx context.Context, in *filter.ClearRequest, opts ...grpc.CallOption) (*filter.ClearResponse, error)
+	UpdateScore(ctx context.Context, in *filter.UpdateScoreRequest, opts ...grpc.CallOption) (*filter.UpdateScoreResponse, error)
+	DeleteByKey(ctx context.Context, in *filter.DeleteByKeyRequest, opts ...grpc.CallOption) (*filter.DeleteByKeyResponse, error)
+	GetTopK(ctx context.Context, in *filter.GetTopKRequest, opts ...grpc.CallOption) (*filter.GetTopKResponse, error)
+	GetBottomK(ctx context.Context, in *filter.GetBottomKRequest, opts ...grpc.CallOption) (*filter.GetBottomKResponse, error)
+	GetRange(ctx context.Context, in *filter.GetRangeRequest, opts ...grpc.CallOption) (*filter.GetRangeResponse, error)
+}
+
+// What /insert answers, the outcome spelled out as stored, rejected,
+// evicted or updated
+type insertReply struct {
+	Success   bool               `json:"success"`
+	Outcome   string             `json:"outcome"`
+	Evicted   *filter.FilterItem `json:"evicted,omitempty"`
+	Threshold *float32           `json:"threshold,omitempty"`
+}
+
 // NewFrontend creates a new Frontend instance with the specified configuration.
-func NewProxy(port int, filterAddr, ID string) *Proxy {
+// More than one filter address makes the proxy a coordinator over shards.
+func NewProxy(port int, filterAddrs []string, ID string) *Proxy {
 	p := &Proxy{
-		port:         port,
-		filterClient: filter.NewFilterServiceClient(dial(filterAddr)),
-		ID:           ID,
+		port: port,
+		ID:   ID,
+	}
+	if len(filterAddrs) == 1 {
+		p.filterClient = NewFilterClient(context.Background(), filterAddrs[0])
+	} else {
+		p.filterClient = NewCluster(filterAddrs)
 	}
 	return p
 }
 
+// A proxy in front of score partitioned filters, see NewBandRouter for the
+// spec
+func NewBandProxy(port int, bandSpec string, ID string) (*Proxy, error) {
+	bands, err := NewBandRouter(context.Background(), bandSpec)
+	if err != nil {
+		return nil, err
+	}
+	return &Proxy{
+		port:         port,
+		filterClient: bands,
+		bands:        bands,
+		ID:           ID,
+	}, nil
+}
+
 func (s *Proxy) Run() error {
 	// http.Handle("/", http.FileServer(http.Dir("./static")))
 	http.HandleFunc("/insert", s.insertHandler)
@@ -34,8 +90,21 @@ func (s *Proxy) Run() error {
 	http.HandleFunc("/get-min", s.getMinHandler)
 	http.HandleFunc("/remove-max", s.removeMaxHandler)
 	http.HandleFunc("/remove-min", s.removeMinHandler)
+	http.HandleFunc("/lease-max", s.leaseMaxHandler)
+	http.HandleFunc("/ack", s.ackHandler)
+	http.HandleFunc("/nack", s.nackHandler)
 	http.HandleFunc("/get-size", s.getSizeHandler)
 	http.HandleFunc("/clear", s.clearHandler)
+	http.HandleFunc("/update-score", s.updateScoreHandler)
+	http.HandleFunc("/delete", s.deleteHandler)
+	http.HandleFunc("/top-k", s.topKHandler)
+	http.HandleFunc("/bottom-k", s.bottomKHandler)
+	http.HandleFunc("/range", s.rangeHandler)
+	if s.bands != nil {
+		http.HandleFunc("/bands", s.bandsHandler)
+		http.HandleFunc("/split-band", s.splitBandHandler)
+		http.HandleFunc("/move-band", s.moveBandHandler)
+	}
 
 	log.Printf("http to grpc proxy %v server running at port: %d", s.ID, s.port)
 	return http.ListenAndServe(fmt.Sprintf(":%d", s.port), nil)
@@ -61,8 +130,17 @@ func (s *Proxy) insertHandler(w http.ResponseWriter, r *http.Request) {
 		Item: &filter.FilterItem{
 			Score: float32(score),
 			Data:  []byte{0x01, 0x02, 0x03, 0x04},
+			Key:   r.URL.Query().Get("key"),
 		},
 	}
+	if ttlStr := r.URL.Query().Get("ttl"); ttlStr != "" {
+		ttl, err := time.ParseDuration(ttlStr)
+		if err != nil {
+			http.Error(w, "Malformed request to `/insert` endpoint!", http.StatusBadRequest)
+			return
+		}
+		req.Item.TtlMs = ttl.Milliseconds()
+	}
 	reply, err := s.filterClient.InsertItem(ctx, req)
 
 	if err != nil {
@@ -83,7 +161,12 @@ func (s *Proxy) insertHandler(w http.ResponseWriter, r *http.Request) {
 
 	logMsg("proxy.insertHandler", inStr, outStr, errStr, duration)
 
-	err = json.NewEncoder(w).Encode(reply)
+	err = json.NewEncoder(w).Encode(insertReply{
+		Success:   reply.GetSuccess(),
+		Outcome:   strings.ToLower(reply.GetOutcome().String()),
+		Evicted:   reply.GetEvicted(),
+		Threshold: reply.Threshold,
+	})
 }
 
 func (s *Proxy) getMaxHandler(w http.ResponseWriter, r *http.Request) {
@@ -149,7 +232,13 @@ func (s *Proxy) removeMaxHandler(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	req := &filter.RemoveMaxItemRequest{}
+	waitMs, minScore, err := parseRemoveParams(r)
+	if err != nil {
+		http.Error(w, "Malformed request to `/remove-max` endpoint!", http.StatusBadRequest)
+		return
+	}
+
+	req := &filter.RemoveMaxItemRequest{WaitMs: waitMs, MinScore: minScore}
 	reply, err := s.filterClient.RemoveMaxItem(ctx, req)
 
 	if err != nil {
@@ -178,7 +267,13 @@ func (s *Proxy) removeMinHandler(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	req := &filter.RemoveMinItemRequest{}
+	waitMs, minScore, err := parseRemoveParams(r)
+	if err != nil {
+		http.Error(w, "Malformed request to `/remove-min` endpoint!", http.StatusBadRequest)
+		return
+	}
+
+	req := &filter.RemoveMinItemRequest{WaitMs: waitMs, MinScore: minScore}
 	reply, err := s.filterClient.RemoveMinItem(ctx, req)
 
 	if err != nil {
@@ -202,6 +297,115 @@ func (s *Proxy) removeMinHandler(w http.ResponseWriter, r *http.Request) {
 	err = json.NewEncoder(w).Encode(reply)
 }
 
+func (s *Proxy) leaseMaxHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	ctx := r.Context()
+
+	var timeout time.Duration
+	if timeoutStr := r.URL.Query().Get("timeout"); timeoutStr != "" {
+		var err error
+		timeout, err = time.ParseDuration(timeoutStr)
+		if err != nil {
+			http.Error(w, "Malformed request to `/lease-max` endpoint!", http.StatusBadRequest)
+			return
+		}
+	}
+
+	req := &filter.LeaseMaxItemRequest{TimeoutMs: timeout.Milliseconds()}
+	reply, err := s.filterClient.LeaseMaxItem(ctx, req)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Calculate the duration in microseconds
+	duration := int64(time.Since(start).Microseconds())
+	in, _ := json.Marshal(req)
+	// out, _ := json.Marshal(reply)
+	inStr, outStr := string(in), "{}"
+
+	errStr := fmt.Sprintf("%v", err)
+	if err == nil {
+		errStr = "<nil>"
+	}
+
+	logMsg("proxy.leaseMaxHandler", inStr, outStr, errStr, duration)
+
+	err = json.NewEncoder(w).Encode(reply)
+}
+
+func (s *Proxy) ackHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	ctx := r.Context()
+
+	leaseID := r.URL.Query().Get("lease_id")
+	if leaseID == "" {
+		http.Error(w, "Malformed request to `/ack` endpoint!", http.StatusBadRequest)
+		return
+	}
+
+	req := &filter.AckRequest{LeaseId: leaseID}
+	reply, err := s.filterClient.Ack(ctx, req)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Calculate the duration in microseconds
+	duration := int64(time.Since(start).Microseconds())
+	in, _ := json.Marshal(req)
+	// out, _ := json.Marshal(reply)
+	inStr, outStr := string(in), "{}"
+
+	errStr := fmt.Sprintf("%v", err)
+	if err == nil {
+		errStr = "<nil>"
+	}
+
+	logMsg("proxy.ackHandler", inStr, outStr, errStr, duration)
+
+	err = json.NewEncoder(w).Encode(reply)
+}
+
+func (s *Proxy) nackHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	ctx := r.Context()
+
+	leaseID := r.URL.Query().Get("lease_id")
+	if leaseID == "" {
+		http.Error(w, "Malformed request to `/nack` endpoint!", http.StatusBadRequest)
+		return
+	}
+
+	req := &filter.NackRequest{LeaseId: leaseID}
+	reply, err := s.filterClient.Nack(ctx, req)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Calculate the duration in microseconds
+	duration := int64(time.Since(start).Microseconds())
+	in, _ := json.Marshal(req)
+	// out, _ := json.Marshal(reply)
+	inStr, outStr := string(in), "{}"
+
+	errStr := fmt.Sprintf("%v", err)
+	if err == nil {
+		errStr = "<nil>"
+	}
+
+	logMsg("proxy.nackHandler", inStr, outStr, errStr, duration)
+
+	err = json.NewEncoder(w).Encode(reply)
+}
+
 func (s *Proxy) getSizeHandler(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 
@@ -259,3 +463,325 @@ func (s *Proxy) clearHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = json.NewEncoder(w).Encode(reply)
 }
+
+func (s *Proxy) updateScoreHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	ctx := r.Context()
+
+	key := r.URL.Query().Get("key")
+	score, err := strconv.ParseFloat(r.URL.Query().Get("score"), 32)
+	if key == "" || err != nil {
+		http.Error(w, "Malformed request to `/update-score` endpoint!", http.StatusBadRequest)
+		return
+	}
+
+	req := &filter.UpdateScoreRequest{Key: key, Score: float32(score)}
+	reply, err := s.filterClient.UpdateScore(ctx, req)
+
+	if err != nil {
+		http.Error(w, err.Error(), keyedStatus(err))
+		return
+	}
+
+	// Calculate the duration in microseconds
+	duration := int64(time.Since(start).Microseconds())
+	in, _ := json.Marshal(req)
+	// out, _ := json.Marshal(reply)
+	inStr, outStr := string(in), "{}"
+
+	errStr := fmt.Sprintf("%v", err)
+	if err == nil {
+		errStr = "<nil>"
+	}
+
+	logMsg("proxy.updateScoreHandler", inStr, outStr, errStr, duration)
+
+	err = json.NewEncoder(w).Encode(reply)
+}
+
+func (s *Proxy) deleteHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	ctx := r.Context()
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "Malformed request to `/delete` endpoint!", http.StatusBadRequest)
+		return
+	}
+
+	req := &filter.DeleteByKeyRequest{Key: key}
+	reply, err := s.filterClient.DeleteByKey(ctx, req)
+
+	if err != nil {
+		http.Error(w, err.Error(), keyedStatus(err))
+		return
+	}
+
+	// Calculate the duration in microseconds
+	duration := int64(time.Since(start).Microseconds())
+	in, _ := json.Marshal(req)
+	// out, _ := json.Marshal(reply)
+	inStr, outStr := string(in), "{}"
+
+	errStr := fmt.Sprintf("%v", err)
+	if err == nil {
+		errStr = "<nil>"
+	}
+
+	logMsg("proxy.deleteHandler", inStr, outStr, errStr, duration)
+
+	err = json.NewEncoder(w).Encode(reply)
+}
+
+func (s *Proxy) topKHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	ctx := r.Context()
+
+	k, err := strconv.Atoi(r.URL.Query().Get("k"))
+	if err != nil {
+		http.Error(w, "Malformed request to `/top-k` endpoint!", http.StatusBadRequest)
+		return
+	}
+
+	req := &filter.GetTopKRequest{K: int32(k)}
+	reply, err := s.filterClient.GetTopK(ctx, req)
+
+	if err != nil {
+		http.Error(w, err.Error(), queryStatus(err))
+		return
+	}
+
+	// Calculate the duration in microseconds
+	duration := int64(time.Since(start).Microseconds())
+	in, _ := json.Marshal(req)
+	// out, _ := json.Marshal(reply)
+	inStr, outStr := string(in), "{}"
+
+	errStr := fmt.Sprintf("%v", err)
+	if err == nil {
+		errStr = "<nil>"
+	}
+
+	logMsg("proxy.topKHandler", inStr, outStr, errStr, duration)
+
+	err = json.NewEncoder(w).Encode(reply)
+}
+
+func (s *Proxy) bottomKHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	ctx := r.Context()
+
+	k, err := strconv.Atoi(r.URL.Query().Get("k"))
+	if err != nil {
+		http.Error(w, "Malformed request to `/bottom-k` endpoint!", http.StatusBadRequest)
+		return
+	}
+
+	req := &filter.GetBottomKRequest{K: int32(k)}
+	reply, err := s.filterClient.GetBottomK(ctx, req)
+
+	if err != nil {
+		http.Error(w, err.Error(), queryStatus(err))
+		return
+	}
+
+	// Calculate the duration in microseconds
+	duration := int64(time.Since(start).Microseconds())
+	in, _ := json.Marshal(req)
+	// out, _ := json.Marshal(reply)
+	inStr, outStr := string(in), "{}"
+
+	errStr := fmt.Sprintf("%v", err)
+	if err == nil {
+		errStr = "<nil>"
+	}
+
+	logMsg("proxy.bottomKHandler", inStr, outStr, errStr, duration)
+
+	err = json.NewEncoder(w).Encode(reply)
+}
+
+func (s *Proxy) rangeHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	ctx := r.Context()
+
+	req, err := parseRangeParams(r)
+	if err != nil {
+		http.Error(w, "Malformed request to `/range` endpoint!", http.StatusBadRequest)
+		return
+	}
+	reply, err := s.filterClient.GetRange(ctx, req)
+
+	if err != nil {
+		http.Error(w, err.Error(), queryStatus(err))
+		return
+	}
+
+	// Calculate the duration in microseconds
+	duration := int64(time.Since(start).Microseconds())
+	in, _ := json.Marshal(req)
+	// out, _ := json.Marshal(reply)
+	inStr, outStr := string(in), "{}"
+
+	errStr := fmt.Sprintf("%v", err)
+	if err == nil {
+		errStr = "<nil>"
+	}
+
+	logMsg("proxy.rangeHandler", inStr, outStr, errStr, duration)
+
+	err = json.NewEncoder(w).Encode(reply)
+}
+
+func (s *Proxy) bandsHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	ctx := r.Context()
+
+	reply := s.bands.Bands(ctx)
+
+	// Calculate the duration in microseconds
+	duration := int64(time.Since(start).Microseconds())
+	inStr, outStr := "{}", "{}"
+
+	logMsg("proxy.bandsHandler", inStr, outStr, "<nil>", duration)
+
+	json.NewEncoder(w).Encode(reply)
+}
+
+// ?band=<index>&at=<score>&addr=<filter address>
+func (s *Proxy) splitBandHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	ctx := r.Context()
+
+	i, err := strconv.Atoi(r.URL.Query().Get("band"))
+	at, err2 := strconv.ParseFloat(r.URL.Query().Get("at"), 32)
+	addr := r.URL.Query().Get("addr")
+	if err != nil || err2 != nil || addr == "" {
+		http.Error(w, "Malformed request to `/split-band` endpoint!", http.StatusBadRequest)
+		return
+	}
+
+	err = s.bands.Split(ctx, i, float32(at), addr)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Calculate the duration in microseconds
+	duration := int64(time.Since(start).Microseconds())
+	inStr, outStr := r.URL.RawQuery, "{}"
+
+	logMsg("proxy.splitBandHandler", inStr, outStr, "<nil>", duration)
+
+	json.NewEncoder(w).Encode(s.bands.Bands(ctx))
+}
+
+// ?band=<index>&at=<score>, moves the boundary below band
+func (s *Proxy) moveBandHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	ctx := r.Context()
+
+	i, err := strconv.Atoi(r.URL.Query().Get("band"))
+	at, err2 := strconv.ParseFloat(r.URL.Query().Get("at"), 32)
+	if err != nil || err2 != nil {
+		http.Error(w, "Malformed request to `/move-band` endpoint!", http.StatusBadRequest)
+		return
+	}
+
+	err = s.bands.MoveBoundary(ctx, i, float32(at))
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Calculate the duration in microseconds
+	duration := int64(time.Since(start).Microseconds())
+	inStr, outStr := r.URL.RawQuery, "{}"
+
+	logMsg("proxy.moveBandHandler", inStr, outStr, "<nil>", duration)
+
+	json.NewEncoder(w).Encode(s.bands.Bands(ctx))
+}
+
+// a missing key is the caller's problem, not the filter's
+func keyedStatus(err error) int {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func queryStatus(err error) int {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ?min=<score>&max=<score>&limit=<n> of /range, the scores default to
+// everything and the limit to none
+func parseRangeParams(r *http.Request) (*filter.GetRangeRequest, error) {
+	req := &filter.GetRangeRequest{
+		MinScore: -math.MaxFloat32,
+		MaxScore: math.MaxFloat32,
+	}
+	for param, score := range map[string]*float32{"min": &req.MinScore, "max": &req.MaxScore} {
+		if scoreStr := r.URL.Query().Get(param); scoreStr != "" {
+			f, err := strconv.ParseFloat(scoreStr, 32)
+			if err != nil {
+				return nil, err
+			}
+			*score = float32(f)
+		}
+	}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			return nil, err
+		}
+		req.Limit = int32(limit)
+	}
+	return req, nil
+}
+
+// optional ?wait=<duration>&min_score=<score> of the remove endpoints
+func parseRemoveParams(r *http.Request) (int64, *float32, error) {
+	var waitMs int64
+	var minScore *float32
+
+	if waitStr := r.URL.Query().Get("wait"); waitStr != "" {
+		wait, err := time.ParseDuration(waitStr)
+		if err != nil {
+			return 0, nil, err
+		}
+		waitMs = wait.Milliseconds()
+	}
+	if scoreStr := r.URL.Query().Get("min_score"); scoreStr != "" {
+		score, err := strconv.ParseFloat(scoreStr, 32)
+		if err != nil {
+			return 0, nil, err
+		}
+		minScore = new(float32)
+		*minScore = float32(score)
+	}
+
+	return waitMs, minScore, nil
+}
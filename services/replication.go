@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// how long a backup waits before reconnecting to its primary
+const followRetry = time.Second
+
+func (s *Filter) replicator() (*apps.ReplicatedCDSFApp, error) {
+	r, ok := apps.As[*apps.ReplicatedCDSFApp](s.app)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "Filter is not replicated")
+	}
+	return r, nil
+}
+
+// Stream the whole filter and then every mutation to a backup, until the
+// backup hangs up or falls too far behind
+func (s *Filter) Replicate(req *filter.ReplicateRequest, stream filter.FilterService_ReplicateServer) error {
+	r, err := s.replicator()
+	if err != nil {
+		return err
+	}
+	records, f, err := r.Follow()
+	if err != nil {
+		return err
+	}
+	defer r.Unfollow(f)
+
+	for _, rec := range records {
+		if err := stream.Send(rec); err != nil {
+			return err
+		}
+	}
+
+	ctx := stream.Context()
+	for {
+		select {
+		case rec := <-f.Records:
+			if err := stream.Send(rec); err != nil {
+				return err
+			}
+		case <-f.Lagged:
+			return status.Errorf(codes.ResourceExhausted, "Backup fell behind, reconnect to resync")
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Stop following the primary and take mutations from now on
+func (s *Filter) Promote(ctx context.Context, req *filter.PromoteRequest) (*filter.PromoteResponse, error) {
+	resp := &filter.PromoteResponse{Success: true}
+	r, err := s.replicator()
+	if err != nil {
+		resp.Success = false
+		return resp, err
+	}
+
+	s.followLk.Lock()
+	if s.stopFollowing != nil {
+		s.stopFollowing()
+		<-s.following
+		s.stopFollowing = nil
+	}
+	s.followLk.Unlock()
+
+	err = r.Promote()
+	log.Printf("filter <%s> promoted to primary", s.name)
+	return resp, err
+}
+
+// Keep this backup in sync with the primary at addr until ctx is done or the
+// backup gets promoted, reconnecting whenever the stream breaks
+func (s *Filter) FollowPrimary(ctx context.Context, addr string) error {
+	r, err := s.replicator()
+	if err != nil {
+		return err
+	}
+	if r.IsPrimary() {
+		return status.Errorf(codes.FailedPrecondition, "Filter is the primary")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	s.followLk.Lock()
+	s.stopFollowing, s.following = cancel, done
+	s.followLk.Unlock()
+
+	conn := dial(addr)
+	client := filter.NewFilterServiceClient(conn)
+	go func() {
+		defer close(done)
+		defer conn.Close()
+		for {
+			err := s.follow(ctx, client, r)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("filter <%s> lost the primary at %s: %v", s.name, addr, err)
+			select {
+			case <-time.After(followRetry):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// apply the primary's stream until it breaks
+func (s *Filter) follow(ctx context.Context, client filter.FilterServiceClient, r *apps.ReplicatedCDSFApp) error {
+	stream, err := client.Replicate(ctx, &filter.ReplicateRequest{})
+	if err != nil {
+		return err
+	}
+	for {
+		rec, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if err := r.Apply(rec); err != nil {
+			return err
+		}
+	}
+}
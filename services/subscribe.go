@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+)
+
+// Token bucket for a subscriber: it can bank up to window items and earns
+// rate items per second. A zero rate means no pacing, a subscriber's window
+// or the stream's flow control is the only limit then.
+type credit struct {
+	rate   float64
+	window float64
+	tokens float64
+	last   time.Time
+}
+
+func newCredit(rate float64, window int32) *credit {
+	if window < 1 {
+		window = 1
+	}
+	return &credit{
+		rate:   rate,
+		window: float64(window),
+		tokens: float64(window),
+		last:   time.Now(),
+	}
+}
+
+// block until there is credit for one more item or ctx is done
+func (c *credit) take(ctx context.Context) error {
+	if c.rate <= 0 {
+		return ctx.Err()
+	}
+	for {
+		wait := c.tryTake()
+		if wait == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// take credit for one item if there is some, otherwise say how long until
+// there is
+func (c *credit) tryTake() time.Duration {
+	if c.rate <= 0 {
+		return 0
+	}
+	now := time.Now()
+	c.tokens += now.Sub(c.last).Seconds() * c.rate
+	if c.tokens > c.window {
+		c.tokens = c.window
+	}
+	c.last = now
+	if c.tokens >= 1 {
+		c.tokens--
+		return 0
+	}
+	return time.Duration((1 - c.tokens) / c.rate * float64(time.Second))
+}
+
+// hand back credit that wasn't used after all
+func (c *credit) refund() {
+	if c.rate > 0 {
+		c.tokens++
+	}
+}
+
+// Items a subscriber without a rate may have outstanding: one is out from the
+// time it's taken until the subscriber credits it back
+type window chan struct{}
+
+// block until one more item may go out or ctx is done
+func (w window) take(ctx context.Context) error {
+	select {
+	case w <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// let n more out, credit for more than is out is dropped
+func (w window) give(n int32) {
+	for ; n > 0; n-- {
+		select {
+		case <-w:
+		default:
+			return
+		}
+	}
+}
+
+// Push the best item to the subscriber whenever it has credit, until it goes
+// away. With a rate the credit comes with time, without one and with a window
+// it comes back from the subscriber in the requests after the first, so at
+// most window items are ever on their way or waiting to be handled.
+func (s *Filter) Subscribe(stream filter.FilterService_SubscribeServer) error {
+	ctx := stream.Context()
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	take := newCredit(req.GetRate(), req.GetWindow()).take
+	if req.GetRate() <= 0 && req.GetWindow() > 0 {
+		w := make(window, req.GetWindow())
+		take = w.take
+		go func() {
+			for {
+				req, err := stream.Recv()
+				if err != nil {
+					return
+				}
+				w.give(req.GetCredit())
+			}
+		}()
+	}
+
+	for {
+		if take(ctx) != nil {
+			// the subscriber is gone, that's the normal way out
+			return nil
+		}
+		item, err := s.waitRemove(ctx, true, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if err := stream.Send(&filter.SubscribeResponse{Item: item}); err != nil {
+			// never made it out, don't lose it
+			s.putBack(item)
+			return err
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+)
+
+// how often WatchThreshold looks for removals when the watcher doesn't say
+const defaultWatchInterval = 100 * time.Millisecond
+
+// the score an item has to beat to get in, nil while the filter has room
+func (s *Filter) threshold() *float32 {
+	if !s.app.IsFull() {
+		return nil
+	}
+	min, err := s.app.GetMin()
+	if err != nil {
+		return nil
+	}
+	threshold := apps.EffectiveScore(min)
+	return &threshold
+}
+
+// Push the admission threshold whenever it changes, starting with the
+// current one. Inserts are seen right away, removals within interval_ms.
+func (s *Filter) WatchThreshold(req *filter.WatchThresholdRequest, stream filter.FilterService_WatchThresholdServer) error {
+	interval := time.Duration(req.GetIntervalMs()) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx := stream.Context()
+	var last *float32
+	first := true
+	for {
+		arrived := s.arrivals.wait()
+		threshold := s.threshold()
+		if first || !sameThreshold(threshold, last) {
+			if err := stream.Send(&filter.ThresholdUpdate{Threshold: threshold}); err != nil {
+				return err
+			}
+			last, first = threshold, false
+		}
+
+		select {
+		case <-arrived:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func sameThreshold(a, b *float32) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
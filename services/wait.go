@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Wakes up everyone waiting for items once something got inserted. Waiters
+// grab the channel before they look at the filter, so an insert that lands in
+// between still wakes them.
+type arrivals struct {
+	ch atomic.Pointer[chan struct{}]
+}
+
+// channel that gets closed by the next notify
+func (a *arrivals) wait() <-chan struct{} {
+	for {
+		if ch := a.ch.Load(); ch != nil {
+			return *ch
+		}
+		ch := make(chan struct{})
+		if a.ch.CompareAndSwap(nil, &ch) {
+			return ch
+		}
+	}
+}
+
+func (a *arrivals) notify() {
+	// cheap when nobody waits, this is on every insert
+	if a.ch.Load() == nil {
+		return
+	}
+	if ch := a.ch.Swap(nil); ch != nil {
+		close(*ch)
+	}
+}
+
+// Remove from one end, parking the caller for up to waitMs until there is an
+// item scoring at least minScore (any item if nil). The caller's deadline and
+// cancellation cut the wait short.
+func (s *Filter) removeItem(ctx context.Context, max bool, waitMs int64, minScore *float32) (*filter.FilterItem, error) {
+	if waitMs <= 0 {
+		return s.tryRemove(max, minScore)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(waitMs)*time.Millisecond)
+	defer cancel()
+	item, err := s.waitRemove(waitCtx, max, minScore)
+	if err == nil {
+		return item, nil
+	}
+	if ctx.Err() != nil {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	// we ran out of wait, one last go for the usual error
+	return s.tryRemove(max, minScore)
+}
+
+// keep trying until there is a qualifying item or ctx is done
+func (s *Filter) waitRemove(ctx context.Context, max bool, minScore *float32) (*filter.FilterItem, error) {
+	for {
+		arrived := s.arrivals.wait()
+		item, err := s.tryRemove(max, minScore)
+		if err == nil {
+			return item, nil
+		}
+		if status.Code(err) == codes.FailedPrecondition {
+			// a backup, no point waiting
+			return nil, err
+		}
+		select {
+		case <-arrived:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *Filter) tryRemove(max bool, minScore *float32) (*filter.FilterItem, error) {
+	get, remove := s.app.GetMin, s.app.RemoveMin
+	if max {
+		get, remove = s.app.GetMax, s.app.RemoveMax
+	}
+
+	if minScore != nil {
+		// don't take anything out unless it qualifies
+		item, err := get()
+		if err != nil {
+			return nil, err
+		}
+		if apps.EffectiveScore(item) < *minScore {
+			return nil, status.Errorf(codes.Internal,
+				"Filter has no item scoring at least %v", *minScore)
+		}
+	}
+
+	item, err := remove()
+	if err != nil {
+		return nil, err
+	}
+	if minScore != nil && apps.EffectiveScore(item) < *minScore {
+		// someone beat us to the item we saw, put this one back
+		s.putBack(item)
+		return nil, status.Errorf(codes.Internal,
+			"Filter has no item scoring at least %v", *minScore)
+	}
+	return item, nil
+}
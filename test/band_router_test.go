@@ -0,0 +1,170 @@
+package test
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/Jfroel/cdsf-microservice/services"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// n -heap filters on localhost, returns their clients and addresses
+func startFilters(t *testing.T, n int, cap int) ([]filter.FilterServiceClient, []string) {
+	var clients []filter.FilterServiceClient
+	var addrs []string
+	for i := 0; i < n; i++ {
+		client, addr := serveFilter(t, services.NewFilter("band", 0, apps.NewFilterApp(filterConfig(cap))))
+		clients = append(clients, client)
+		addrs = append(addrs, addr)
+	}
+	return clients, addrs
+}
+
+// every item of the filter is within [lo, hi)
+func requireInBand(t *testing.T, client filter.FilterServiceClient, lo, hi float32) {
+	ctx := context.Background()
+	if max, err := client.GetMaxItem(ctx, &filter.GetMaxItemRequest{}); err == nil {
+		require.Less(t, max.GetItem().GetScore(), hi)
+	}
+	if min, err := client.GetMinItem(ctx, &filter.GetMinItemRequest{}); err == nil {
+		require.GreaterOrEqual(t, min.GetItem().GetScore(), lo)
+	}
+}
+
+func TestBandRouter(t *testing.T) {
+	ctx := context.Background()
+	filters, addrs := startFilters(t, 4, 1000)
+
+	// the router moves what a filter holds outside its band on startup
+	_, err := filters[2].InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.95}})
+	require.NoError(t, err)
+	router, err := services.NewBandRouter(ctx, addrs[0]+"@0.7,"+addrs[1]+"@0.3,"+addrs[2])
+	require.NoError(t, err)
+
+	scores := []float32{0.95}
+	for i := 0; i < 200; i++ {
+		score := rand.Float32()
+		scores = append(scores, score)
+		_, err := router.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		require.NoError(t, err)
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i] > scores[j] })
+	requireInBand(t, filters[0], 0.7, 2)
+	requireInBand(t, filters[1], 0.3, 0.7)
+	requireInBand(t, filters[2], -1, 0.3)
+
+	// filters turn down what isn't theirs
+	_, err = filters[0].InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.1}})
+	require.Equal(t, codes.OutOfRange, status.Code(err))
+	band, err := filters[1].GetBand(ctx, &filter.GetBandRequest{})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.3), band.GetLo())
+	require.Equal(t, float32(0.7), band.GetHi())
+
+	// split the top band onto the spare filter, then widen the band below it
+	require.NoError(t, router.Split(ctx, 0, 0.85, addrs[3]))
+	requireInBand(t, filters[3], 0.85, 2)
+	requireInBand(t, filters[0], 0.7, 0.85)
+	require.NoError(t, router.MoveBoundary(ctx, 1, 0.5))
+	requireInBand(t, filters[0], 0.5, 0.85)
+	requireInBand(t, filters[1], 0.3, 0.5)
+
+	bands := router.Bands(ctx)
+	require.Len(t, bands, 4)
+	require.Nil(t, bands[0].Hi)
+	require.Equal(t, float32(0.5), *bands[1].Lo)
+	require.Nil(t, bands[3].Lo)
+	size, err := router.GetSize(ctx, &filter.GetSizeRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int32(len(scores)), size.GetSize())
+
+	// nothing got lost or reordered along the way
+	min, err := router.RemoveMinItem(ctx, &filter.RemoveMinItemRequest{})
+	require.NoError(t, err)
+	require.Equal(t, scores[len(scores)-1], min.GetItem().GetScore())
+	for _, score := range scores[:len(scores)-1] {
+		resp, err := router.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{})
+		require.NoError(t, err)
+		require.Equal(t, score, resp.GetItem().GetScore())
+	}
+	_, err = router.GetMaxItem(ctx, &filter.GetMaxItemRequest{})
+	require.Error(t, err)
+}
+
+func TestBandRouterRemoveWait(t *testing.T) {
+	ctx := context.Background()
+	_, addrs := startFilters(t, 2, 100)
+	router, err := services.NewBandRouter(ctx, addrs[0]+"@0.5,"+addrs[1])
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		router.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.2}})
+	}()
+	resp, err := router.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{WaitMs: 5000})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.2), resp.GetItem().GetScore())
+
+	// min_score stops at the top band, the lower ones can't do better
+	_, err = router.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.6}})
+	require.NoError(t, err)
+	minScore := float32(0.8)
+	_, err = router.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{MinScore: &minScore})
+	require.Error(t, err)
+
+	lease, err := router.LeaseMaxItem(ctx, &filter.LeaseMaxItemRequest{})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.6), lease.GetItem().GetScore())
+	_, err = router.Ack(ctx, &filter.AckRequest{LeaseId: lease.GetLeaseId()})
+	require.NoError(t, err)
+
+	_, err = services.NewBandRouter(ctx, addrs[1]+"@0.5,"+addrs[0]+"@0.7")
+	require.Error(t, err)
+}
+
+func TestBandRouterRollback(t *testing.T) {
+	ctx := context.Background()
+	serve := func(cfg apps.Config) (filter.FilterServiceClient, string) {
+		return serveFilter(t, services.NewFilter("band", 0, apps.NewFilterApp(cfg)))
+	}
+	// filters with score decay take no negative scores
+	_, upperAddr := serve(decayConfig(10, time.Hour))
+	lower, lowerAddr := serve(filterConfig(10))
+	spare, spareAddr := serve(decayConfig(10, time.Hour))
+	router, err := services.NewBandRouter(ctx, upperAddr+"@0,"+lowerAddr)
+	require.NoError(t, err)
+	for _, score := range []float32{0.6, -0.2, -0.8} {
+		_, err := router.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		require.NoError(t, err)
+	}
+
+	// -0.2 can't move to a filter with score decay, so the moves fail and
+	// are undone
+	err = router.Split(ctx, 1, -0.5, spareAddr)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	err = router.MoveBoundary(ctx, 0, -0.5)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	bands := router.Bands(ctx)
+	require.Len(t, bands, 2)
+	require.Equal(t, float32(0), *bands[0].Lo)
+	require.Equal(t, int32(2), sizeOf(t, lower))
+	band, err := lower.GetBand(ctx, &filter.GetBandRequest{})
+	require.NoError(t, err)
+	require.Nil(t, band.Lo)
+	require.Equal(t, float32(0), band.GetHi())
+	band, err = spare.GetBand(ctx, &filter.GetBandRequest{})
+	require.NoError(t, err)
+	require.Nil(t, band.Lo)
+	require.Nil(t, band.Hi)
+	size, err := router.GetSize(ctx, &filter.GetSizeRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int32(3), size.GetSize())
+}
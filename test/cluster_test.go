@@ -0,0 +1,133 @@
+package test
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/Jfroel/cdsf-microservice/services"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// n -heap filters on localhost and a cluster over them, plus the shards
+// themselves
+func startCluster(t *testing.T, n int, cap int) (*services.Cluster, []filter.FilterServiceClient) {
+	var addrs []string
+	var shards []filter.FilterServiceClient
+	for i := 0; i < n; i++ {
+		client, addr := serveFilter(t, services.NewFilter("shard", 0, apps.NewFilterApp(filterConfig(cap))))
+		addrs = append(addrs, addr)
+		shards = append(shards, client)
+	}
+	return services.NewCluster(addrs), shards
+}
+
+func TestClusterOrder(t *testing.T) {
+	cluster, shards := startCluster(t, 3, 100)
+	ctx := context.Background()
+
+	scores := make([]float32, 60)
+	for i := range scores {
+		scores[i] = rand.Float32()
+		_, err := cluster.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: scores[i], Data: []byte{1, 2, 3, 4}}})
+		require.NoError(t, err)
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i] > scores[j] })
+
+	// every shard got some of them
+	for _, shard := range shards {
+		resp, err := shard.GetSize(ctx, &filter.GetSizeRequest{})
+		require.NoError(t, err)
+		require.NotZero(t, resp.GetSize())
+	}
+	size, err := cluster.GetSize(ctx, &filter.GetSizeRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int32(60), size.GetSize())
+
+	// but it still reads like one filter
+	max, err := cluster.GetMaxItem(ctx, &filter.GetMaxItemRequest{})
+	require.NoError(t, err)
+	require.Equal(t, scores[0], max.GetItem().GetScore())
+	min, err := cluster.GetMinItem(ctx, &filter.GetMinItemRequest{})
+	require.NoError(t, err)
+	require.Equal(t, scores[59], min.GetItem().GetScore())
+
+	for i := 0; i < 30; i++ {
+		resp, err := cluster.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{})
+		require.NoError(t, err)
+		require.Equal(t, scores[i], resp.GetItem().GetScore())
+	}
+	resp, err := cluster.RemoveMinItem(ctx, &filter.RemoveMinItemRequest{})
+	require.NoError(t, err)
+	require.Equal(t, scores[59], resp.GetItem().GetScore())
+
+	// leases remember their shard
+	lease, err := cluster.LeaseMaxItem(ctx, &filter.LeaseMaxItemRequest{})
+	require.NoError(t, err)
+	require.Equal(t, scores[30], lease.GetItem().GetScore())
+	_, err = cluster.Nack(ctx, &filter.NackRequest{LeaseId: lease.GetLeaseId()})
+	require.NoError(t, err)
+	lease, err = cluster.LeaseMaxItem(ctx, &filter.LeaseMaxItemRequest{})
+	require.NoError(t, err)
+	require.Equal(t, scores[30], lease.GetItem().GetScore())
+	_, err = cluster.Ack(ctx, &filter.AckRequest{LeaseId: lease.GetLeaseId()})
+	require.NoError(t, err)
+	_, err = cluster.Ack(ctx, &filter.AckRequest{LeaseId: "nope"})
+	require.Error(t, err)
+
+	_, err = cluster.Clear(ctx, &filter.ClearRequest{})
+	require.NoError(t, err)
+	size, err = cluster.GetSize(ctx, &filter.GetSizeRequest{})
+	require.NoError(t, err)
+	require.Zero(t, size.GetSize())
+	_, err = cluster.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{})
+	require.Error(t, err)
+}
+
+func TestClusterRemoveWait(t *testing.T) {
+	cluster, shards := startCluster(t, 3, 100)
+	ctx := context.Background()
+
+	// the item shows up on whichever shard, the wait covers all of them
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		shards[2].InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.6}})
+	}()
+	resp, err := cluster.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{WaitMs: 5000})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.6), resp.GetItem().GetScore())
+
+	// nobody else got anything out of it
+	size, err := cluster.GetSize(ctx, &filter.GetSizeRequest{})
+	require.NoError(t, err)
+	require.Zero(t, size.GetSize())
+
+	start := time.Now()
+	_, err = cluster.RemoveMinItem(ctx, &filter.RemoveMinItemRequest{WaitMs: 100})
+	require.Error(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+// A shard that fails for anything but being empty fails the read, it might
+// hold the best item
+func TestClusterShardDown(t *testing.T) {
+	client, addr := serveFilter(t, services.NewFilter("shard", 0, apps.NewFilterApp(filterConfig(10))))
+	cluster := services.NewCluster([]string{addr, "127.0.0.1:1"})
+	ctx := context.Background()
+	_, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.5}})
+	require.NoError(t, err)
+
+	_, err = cluster.GetMaxItem(ctx, &filter.GetMaxItemRequest{})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	_, err = cluster.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	size, err := client.GetSize(ctx, &filter.GetSizeRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), size.GetSize())
+}
@@ -0,0 +1,167 @@
+package test
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func decayConfig(cap int, halfLife time.Duration) apps.Config {
+	cfg := filterConfig(cap)
+	cfg.HalfLife = halfLife
+	return cfg
+}
+
+func TestDecayOrder(t *testing.T) {
+	app := apps.NewFilterApp(decayConfig(10, 50*time.Millisecond))
+
+	insertItem(t, app, &filter.FilterItem{Score: 0.8, Data: []byte("old")})
+	time.Sleep(100 * time.Millisecond)
+	insertItem(t, app, &filter.FilterItem{Score: 0.5, Data: []byte("new")})
+
+	// two half-lives later the old item is down to about 0.2
+	max, err := app.GetMax()
+	require.NoError(t, err)
+	require.Equal(t, []byte("new"), max.GetData())
+	require.Equal(t, float32(0.5), max.GetScore())
+	require.InDelta(t, 0.5, max.GetEffectiveScore(), 0.05)
+
+	min, err := app.GetMin()
+	require.NoError(t, err)
+	require.Equal(t, []byte("old"), min.GetData())
+	require.Equal(t, float32(0.8), min.GetScore())
+	require.InDelta(t, 0.2, min.GetEffectiveScore(), 0.05)
+	require.NotZero(t, min.GetInsertedUnixMs())
+
+	_, err = app.Insert(&filter.FilterItem{Score: -0.1})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestDecayEviction(t *testing.T) {
+	client := startFilter(t, apps.NewFilterApp(decayConfig(2, 50*time.Millisecond)))
+	ctx := context.Background()
+
+	for _, score := range []float32{0.9, 0.8} {
+		_, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		require.NoError(t, err)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	// the decayed 0.8 goes, and what's left sets a decayed threshold
+	resp, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.5}})
+	require.NoError(t, err)
+	require.Equal(t, filter.InsertOutcome_EVICTED, resp.GetOutcome())
+	require.Equal(t, float32(0.8), resp.GetEvicted().GetScore())
+	require.Less(t, resp.GetEvicted().GetEffectiveScore(), float32(0.2))
+	require.Less(t, resp.GetThreshold(), float32(0.2))
+
+	// min_score goes by the effective score too
+	_, err = client.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{MinScore: proto.Float32(0.6)})
+	require.Error(t, err)
+	removed, err := client.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{MinScore: proto.Float32(0.4)})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.5), removed.GetItem().GetScore())
+}
+
+func TestDecayRebase(t *testing.T) {
+	// with a 1ms half-life the epoch moves every 64ms
+	app := apps.NewFilterApp(decayConfig(100, time.Millisecond))
+
+	t0 := time.Now().UnixMilli()
+	var old, young []float32
+	for i := 0; i < 50; i++ {
+		score := rand.Float32()
+		old = append(old, score)
+		insertItem(t, app, &filter.FilterItem{Score: score, InsertedUnixMs: t0})
+	}
+	time.Sleep(100 * time.Millisecond)
+	for i := 0; i < 50; i++ {
+		score := rand.Float32()
+		young = append(young, score)
+		insertItem(t, app, &filter.FilterItem{Score: score, InsertedUnixMs: t0 + 100})
+	}
+
+	// every young item beats every old one, the scores come back unchanged
+	sort.Slice(old, func(i, j int) bool { return old[i] > old[j] })
+	sort.Slice(young, func(i, j int) bool { return young[i] > young[j] })
+	require.Equal(t, append(young, old...), drainScores(app))
+}
+
+func TestDecayRebaseConcurrent(t *testing.T) {
+	// the epoch moves every 64ms under inserts from all sides
+	app := apps.NewFilterApp(decayConfig(2000, time.Millisecond))
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				app.Insert(&filter.FilterItem{Score: 0.5})
+				time.Sleep(100 * time.Microsecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// with one score the newer item always ranks higher, none keyed off an
+	// old epoch jumps the queue
+	last := int64(math.MaxInt64)
+	for app.GetSize() > 0 {
+		item, err := app.RemoveMax()
+		require.NoError(t, err)
+		require.LessOrEqual(t, item.GetInsertedUnixMs(), last)
+		last = item.GetInsertedUnixMs()
+	}
+}
+
+func TestDecayDurable(t *testing.T) {
+	cfg := decayConfig(10, time.Hour)
+	cfg.DataDir, cfg.Fsync = t.TempDir(), "always"
+
+	app := apps.NewFilterApp(cfg)
+	arrivals := make(map[float32]int64)
+	for _, score := range []float32{0.2, 0.4, 0.6} {
+		item := &filter.FilterItem{Score: score}
+		insertItem(t, app, item)
+		arrivals[score] = item.GetInsertedUnixMs()
+		time.Sleep(5 * time.Millisecond)
+	}
+	durable, _ := apps.As[*apps.DurableCDSFApp](app)
+	require.NoError(t, durable.Close())
+
+	// the items are as old as they were, not as old as the restart
+	recovered := apps.NewFilterApp(cfg)
+	for i := 0; i < 3; i++ {
+		item, err := recovered.RemoveMax()
+		require.NoError(t, err)
+		require.Equal(t, arrivals[item.GetScore()], item.GetInsertedUnixMs())
+	}
+}
+
+func TestDecayLease(t *testing.T) {
+	app := apps.NewFilterApp(decayConfig(2, time.Hour)).(apps.LeasingFilter)
+	insertItem(t, app, &filter.FilterItem{Score: 0.9})
+	insertItem(t, app, &filter.FilterItem{Score: 0.5})
+	_, _, err := app.LeaseMax(time.Minute)
+	require.NoError(t, err)
+
+	// the filter hands out copies, a worse item still sees it got trimmed
+	// itself
+	res := insertItem(t, app, &filter.FilterItem{Score: 0.1})
+	require.Equal(t, apps.InsertRejected, res.Outcome)
+	res = insertItem(t, app, &filter.FilterItem{Score: 0.7})
+	require.Equal(t, apps.InsertEvicted, res.Outcome)
+	require.Equal(t, float32(0.5), res.Evicted.GetScore())
+}
@@ -0,0 +1,209 @@
+package test
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// a durable -heap app in dir, snapshots are only taken when the test asks
+func openDurable(t *testing.T, dir string, cap int, fsync string) *apps.DurableCDSFApp {
+	cfg := filterConfig(cap)
+	cfg.DataDir, cfg.Fsync = dir, fsync
+	app, err := apps.NewDurableCDSFApp(apps.NewCDSFApp(cfg), cfg)
+	require.NoError(t, err)
+	return app
+}
+
+// drain app best first, so two filters can be compared
+func drainScores(app apps.ConcurrentDataStreamFilter) []float32 {
+	var scores []float32
+	for {
+		item, err := app.RemoveMax()
+		if err != nil {
+			return scores
+		}
+		scores = append(scores, item.GetScore())
+	}
+}
+
+func TestDurableRecovery(t *testing.T) {
+	dir := t.TempDir()
+	app := openDurable(t, dir, 3, "always")
+
+	for _, score := range []float32{0.2, 0.5, 0.7, 0.9} {
+		insertItem(t, app, &filter.FilterItem{Score: score, Data: []byte("item")})
+	}
+	// 0.2 got evicted, and 0.1 only fits once 0.9 is gone
+	item, err := app.RemoveMax()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.9), item.GetScore())
+	insertItem(t, app, &filter.FilterItem{Score: 0.1})
+	require.NoError(t, app.InsertBatch([]*filter.FilterItem{{Score: 0.3}, {Score: 0.8}}))
+	_, err = app.RemoveMin()
+	require.NoError(t, err)
+	require.NoError(t, app.Close())
+
+	app = openDurable(t, dir, 3, "always")
+	defer app.Close()
+	max, err := app.GetMax()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.8), max.GetScore())
+	min, err := app.GetMin()
+	require.NoError(t, err)
+	require.Equal(t, []byte("item"), min.GetData())
+	require.Equal(t, []float32{0.8, 0.7}, drainScores(app))
+}
+
+func TestDurableSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	app := openDurable(t, dir, 50, "never")
+	expected := apps.NewCDSFApp(filterConfig(50))
+
+	mutate := func() {
+		for i := 0; i < 200; i++ {
+			item := &filter.FilterItem{Score: rand.Float32()}
+			switch rand.Intn(4) {
+			case 0:
+				app.RemoveMax()
+				expected.RemoveMax()
+			case 1:
+				app.RemoveMin()
+				expected.RemoveMin()
+			default:
+				insertItem(t, app, item)
+				insertItem(t, expected, item)
+			}
+		}
+	}
+
+	mutate()
+	require.NoError(t, app.Snapshot())
+	mutate()
+	require.NoError(t, app.Snapshot())
+	mutate()
+	require.NoError(t, app.Close())
+
+	// only the newest snapshot and the segments after it are kept
+	snapshots, err := filepath.Glob(filepath.Join(dir, "snapshot-*"))
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	segments, err := filepath.Glob(filepath.Join(dir, "wal-*"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+
+	app = openDurable(t, dir, 50, "never")
+	defer app.Close()
+	require.Equal(t, drainScores(expected), drainScores(app))
+}
+
+// Mutations run at once and may be logged out of order, what the log comes
+// back with is still what the filter had
+func TestDurableConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	app := openDurable(t, dir, 50, "always")
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 300; i++ {
+				switch rand.Intn(4) {
+				case 0:
+					app.RemoveMax()
+				case 1:
+					app.RemoveMin()
+				default:
+					// few enough scores that items alike go in and out
+					app.Insert(&filter.FilterItem{Score: float32(rand.Intn(20)) / 20})
+				}
+			}
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, app.Snapshot())
+	}
+	wg.Wait()
+
+	// drain a copy, the app has to close as it is
+	items, err := app.Unwrap().(apps.QueryingFilter).TopK(0)
+	require.NoError(t, err)
+	expected := apps.NewCDSFApp(filterConfig(50))
+	require.NoError(t, expected.InsertBatch(items))
+	require.NoError(t, app.Close())
+
+	app = openDurable(t, dir, 50, "always")
+	defer app.Close()
+	require.Equal(t, drainScores(expected), drainScores(app))
+}
+
+func TestDurableTornTail(t *testing.T) {
+	dir := t.TempDir()
+	app := openDurable(t, dir, 10, "always")
+	insertItem(t, app, &filter.FilterItem{Score: 0.4})
+	insertItem(t, app, &filter.FilterItem{Score: 0.6})
+	require.NoError(t, app.Close())
+
+	// half a record, as if we died in the middle of a write
+	segments, err := filepath.Glob(filepath.Join(dir, "wal-*"))
+	require.NoError(t, err)
+	f, err := os.OpenFile(segments[len(segments)-1], os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{1, 42, 0})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	app = openDurable(t, dir, 10, "always")
+	require.Equal(t, 2, app.GetSize())
+	insertItem(t, app, &filter.FilterItem{Score: 0.5})
+	require.NoError(t, app.Close())
+
+	// the torn record doesn't hide what was logged after it
+	app = openDurable(t, dir, 10, "always")
+	defer app.Close()
+	require.Equal(t, []float32{0.6, 0.5, 0.4}, drainScores(app))
+}
+
+func TestDurableLease(t *testing.T) {
+	dir := t.TempDir()
+	open := func() (*apps.DurableCDSFApp, *apps.LeasingCDSFApp) {
+		durable := openDurable(t, dir, 10, "always")
+		return durable, apps.NewLeasingCDSFApp(durable, 10)
+	}
+
+	durable, app := open()
+	for _, score := range []float32{0.3, 0.6, 0.9} {
+		insertItem(t, app, &filter.FilterItem{Score: score})
+	}
+	_, acked, err := app.LeaseMax(time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, app.Ack(acked))
+	_, nacked, err := app.LeaseMax(time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, app.Nack(nacked))
+	item, _, err := app.LeaseMax(time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, float32(0.6), item.GetScore())
+	require.NoError(t, durable.Close())
+
+	// the lease that was still out ended with the filter, its item is back
+	durable, app = open()
+	require.Equal(t, 2, app.GetSize())
+	_, id, err := app.LeaseMax(time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, durable.Close())
+
+	// the lease IDs start over, which doesn't mix up the two leases
+	durable, _ = open()
+	defer durable.Close()
+	require.Equal(t, "1", id)
+	require.Equal(t, []float32{0.6, 0.3}, drainScores(durable))
+}
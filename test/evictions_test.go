@@ -0,0 +1,113 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/Jfroel/cdsf-microservice/services"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchEvictions(t *testing.T) {
+	app := apps.NewFilterApp(filterConfig(2))
+	client := startFilter(t, app)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.WatchEvictions(ctx, &filter.WatchEvictionsRequest{})
+	require.NoError(t, err)
+	// evictions only go out once the headers are back
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	for _, score := range []float32{0.2, 0.5, 0.7} {
+		_, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score, Data: []byte("item")}})
+		require.NoError(t, err)
+	}
+	ev, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.2), ev.GetItem().GetScore())
+	require.Equal(t, []byte("item"), ev.GetItem().GetData())
+	require.Equal(t, filter.EvictReason_CAPACITY, ev.GetReason())
+	require.InDelta(t, time.Now().UnixMilli(), ev.GetTimeUnixMs(), 5000)
+
+	// with the max leased out the filter is still full, so the next insert
+	// gets the bottom trimmed
+	_, err = client.LeaseMaxItem(ctx, &filter.LeaseMaxItemRequest{})
+	require.NoError(t, err)
+	_, err = client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.9}})
+	require.NoError(t, err)
+	ev, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.5), ev.GetItem().GetScore())
+	require.Equal(t, filter.EvictReason_LEASE, ev.GetReason())
+}
+
+func TestEvictionLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "evictions.jsonl")
+	log, err := services.OpenEvictionLog(path)
+	require.NoError(t, err)
+	f := services.NewFilter("test", 0, apps.NewFilterApp(filterConfig(1)))
+	f.AddEvictionSink(log)
+	client, _ := serveFilter(t, f)
+
+	ctx := context.Background()
+	for _, score := range []float32{0.1, 0.2, 0.3} {
+		_, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score, Data: []byte{7}}})
+		require.NoError(t, err)
+	}
+	require.NoError(t, log.Close())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var scores []float32
+	lines := bufio.NewScanner(file)
+	for lines.Scan() {
+		var line struct {
+			Time   time.Time `json:"time"`
+			Reason string    `json:"reason"`
+			Score  float32   `json:"score"`
+			Data   []byte    `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(lines.Bytes(), &line))
+		require.Equal(t, "capacity", line.Reason)
+		require.Equal(t, []byte{7}, line.Data)
+		require.False(t, line.Time.IsZero())
+		scores = append(scores, line.Score)
+	}
+	require.Equal(t, []float32{0.1, 0.2}, scores)
+}
+
+func TestEvictionForwarder(t *testing.T) {
+	deadLetters, deadLettersAddr := serveFilter(t, services.NewFilter("dead-letters", 0, apps.NewFilterApp(filterConfig(100))))
+	fwd := services.NewEvictionForwarder(deadLettersAddr)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go fwd.Run(ctx)
+
+	f := services.NewFilter("test", 0, apps.NewFilterApp(filterConfig(5)))
+	f.AddEvictionSink(fwd)
+	client, _ := serveFilter(t, f)
+
+	items := make([]*filter.FilterItem, 20)
+	for i := range items {
+		items[i] = &filter.FilterItem{Score: float32(i) / 20}
+	}
+	_, err := client.InsertItems(ctx, &filter.InsertItemsRequest{Items: items})
+	require.NoError(t, err)
+
+	// the 15 that didn't fit end up in the dead-letter filter
+	require.Eventually(t, func() bool { return sizeOf(t, deadLetters) == 15 }, 5*time.Second, 10*time.Millisecond)
+	max, err := deadLetters.GetMaxItem(ctx, &filter.GetMaxItemRequest{})
+	require.NoError(t, err)
+	require.Equal(t, float32(14)/20, max.GetItem().GetScore())
+	require.Equal(t, int32(5), sizeOf(t, client))
+}
@@ -0,0 +1,237 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/Jfroel/cdsf-microservice/services"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestInsertItemOutcome(t *testing.T) {
+	client := startFilter(t, apps.NewCDSFApp(apps.Config{FilterType: "coarseRW", Capacity: 2}))
+	ctx := context.Background()
+
+	insert := func(score float32) *filter.InsertItemResponse {
+		resp, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score, Data: []byte("item")}})
+		require.NoError(t, err)
+		require.True(t, resp.GetSuccess())
+		return resp
+	}
+	require.Equal(t, filter.InsertOutcome_STORED, insert(0.4).GetOutcome())
+	require.Equal(t, filter.InsertOutcome_STORED, insert(0.6).GetOutcome())
+
+	resp := insert(0.3)
+	require.Equal(t, filter.InsertOutcome_REJECTED, resp.GetOutcome())
+	require.Nil(t, resp.GetEvicted())
+
+	resp = insert(0.7)
+	require.Equal(t, filter.InsertOutcome_EVICTED, resp.GetOutcome())
+	require.Equal(t, float32(0.4), resp.GetEvicted().GetScore())
+	require.Equal(t, []byte("item"), resp.GetEvicted().GetData())
+
+	// an insert that fails says so, not stored
+	f := services.NewFilter("test", 0, apps.NewCDSFApp(apps.Config{FilterType: "coarseRW", Capacity: 2}))
+	resp, err := f.InsertItem(ctx, &filter.InsertItemRequest{})
+	require.Error(t, err)
+	require.False(t, resp.GetSuccess())
+	require.Equal(t, filter.InsertOutcome_FAILED, resp.GetOutcome())
+}
+
+func TestInsertStream(t *testing.T) {
+	app := apps.NewCDSFApp(apps.Config{FilterType: "coarseRW", Capacity: 10})
+	client := startFilter(t, app)
+
+	stream, err := client.InsertStream(context.Background())
+	require.NoError(t, err)
+
+	// 10 to fill it up, 5 that beat the min, 5 that don't
+	for i := 0; i < 10; i++ {
+		require.NoError(t, stream.Send(&filter.FilterItem{Score: 0.5}))
+	}
+	for i := 0; i < 5; i++ {
+		require.NoError(t, stream.Send(&filter.FilterItem{Score: 0.9}))
+	}
+	for i := 0; i < 5; i++ {
+		require.NoError(t, stream.Send(&filter.FilterItem{Score: 0.1}))
+	}
+	resp, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+
+	require.EqualValues(t, 15, resp.GetAccepted())
+	require.EqualValues(t, 5, resp.GetRejected())
+	require.EqualValues(t, 5, resp.GetEvicted())
+	require.Equal(t, 10, app.GetSize())
+}
+
+func TestSubscribe(t *testing.T) {
+	app := apps.NewCDSFApp(apps.Config{FilterType: "coarseRW", Capacity: 100})
+	client := startFilter(t, app)
+
+	for _, score := range []float32{0.2, 0.8, 0.5} {
+		insertItem(t, app, &filter.FilterItem{Score: score})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := subscribe(t, client, ctx, &filter.SubscribeRequest{})
+
+	// what is already there comes out best first
+	for _, want := range []float32{0.8, 0.5, 0.2} {
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, want, resp.GetItem().GetScore())
+	}
+
+	// then the subscriber parks on the empty filter until the next insert
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		client.InsertItem(context.Background(), &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.7}})
+	}()
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.7), resp.GetItem().GetScore())
+
+	cancel()
+	_, err = stream.Recv()
+	require.Error(t, err)
+}
+
+func TestSubscribeRate(t *testing.T) {
+	app := apps.NewCDSFApp(apps.Config{FilterType: "coarseRW", Capacity: 100})
+	client := startFilter(t, app)
+	for i := 0; i < 20; i++ {
+		insertItem(t, app, &filter.FilterItem{Score: float32(i) / 20})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := subscribe(t, client, ctx, &filter.SubscribeRequest{Rate: 100, Window: 5})
+
+	// the first 5 are banked credit, the next 10 come at 100 per second
+	start := time.Now()
+	for i := 0; i < 15; i++ {
+		_, err := stream.Recv()
+		require.NoError(t, err)
+	}
+	require.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+// Without a rate the window is how many items the subscriber may have out,
+// the next one only comes once it hands back credit
+func TestSubscribeWindow(t *testing.T) {
+	app := apps.NewCDSFApp(apps.Config{FilterType: "coarseRW", Capacity: 100})
+	client := startFilter(t, app)
+	for i := 0; i < 10; i++ {
+		insertItem(t, app, &filter.FilterItem{Score: float32(i) / 10})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := subscribe(t, client, ctx, &filter.SubscribeRequest{Window: 3})
+
+	recv := func(n int) {
+		for i := 0; i < n; i++ {
+			_, err := stream.Recv()
+			require.NoError(t, err)
+		}
+	}
+	recv(3)
+	// nothing more leaves the filter until credit comes back
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 7, app.GetSize())
+
+	require.NoError(t, stream.Send(&filter.SubscribeRequest{Credit: 2}))
+	recv(2)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 5, app.GetSize())
+}
+
+// open a subscription paced by req
+func subscribe(t *testing.T, client filter.FilterServiceClient, ctx context.Context, req *filter.SubscribeRequest) filter.FilterService_SubscribeClient {
+	stream, err := client.Subscribe(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(req))
+	return stream
+}
+
+func TestRemoveWait(t *testing.T) {
+	app := apps.NewCDSFApp(apps.Config{FilterType: "coarseRW", Capacity: 100})
+	client := startFilter(t, app)
+	ctx := context.Background()
+	insertLater := func(score float32) {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		}()
+	}
+
+	// no wait fails right away like before
+	_, err := client.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{})
+	require.Error(t, err)
+
+	// parks until the insert shows up
+	insertLater(0.4)
+	resp, err := client.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{WaitMs: 5000})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.4), resp.GetItem().GetScore())
+
+	// gives up after the wait
+	start := time.Now()
+	_, err = client.RemoveMinItem(ctx, &filter.RemoveMinItemRequest{WaitMs: 100})
+	require.Error(t, err)
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	// the caller's deadline wins over a longer wait
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = client.RemoveMaxItem(short, &filter.RemoveMaxItemRequest{WaitMs: 5000})
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+
+	// items below the min score don't count
+	insertItem(t, app, &filter.FilterItem{Score: 0.3})
+	minScore := float32(0.5)
+	_, err = client.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{MinScore: &minScore})
+	require.Error(t, err)
+
+	insertLater(0.6)
+	resp, err = client.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{WaitMs: 5000, MinScore: &minScore})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.6), resp.GetItem().GetScore())
+	require.Equal(t, 1, app.GetSize())
+
+	// for RemoveMin the min itself has to qualify
+	minScore = 0.2
+	respMin, err := client.RemoveMinItem(ctx, &filter.RemoveMinItemRequest{MinScore: &minScore})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.3), respMin.GetItem().GetScore())
+}
+
+func TestLeaseRPCs(t *testing.T) {
+	app := apps.NewFilterApp(apps.Config{FilterType: "coarseRW", Capacity: 10})
+	client := startFilter(t, app)
+	ctx := context.Background()
+	insertItem(t, app, &filter.FilterItem{Score: 0.6})
+
+	lease, err := client.LeaseMaxItem(ctx, &filter.LeaseMaxItemRequest{TimeoutMs: 60000})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.6), lease.GetItem().GetScore())
+	require.NotEmpty(t, lease.GetLeaseId())
+
+	// a consumer waiting on the empty filter gets the nacked item
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		client.Nack(ctx, &filter.NackRequest{LeaseId: lease.GetLeaseId()})
+	}()
+	resp, err := client.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{WaitMs: 5000})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.6), resp.GetItem().GetScore())
+
+	_, err = client.Ack(ctx, &filter.AckRequest{LeaseId: lease.GetLeaseId()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/Jfroel/cdsf-microservice/services"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// a local -heap filter forwarding into upstreamAddr until the test ends
+func startForwarder(t *testing.T, upstreamAddr string, cfg services.ForwarderConfig) filter.FilterServiceClient {
+	local := services.NewFilter("edge", 0, apps.NewFilterApp(filterConfig(100)))
+	client, _ := serveFilter(t, local)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go services.NewForwarder(local, upstreamAddr, cfg).Run(ctx)
+	return client
+}
+
+func sizeOf(t *testing.T, client filter.FilterServiceClient) int32 {
+	resp, err := client.GetSize(context.Background(), &filter.GetSizeRequest{})
+	require.NoError(t, err)
+	return resp.GetSize()
+}
+
+func TestForwarderThreshold(t *testing.T) {
+	ctx := context.Background()
+	upstream, upstreamAddr := serveFilter(t, services.NewFilter("core", 0, apps.NewFilterApp(filterConfig(100))))
+	threshold := float32(0.5)
+	edge := startForwarder(t, upstreamAddr, services.ForwarderConfig{Threshold: &threshold})
+
+	for _, score := range []float32{0.3, 0.7, 0.9, 0.1} {
+		_, err := edge.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		require.NoError(t, err)
+	}
+
+	// only what meets the threshold goes up, the rest stays at the edge
+	require.Eventually(t, func() bool { return sizeOf(t, upstream) == 2 }, 5*time.Second, 10*time.Millisecond)
+	min, err := upstream.GetMinItem(ctx, &filter.GetMinItemRequest{})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.7), min.GetItem().GetScore())
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int32(2), sizeOf(t, edge))
+}
+
+func TestForwarderRate(t *testing.T) {
+	ctx := context.Background()
+	upstream, upstreamAddr := serveFilter(t, services.NewFilter("core", 0, apps.NewFilterApp(filterConfig(100))))
+	edge := startForwarder(t, upstreamAddr, services.ForwarderConfig{Rate: 20, Batch: 10})
+
+	items := make([]*filter.FilterItem, 10)
+	for i := range items {
+		items[i] = &filter.FilterItem{Score: float32(i) / 10}
+	}
+	start := time.Now()
+	_, err := edge.InsertItems(ctx, &filter.InsertItemsRequest{Items: items})
+	require.NoError(t, err)
+
+	// 20 a second, so all 10 take about half a second, best first
+	require.Eventually(t, func() bool { return sizeOf(t, upstream) == 10 }, 5*time.Second, 10*time.Millisecond)
+	require.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	max, err := upstream.GetMaxItem(ctx, &filter.GetMaxItemRequest{})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.9), max.GetItem().GetScore())
+}
+
+func TestForwarderUpstreamDown(t *testing.T) {
+	ctx := context.Background()
+
+	// grab a free port and leave nothing listening on it for now
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	upstreamAddr := lis.Addr().String()
+	lis.Close()
+
+	edge := startForwarder(t, upstreamAddr, services.ForwarderConfig{MaxBackoff: 100 * time.Millisecond})
+	for _, score := range []float32{0.2, 0.4, 0.6} {
+		_, err := edge.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		require.NoError(t, err)
+	}
+
+	// held at the edge while there is nowhere to send them, one may be out
+	// on a retry at any moment
+	time.Sleep(300 * time.Millisecond)
+	require.Eventually(t, func() bool { return sizeOf(t, edge) == 3 }, time.Second, 5*time.Millisecond)
+
+	lis, err = net.Listen("tcp", upstreamAddr)
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	filter.RegisterFilterServiceServer(srv, services.NewFilter("core", 0, apps.NewFilterApp(filterConfig(100))))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.Dial(upstreamAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	upstream := filter.NewFilterServiceClient(conn)
+
+	require.Eventually(t, func() bool { return sizeOf(t, upstream) == 3 }, 10*time.Second, 20*time.Millisecond)
+	require.Equal(t, int32(0), sizeOf(t, edge))
+}
+
+func TestForwarderRefused(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// a filter with score decay takes no negative scores
+	upstream, upstreamAddr := serveFilter(t, services.NewFilter("core", 0, apps.NewFilterApp(decayConfig(100, time.Hour))))
+	edge := startForwarder(t, upstreamAddr, services.ForwarderConfig{Batch: 10})
+	stream, err := edge.WatchEvictions(ctx, &filter.WatchEvictionsRequest{})
+	require.NoError(t, err)
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	items := []*filter.FilterItem{{Score: 0.7}, {Score: -0.3}, {Score: 0.5}}
+	_, err = edge.InsertItems(ctx, &filter.InsertItemsRequest{Items: items})
+	require.NoError(t, err)
+
+	// the rest of the batch still goes, the refused item is evicted
+	require.Eventually(t, func() bool { return sizeOf(t, upstream) == 2 }, 5*time.Second, 10*time.Millisecond)
+	ev, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, float32(-0.3), ev.GetItem().GetScore())
+	require.Equal(t, filter.EvictReason_REFUSED, ev.GetReason())
+	require.Equal(t, int32(0), sizeOf(t, edge))
+}
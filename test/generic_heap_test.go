@@ -0,0 +1,101 @@
+package test
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// a plain struct, no protobuf involved
+type job struct {
+	priority int
+	name     string
+}
+
+func jobLess(a, b job) bool {
+	return a.priority < b.priority
+}
+
+func TestGenericHeapOrder(t *testing.T) {
+	var tests = []struct {
+		cap        int
+		numInserts int
+	}{
+		{1, 1},
+		{10, 10},
+		{100, 60},
+		{1000, 1000},
+	}
+	for _, tt := range tests {
+		heap := apps.NewCoarseRWMaxMinHeapFunc(tt.cap, jobLess)
+
+		for i := 0; i < tt.numInserts; i++ {
+			heap.Insert(job{priority: rand.Intn(1000), name: "job"})
+		}
+		require.Equal(t, tt.numInserts, heap.Size())
+
+		// alternate ends, both have to see the order given by less
+		hi, lo := 1000, -1
+		for i := 0; i < tt.numInserts; i++ {
+			if i%2 == 0 {
+				max := heap.GetMax()
+				item := heap.RemoveMax()
+				require.Equal(t, max, item)
+				require.GreaterOrEqual(t, hi, item.priority)
+				hi = item.priority
+			} else {
+				min := heap.GetMin()
+				item := heap.RemoveMin()
+				require.Equal(t, min, item)
+				require.LessOrEqual(t, lo, item.priority)
+				lo = item.priority
+			}
+		}
+
+		// the zero job marks an empty heap
+		assert.True(t, heap.IsEmpty())
+		assert.Equal(t, job{}, heap.RemoveMax())
+		assert.Equal(t, job{}, heap.GetMin())
+	}
+}
+
+func TestGenericHeapCapacityKeepsTopItems(t *testing.T) {
+	// reversed order, so the heap keeps the smallest ints
+	var heap apps.MaxMinHeap[int] = apps.NewFlatCombiningMaxMinHeap[int](
+		apps.NewCoarseRWMaxMinHeapFunc(10, func(a, b int) bool { return a > b }))
+
+	values := rand.Perm(1000)
+	for _, v := range values {
+		heap.Insert(v)
+	}
+	require.Equal(t, 10, heap.Size())
+	require.True(t, heap.IsFull())
+
+	sort.Ints(values)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, values[i], heap.RemoveMax())
+	}
+}
+
+func TestGenericHeapInsertBatch(t *testing.T) {
+	heap := apps.NewCoarseRWMaxMinHeapFunc(100, jobLess)
+
+	jobs := make([]job, 1000)
+	for i := range jobs {
+		jobs[i] = job{priority: i, name: "job"}
+	}
+	rand.Shuffle(len(jobs), func(i, j int) { jobs[i], jobs[j] = jobs[j], jobs[i] })
+	require.True(t, heap.InsertBatch(jobs))
+	require.True(t, heap.IsFull())
+
+	// the top 100 priorities, in order from both ends
+	require.Equal(t, 900, heap.RemoveMin().priority)
+	for p := 999; p > 900; p-- {
+		require.Equal(t, p, heap.RemoveMax().priority)
+	}
+	require.True(t, heap.IsEmpty())
+}
@@ -0,0 +1,376 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/Jfroel/cdsf-microservice/services"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// the -heap under test if it keeps a key index, or wraps one that does, the
+// coarse grain heap otherwise
+func keyedConfig(cap int) apps.Config {
+	if _, ok := keyedHeaps[*HEAP]; ok || *HEAP == "flatcombining" {
+		return filterConfig(cap)
+	}
+	return apps.Config{FilterType: "coarseRW", Capacity: cap}
+}
+
+// every heap that keeps a key index
+var keyedHeaps = map[string]func(cap int) apps.KeyedFilterHeap{
+	"coarseRW": func(cap int) apps.KeyedFilterHeap { return apps.NewCoarseRWMaxMinHeap(cap) },
+	"subtree":  func(cap int) apps.KeyedFilterHeap { return apps.NewSubtreeMaxMinHeap(cap) },
+}
+
+func rescore(score float32) func(item *filter.FilterItem) *filter.FilterItem {
+	return func(item *filter.FilterItem) *filter.FilterItem {
+		return &filter.FilterItem{Score: score, Data: item.GetData(), Key: item.GetKey()}
+	}
+}
+
+func TestKeyedHeapUpsert(t *testing.T) {
+	for name, ctor := range keyedHeaps {
+		t.Run(name, func(t *testing.T) { testKeyedHeapUpsert(t, ctor(3)) })
+	}
+}
+
+func testKeyedHeapUpsert(t *testing.T, heap apps.KeyedFilterHeap) {
+
+	require.Equal(t, apps.InsertStored, heap.Insert(&filter.FilterItem{Score: 0.5, Key: "a"}).Outcome)
+	require.Equal(t, apps.InsertStored, heap.Insert(&filter.FilterItem{Score: 0.6}).Outcome)
+	require.Equal(t, apps.InsertStored, heap.Insert(&filter.FilterItem{Score: 0.7, Key: "b"}).Outcome)
+
+	// a key that's already there is replaced in place, even in a full heap
+	// and even with a worse score
+	res := heap.Insert(&filter.FilterItem{Score: 0.1, Key: "b", Data: []byte("new")})
+	require.Equal(t, apps.InsertUpdated, res.Outcome)
+	require.Nil(t, res.Evicted)
+	require.Equal(t, 3, heap.Size())
+	require.Equal(t, []byte("new"), heap.GetMin().GetData())
+
+	// a new key pushes the min out and frees its key
+	res = heap.Insert(&filter.FilterItem{Score: 0.9, Key: "c"})
+	require.Equal(t, apps.InsertEvicted, res.Outcome)
+	require.Equal(t, "b", res.Evicted.GetKey())
+	_, ok := heap.Delete("b")
+	require.False(t, ok)
+
+	// within a merged batch the last item with a key wins
+	batch := []*filter.FilterItem{{Score: 0.2, Key: "a"}, {Score: 0.3, Key: "d"}, {Score: 0.8, Key: "d"}}
+	for i := 0; i < 20; i++ {
+		batch = append(batch, &filter.FilterItem{Score: 0.01})
+	}
+	heap.InsertBatch(batch)
+	require.Equal(t, 3, heap.Size())
+	var keys []string
+	for !heap.IsEmpty() {
+		keys = append(keys, heap.RemoveMax().GetKey())
+	}
+	require.Equal(t, []string{"c", "d", ""}, keys)
+}
+
+func TestKeyedHeapUpdateAndDelete(t *testing.T) {
+	for name, ctor := range keyedHeaps {
+		t.Run(name, func(t *testing.T) { testKeyedHeapUpdateAndDelete(t, ctor(2000)) })
+	}
+}
+
+func testKeyedHeapUpdateAndDelete(t *testing.T, heap apps.KeyedFilterHeap) {
+	const n = 2000
+
+	// every operation checked against a plain map, with the order checked
+	// through the max and min after each one
+	scores := make(map[string]float32)
+	check := func() {
+		require.Equal(t, len(scores), heap.Size())
+		if len(scores) == 0 {
+			return
+		}
+		max, min := float32(-1), float32(2)
+		for _, score := range scores {
+			if score > max {
+				max = score
+			}
+			if score < min {
+				min = score
+			}
+		}
+		require.Equal(t, max, heap.GetMax().GetScore())
+		require.Equal(t, min, heap.GetMin().GetScore())
+	}
+
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprint(rand.Intn(n / 2))
+		score := rand.Float32()
+		switch rand.Intn(6) {
+		case 0, 1:
+			heap.Insert(&filter.FilterItem{Score: score, Key: key})
+			scores[key] = score
+		case 2:
+			item, ok := heap.Update(key, rescore(score))
+			_, had := scores[key]
+			require.Equal(t, had, ok)
+			if ok {
+				require.Equal(t, key, item.GetKey())
+				require.Equal(t, score, item.GetScore())
+				scores[key] = score
+			}
+		case 3:
+			item, ok := heap.Delete(key)
+			old, had := scores[key]
+			require.Equal(t, had, ok)
+			if ok {
+				require.Equal(t, old, item.GetScore())
+				delete(scores, key)
+			}
+		case 4:
+			if item := heap.RemoveMax(); item != nil {
+				delete(scores, item.GetKey())
+			}
+		case 5:
+			if item := heap.RemoveMin(); item != nil {
+				delete(scores, item.GetKey())
+			}
+		}
+		check()
+	}
+
+	// the keys still find their items after all the moving around
+	for key, score := range scores {
+		item, ok := heap.Delete(key)
+		require.True(t, ok)
+		require.Equal(t, score, item.GetScore())
+		delete(scores, key)
+		check()
+	}
+	require.True(t, heap.IsEmpty())
+}
+
+func TestKeyedHeapConcurrent(t *testing.T) {
+	const keys = 200
+	heap := apps.NewSubtreeMaxMinHeap(keys / 2)
+
+	// upserts, moves and deletes in the middle of the heap race with the
+	// top-down inserts and removals
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5000; i++ {
+				key := fmt.Sprint(rand.Intn(keys))
+				switch rand.Intn(6) {
+				case 0, 1:
+					heap.Insert(&filter.FilterItem{Score: rand.Float32(), Key: key})
+				case 2:
+					heap.Insert(&filter.FilterItem{Score: rand.Float32()})
+				case 3:
+					heap.Update(key, rescore(rand.Float32()))
+				case 4:
+					heap.Delete(key)
+				case 5:
+					heap.RemoveMin()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// every key is there at most once and is found where it is
+	seen := make(map[string]bool)
+	var scores []float32
+	for !heap.IsEmpty() {
+		item := heap.RemoveMax()
+		scores = append(scores, item.GetScore())
+		if key := item.GetKey(); key != "" {
+			require.False(t, seen[key], key)
+			seen[key] = true
+		}
+	}
+	require.True(t, sort.SliceIsSorted(scores, func(i, j int) bool { return scores[i] > scores[j] }))
+	for key := range seen {
+		_, ok := heap.Delete(key)
+		require.False(t, ok)
+	}
+}
+
+func TestKeyedFlatCombining(t *testing.T) {
+	app := apps.NewFilterApp(apps.Config{FilterType: "coarseRW", FlatCombining: true, Capacity: 10}).(apps.KeyedFilter)
+	insertItem(t, app, &filter.FilterItem{Score: 0.2, Key: "a"})
+	res := insertItem(t, app, &filter.FilterItem{Score: 0.4, Key: "a"})
+	require.Equal(t, apps.InsertUpdated, res.Outcome)
+	_, err := app.UpdateScore("a", 0.6)
+	require.NoError(t, err)
+	require.Equal(t, []float32{0.6}, drainScores(app))
+}
+
+func TestKeyedRPCs(t *testing.T) {
+	client := startFilter(t, apps.NewFilterApp(keyedConfig(10)))
+	ctx := context.Background()
+
+	for i, score := range []float32{0.3, 0.5, 0.7} {
+		_, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score, Key: fmt.Sprint("k", i)}})
+		require.NoError(t, err)
+	}
+	resp, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.4, Key: "k1"}})
+	require.NoError(t, err)
+	require.Equal(t, filter.InsertOutcome_UPDATED, resp.GetOutcome())
+
+	update, err := client.UpdateScore(ctx, &filter.UpdateScoreRequest{Key: "k0", Score: 0.9})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.9), update.GetItem().GetScore())
+	max, err := client.GetMaxItem(ctx, &filter.GetMaxItemRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "k0", max.GetItem().GetKey())
+
+	deleted, err := client.DeleteByKey(ctx, &filter.DeleteByKeyRequest{Key: "k1"})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.4), deleted.GetItem().GetScore())
+	require.Equal(t, int32(2), sizeOf(t, client))
+
+	_, err = client.DeleteByKey(ctx, &filter.DeleteByKeyRequest{Key: "k1"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.UpdateScore(ctx, &filter.UpdateScoreRequest{Key: "nope", Score: 0.1})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// heaps without a key index turn keyed items down
+	plain := startFilter(t, apps.NewFilterApp(apps.Config{FilterType: "skiplist", Capacity: 10}))
+	_, err = plain.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.3, Key: "k"}})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+	_, err = plain.DeleteByKey(ctx, &filter.DeleteByKeyRequest{Key: "k"})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestKeyedLeaseSuperseded(t *testing.T) {
+	app := apps.NewLeasingCDSFApp(apps.NewCDSFApp(keyedConfig(10)), 10)
+	insertItem(t, app, &filter.FilterItem{Score: 0.9, Key: "a", Data: []byte("old")})
+	insertItem(t, app, &filter.FilterItem{Score: 0.5, Key: "b"})
+
+	_, id, err := app.LeaseMax(time.Minute)
+	require.NoError(t, err)
+	insertItem(t, app, &filter.FilterItem{Score: 0.3, Key: "a", Data: []byte("new")})
+
+	// the nacked item is older than the one under its key now
+	require.NoError(t, app.Nack(id))
+	require.Equal(t, 2, app.GetSize())
+	moved, err := app.UpdateScore("a", 0.4)
+	require.NoError(t, err)
+	require.Equal(t, []byte("new"), moved.GetData())
+
+	// without a newer one it goes back as usual
+	_, id, err = app.LeaseMax(time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, app.Nack(id))
+	require.Equal(t, []float32{0.5, 0.4}, drainScores(app))
+}
+
+func TestFilterClientSendsKeyed(t *testing.T) {
+	raw := startFilter(t, apps.NewFilterApp(keyedConfig(3)))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := services.WrapFilterClient(ctx, raw)
+	for i, score := range []float32{0.5, 0.6, 0.7} {
+		_, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score, Key: fmt.Sprint("k", i)}})
+		require.NoError(t, err)
+	}
+	require.Equal(t, float32(0.5), *client.Threshold())
+
+	// below the threshold, but they update the items under their keys
+	resp, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.1, Key: "k2"}})
+	require.NoError(t, err)
+	require.Equal(t, filter.InsertOutcome_UPDATED, resp.GetOutcome())
+	_, err = client.InsertItems(ctx, &filter.InsertItemsRequest{Items: []*filter.FilterItem{{Score: 0.2, Key: "k1"}, {Score: 0.05}}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), client.Dropped())
+	bottom, err := raw.GetBottomK(ctx, &filter.GetBottomKRequest{K: 3})
+	require.NoError(t, err)
+	require.Equal(t, []float32{0.1, 0.2, 0.5}, scoresOf(bottom.GetItems()))
+}
+
+func TestKeyedDurable(t *testing.T) {
+	dir := t.TempDir()
+	cfg := keyedConfig(10)
+	cfg.DataDir, cfg.Fsync = dir, "always"
+
+	app := apps.NewFilterApp(cfg).(apps.KeyedFilter)
+	insertItem(t, app, &filter.FilterItem{Score: 0.2, Key: "a"})
+	insertItem(t, app, &filter.FilterItem{Score: 0.4, Key: "b"})
+	insertItem(t, app, &filter.FilterItem{Score: 0.6, Key: "c"})
+	_, err := app.UpdateScore("a", 0.8)
+	require.NoError(t, err)
+	_, err = app.Delete("b")
+	require.NoError(t, err)
+	durable, _ := apps.As[*apps.DurableCDSFApp](app)
+	require.NoError(t, durable.Close())
+
+	recovered := apps.NewFilterApp(cfg)
+	require.Equal(t, []float32{0.8, 0.6}, drainScores(recovered))
+}
+
+func TestBandRouterKeyed(t *testing.T) {
+	ctx := context.Background()
+	var addrs []string
+	var filters []filter.FilterServiceClient
+	for i := 0; i < 2; i++ {
+		client, addr := serveFilter(t, services.NewFilter("band", 0, apps.NewFilterApp(keyedConfig(10))))
+		filters = append(filters, client)
+		addrs = append(addrs, addr)
+	}
+	router, err := services.NewBandRouter(ctx, addrs[0]+"@0.5,"+addrs[1])
+	require.NoError(t, err)
+
+	_, err = router.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.2, Key: "a", Data: []byte{1}}})
+	require.NoError(t, err)
+
+	// a new score in the other band moves the item over, data and all
+	resp, err := router.UpdateScore(ctx, &filter.UpdateScoreRequest{Key: "a", Score: 0.7})
+	require.NoError(t, err)
+	require.Equal(t, []byte{1}, resp.GetItem().GetData())
+	require.Equal(t, int32(1), sizeOf(t, filters[0]))
+	require.Equal(t, int32(0), sizeOf(t, filters[1]))
+
+	// so does inserting the key again
+	_, err = router.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.1, Key: "a"}})
+	require.NoError(t, err)
+	require.Equal(t, int32(0), sizeOf(t, filters[0]))
+	require.Equal(t, int32(1), sizeOf(t, filters[1]))
+
+	deleted, err := router.DeleteByKey(ctx, &filter.DeleteByKeyRequest{Key: "a"})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.1), deleted.GetItem().GetScore())
+	_, err = router.DeleteByKey(ctx, &filter.DeleteByKeyRequest{Key: "a"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestClusterKeyed(t *testing.T) {
+	cluster, _ := startCluster(t, 3, 100)
+	ctx := context.Background()
+
+	// every version of a key lands on the same shard
+	for i := 0; i < 10; i++ {
+		_, err := cluster.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: rand.Float32(), Key: "a"}})
+		if status.Code(err) == codes.Unimplemented {
+			t.Skip("-heap has no key index")
+		}
+		require.NoError(t, err)
+	}
+	size, err := cluster.GetSize(ctx, &filter.GetSizeRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), size.GetSize())
+
+	_, err = cluster.UpdateScore(ctx, &filter.UpdateScoreRequest{Key: "a", Score: 0.5})
+	require.NoError(t, err)
+	deleted, err := cluster.DeleteByKey(ctx, &filter.DeleteByKeyRequest{Key: "a"})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.5), deleted.GetItem().GetScore())
+}
@@ -0,0 +1,146 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func newLeasingApp(cap int) *apps.LeasingCDSFApp {
+	return apps.NewLeasingCDSFApp(apps.NewCDSFApp(filterConfig(cap)), cap)
+}
+
+func TestLeaseAckNack(t *testing.T) {
+	app := newLeasingApp(10)
+	for _, score := range []float32{0.1, 0.5, 0.9} {
+		insertItem(t, app, &filter.FilterItem{Score: score})
+	}
+
+	item, id, err := app.LeaseMax(time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, float32(0.9), item.GetScore())
+
+	// hidden, but still counted
+	max, err := app.GetMax()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.5), max.GetScore())
+	require.Equal(t, 3, app.GetSize())
+
+	// nack puts it back with its score
+	require.NoError(t, app.Nack(id))
+	max, err = app.GetMax()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.9), max.GetScore())
+	require.Equal(t, 3, app.GetSize())
+	require.Error(t, app.Nack(id))
+
+	// ack drops it for good
+	_, id, err = app.LeaseMax(time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, app.Ack(id))
+	require.Equal(t, 2, app.GetSize())
+	require.Error(t, app.Ack(id))
+	require.Error(t, app.Nack(id))
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	app := newLeasingApp(10)
+	insertItem(t, app, &filter.FilterItem{Score: 0.7})
+
+	returned := make(chan struct{}, 1)
+	app.OnReturn(func() { returned <- struct{}{} })
+
+	_, id, err := app.LeaseMax(20 * time.Millisecond)
+	require.NoError(t, err)
+	_, err = app.GetMax()
+	require.Error(t, err)
+
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lease never expired")
+	}
+	max, err := app.GetMax()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.7), max.GetScore())
+	require.Error(t, app.Ack(id))
+}
+
+func TestLeaseCountsAgainstCapacity(t *testing.T) {
+	app := newLeasingApp(3)
+	var evicted []float32
+	app.OnEvict(func(item *filter.FilterItem, reason apps.EvictReason) {
+		evicted = append(evicted, item.GetScore())
+	})
+	for _, score := range []float32{0.1, 0.5, 0.9} {
+		insertItem(t, app, &filter.FilterItem{Score: score})
+	}
+	_, id, err := app.LeaseMax(time.Minute)
+	require.NoError(t, err)
+	require.True(t, app.IsFull())
+
+	// the filter is full with the lease, so the new item pushes out the min
+	res := insertItem(t, app, &filter.FilterItem{Score: 0.3})
+	require.Equal(t, apps.InsertEvicted, res.Outcome)
+	require.Equal(t, float32(0.1), res.Evicted.GetScore())
+	require.Equal(t, 3, app.GetSize())
+	min, err := app.GetMin()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.3), min.GetScore())
+
+	// and one that doesn't beat the min goes nowhere, which its outcome
+	// says, it isn't reported as evicted
+	res = insertItem(t, app, &filter.FilterItem{Score: 0.2})
+	require.Equal(t, apps.InsertRejected, res.Outcome)
+	require.Equal(t, 3, app.GetSize())
+	require.Equal(t, []float32{0.1}, evicted)
+
+	require.NoError(t, app.Nack(id))
+	require.Equal(t, 3, app.GetSize())
+	max, err := app.GetMax()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.9), max.GetScore())
+}
+
+func TestLeaseReturnRejected(t *testing.T) {
+	// an app that holds less than the leases leave room for, like a relaxed
+	// heap, or one an insert filled up before the trim
+	app := apps.NewLeasingCDSFApp(apps.NewCDSFApp(filterConfig(2)), 3)
+	var evicted []float32
+	var reasons []apps.EvictReason
+	app.OnEvict(func(item *filter.FilterItem, reason apps.EvictReason) {
+		evicted = append(evicted, item.GetScore())
+		reasons = append(reasons, reason)
+	})
+	insertItem(t, app, &filter.FilterItem{Score: 0.5})
+	insertItem(t, app, &filter.FilterItem{Score: 0.6})
+	_, id, err := app.LeaseMax(time.Minute)
+	require.NoError(t, err)
+	insertItem(t, app, &filter.FilterItem{Score: 0.7})
+	insertItem(t, app, &filter.FilterItem{Score: 0.8})
+
+	// the returned item doesn't beat the min, so it's gone, and said so
+	require.NoError(t, app.Nack(id))
+	require.Equal(t, []float32{0.5, 0.6}, evicted)
+	require.Equal(t, []apps.EvictReason{apps.EvictCapacity, apps.EvictCapacity}, reasons)
+	require.Equal(t, 2, app.GetSize())
+
+	// one that expired while it was out is only reported as expired
+	evicted, reasons = nil, nil
+	app = newLeasingApp(10)
+	app.OnEvict(func(item *filter.FilterItem, reason apps.EvictReason) {
+		evicted = append(evicted, item.GetScore())
+		reasons = append(reasons, reason)
+	})
+	insertItem(t, app, &filter.FilterItem{Score: 0.9, ExpiresUnixMs: expiresIn(50 * time.Millisecond)})
+	_, id, err = app.LeaseMax(time.Minute)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, app.Nack(id))
+	require.Equal(t, []float32{0.9}, evicted)
+	require.Equal(t, []apps.EvictReason{apps.EvictExpired}, reasons)
+	require.Zero(t, app.GetSize())
+}
@@ -3,8 +3,11 @@ package test
 import (
 	"math"
 	"math/rand"
+	"sort"
+	"sync"
 	"testing"
 
+	"github.com/Jfroel/cdsf-microservice/apps"
 	"github.com/Jfroel/cdsf-microservice/proto/filter"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -282,3 +285,210 @@ func TestInsertPastCapacity(t *testing.T) {
 		}
 	}
 }
+
+func TestInsertOutcome(t *testing.T) {
+	heap := heapCtor(3)
+	var tests = []struct {
+		score   float32
+		outcome apps.InsertOutcome
+		evicted float32
+	}{
+		{0.5, apps.InsertStored, 0},
+		{0.2, apps.InsertStored, 0},
+		{0.8, apps.InsertStored, 0},
+		{0.1, apps.InsertRejected, 0},
+		{0.2, apps.InsertRejected, 0}, // ties with the min don't get in
+		{0.6, apps.InsertEvicted, 0.2},
+		{0.9, apps.InsertEvicted, 0.5},
+	}
+	for _, tt := range tests {
+		res := heap.Insert(&filter.FilterItem{Score: tt.score})
+		require.Equal(t, tt.outcome, res.Outcome, "score %v", tt.score)
+		require.Equal(t, tt.evicted, res.Evicted.GetScore(), "score %v", tt.score)
+	}
+
+	empty := heapCtor(0)
+	require.Equal(t, apps.InsertRejected, empty.Insert(&filter.FilterItem{Score: 1}).Outcome)
+}
+
+func TestEvictHook(t *testing.T) {
+	heap := heapCtor(50)
+	var evicted []float32
+	heap.OnEvict(func(item *filter.FilterItem) { evicted = append(evicted, item.GetScore()) })
+
+	for i := 1; i <= 51; i++ {
+		heap.Insert(&filter.FilterItem{Score: float32(i) / 100})
+	}
+	require.Equal(t, []float32{0.01}, evicted)
+
+	// a small batch goes in item by item, the one that doesn't make it is
+	// reported along with the one pushed out
+	evicted = nil
+	heap.InsertBatch([]*filter.FilterItem{{Score: 0.001}, {Score: 0.9}})
+	sort.Slice(evicted, func(i, j int) bool { return evicted[i] < evicted[j] })
+	require.Equal(t, []float32{0.001, 0.02}, evicted)
+
+	// a big one gets merged in, everything cut from the heap or the batch
+	// is reported
+	evicted = nil
+	batch := make([]*filter.FilterItem, 100)
+	for i := range batch {
+		batch[i] = &filter.FilterItem{Score: 0.5 + float32(i)/1000}
+	}
+	heap.InsertBatch(batch)
+	require.Len(t, evicted, 100)
+	require.Equal(t, 50, heap.Size())
+	min := heap.GetMin().GetScore()
+	for _, score := range evicted {
+		require.LessOrEqual(t, score, min)
+	}
+}
+
+func TestConcurrentInsertOutcome(t *testing.T) {
+	const cap, threads, perThread = 100, 8, 1000
+	heap := heapCtor(cap)
+
+	// every item ends up either in the heap, rejected or evicted, and only
+	// plain stores grow the heap
+	var mu sync.Mutex
+	stored, gone := 0, make(map[*filter.FilterItem]bool)
+	var wg sync.WaitGroup
+	for w := 0; w < threads; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perThread; i++ {
+				item := &filter.FilterItem{Score: rand.Float32()}
+				res := heap.Insert(item)
+				mu.Lock()
+				switch res.Outcome {
+				case apps.InsertStored:
+					stored++
+				case apps.InsertRejected:
+					gone[item] = true
+				case apps.InsertEvicted:
+					assert.False(t, gone[res.Evicted])
+					gone[res.Evicted] = true
+				default:
+					t.Errorf("unexpected outcome %v", res.Outcome)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, cap, stored)
+	require.Equal(t, threads*perThread-cap, len(gone))
+	for !heap.IsEmpty() {
+		require.False(t, gone[heap.RemoveMax()])
+	}
+}
+
+func TestConcurrentInsertAndRemove(t *testing.T) {
+	show()
+	var tests = []struct {
+		cap     int
+		numOps  int
+		numRuns int
+		threads int
+	}{
+		{1, 1000, 2, 4},
+		{3, 1000, 2, 8},
+		{100, 10000, 3, 8},
+		{100000, 100000, 2, 10},
+	}
+	for _, tt := range tests {
+
+		heap := heapCtor(tt.cap)
+		for i := 0; i < tt.numRuns; i++ {
+			numJobs := tt.numOps
+			jobs := make(chan int, numJobs)
+			results := make(chan int, numJobs)
+
+			for w := 1; w <= tt.threads; w++ {
+				go workerMixed(w, jobs, results, heap)
+			}
+			for j := 1; j <= numJobs; j++ {
+				jobs <- j
+			}
+			close(jobs)
+
+			for j := 1; j <= numJobs; j++ {
+				<-results
+			}
+
+			// whatever is left must still come out in order
+			size := heap.Size()
+			require.LessOrEqual(t, size, tt.cap)
+
+			var lastScore float32 = 2.0
+			for j := 0; j < size; j++ {
+				curScore := heap.RemoveMax().GetScore()
+				require.GreaterOrEqual(t, lastScore, curScore)
+				lastScore = curScore
+			}
+			require.True(t, heap.IsEmpty())
+		}
+	}
+}
+
+func TestInsertBatch(t *testing.T) {
+	var tests = []struct {
+		cap    int
+		before int
+		batch  int
+		levels int // number of distinct scores, 0 for any float
+	}{
+		{10, 0, 5, 0},
+		{10, 0, 10, 0},
+		{10, 5, 20, 0},
+		{100, 99, 1, 0},
+		{1000, 0, 5000, 0},
+		{1000, 900, 50, 0},
+		{1000, 500, 5000, 3},
+		{100000, 50000, 100000, 0},
+	}
+	for _, tt := range tests {
+		heap := heapCtor(tt.cap)
+		score := func() float32 {
+			if tt.levels > 0 {
+				return float32(rand.Intn(tt.levels)) / float32(tt.levels)
+			}
+			return rand.Float32()
+		}
+
+		scores := make([]float32, 0, tt.before+tt.batch)
+		for i := 0; i < tt.before; i++ {
+			scores = append(scores, score())
+			heap.Insert(&filter.FilterItem{Score: scores[i], Data: []byte{}})
+		}
+		batch := make([]*filter.FilterItem, tt.batch)
+		for i := range batch {
+			batch[i] = &filter.FilterItem{Score: score(), Data: []byte{}}
+			scores = append(scores, batch[i].GetScore())
+		}
+		require.True(t, heap.InsertBatch(batch))
+
+		want := len(scores)
+		if tt.cap < want {
+			want = tt.cap
+		}
+		require.Equal(t, want, heap.Size())
+
+		// exactly the top cap scores survive, and both ends still see them
+		sort.Slice(scores, func(i, j int) bool { return scores[i] > scores[j] })
+		scores = scores[:want]
+		hi, lo := 0, want-1
+		for i := 0; i < want; i++ {
+			if i%2 == 0 {
+				require.Equal(t, scores[hi], heap.RemoveMax().GetScore())
+				hi++
+			} else {
+				require.Equal(t, scores[lo], heap.RemoveMin().GetScore())
+				lo--
+			}
+		}
+		require.True(t, heap.IsEmpty())
+	}
+}
@@ -0,0 +1,130 @@
+package test
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// Rank error of RemoveMax: how many items still in the heap beat the one it
+// returned. Run with -v to see the numbers for each number of queues.
+func TestMultiQueueRankError(t *testing.T) {
+	var tests = []struct {
+		queues     int
+		numInserts int
+		numRemoves int
+	}{
+		{2, 10000, 5000},
+		{4, 10000, 5000},
+		{8, 10000, 5000},
+		{16, 10000, 5000},
+		{32, 10000, 5000},
+		{64, 10000, 5000},
+	}
+	for _, tt := range tests {
+		heap := apps.NewMultiQueueMaxMinHeap(tt.queues, tt.numInserts)
+
+		// scores still in the heap, best first
+		present := make([]float32, tt.numInserts)
+		for i := range present {
+			present[i] = rand.Float32()
+			heap.Insert(&filter.FilterItem{Score: present[i], Data: []byte{}})
+		}
+		sort.Slice(present, func(i, j int) bool { return present[i] > present[j] })
+
+		total, worst := 0, 0
+		for i := 0; i < tt.numRemoves; i++ {
+			score := heap.RemoveMax().GetScore()
+			rank := sort.Search(len(present), func(j int) bool { return present[j] <= score })
+			require.Equal(t, score, present[rank])
+			present = append(present[:rank], present[rank+1:]...)
+
+			total += rank
+			if rank > worst {
+				worst = rank
+			}
+		}
+
+		mean := float64(total) / float64(tt.numRemoves)
+		t.Logf("queues: %3d  mean rank error: %7.2f  max rank error: %5d", tt.queues, mean, worst)
+
+		// the expected rank error grows linearly with the number of queues
+		require.Less(t, mean, float64(2*tt.queues))
+		require.Equal(t, tt.numInserts-tt.numRemoves, heap.Size())
+	}
+}
+
+func TestMultiQueueCapacity(t *testing.T) {
+	heap := apps.NewMultiQueueMaxMinHeap(8, 100)
+	evicted := 0
+	heap.OnEvict(func(item *filter.FilterItem) { evicted++ })
+
+	// the queues only hold their share, but filling the heap evicts nothing
+	for i := 0; i < 100; i++ {
+		require.Equal(t, apps.InsertStored, heap.Insert(&filter.FilterItem{Score: rand.Float32()}).Outcome)
+	}
+	require.Zero(t, evicted)
+
+	for i := 0; i < 1000; i++ {
+		heap.Insert(&filter.FilterItem{Score: rand.Float32(), Data: []byte{}})
+		require.LessOrEqual(t, heap.Size(), 100)
+	}
+	require.True(t, heap.IsFull())
+
+	heap.Clear()
+	require.True(t, heap.IsEmpty())
+	require.Nil(t, heap.RemoveMax())
+	require.Nil(t, heap.RemoveMin())
+}
+
+// Mixed producers and consumers, compare against the exact heaps with
+// go test ./test/ -bench MultiQueue -run ^$
+func BenchmarkMultiQueue(b *testing.B) {
+	capacity := 1 << 16
+	heaps := []struct {
+		name string
+		ctor func() apps.FilterHeap
+	}{
+		{"coarseRW", func() apps.FilterHeap { return apps.NewCoarseRWMaxMinHeap(capacity) }},
+		{"subtree", func() apps.FilterHeap { return apps.NewSubtreeMaxMinHeap(capacity) }},
+		{"skiplist", func() apps.FilterHeap { return apps.NewSkipListMaxMinHeap(capacity) }},
+		{"flatcombining", func() apps.FilterHeap {
+			return apps.NewFlatCombiningMaxMinHeap[*filter.FilterItem](apps.NewCoarseRWMaxMinHeap(capacity))
+		}},
+	}
+	for _, queues := range []int{2, 4, 8, 16, 32} {
+		queues := queues
+		heaps = append(heaps, struct {
+			name string
+			ctor func() apps.FilterHeap
+		}{fmt.Sprintf("multiqueue-%d", queues), func() apps.FilterHeap {
+			return apps.NewMultiQueueMaxMinHeap(queues, capacity)
+		}})
+	}
+
+	for _, h := range heaps {
+		b.Run(h.name, func(b *testing.B) {
+			heap := h.ctor()
+			for i := 0; i < capacity/2; i++ {
+				heap.Insert(&filter.FilterItem{Score: rand.Float32(), Data: []byte{}})
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					if i%2 == 0 {
+						heap.Insert(&filter.FilterItem{Score: rand.Float32(), Data: []byte{}})
+					} else {
+						heap.RemoveMax()
+					}
+					i++
+				}
+			})
+		})
+	}
+}
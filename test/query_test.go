@@ -0,0 +1,231 @@
+package test
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/Jfroel/cdsf-microservice/services"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// n random scores, best first
+func randomScores(n int) []float32 {
+	scores := make([]float32, n)
+	for i := range scores {
+		scores[i] = rand.Float32()
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i] > scores[j] })
+	return scores
+}
+
+func scoresOf(items []*filter.FilterItem) []float32 {
+	scores := []float32{}
+	for _, item := range items {
+		scores = append(scores, item.GetScore())
+	}
+	return scores
+}
+
+func reversed(scores []float32) []float32 {
+	r := make([]float32, len(scores))
+	for i, score := range scores {
+		r[len(scores)-1-i] = score
+	}
+	return r
+}
+
+// the scores from lo to hi, best first
+func between(scores []float32, lo, hi float32) []float32 {
+	in := []float32{}
+	for _, score := range scores {
+		if lo <= score && score <= hi {
+			in = append(in, score)
+		}
+	}
+	return in
+}
+
+func TestWalkHeaps(t *testing.T) {
+	const n = 500
+	for name, ctor := range map[string]func(cap int) apps.WalkingFilterHeap{
+		"coarseRW": func(cap int) apps.WalkingFilterHeap { return apps.NewCoarseRWMaxMinHeap(cap) },
+		"soa":      func(cap int) apps.WalkingFilterHeap { return apps.NewSoAMaxMinHeap(cap) },
+		"skiplist": func(cap int) apps.WalkingFilterHeap { return apps.NewSkipListMaxMinHeap(cap) },
+		"subtree":  func(cap int) apps.WalkingFilterHeap { return apps.NewSubtreeMaxMinHeap(cap) },
+	} {
+		ctor := ctor
+		t.Run(name, func(t *testing.T) {
+			walker := ctor(n)
+			scores := randomScores(n)
+			for _, score := range scores {
+				walker.(apps.FilterHeap).Insert(&filter.FilterItem{Score: score})
+			}
+			walk := func(max bool, skip func(item *filter.FilterItem) bool, k int) []float32 {
+				got := []float32{}
+				walker.Walk(max, skip, func(item *filter.FilterItem) bool {
+					got = append(got, item.GetScore())
+					return len(got) < k
+				})
+				return got
+			}
+
+			require.Equal(t, scores, walk(true, nil, n))
+			require.Equal(t, reversed(scores), walk(false, nil, n))
+			require.Equal(t, scores[:10], walk(true, nil, 10))
+			require.Equal(t, reversed(scores)[:10], walk(false, nil, 10))
+
+			// the skipped items are the best ones, the walk goes on below them
+			above := func(item *filter.FilterItem) bool { return item.GetScore() > 0.5 }
+			require.Equal(t, between(scores, 0, 0.5), walk(true, above, n))
+		})
+	}
+}
+
+func TestQueryApp(t *testing.T) {
+	app := apps.NewFilterApp(filterConfig(100)).(apps.QueryingFilter)
+	scores := randomScores(50)
+	for _, score := range scores {
+		insertItem(t, app, &filter.FilterItem{Score: score})
+	}
+
+	top, err := app.TopK(10)
+	require.NoError(t, err)
+	require.Equal(t, scores[:10], scoresOf(top))
+	bottom, err := app.BottomK(10)
+	require.NoError(t, err)
+	require.Equal(t, reversed(scores)[:10], scoresOf(bottom))
+	all, err := app.TopK(100)
+	require.NoError(t, err)
+	require.Equal(t, scores, scoresOf(all))
+
+	in, err := app.Range(0.3, 0.6, 0)
+	require.NoError(t, err)
+	require.Equal(t, between(scores, 0.3, 0.6), scoresOf(in))
+	in, err = app.Range(0.3, 0.6, 3)
+	require.NoError(t, err)
+	require.Equal(t, between(scores, 0.3, 0.6)[:3], scoresOf(in))
+	in, err = app.Range(0.6, 0.3, 0)
+	require.NoError(t, err)
+	require.Empty(t, in)
+
+	// nothing was taken out, and expired items aren't there
+	require.Equal(t, 50, app.GetSize())
+	insertItem(t, app, &filter.FilterItem{Score: 1, ExpiresUnixMs: expiresIn(50 * time.Millisecond)})
+	time.Sleep(100 * time.Millisecond)
+	top, err = app.TopK(1)
+	require.NoError(t, err)
+	require.Equal(t, scores[:1], scoresOf(top))
+}
+
+func TestQueryDecay(t *testing.T) {
+	app := apps.NewFilterApp(decayConfig(10, 50*time.Millisecond)).(apps.QueryingFilter)
+	insertItem(t, app, &filter.FilterItem{Score: 0.8, Data: []byte("old")})
+	time.Sleep(100 * time.Millisecond)
+	insertItem(t, app, &filter.FilterItem{Score: 0.5, Data: []byte("new")})
+
+	// by effective score, the old item is down to about 0.2
+	top, err := app.TopK(2)
+	require.NoError(t, err)
+	require.Equal(t, []float32{0.5, 0.8}, scoresOf(top))
+	require.InDelta(t, 0.2, top[1].GetEffectiveScore(), 0.05)
+
+	in, err := app.Range(0.3, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, in, 1)
+	require.Equal(t, []byte("new"), in[0].GetData())
+	in, err = app.Range(-1, 0.3, 0)
+	require.NoError(t, err)
+	require.Len(t, in, 1)
+	require.Equal(t, []byte("old"), in[0].GetData())
+}
+
+func TestQueryService(t *testing.T) {
+	client := startFilter(t, apps.NewFilterApp(keyedConfig(10)))
+	ctx := context.Background()
+	for _, score := range []float32{0.1, 0.5, 0.9} {
+		_, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		require.NoError(t, err)
+	}
+
+	top, err := client.GetTopK(ctx, &filter.GetTopKRequest{K: 2})
+	require.NoError(t, err)
+	require.Equal(t, []float32{0.9, 0.5}, scoresOf(top.GetItems()))
+	bottom, err := client.GetBottomK(ctx, &filter.GetBottomKRequest{K: 5})
+	require.NoError(t, err)
+	require.Equal(t, []float32{0.1, 0.5, 0.9}, scoresOf(bottom.GetItems()))
+	in, err := client.GetRange(ctx, &filter.GetRangeRequest{MinScore: 0.1, MaxScore: 0.5})
+	require.NoError(t, err)
+	require.Equal(t, []float32{0.5, 0.1}, scoresOf(in.GetItems()))
+
+	_, err = client.GetTopK(ctx, &filter.GetTopKRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.GetRange(ctx, &filter.GetRangeRequest{MaxScore: 1, Limit: -1})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// the multiqueue's queues hold no order between them
+	relaxed := startFilter(t, apps.NewFilterApp(apps.Config{FilterType: "multiqueue", Relaxation: 1, Capacity: 10}))
+	_, err = relaxed.GetTopK(ctx, &filter.GetTopKRequest{K: 2})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestQuerySharded(t *testing.T) {
+	app := apps.NewFilterApp(shardedConfig(4, 100)).(apps.QueryingFilter)
+	scores := randomScores(100)
+	for _, score := range scores {
+		insertItem(t, app, &filter.FilterItem{Score: score})
+	}
+
+	top, err := app.TopK(10)
+	require.NoError(t, err)
+	require.Equal(t, scores[:10], scoresOf(top))
+	bottom, err := app.BottomK(10)
+	require.NoError(t, err)
+	require.Equal(t, reversed(scores)[:10], scoresOf(bottom))
+	in, err := app.Range(0.25, 0.75, 0)
+	require.NoError(t, err)
+	require.Equal(t, between(scores, 0.25, 0.75), scoresOf(in))
+}
+
+func TestQueryClusterAndBands(t *testing.T) {
+	ctx := context.Background()
+	cluster, _ := startCluster(t, 3, 100)
+	_, addrs := startFilters(t, 3, 100)
+	router, err := services.NewBandRouter(ctx, addrs[0]+"@0.7,"+addrs[1]+"@0.3,"+addrs[2])
+	require.NoError(t, err)
+
+	scores := randomScores(60)
+	for _, score := range scores {
+		_, err := cluster.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		require.NoError(t, err)
+		_, err = router.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		require.NoError(t, err)
+	}
+
+	for name, client := range map[string]interface {
+		GetTopK(ctx context.Context, in *filter.GetTopKRequest, opts ...grpc.CallOption) (*filter.GetTopKResponse, error)
+		GetBottomK(ctx context.Context, in *filter.GetBottomKRequest, opts ...grpc.CallOption) (*filter.GetBottomKResponse, error)
+		GetRange(ctx context.Context, in *filter.GetRangeRequest, opts ...grpc.CallOption) (*filter.GetRangeResponse, error)
+	}{"cluster": cluster, "bands": router} {
+		top, err := client.GetTopK(ctx, &filter.GetTopKRequest{K: 25})
+		require.NoError(t, err, name)
+		require.Equal(t, scores[:25], scoresOf(top.GetItems()), name)
+		bottom, err := client.GetBottomK(ctx, &filter.GetBottomKRequest{K: 25})
+		require.NoError(t, err, name)
+		require.Equal(t, reversed(scores)[:25], scoresOf(bottom.GetItems()), name)
+
+		in, err := client.GetRange(ctx, &filter.GetRangeRequest{MinScore: 0.2, MaxScore: 0.8})
+		require.NoError(t, err, name)
+		require.Equal(t, between(scores, 0.2, 0.8), scoresOf(in.GetItems()), name)
+		in, err = client.GetRange(ctx, &filter.GetRangeRequest{MinScore: 0.2, MaxScore: 0.8, Limit: 5})
+		require.NoError(t, err, name)
+		require.Equal(t, between(scores, 0.2, 0.8)[:5], scoresOf(in.GetItems()), name)
+	}
+}
@@ -0,0 +1,253 @@
+package test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRemoveTopKHeap(t *testing.T) {
+	heap := heapCtor(100)
+	scores := randomScores(50)
+	for _, score := range scores {
+		heap.Insert(&filter.FilterItem{Score: score})
+	}
+
+	require.Empty(t, heap.RemoveTopK(0))
+	require.Equal(t, scores[:10], scoresOf(heap.RemoveTopK(10)))
+	require.Equal(t, 40, heap.Size())
+
+	// the threshold itself is in, the next item isn't
+	threshold := &filter.FilterItem{Score: scores[19]}
+	require.Equal(t, scores[10:15], scoresOf(heap.RemoveAbove(threshold, 5)))
+	require.Equal(t, scores[15:20], scoresOf(heap.RemoveAbove(threshold, 0)))
+	require.Empty(t, heap.RemoveAbove(threshold, 0))
+
+	require.Equal(t, scores[20:], scoresOf(heap.RemoveTopK(100)))
+	require.True(t, heap.IsEmpty())
+	require.Empty(t, heap.RemoveTopK(10))
+}
+
+func TestRemoveTopKExpired(t *testing.T) {
+	heap := apps.NewTTLMaxMinHeap(heapCtor(10))
+	heap.Insert(&filter.FilterItem{Score: 0.9, ExpiresUnixMs: expiresIn(50 * time.Millisecond)})
+	heap.Insert(&filter.FilterItem{Score: 0.8})
+	heap.Insert(&filter.FilterItem{Score: 0.7, ExpiresUnixMs: expiresIn(50 * time.Millisecond)})
+	heap.Insert(&filter.FilterItem{Score: 0.6})
+	heap.Insert(&filter.FilterItem{Score: 0.5})
+	time.Sleep(100 * time.Millisecond)
+
+	// the expired items don't count towards k
+	require.Equal(t, []float32{0.8, 0.6}, scoresOf(heap.RemoveTopK(2)))
+	require.Equal(t, []float32{0.5}, scoresOf(heap.RemoveAbove(&filter.FilterItem{Score: 0.1}, 0)))
+}
+
+func TestRemoveTopKApp(t *testing.T) {
+	for name, cfg := range map[string]apps.Config{
+		"plain":   filterConfig(100),
+		"sharded": shardedConfig(4, 100),
+	} {
+		cfg := cfg
+		t.Run(name, func(t *testing.T) {
+			app := apps.NewFilterApp(cfg)
+			scores := randomScores(50)
+			for _, score := range scores {
+				insertItem(t, app, &filter.FilterItem{Score: score})
+			}
+
+			items, err := app.RemoveTopK(10)
+			require.NoError(t, err)
+			require.Equal(t, scores[:10], scoresOf(items))
+			items, err = app.RemoveAbove(scores[29], 0)
+			require.NoError(t, err)
+			require.Equal(t, scores[10:30], scoresOf(items))
+			require.Equal(t, 20, app.GetSize())
+			items, err = app.RemoveAbove(1, 0)
+			require.NoError(t, err)
+			require.Empty(t, items)
+		})
+	}
+}
+
+func TestRemoveAboveDecay(t *testing.T) {
+	app := apps.NewFilterApp(decayConfig(10, 50*time.Millisecond))
+	insertItem(t, app, &filter.FilterItem{Score: 0.8, Data: []byte("old")})
+	time.Sleep(100 * time.Millisecond)
+	insertItem(t, app, &filter.FilterItem{Score: 0.5, Data: []byte("new")})
+
+	// the old item is down to about 0.2
+	items, err := app.RemoveAbove(0.3, 0)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.Equal(t, []byte("new"), items[0].GetData())
+	require.Equal(t, 1, app.GetSize())
+}
+
+func TestRemoveTopKDurable(t *testing.T) {
+	dir := t.TempDir()
+	app := openDurable(t, dir, 50, "always")
+	scores := randomScores(30)
+	for _, score := range scores {
+		insertItem(t, app, &filter.FilterItem{Score: score})
+	}
+	_, err := app.RemoveTopK(5)
+	require.NoError(t, err)
+	_, err = app.RemoveAbove(scores[9], 0)
+	require.NoError(t, err)
+	require.NoError(t, app.Close())
+
+	app = openDurable(t, dir, 50, "never")
+	defer app.Close()
+	require.Equal(t, scores[10:], drainScores(app))
+}
+
+func TestRemoveAboveDecayDurable(t *testing.T) {
+	cfg := decayConfig(10, 200*time.Millisecond)
+	cfg.DataDir, cfg.Fsync = t.TempDir(), "always"
+	app := apps.NewFilterApp(cfg)
+	insertItem(t, app, &filter.FilterItem{Score: 1})
+	removed, err := app.RemoveAbove(0.9, 0)
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	durable, _ := apps.As[*apps.DurableCDSFApp](app)
+	require.NoError(t, durable.Close())
+
+	// 1.0 has decayed below 0.9 by the replay, it's still gone
+	time.Sleep(400 * time.Millisecond)
+	app = apps.NewFilterApp(cfg)
+	defer app.Close()
+	require.Equal(t, 0, app.GetSize())
+}
+
+func TestRemoveBatchService(t *testing.T) {
+	client := startFilter(t, apps.NewFilterApp(filterConfig(100)))
+	ctx := context.Background()
+	scores := randomScores(50)
+	for _, score := range scores {
+		_, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		require.NoError(t, err)
+	}
+
+	top, err := client.RemoveTopK(ctx, &filter.RemoveTopKRequest{K: 5})
+	require.NoError(t, err)
+	require.Equal(t, scores[:5], scoresOf(top.GetItems()))
+	above, err := client.RemoveAbove(ctx, &filter.RemoveAboveRequest{Threshold: scores[9]})
+	require.NoError(t, err)
+	require.Equal(t, scores[5:10], scoresOf(above.GetItems()))
+
+	_, err = client.RemoveTopK(ctx, &filter.RemoveTopKRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.RemoveAbove(ctx, &filter.RemoveAboveRequest{Limit: -1})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// every batch of a drain, until the filter ends the stream
+func drainBatches(t *testing.T, stream filter.FilterService_DrainClient) [][]float32 {
+	var batches [][]float32
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return batches
+		}
+		require.NoError(t, err)
+		batches = append(batches, scoresOf(resp.GetItems()))
+	}
+}
+
+func TestDrain(t *testing.T) {
+	client := startFilter(t, apps.NewFilterApp(filterConfig(100)))
+	ctx := context.Background()
+	scores := randomScores(50)
+	for _, score := range scores {
+		_, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		require.NoError(t, err)
+	}
+
+	// up to limit, in batches
+	stream, err := client.Drain(ctx, &filter.DrainRequest{Limit: 10, BatchSize: 4})
+	require.NoError(t, err)
+	require.Equal(t, [][]float32{scores[:4], scores[4:8], scores[8:10]}, drainBatches(t, stream))
+
+	// down to min_score
+	minScore := scores[29]
+	stream, err = client.Drain(ctx, &filter.DrainRequest{MinScore: &minScore, BatchSize: 7})
+	require.NoError(t, err)
+	batches := drainBatches(t, stream)
+	require.Len(t, batches, 3)
+	var drained []float32
+	for _, batch := range batches {
+		drained = append(drained, batch...)
+	}
+	require.Equal(t, scores[10:30], drained)
+
+	// everything else in the default batch size
+	stream, err = client.Drain(ctx, &filter.DrainRequest{})
+	require.NoError(t, err)
+	require.Equal(t, [][]float32{scores[30:]}, drainBatches(t, stream))
+	size, err := client.GetSize(ctx, &filter.GetSizeRequest{})
+	require.NoError(t, err)
+	require.Zero(t, size.GetSize())
+
+	stream, err = client.Drain(ctx, &filter.DrainRequest{BatchSize: -1})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// an app whose batch removals come back one short, like the multiqueue's can
+type shortBatches struct {
+	apps.ConcurrentDataStreamFilter
+}
+
+func (a shortBatches) RemoveTopK(k int) ([]*filter.FilterItem, error) {
+	return a.ConcurrentDataStreamFilter.RemoveTopK(k - 1)
+}
+
+// a short batch doesn't end the drain, only an empty one does
+func TestDrainShortBatches(t *testing.T) {
+	app := apps.NewFilterApp(filterConfig(100))
+	client := startFilter(t, shortBatches{app})
+	for _, score := range randomScores(10) {
+		insertItem(t, app, &filter.FilterItem{Score: score})
+	}
+
+	stream, err := client.Drain(context.Background(), &filter.DrainRequest{BatchSize: 4})
+	require.NoError(t, err)
+	var sizes []int
+	for _, batch := range drainBatches(t, stream) {
+		sizes = append(sizes, len(batch))
+	}
+	require.Equal(t, []int{3, 3, 3, 1}, sizes)
+	require.Zero(t, app.GetSize())
+}
+
+func TestDrainConcurrentInserts(t *testing.T) {
+	client := startFilter(t, apps.NewFilterApp(filterConfig(1000)))
+	ctx := context.Background()
+
+	// drains racing inserts lose nothing
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, score := range randomScores(500) {
+			client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		}
+	}()
+	drained := 0
+	for i := 0; i < 20; i++ {
+		stream, err := client.Drain(ctx, &filter.DrainRequest{BatchSize: 10})
+		require.NoError(t, err)
+		for _, batch := range drainBatches(t, stream) {
+			drained += len(batch)
+		}
+	}
+	<-done
+	require.Equal(t, int32(500-drained), sizeOf(t, client))
+}
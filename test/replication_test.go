@@ -0,0 +1,167 @@
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/Jfroel/cdsf-microservice/services"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// a -heap filter server in the given replication role
+func startReplica(t *testing.T, role string, cap int) (*services.Filter, filter.FilterServiceClient, string) {
+	cfg := filterConfig(cap)
+	cfg.Role = role
+	s := services.NewFilter(role, 0, apps.NewFilterApp(cfg))
+	client, addr := serveFilter(t, s)
+	return s, client, addr
+}
+
+// wait until the backup has caught up to size items with the given ends
+func requireCaughtUp(t *testing.T, client filter.FilterServiceClient, size int32, max, min float32) {
+	ctx := context.Background()
+	require.Eventually(t, func() bool {
+		resp, err := client.GetSize(ctx, &filter.GetSizeRequest{})
+		if err != nil || resp.GetSize() != size {
+			return false
+		}
+		if size == 0 {
+			return true
+		}
+		hi, err := client.GetMaxItem(ctx, &filter.GetMaxItemRequest{})
+		if err != nil {
+			return false
+		}
+		lo, err := client.GetMinItem(ctx, &filter.GetMinItemRequest{})
+		return err == nil && hi.GetItem().GetScore() == max && lo.GetItem().GetScore() == min
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestReplication(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, primary, primaryAddr := startReplica(t, "primary", 5)
+	for _, score := range []float32{0.3, 0.6} {
+		_, err := primary.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		require.NoError(t, err)
+	}
+
+	// a backup joining late gets the whole filter first
+	s, backup, _ := startReplica(t, "backup", 5)
+	require.NoError(t, s.FollowPrimary(ctx, primaryAddr))
+	requireCaughtUp(t, backup, 2, 0.6, 0.3)
+
+	// then every mutation, evictions included
+	items := []*filter.FilterItem{{Score: 0.1}, {Score: 0.9}, {Score: 0.5}, {Score: 0.7}, {Score: 0.8}}
+	_, err := primary.InsertItems(ctx, &filter.InsertItemsRequest{Items: items})
+	require.NoError(t, err)
+	_, err = primary.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{})
+	require.NoError(t, err)
+	requireCaughtUp(t, backup, 4, 0.8, 0.5)
+
+	// backups are read only
+	_, err = backup.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 1}})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = backup.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{WaitMs: 5000})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = backup.Clear(ctx, &filter.ClearRequest{})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// a promoted backup takes writes and stops listening to the old primary
+	_, err = backup.Promote(ctx, &filter.PromoteRequest{})
+	require.NoError(t, err)
+	_, err = backup.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.95}})
+	require.NoError(t, err)
+	_, err = primary.Clear(ctx, &filter.ClearRequest{})
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	requireCaughtUp(t, backup, 5, 0.95, 0.5)
+}
+
+func TestReplicationNotEnabled(t *testing.T) {
+	client := startFilter(t, apps.NewFilterApp(filterConfig(10)))
+	_, err := client.Promote(context.Background(), &filter.PromoteRequest{})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestReplicationTakesOutWhatThePrimaryDid(t *testing.T) {
+	primary := apps.NewReplicatedCDSFApp(apps.NewCDSFApp(filterConfig(5)), true)
+	backup := apps.NewReplicatedCDSFApp(apps.NewCDSFApp(filterConfig(5)), false)
+	records, follower, err := primary.Follow()
+	require.NoError(t, err)
+	for _, rec := range records {
+		require.NoError(t, backup.Apply(rec))
+	}
+
+	insertItem(t, primary, &filter.FilterItem{Score: 0.9, ExpiresUnixMs: expiresIn(50 * time.Millisecond)})
+	insertItem(t, primary, &filter.FilterItem{Score: 0.5})
+	item, err := primary.RemoveMax()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.9), item.GetScore())
+
+	// 0.9 expired before the backup got to the removal, which still doesn't
+	// take 0.5
+	time.Sleep(100 * time.Millisecond)
+	for len(follower.Records) > 0 {
+		require.NoError(t, backup.Apply(<-follower.Records))
+	}
+	require.Equal(t, []float32{0.5}, drainScores(backup.Unwrap()))
+}
+
+func TestReplicationFollowLeavesThePrimary(t *testing.T) {
+	for name, cfg := range map[string]apps.Config{
+		"walked": filterConfig(100),
+		"logged": {FilterType: "multiqueue", Relaxation: 1, Capacity: 100},
+	} {
+		cfg := cfg
+		t.Run(name, func(t *testing.T) {
+			cfg.DataDir, cfg.Fsync = t.TempDir(), "always"
+			durable, err := apps.NewDurableCDSFApp(apps.NewCDSFApp(cfg), cfg)
+			require.NoError(t, err)
+			t.Cleanup(func() { durable.Close() })
+			primary := apps.NewReplicatedCDSFApp(durable, true)
+			for _, score := range []float32{0.2, 0.4, 0.6} {
+				insertItem(t, primary, &filter.FilterItem{Score: score})
+			}
+			logged := walSize(t, cfg.DataDir)
+
+			records, _, err := primary.Follow()
+			require.NoError(t, err)
+			backup := apps.NewReplicatedCDSFApp(apps.NewCDSFApp(filterConfig(100)), false)
+			for _, rec := range records {
+				require.NoError(t, backup.Apply(rec))
+			}
+			require.Equal(t, []float32{0.6, 0.4, 0.2}, drainScores(backup.Unwrap()))
+
+			// nothing was taken out of the primary, or logged going back in
+			require.Equal(t, 3, primary.GetSize())
+			require.Equal(t, logged, walSize(t, cfg.DataDir))
+		})
+	}
+
+	// neither walked nor logged, there's nothing to send a follower
+	relaxed := apps.NewReplicatedCDSFApp(apps.NewCDSFApp(apps.Config{FilterType: "multiqueue", Relaxation: 1, Capacity: 100}), true)
+	_, _, err := relaxed.Follow()
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+// bytes in the write-ahead log segments under dir
+func walSize(t *testing.T, dir string) int64 {
+	segments, err := filepath.Glob(filepath.Join(dir, "wal-*"))
+	require.NoError(t, err)
+	var n int64
+	for _, seg := range segments {
+		info, err := os.Stat(seg)
+		require.NoError(t, err)
+		n += info.Size()
+	}
+	return n
+}
@@ -0,0 +1,250 @@
+package test
+
+import (
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedGlobalOrder(t *testing.T) {
+	var tests = []struct {
+		cap        int
+		shards     int
+		numInserts int
+	}{
+		{10, 1, 10},
+		{10, 4, 10},
+		{100, 8, 60},
+		{1000, 3, 1000},
+	}
+	for _, tt := range tests {
+		app := apps.NewShardedCDSFApp(shardedConfig(tt.shards, tt.cap))
+
+		for i := 0; i < tt.numInserts; i++ {
+			insertItem(t, app, &filter.FilterItem{Score: rand.Float32(), Data: []byte{}})
+		}
+		require.Equal(t, tt.numInserts, app.GetSize())
+
+		// alternate ends, both have to see the global order
+		var hi float32 = 2.0
+		var lo float32 = -1.0
+		for i := 0; i < tt.numInserts; i++ {
+			if i%2 == 0 {
+				max, err := app.GetMax()
+				require.NoError(t, err)
+				item, err := app.RemoveMax()
+				require.NoError(t, err)
+				require.Equal(t, max.GetScore(), item.GetScore())
+				require.GreaterOrEqual(t, hi, item.GetScore())
+				hi = item.GetScore()
+			} else {
+				min, err := app.GetMin()
+				require.NoError(t, err)
+				item, err := app.RemoveMin()
+				require.NoError(t, err)
+				require.Equal(t, min.GetScore(), item.GetScore())
+				require.LessOrEqual(t, lo, item.GetScore())
+				lo = item.GetScore()
+			}
+		}
+
+		require.Equal(t, 0, app.GetSize())
+		_, err := app.RemoveMax()
+		require.Error(t, err)
+	}
+}
+
+func TestShardedCapacityKeepsTopItems(t *testing.T) {
+	var tests = []struct {
+		cap        int
+		shards     int
+		numInserts int
+		threads    int
+	}{
+		{1, 4, 100, 4},
+		{10, 4, 1000, 8},
+		{1000, 8, 20000, 8},
+	}
+	for _, tt := range tests {
+		app := apps.NewShardedCDSFApp(shardedConfig(tt.shards, tt.cap))
+
+		scores := make([]float32, tt.numInserts)
+		for i := range scores {
+			scores[i] = rand.Float32()
+		}
+
+		var wg sync.WaitGroup
+		for w := 0; w < tt.threads; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := w; i < tt.numInserts; i += tt.threads {
+					app.Insert(&filter.FilterItem{Score: scores[i], Data: []byte{}})
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		require.Equal(t, tt.cap, app.GetSize())
+
+		// exactly the cap best scores survive
+		sort.Slice(scores, func(i, j int) bool { return scores[i] > scores[j] })
+		for i := 0; i < tt.cap; i++ {
+			item, err := app.RemoveMax()
+			require.NoError(t, err)
+			require.Equal(t, scores[i], item.GetScore())
+		}
+
+		require.NoError(t, app.Clear())
+		require.Equal(t, 0, app.GetSize())
+	}
+}
+
+func TestShardedInsertBatch(t *testing.T) {
+	var tests = []struct {
+		cap     int
+		shards  int
+		batches int
+		batch   int
+	}{
+		{10, 4, 1, 5},
+		{10, 4, 8, 100},
+		{1000, 8, 16, 500},
+	}
+	for _, tt := range tests {
+		app := apps.NewShardedCDSFApp(shardedConfig(tt.shards, tt.cap))
+
+		scores := make([]float32, tt.batches*tt.batch)
+		for i := range scores {
+			scores[i] = rand.Float32()
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, tt.batches)
+		for b := 0; b < tt.batches; b++ {
+			wg.Add(1)
+			go func(b int) {
+				defer wg.Done()
+				items := make([]*filter.FilterItem, tt.batch)
+				for i := range items {
+					items[i] = &filter.FilterItem{Score: scores[b*tt.batch+i], Data: []byte{}}
+				}
+				errs <- app.InsertBatch(items)
+			}(b)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		want := len(scores)
+		if tt.cap < want {
+			want = tt.cap
+		}
+		require.Equal(t, want, app.GetSize())
+
+		sort.Slice(scores, func(i, j int) bool { return scores[i] > scores[j] })
+		for i := 0; i < want; i++ {
+			item, err := app.RemoveMax()
+			require.NoError(t, err)
+			require.Equal(t, scores[i], item.GetScore())
+		}
+	}
+
+	// one nil item rejects the whole batch
+	app := apps.NewShardedCDSFApp(shardedConfig(2, 10))
+	require.Error(t, app.InsertBatch([]*filter.FilterItem{{Score: 0.5}, nil}))
+	require.Equal(t, 0, app.GetSize())
+}
+
+func TestShardedSkewedShards(t *testing.T) {
+	app := apps.NewShardedCDSFApp(shardedConfig(4, 40))
+	evicted := 0
+	app.OnEvict(func(item *filter.FilterItem, reason apps.EvictReason) { evicted++ })
+
+	// round robin puts every fourth item in the same shard, give those the
+	// best scores and take them all out again
+	for i := 0; i < 40; i++ {
+		score := float32(i) / 100
+		if i%4 == 0 {
+			score += 1
+		}
+		insertItem(t, app, &filter.FilterItem{Score: score})
+	}
+	top, err := app.RemoveTopK(10)
+	require.NoError(t, err)
+	require.Len(t, top, 10)
+
+	// the other shards are past their share, the refill still fits
+	for i := 0; i < 10; i++ {
+		insertItem(t, app, &filter.FilterItem{Score: 0.5})
+	}
+	require.Equal(t, 40, app.GetSize())
+	require.Zero(t, evicted)
+
+	// and the filter is full now, the next insert evicts the global min
+	res := insertItem(t, app, &filter.FilterItem{Score: 0.9})
+	require.Equal(t, apps.InsertEvicted, res.Outcome)
+	require.Equal(t, float32(0.01), res.Evicted.GetScore())
+	require.Equal(t, 40, app.GetSize())
+}
+
+// Producers racing into a filter that fills up while they go end up with the
+// same items a single producer would, and a reader never finds the filter
+// empty while it counts items
+func TestShardedConcurrentMatchesSequential(t *testing.T) {
+	const cap, shards, threads, perThread = 16, 4, 8, 4
+	for round := 0; round < 100; round++ {
+		app := apps.NewShardedCDSFApp(shardedConfig(shards, cap))
+		defer app.Close()
+		oracle := apps.NewCDSFApp(apps.Config{FilterType: "coarseRW", Capacity: cap})
+
+		items := make([]*filter.FilterItem, threads*perThread)
+		for i := range items {
+			items[i] = &filter.FilterItem{Score: rand.Float32(), Data: []byte{}}
+			insertItem(t, oracle, items[i])
+		}
+
+		var wg sync.WaitGroup
+		for w := 0; w < threads; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := w; i < len(items); i += threads {
+					app.Insert(items[i])
+				}
+			}(w)
+		}
+		done := make(chan struct{})
+		emptied := make(chan bool, 1)
+		go func() {
+			defer close(emptied)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if app.GetSize() > 0 {
+					if _, err := app.GetMax(); err != nil {
+						emptied <- true
+						return
+					}
+				}
+				runtime.Gosched()
+			}
+		}()
+		wg.Wait()
+		close(done)
+		require.False(t, <-emptied, "filter said it was empty while it counted items")
+
+		require.Equal(t, drainScores(oracle), drainScores(app))
+	}
+}
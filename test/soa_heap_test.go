@@ -0,0 +1,86 @@
+package test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+)
+
+// the filter's default capacity, levelToSize(18) in cmd/main.go
+const benchCapacity = 1<<18 - 1
+
+// Single threaded, so only the layout differs between the two heaps
+// go test ./test/ -bench HeapLayout -run ^$ -benchmem
+func BenchmarkHeapLayout(b *testing.B) {
+	heaps := []struct {
+		name string
+		ctor func() apps.FilterHeap
+	}{
+		{"coarseRW", func() apps.FilterHeap { return apps.NewCoarseRWMaxMinHeap(benchCapacity) }},
+		{"soa", func() apps.FilterHeap { return apps.NewSoAMaxMinHeap(benchCapacity) }},
+	}
+
+	// premade items so the benchmark doesn't measure the allocator, better
+	// ones score above all of items
+	items := make([]*filter.FilterItem, 1<<20)
+	better := make([]*filter.FilterItem, len(items))
+	for i := range items {
+		items[i] = &filter.FilterItem{Score: rand.Float32(), Data: []byte{}}
+		better[i] = &filter.FilterItem{Score: 1 + rand.Float32()}
+	}
+	fill := func(heap apps.FilterHeap) {
+		for i := 0; i < benchCapacity; i++ {
+			heap.Insert(items[i%len(items)])
+		}
+	}
+
+	for _, h := range heaps {
+		// full heap, every insert evicts the min
+		b.Run(h.name+"/insert-full", func(b *testing.B) {
+			heap := h.ctor()
+			fill(heap)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				heap.Insert(better[i%len(better)])
+			}
+		})
+
+		b.Run(h.name+"/remove-max-insert", func(b *testing.B) {
+			heap := h.ctor()
+			fill(heap)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				heap.Insert(items[i%len(items)])
+				heap.RemoveMax()
+			}
+		})
+
+		b.Run(h.name+"/remove-min-insert", func(b *testing.B) {
+			heap := h.ctor()
+			fill(heap)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				heap.RemoveMin()
+				heap.Insert(items[i%len(items)])
+			}
+		})
+
+		// build up from empty and drain again
+		b.Run(h.name+"/fill-drain", func(b *testing.B) {
+			heap := h.ctor()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				fill(heap)
+				for !heap.IsEmpty() {
+					heap.RemoveMax()
+				}
+			}
+		})
+	}
+}
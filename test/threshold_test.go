@@ -0,0 +1,84 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/Jfroel/cdsf-microservice/services"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertThreshold(t *testing.T) {
+	client := startFilter(t, apps.NewFilterApp(filterConfig(3)))
+	ctx := context.Background()
+
+	// no threshold until the filter is full, then it's the min
+	for _, score := range []float32{0.5, 0.6} {
+		resp, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		require.NoError(t, err)
+		require.Nil(t, resp.Threshold)
+		require.Zero(t, resp.GetThreshold())
+	}
+	resp, err := client.InsertItems(ctx, &filter.InsertItemsRequest{Items: []*filter.FilterItem{{Score: 0.7}, {Score: 0.8}}})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.6), resp.GetThreshold())
+
+	// the watcher hears about every change, removals too
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.WatchThreshold(watchCtx, &filter.WatchThresholdRequest{IntervalMs: 10})
+	require.NoError(t, err)
+	update, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.6), update.GetThreshold())
+
+	_, err = client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.9}})
+	require.NoError(t, err)
+	update, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.7), update.GetThreshold())
+
+	_, err = client.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{})
+	require.NoError(t, err)
+	update, err = stream.Recv()
+	require.NoError(t, err)
+	require.Nil(t, update.Threshold)
+}
+
+func TestFilterClientDropsBelowThreshold(t *testing.T) {
+	raw := startFilter(t, apps.NewFilterApp(filterConfig(3)))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := services.WrapFilterClient(ctx, raw)
+
+	for _, score := range []float32{0.5, 0.6, 0.7} {
+		resp, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: score}})
+		require.NoError(t, err)
+		require.True(t, resp.GetSuccess())
+	}
+	require.Equal(t, float32(0.5), *client.Threshold())
+
+	// never sent, the filter didn't want them anyway
+	resp, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.5}})
+	require.NoError(t, err)
+	require.Equal(t, filter.InsertOutcome_REJECTED, resp.GetOutcome())
+	batch, err := client.InsertItems(ctx, &filter.InsertItemsRequest{Items: []*filter.FilterItem{{Score: 0.1}, {Score: 0.8}}})
+	require.NoError(t, err)
+	require.True(t, batch.GetSuccess())
+	require.Equal(t, int64(2), client.Dropped())
+	min, err := raw.GetMinItem(ctx, &filter.GetMinItemRequest{})
+	require.NoError(t, err)
+	require.Equal(t, float32(0.6), min.GetItem().GetScore())
+
+	// once the filter has room again, everything goes through
+	_, err = raw.RemoveMaxItem(ctx, &filter.RemoveMaxItemRequest{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return client.Threshold() == nil }, 5*time.Second, 10*time.Millisecond)
+	resp, err = client.InsertItem(ctx, &filter.InsertItemRequest{Item: &filter.FilterItem{Score: 0.2}})
+	require.NoError(t, err)
+	require.Equal(t, filter.InsertOutcome_STORED, resp.GetOutcome())
+	require.Equal(t, int64(2), client.Dropped())
+}
@@ -0,0 +1,210 @@
+package test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Jfroel/cdsf-microservice/apps"
+	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func expiresIn(d time.Duration) int64 {
+	return time.Now().Add(d).UnixMilli()
+}
+
+func TestTTLHeapExpiredNeverReturned(t *testing.T) {
+	heap := apps.NewTTLMaxMinHeap(heapCtor(10))
+	var expired atomic.Int32
+	heap.OnExpire(func(item *filter.FilterItem) { expired.Add(1) })
+
+	res := heap.Insert(&filter.FilterItem{Score: 0.9, ExpiresUnixMs: expiresIn(-time.Second)})
+	require.Equal(t, apps.InsertRejected, res.Outcome)
+	require.Equal(t, int32(1), expired.Load())
+	require.Equal(t, 0, heap.Size())
+
+	// the best and the worst items go first
+	heap.Insert(&filter.FilterItem{Score: 0.8, ExpiresUnixMs: expiresIn(50 * time.Millisecond)})
+	heap.Insert(&filter.FilterItem{Score: 0.7, ExpiresUnixMs: expiresIn(50 * time.Millisecond)})
+	heap.Insert(&filter.FilterItem{Score: 0.1, ExpiresUnixMs: expiresIn(50 * time.Millisecond)})
+	heap.Insert(&filter.FilterItem{Score: 0.5})
+	heap.Insert(&filter.FilterItem{Score: 0.4, ExpiresUnixMs: expiresIn(time.Minute)})
+	require.Equal(t, float32(0.8), heap.GetMax().GetScore())
+	time.Sleep(100 * time.Millisecond)
+
+	require.Equal(t, float32(0.5), heap.GetMax().GetScore())
+	require.Equal(t, float32(0.4), heap.GetMin().GetScore())
+	require.Equal(t, float32(0.5), heap.RemoveMax().GetScore())
+	require.Equal(t, float32(0.4), heap.RemoveMax().GetScore())
+	require.Nil(t, heap.RemoveMax())
+	require.Equal(t, int32(4), expired.Load())
+}
+
+func TestTTLHeapReap(t *testing.T) {
+	const n = 1000
+	for name, ctor := range map[string]func(cap int) apps.FilterHeap{
+		"coarseRW": func(cap int) apps.FilterHeap { return apps.NewCoarseRWMaxMinHeap(cap) },
+		"soa":      func(cap int) apps.FilterHeap { return apps.NewSoAMaxMinHeap(cap) },
+		"subtree":  func(cap int) apps.FilterHeap { return apps.NewSubtreeMaxMinHeap(cap) },
+		"skiplist": func(cap int) apps.FilterHeap { return apps.NewSkipListMaxMinHeap(cap) },
+		"flatcombining": func(cap int) apps.FilterHeap {
+			return apps.NewFlatCombiningMaxMinHeap[*filter.FilterItem](apps.NewCoarseRWMaxMinHeap(cap))
+		},
+		"multiqueue": func(cap int) apps.FilterHeap { return apps.NewMultiQueueMaxMinHeap(4, cap) },
+	} {
+		ctor := ctor
+		t.Run(name, func(t *testing.T) {
+			heap := apps.NewTTLMaxMinHeap(ctor(n))
+			var expired atomic.Int32
+			heap.OnExpire(func(item *filter.FilterItem) {
+				require.Equal(t, []byte{0}, item.GetData())
+				expired.Add(1)
+			})
+
+			// every other item expires, spread all over the heap
+			for i := 0; i < n; i++ {
+				item := &filter.FilterItem{Score: float32(i) / n, Data: []byte{byte(i % 2)}}
+				if i%2 == 0 {
+					item.ExpiresUnixMs = expiresIn(50 * time.Millisecond)
+				}
+				heap.Insert(item)
+			}
+			require.Equal(t, 0, heap.Reap())
+			time.Sleep(100 * time.Millisecond)
+
+			require.Equal(t, n/2, heap.Reap())
+			require.Equal(t, int32(n/2), expired.Load())
+			require.Equal(t, n/2, heap.Size())
+			var scores []float32
+			for !heap.IsEmpty() {
+				item := heap.RemoveMax()
+				require.Equal(t, []byte{1}, item.GetData())
+				scores = append(scores, item.GetScore())
+			}
+			if name != "multiqueue" {
+				// still in order after the holes were filled
+				require.IsDecreasing(t, scores)
+			}
+		})
+	}
+
+	// a heap without RemoveWhere only loses the expired items at its ends
+	plain := apps.NewTTLMaxMinHeap(struct{ apps.FilterHeap }{apps.NewCoarseRWMaxMinHeap(10)})
+	plain.Insert(&filter.FilterItem{Score: 0.9, ExpiresUnixMs: expiresIn(50 * time.Millisecond)})
+	plain.Insert(&filter.FilterItem{Score: 0.5, ExpiresUnixMs: expiresIn(50 * time.Millisecond)})
+	plain.Insert(&filter.FilterItem{Score: 0.3})
+	plain.Insert(&filter.FilterItem{Score: 0.1, ExpiresUnixMs: expiresIn(50 * time.Millisecond)})
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, 3, plain.Reap())
+	require.Equal(t, 1, plain.Size())
+}
+
+func TestTTLHeapClose(t *testing.T) {
+	heap := apps.NewTTLMaxMinHeap(heapCtor(10))
+	stopped := make(chan struct{})
+	go func() {
+		heap.ReapEvery(time.Millisecond)
+		close(stopped)
+	}()
+
+	heap.Close()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reaper still running after Close")
+	}
+}
+
+func TestTTLApp(t *testing.T) {
+	cfg := filterConfig(10)
+	cfg.DefaultTTL, cfg.ReapInterval = 50*time.Millisecond, 10*time.Millisecond
+	app := apps.NewFilterApp(cfg)
+
+	// the TTL becomes an expiry on the way in
+	short := &filter.FilterItem{Score: 0.9}
+	long := &filter.FilterItem{Score: 0.2, TtlMs: time.Minute.Milliseconds()}
+	insertItem(t, app, short)
+	insertItem(t, app, long)
+	require.InDelta(t, expiresIn(50*time.Millisecond), short.GetExpiresUnixMs(), 1000)
+	require.InDelta(t, expiresIn(time.Minute), long.GetExpiresUnixMs(), 1000)
+	require.Zero(t, long.GetTtlMs())
+
+	require.Eventually(t, func() bool { return app.GetSize() == 1 }, 5*time.Second, 10*time.Millisecond)
+	max, err := app.GetMax()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.2), max.GetScore())
+}
+
+func TestTTLEvictionCounts(t *testing.T) {
+	cfg := filterConfig(1)
+	cfg.ReapInterval = 10 * time.Millisecond
+	client := startFilter(t, apps.NewFilterApp(cfg))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.WatchEvictions(ctx, &filter.WatchEvictionsRequest{})
+	require.NoError(t, err)
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	for _, item := range []*filter.FilterItem{{Score: 0.2}, {Score: 0.5, TtlMs: 50}} {
+		_, err := client.InsertItem(ctx, &filter.InsertItemRequest{Item: item})
+		require.NoError(t, err)
+	}
+	ev, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, filter.EvictReason_CAPACITY, ev.GetReason())
+	ev, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.5), ev.GetItem().GetScore())
+	require.Equal(t, filter.EvictReason_EXPIRED, ev.GetReason())
+
+	size, err := client.GetSize(ctx, &filter.GetSizeRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int32(0), size.GetSize())
+	require.Equal(t, int64(1), size.GetEvicted())
+	require.Equal(t, int64(1), size.GetExpired())
+}
+
+func TestTTLSharded(t *testing.T) {
+	cfg := shardedConfig(2, 10)
+	cfg.ReapInterval = 10 * time.Millisecond
+	app := apps.NewFilterApp(cfg)
+
+	insertItem(t, app, &filter.FilterItem{Score: 0.9, ExpiresUnixMs: expiresIn(-time.Second)})
+	require.Equal(t, 0, app.GetSize())
+	for i := 0; i < 10; i++ {
+		item := &filter.FilterItem{Score: float32(i) / 10}
+		if i%2 == 0 {
+			item.TtlMs = 50
+		}
+		insertItem(t, app, item)
+	}
+	require.Eventually(t, func() bool { return app.GetSize() == 5 }, 5*time.Second, 10*time.Millisecond)
+
+	// the expired items' slots are free again
+	for i := 0; i < 5; i++ {
+		res := insertItem(t, app, &filter.FilterItem{Score: 0.01})
+		require.Equal(t, apps.InsertStored, res.Outcome)
+	}
+	require.True(t, app.IsFull())
+}
+
+func TestTTLDurableReplay(t *testing.T) {
+	dir := t.TempDir()
+	app := openDurable(t, dir, 10, "always")
+	insertItem(t, app, &filter.FilterItem{Score: 0.9, ExpiresUnixMs: expiresIn(100 * time.Millisecond)})
+	insertItem(t, app, &filter.FilterItem{Score: 0.5})
+	item, err := app.RemoveMax()
+	require.NoError(t, err)
+	require.Equal(t, float32(0.9), item.GetScore())
+	require.NoError(t, app.Close())
+
+	// 0.9 has expired by the replay, which doesn't make the removal take 0.5
+	time.Sleep(150 * time.Millisecond)
+	app = openDurable(t, dir, 10, "always")
+	defer app.Close()
+	require.Equal(t, []float32{0.5}, drainScores(app))
+}
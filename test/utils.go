@@ -3,6 +3,8 @@ package test
 import (
 	"fmt"
 	"math/rand"
+	"net"
+	"testing"
 
 	"flag"
 	"runtime"
@@ -10,30 +12,54 @@ import (
 
 	"github.com/Jfroel/cdsf-microservice/apps"
 	"github.com/Jfroel/cdsf-microservice/proto/filter"
+	"github.com/Jfroel/cdsf-microservice/services"
+	"google.golang.org/grpc"
 )
 
 var (
-	HEAP = flag.Int("heap", 0, "proxy server port")
+	HEAP = flag.String("heap", "coarseRW", "heap implementation under test: coarseRW, soa, subtree, skiplist or flatcombining")
 )
 
-func heapCtor(cap int) apps.MaxMinHeap {
-	if *HEAP == 0 {
-		return apps.NewCoarseRWMaxMinHeap(cap)
-	} else {
-		panic("subtree locking heap not yet implemented")
+// every exact MaxMinHeap, selectable with -heap
+var heapCtors = map[string]func(cap int) apps.FilterHeap{
+	"coarseRW": func(cap int) apps.FilterHeap { return apps.NewCoarseRWMaxMinHeap(cap) },
+	"soa":      func(cap int) apps.FilterHeap { return apps.NewSoAMaxMinHeap(cap) },
+	"subtree":  func(cap int) apps.FilterHeap { return apps.NewSubtreeMaxMinHeap(cap) },
+	"skiplist": func(cap int) apps.FilterHeap { return apps.NewSkipListMaxMinHeap(cap) },
+	"flatcombining": func(cap int) apps.FilterHeap {
+		return apps.NewFlatCombiningMaxMinHeap[*filter.FilterItem](apps.NewCoarseRWMaxMinHeap(cap))
+	},
+}
+
+func heapCtor(cap int) apps.FilterHeap {
+	ctor, ok := heapCtors[*HEAP]
+	if !ok {
+		panic(fmt.Sprintf("unknown -heap %q", *HEAP))
 	}
+	return ctor(cap)
 }
 
-func show() {
-	if *HEAP == 0 {
-		fmt.Println("RW lock")
-	} else {
-		fmt.Println("Subtree lock")
+// app config whose heap is the -heap under test
+func filterConfig(cap int) apps.Config {
+	if *HEAP == "flatcombining" {
+		return apps.Config{FilterType: "coarseRW", FlatCombining: true, Capacity: cap}
 	}
+	return apps.Config{FilterType: *HEAP, Capacity: cap}
+}
+
+// sharded app config whose shards are the -heap under test
+func shardedConfig(shards int, cap int) apps.Config {
+	cfg := filterConfig(cap)
+	cfg.FilterType, cfg.ShardType, cfg.Shards = "sharded", cfg.FilterType, shards
+	return cfg
+}
+
+func show() {
+	fmt.Println("heap:", *HEAP)
 }
 
 func workerInsert(id int, jobs <-chan int, results chan<- int,
-	heap apps.MaxMinHeap) {
+	heap apps.FilterHeap) {
 	for range jobs {
 		heap.Insert(&filter.FilterItem{Score: rand.Float32(), Data: []byte{}})
 		results <- 0
@@ -41,15 +67,31 @@ func workerInsert(id int, jobs <-chan int, results chan<- int,
 }
 
 func workerRemoveMax(id int, jobs <-chan int, results chan<- int,
-	heap apps.MaxMinHeap) {
+	heap apps.FilterHeap) {
 	for range jobs {
 		heap.RemoveMax()
 		results <- 0
 	}
 }
 
+// inserts half of the time, removes from either end otherwise
+func workerMixed(id int, jobs <-chan int, results chan<- int,
+	heap apps.FilterHeap) {
+	for j := range jobs {
+		switch j % 4 {
+		case 0:
+			heap.RemoveMax()
+		case 1:
+			heap.RemoveMin()
+		default:
+			heap.Insert(&filter.FilterItem{Score: rand.Float32(), Data: []byte{}})
+		}
+		results <- 0
+	}
+}
+
 func workerInsertTimed(id int, jobs <-chan int, results chan<- int64,
-	heap apps.MaxMinHeap) {
+	heap apps.FilterHeap) {
 	for range jobs {
 		start := time.Now().UnixNano()
 		heap.Insert(&filter.FilterItem{Score: rand.Float32(), Data: []byte{}})
@@ -59,7 +101,7 @@ func workerInsertTimed(id int, jobs <-chan int, results chan<- int64,
 }
 
 func workerRemoveMaxTimed(id int, jobs <-chan int, results chan<- int64,
-	heap apps.MaxMinHeap) {
+	heap apps.FilterHeap) {
 	runtime.LockOSThread()
 	for range jobs {
 		start := time.Now().UnixNano()
@@ -70,3 +112,37 @@ func workerRemoveMaxTimed(id int, jobs <-chan int, results chan<- int64,
 	}
 	runtime.UnlockOSThread()
 }
+
+// serve app on a free localhost port, stopped when the test ends
+func startFilter(t *testing.T, app apps.ConcurrentDataStreamFilter) filter.FilterServiceClient {
+	client, _ := serveFilter(t, services.NewFilter("test", 0, app))
+	return client
+}
+
+// serve s on a free localhost port, returns a client and the address
+func serveFilter(t *testing.T, s *services.Filter) (filter.FilterServiceClient, string) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	filter.RegisterFilterServiceServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return filter.NewFilterServiceClient(conn), lis.Addr().String()
+}
+
+// insert item into app, failing the test on an error
+func insertItem(t *testing.T, app apps.ConcurrentDataStreamFilter, item *filter.FilterItem) apps.FilterInsertResult {
+	res, err := app.Insert(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}